@@ -49,8 +49,13 @@ func (b *Bytecode) FormatConstants() (output []string) {
 	for cidx, cn := range b.Constants {
 		switch cn := cn.(type) {
 		case *CompiledFunction:
-			output = append(output, fmt.Sprintf(
-				"[% 3d] (Compiled Function|%p)", cidx, &cn))
+			if cn.Name != "" {
+				output = append(output, fmt.Sprintf(
+					"[% 3d] %s (Compiled Function|%p)", cidx, cn.Name, &cn))
+			} else {
+				output = append(output, fmt.Sprintf(
+					"[% 3d] (Compiled Function|%p)", cidx, &cn))
+			}
 			for _, l := range FormatInstructions(cn.Instructions, 0) {
 				output = append(output, fmt.Sprintf("     %s", l))
 			}
@@ -221,13 +226,21 @@ func fixDecodedObject(
 		}
 	case *ImmutableMap:
 		modName := inferModuleName(o)
-		if mod := modules.GetBuiltinModule(modName); mod != nil {
+		switch mod := modules.Get(modName).(type) {
+		case *BuiltinModule:
 			return mod.AsImmutableMap(modName), nil
+		case *LazyModule:
+			v, err := mod.Import(modName)
+			if err != nil {
+				return nil, err
+			}
+			return v.(*ImmutableMap), nil
 		}
 
 		for k, v := range o.Value {
 			// encoding of user function not supported
-			if _, isUserFunction := v.(*UserFunction); isUserFunction {
+			switch v.(type) {
+			case *UserFunction, *VMFunction:
 				return nil, fmt.Errorf("user function not decodable")
 			}
 
@@ -249,7 +262,7 @@ func updateConstIndexes(insts []byte, indexMap map[int]int) {
 		_, read := parser.ReadOperands(numOperands, insts[i+1:])
 
 		switch op {
-		case parser.OpConstant:
+		case parser.OpConstant, parser.OpImport:
 			curIdx := int(insts[i+2]) | int(insts[i+1])<<8
 			newIdx, ok := indexMap[curIdx]
 			if !ok {
@@ -295,4 +308,5 @@ func init() {
 	gob.Register(&Time{})
 	gob.Register(&Undefined{})
 	gob.Register(&UserFunction{})
+	gob.Register(&VMFunction{})
 }
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/diiyw/z"
+)
+
+// bundleMagic ends the executables built by z build -exe: a copy of a z
+// executable, the runner, followed by the encoded bytecode of the script,
+// its size as a big-endian uint64 and bundleMagic.
+const bundleMagic = "\x00zbundle"
+
+// bundleTrailerLen is the length of the size and the magic ending a bundle.
+const bundleTrailerLen = 8 + len(bundleMagic)
+
+// Build compiles the script given in args into a bytecode file, or with -exe
+// into an executable running it, and writes the name of the output file to
+// out.
+func Build(modules *z.ModuleMap, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	exe := flags.Bool("exe", false, "Build a standalone executable")
+	output := flags.String("o", "", "Output file")
+	runner := flags.String("runner", "",
+		"z executable to bundle the script with, the running one by default")
+	flags.BoolVar(&resolvePath, "resolve", resolvePath,
+		"Resolve relative import paths")
+	_ = flags.Parse(args)
+
+	inputFile := flags.Arg(0)
+	if inputFile == "" {
+		return errors.New("missing script file")
+	}
+	src, err := os.ReadFile(inputFile)
+	if err != nil {
+		return err
+	}
+	if len(src) > 1 && string(src[:2]) == "#!" {
+		copy(src, "//")
+	}
+	inputFile, err = filepath.Abs(inputFile)
+	if err != nil {
+		return err
+	}
+	bytecode, err := compileSrc(modules, src, inputFile)
+	if err != nil {
+		return err
+	}
+	var bundle bytes.Buffer
+	if err := bytecode.Encode(&bundle); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		*output = basename(inputFile)
+		if !*exe {
+			*output += ".out"
+		}
+	}
+	if *exe {
+		if *runner == "" {
+			if *runner, err = os.Executable(); err != nil {
+				return err
+			}
+		}
+		err = writeExecutable(*output, *runner, bundle.Bytes())
+	} else {
+		err = os.WriteFile(*output, bundle.Bytes(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, *output)
+	return err
+}
+
+// writeExecutable writes to output a copy of the runner, without the bundle
+// it may already have, followed by bundle.
+func writeExecutable(output, runner string, bundle []byte) (err error) {
+	exe, err := os.ReadFile(runner)
+	if err != nil {
+		return err
+	}
+	if _, n, ok := splitBundle(exe); ok {
+		exe = exe[:n]
+	}
+
+	f, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	trailer := binary.BigEndian.AppendUint64(nil, uint64(len(bundle)))
+	trailer = append(trailer, bundleMagic...)
+	for _, b := range [][]byte{exe, bundle, trailer} {
+		if _, err := f.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitBundle returns the bundle ending data and its offset, or false if data
+// has no bundle.
+func splitBundle(data []byte) ([]byte, int, bool) {
+	if len(data) < bundleTrailerLen ||
+		string(data[len(data)-len(bundleMagic):]) != bundleMagic {
+		return nil, 0, false
+	}
+	trailer := len(data) - bundleTrailerLen
+	size := binary.BigEndian.Uint64(data[trailer:])
+	if size > uint64(trailer) {
+		return nil, 0, false
+	}
+	return data[trailer-int(size) : trailer], trailer - int(size), true
+}
+
+// readBundle returns the bundle of the executable file, or nil if it has
+// none, reading only the end of the file.
+func readBundle(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < int64(bundleTrailerLen) {
+		return nil, nil
+	}
+	trailer := make([]byte, bundleTrailerLen)
+	if _, err := f.ReadAt(trailer,
+		info.Size()-int64(bundleTrailerLen)); err != nil {
+		return nil, err
+	}
+	if string(trailer[8:]) != bundleMagic {
+		return nil, nil
+	}
+	size := binary.BigEndian.Uint64(trailer)
+	if size > uint64(info.Size())-uint64(bundleTrailerLen) {
+		return nil, errors.New("corrupted bundle")
+	}
+	bundle := make([]byte, size)
+	_, err = f.ReadAt(bundle, info.Size()-int64(bundleTrailerLen)-int64(size))
+	if err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// runBundle runs the script bundled in the running executable, if any,
+// passing it all the arguments, and reports whether there was one.
+func runBundle() bool {
+	exe, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	bundle, err := readBundle(exe)
+	if err != nil {
+		exit(err)
+	} else if bundle == nil {
+		return false
+	}
+	modules := scriptModules(os.Args)
+	if err := RunCompiled(modules, bundle); err != nil {
+		exit(err)
+	}
+	return true
+}
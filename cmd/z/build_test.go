@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+)
+
+func TestBuildExecutable(t *testing.T) {
+	dir := t.TempDir()
+	runner := filepath.Join(dir, "runner")
+	require.NoError(t, os.WriteFile(runner, []byte("runner"), 0755))
+	script := filepath.Join(dir, "app.z")
+	require.NoError(t, os.WriteFile(script,
+		[]byte("#!/usr/local/bin/z\nout := 1 + 2"), 0644))
+
+	bundle, err := readBundle(runner)
+	require.NoError(t, err)
+	require.Nil(t, bundle)
+
+	modules := stdlib.GetModuleMap(stdlib.AllModuleNames()...)
+	app := filepath.Join(dir, "app")
+	var out bytes.Buffer
+	err = Build(modules, []string{"-exe", "-runner", runner, "-o", app,
+		script}, &out)
+	require.NoError(t, err)
+	require.Equal(t, app+"\n", out.String())
+	info, err := os.Stat(app)
+	require.NoError(t, err)
+	require.True(t, info.Mode()&0100 != 0)
+
+	data, err := os.ReadFile(app)
+	require.NoError(t, err)
+	require.Equal(t, "runner", string(data[:6]))
+	bundle, err = readBundle(app)
+	require.NoError(t, err)
+	require.NoError(t, RunCompiled(modules, bundle))
+
+	// the bundle of the runner is replaced
+	app2 := filepath.Join(dir, "app2")
+	require.NoError(t, writeExecutable(app2, app, []byte("bundle")))
+	data, err = os.ReadFile(app2)
+	require.NoError(t, err)
+	require.Equal(t, 6+len("bundle")+bundleTrailerLen, len(data))
+	bundle, err = readBundle(app2)
+	require.NoError(t, err)
+	require.Equal(t, "bundle", string(bundle))
+
+	// without -exe the bytecode is written
+	out.Reset()
+	err = Build(modules, []string{"-o", filepath.Join(dir, "app.out"),
+		script}, &out)
+	require.NoError(t, err)
+	data, err = os.ReadFile(filepath.Join(dir, "app.out"))
+	require.NoError(t, err)
+	require.NoError(t, RunCompiled(modules, data))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/diiyw/z"
+	"golang.org/x/term"
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorDim   = "\x1b[2m"
+	colorRed   = "\x1b[31m"
+)
+
+// useColor reports whether the errors written to f are colorized: f must be
+// a terminal and NO_COLOR must not be set.
+func useColor(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(f.Fd()))
+}
+
+// printError writes err to w with the source snippet of the runtime, compile
+// and parse errors, and the function names of the runtime errors.
+func printError(w io.Writer, err error, color bool) {
+	paint := func(s, c string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+
+	var rte *z.RuntimeError
+	if errors.As(err, &rte) && rte == err {
+		_, _ = fmt.Fprintf(w, "%s %s\n", paint("Runtime Error:",
+			colorBold+colorRed), rte.Err)
+		printSnippet(w, rte.Snippet(), paint)
+		for _, f := range rte.Frames {
+			_, _ = fmt.Fprintf(w, "\t%s %s %s\n", paint("at", colorDim),
+				f.Func, paint("("+f.Pos.String()+")", colorDim))
+		}
+		return
+	}
+
+	msg := err.Error()
+	header, rest, _ := strings.Cut(msg, "\n")
+	if prefix, desc, ok := strings.Cut(header, ": "); ok &&
+		strings.HasSuffix(prefix, " Error") {
+		header = paint(prefix+":", colorBold+colorRed) + " " + desc
+	}
+	_, _ = fmt.Fprintln(w, header)
+	if s, ok := err.(interface{ Snippet() string }); ok {
+		printSnippet(w, s.Snippet(), paint)
+	}
+	if rest != "" {
+		_, _ = fmt.Fprintln(w, paint(rest, colorDim))
+	}
+}
+
+// printSnippet writes a snippet rendered by parser.Snippet with the caret
+// in red.
+func printSnippet(
+	w io.Writer,
+	snippet string,
+	paint func(s, c string) string,
+) {
+	if snippet == "" {
+		return
+	}
+	source, caret, _ := strings.Cut(strings.TrimSuffix(snippet, "\n"), "\n")
+	gutter, caret, _ := strings.Cut(caret, "| ")
+	_, _ = fmt.Fprintln(w, source)
+	_, _ = fmt.Fprintln(w, gutter+"| "+paint(caret, colorBold+colorRed))
+}
@@ -1,11 +1,10 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,11 +16,11 @@ import (
 
 const (
 	sourceFileExt = ".z"
-	replPrompt    = ">> "
 )
 
 var (
 	compileOutput string
+	cpuProfile    string
 	showHelp      bool
 	showVersion   bool
 	resolvePath   bool // TODO Remove this flag at version 3
@@ -31,13 +30,19 @@ var (
 func init() {
 	flag.BoolVar(&showHelp, "help", false, "Show help")
 	flag.StringVar(&compileOutput, "o", "", "Compile output file")
+	flag.StringVar(&cpuProfile, "cpuprofile", "",
+		"Write a pprof profile of the script to a file")
 	flag.BoolVar(&showVersion, "version", false, "Show version")
 	flag.BoolVar(&resolvePath, "resolve", false,
 		"Resolve relative import paths")
-	flag.Parse()
 }
 
 func main() {
+	// the executables built with z build -exe only run their script
+	if runBundle() {
+		return
+	}
+	flag.Parse()
 	if showHelp {
 		doHelp()
 		os.Exit(2)
@@ -46,13 +51,53 @@ func main() {
 		return
 	}
 
-	modules := stdlib.GetModuleMap(stdlib.AllModuleNames()...)
-	inputFile := flag.Arg(0)
-	if inputFile == "" {
+	modules := scriptModules(nil)
+	if flag.Arg(0) == "serve" {
+		if err := Serve(modules, flag.Args()[1:]); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "build" {
+		if err := Build(modules, flag.Args()[1:], os.Stdout); err != nil {
+			exit(err)
+		}
+		return
+	}
+	if flag.Arg(0) == "mod" {
+		if err := Mod(flag.Args()[1:], os.Stdout); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "test" {
+		err := Test(modules, flag.Args()[1:], os.Stdout)
+		if err != nil && err != errTestsFailed {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		}
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// z run script.z -- args, or z script.z args for the shebang lines
+	if flag.Arg(0) == "run" {
+		_ = flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	scriptArgs := flag.Args()
+	if len(scriptArgs) > 1 && scriptArgs[1] == "--" {
+		scriptArgs = append(scriptArgs[:1:1], scriptArgs[2:]...)
+	}
+	if len(scriptArgs) == 0 {
 		// REPL
 		RunREPL(modules, os.Stdin, os.Stdout)
 		return
 	}
+	inputFile := scriptArgs[0]
+	modules = scriptModules(scriptArgs)
 
 	inputData, err := os.ReadFile(inputFile)
 	if err != nil {
@@ -72,26 +117,38 @@ func main() {
 	}
 
 	if compileOutput != "" {
-		err := CompileOnly(modules, inputData, inputFile,
-			compileOutput)
-		if err != nil {
-			_, _ = fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(1)
-		}
+		err = CompileOnly(modules, inputData, inputFile, compileOutput)
 	} else if filepath.Ext(inputFile) == sourceFileExt {
-		err := CompileAndRun(modules, inputData, inputFile)
-		if err != nil {
-			_, _ = fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(1)
-		}
+		err = CompileAndRun(modules, inputData, inputFile)
 	} else {
-		if err := RunCompiled(modules, inputData); err != nil {
-			_, _ = fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(1)
-		}
+		err = RunCompiled(modules, inputData)
+	}
+	if err != nil {
+		exit(err)
 	}
 }
 
+// scriptModules returns all the modules for the scripts run with the program
+// arguments args, os.Args if nil. The scripts can send HTTP requests to all
+// hosts.
+func scriptModules(args []string) *z.ModuleMap {
+	return stdlib.GetModuleMapWithOptions(stdlib.Options{
+		Args:      args,
+		HTTPHosts: []string{"*"},
+	}, stdlib.AllModuleNames()...)
+}
+
+// exit exits with the code given to os.exit by the script, or with 1 after
+// printing any other error.
+func exit(err error) {
+	var exitErr *stdlib.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+	printError(os.Stderr, err, useColor(os.Stderr))
+	os.Exit(1)
+}
+
 // CompileOnly compiles the source code and writes the compiled binary into
 // outputFile.
 func CompileOnly(
@@ -139,8 +196,7 @@ func CompileAndRun(
 		return
 	}
 
-	machine := z.NewVM(bytecode, nil, -1)
-	err = machine.Run()
+	err = runBytecode(bytecode)
 	return
 }
 
@@ -152,73 +208,26 @@ func RunCompiled(modules *z.ModuleMap, data []byte) (err error) {
 		return
 	}
 
-	machine := z.NewVM(bytecode, nil, -1)
-	err = machine.Run()
+	err = runBytecode(bytecode)
 	return
 }
 
-// RunREPL starts REPL.
-func RunREPL(modules *z.ModuleMap, in io.Reader, out io.Writer) {
-	stdin := bufio.NewScanner(in)
-	fileSet := parser.NewFileSet()
-	globals := make([]z.Object, z.GlobalsSize)
-	symbolTable := z.NewSymbolTable()
-	for idx, fn := range z.GetAllBuiltinFunctions() {
-		symbolTable.DefineBuiltin(idx, fn.Name)
-	}
-
-	// embed println function
-	symbol := symbolTable.Define("__repl_println__")
-	globals[symbol.Index] = &z.UserFunction{
-		Name: "println",
-		Value: func(args ...z.Object) (ret z.Object, err error) {
-			var printArgs []any
-			for _, arg := range args {
-				if _, isUndefined := arg.(*z.Undefined); isUndefined {
-					printArgs = append(printArgs, "<undefined>")
-				} else {
-					s, _ := z.ToString(arg)
-					printArgs = append(printArgs, s)
-				}
-			}
-			printArgs = append(printArgs, "\n")
-			_, _ = fmt.Print(printArgs...)
-			return
-		},
+// runBytecode runs the bytecode, profiling it if -cpuprofile is set.
+func runBytecode(bytecode *z.Bytecode) error {
+	machine := z.NewVM(bytecode, nil, -1)
+	if cpuProfile == "" {
+		return machine.Run()
 	}
 
-	var constants []z.Object
-	for {
-		_, _ = fmt.Fprint(out, replPrompt)
-		scanned := stdin.Scan()
-		if !scanned {
-			return
-		}
-
-		line := stdin.Text()
-		srcFile := fileSet.AddFile("repl", -1, len(line))
-		p := parser.NewParser(srcFile, []byte(line), nil)
-		file, err := p.ParseFile()
-		if err != nil {
-			_, _ = fmt.Fprintln(out, err.Error())
-			continue
-		}
-
-		file = addPrints(file)
-		c := z.NewCompiler(srcFile, symbolTable, constants, modules, nil)
-		if err := c.Compile(file); err != nil {
-			_, _ = fmt.Fprintln(out, err.Error())
-			continue
-		}
-
-		bytecode := c.Bytecode()
-		machine := z.NewVM(bytecode, globals, -1)
-		if err := machine.Run(); err != nil {
-			_, _ = fmt.Fprintln(out, err.Error())
-			continue
-		}
-		constants = bytecode.Constants
+	profiler := z.NewProfiler(0)
+	machine.SetProfiler(profiler)
+	profiler.Start()
+	err := machine.Run()
+	profiler.Stop()
+	if werr := writeFile(cpuProfile, profiler.WriteProfile); err == nil {
+		err = werr
 	}
+	return err
 }
 
 func compileSrc(
@@ -237,8 +246,20 @@ func compileSrc(
 
 	c := z.NewCompiler(srcFile, nil, nil, modules, nil)
 	c.EnableFileImport(true)
+	importDir := ""
 	if resolvePath {
-		c.SetImportDir(filepath.Dir(inputFile))
+		importDir = filepath.Dir(inputFile)
+		c.SetImportDir(importDir)
+	}
+	// in a module, the vendored dependencies are imported by name and the
+	// files relative to the importing file
+	loader, err := moduleLoader(filepath.Dir(inputFile))
+	if err != nil {
+		return nil, err
+	}
+	if loader != nil {
+		c.SetModuleLoader(z.ChainLoader{loader,
+			&z.FileLoader{Dir: importDir}})
 	}
 
 	if err := c.Compile(file); err != nil {
@@ -253,12 +274,18 @@ func compileSrc(
 func doHelp() {
 	fmt.Println("Usage:")
 	fmt.Println()
-	fmt.Println("	z [flags] {input-file}")
+	fmt.Println("	z [flags] {input-file} [args...]")
+	fmt.Println("	z run [flags] {input-file} [--] [args...]")
+	fmt.Println("	z build [-exe] [-o file] [-runner z-executable] {input-file}")
+	fmt.Println("	z serve [-addr address] {input-file}")
+	fmt.Println("	z test [-run regexp] [-format text|tap|junit] [-o file] [-v] {path...}")
+	fmt.Println("	z mod init|tidy|vendor|verify")
 	fmt.Println()
 	fmt.Println("Flags:")
 	fmt.Println()
-	fmt.Println("	-o        compile output file")
-	fmt.Println("	-version  show version")
+	fmt.Println("	-o           compile output file")
+	fmt.Println("	-cpuprofile  write a pprof profile of the script to file")
+	fmt.Println("	-version     show version")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println()
@@ -279,6 +306,29 @@ func doHelp() {
 	fmt.Println()
 	fmt.Println("	          Run bytecode file (myapp)")
 	fmt.Println()
+	fmt.Println("	z build -exe -o myapp myapp.z")
+	fmt.Println()
+	fmt.Println("	          Build an executable (myapp) running source file (myapp.z),")
+	fmt.Println("	          not requiring z to be installed")
+	fmt.Println()
+	fmt.Println("	z -cpuprofile cpu.pprof myapp.z")
+	fmt.Println()
+	fmt.Println("	          Run source file (myapp.z) and profile it,")
+	fmt.Println("	          see go tool pprof -http :8000 cpu.pprof")
+	fmt.Println()
+	fmt.Println("	z run greet.z -- --name world")
+	fmt.Println()
+	fmt.Println("	          Run source file (greet.z) with arguments,")
+	fmt.Println("	          see os.args and the args module")
+	fmt.Println()
+	fmt.Println("	z serve -addr :8080 hook.z")
+	fmt.Println()
+	fmt.Println("	          Serve HTTP requests with source file (hook.z)")
+	fmt.Println()
+	fmt.Println("	z test -format junit -o report.xml ./tests")
+	fmt.Println()
+	fmt.Println("	          Run the test_* functions of the *_test.z files in ./tests")
+	fmt.Println()
 	fmt.Println()
 }
 
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/diiyw/z/zmod"
+)
+
+// Mod runs the z mod command given in args on the module of the current
+// directory.
+func Mod(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: z mod init|tidy|vendor|verify")
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if args[0] == "init" {
+		name := filepath.Base(cwd)
+		if len(args) > 1 {
+			name = args[1]
+		}
+		_, err := zmod.Init(cwd, name)
+		return err
+	}
+
+	root, err := zmod.FindRoot(cwd)
+	if err != nil {
+		return err
+	}
+	p, err := zmod.Load(root)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "tidy":
+		return p.Tidy()
+	case "vendor":
+		return p.Vendor()
+	case "verify":
+		if err := p.Verify(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(out, "all modules verified")
+		return err
+	default:
+		return fmt.Errorf("unknown z mod command: %s", args[0])
+	}
+}
+
+// moduleLoader returns the loader of the vendored dependencies of the module
+// containing dir, or nil if dir is not in a module.
+func moduleLoader(dir string) (*zmod.Loader, error) {
+	root, err := zmod.FindRoot(dir)
+	if errors.Is(err, zmod.ErrNoManifest) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	p, err := zmod.Load(root)
+	if err != nil {
+		return nil, err
+	}
+	return p.Loader(), nil
+}
@@ -0,0 +1,603 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/parser"
+	"github.com/diiyw/z/stdlib"
+	"github.com/diiyw/z/token"
+)
+
+const (
+	replPrompt         = ">> "
+	replContinuePrompt = ".. "
+	replHistoryFile    = ".z_history"
+	replHistorySize    = 1000
+)
+
+const replHelp = `Commands:
+  :help         show this help
+  :load <file>  run a source file in the current session
+  :type <expr>  show the type of an expression
+  :dis <code>   show the bytecode of code without running it
+  :reset        clear all variables
+
+Statements spanning several lines continue with a ".. " prompt until
+they are complete. Press Tab to complete names and Ctrl-D to exit.
+`
+
+// RunREPL starts REPL. If in is a terminal, lines can be edited, history is
+// kept in ~/.z_history and names are completed with Tab.
+func RunREPL(modules *z.ModuleMap, in io.Reader, out io.Writer) {
+	r := newREPL(modules, out)
+	var lines lineReader
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		lines = newTermReader(f, out, r.completions)
+	} else {
+		lines = &scanReader{s: bufio.NewScanner(in), out: out}
+	}
+	r.run(lines)
+}
+
+type repl struct {
+	modules     *z.ModuleMap
+	out         io.Writer
+	fileSet     *parser.SourceFileSet
+	globals     []z.Object
+	symbolTable *z.SymbolTable
+	constants   []z.Object
+}
+
+func newREPL(modules *z.ModuleMap, out io.Writer) *repl {
+	r := &repl{modules: modules, out: out}
+	r.reset()
+	return r
+}
+
+// reset discards all globals and definitions of the session.
+func (r *repl) reset() {
+	r.fileSet = parser.NewFileSet()
+	r.globals = make([]z.Object, z.GlobalsSize)
+	r.symbolTable = z.NewSymbolTable()
+	r.constants = nil
+	for idx, fn := range z.GetAllBuiltinFunctions() {
+		r.symbolTable.DefineBuiltin(idx, fn.Name)
+	}
+
+	// embed println function
+	symbol := r.symbolTable.Define("__repl_println__")
+	r.globals[symbol.Index] = &z.UserFunction{
+		Name: "println",
+		Value: func(args ...z.Object) (ret z.Object, err error) {
+			var printArgs []any
+			for _, arg := range args {
+				if _, isUndefined := arg.(*z.Undefined); isUndefined {
+					printArgs = append(printArgs, "<undefined>")
+				} else {
+					s, _ := z.ToString(arg)
+					printArgs = append(printArgs, s)
+				}
+			}
+			printArgs = append(printArgs, "\n")
+			_, _ = fmt.Fprint(r.out, printArgs...)
+			return
+		},
+	}
+
+	// embed type function used by :type
+	symbol = r.symbolTable.Define("__repl_type__")
+	r.globals[symbol.Index] = &z.UserFunction{
+		Name: "type",
+		Value: func(args ...z.Object) (ret z.Object, err error) {
+			if len(args) != 1 {
+				return nil, z.ErrWrongNumArguments
+			}
+			_, _ = fmt.Fprintln(r.out, args[0].TypeName())
+			return
+		},
+	}
+}
+
+func (r *repl) run(lines lineReader) {
+	var pending string
+	for {
+		prompt := replPrompt
+		if pending != "" {
+			prompt = replContinuePrompt
+		}
+		line, err := lines.ReadLine(prompt)
+		if errors.Is(err, errInterrupted) {
+			pending = ""
+			continue
+		} else if err != nil {
+			if pending != "" {
+				r.eval(pending, false)
+			}
+			return
+		}
+
+		if pending == "" {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" {
+				continue
+			}
+			if strings.HasPrefix(trimmed, ":") {
+				r.command(trimmed)
+				continue
+			}
+			pending = line
+		} else {
+			pending += "\n" + line
+		}
+		if !r.eval(pending, true) {
+			pending = ""
+		}
+	}
+}
+
+// eval runs src in the session and prints the values of expressions and
+// assignments. It reports whether src is incomplete and more input is
+// needed, which is only possible if partial is set.
+func (r *repl) eval(src string, partial bool) (incomplete bool) {
+	file, srcFile, err := r.parse("repl", src)
+	if err != nil {
+		if partial && isIncomplete(err) {
+			return true
+		}
+		_, _ = fmt.Fprintln(r.out, err.Error())
+		return false
+	}
+	r.exec(addPrints(file), srcFile, "")
+	return false
+}
+
+func (r *repl) parse(
+	name, src string,
+) (*parser.File, *parser.SourceFile, error) {
+	srcFile := r.fileSet.AddFile(name, -1, len(src))
+	p := parser.NewParser(srcFile, []byte(src), nil)
+	file, err := p.ParseFile()
+	return file, srcFile, err
+}
+
+// exec compiles and runs file. If importDir is not empty, file imports are
+// resolved relative to it.
+func (r *repl) exec(
+	file *parser.File,
+	srcFile *parser.SourceFile,
+	importDir string,
+) {
+	c := z.NewCompiler(srcFile, r.symbolTable, r.constants, r.modules, nil)
+	if importDir != "" {
+		c.EnableFileImport(true)
+		c.SetImportDir(importDir)
+	}
+	if err := c.Compile(file); err != nil {
+		_, _ = fmt.Fprintln(r.out, err.Error())
+		return
+	}
+
+	bytecode := c.Bytecode()
+	machine := z.NewVM(bytecode, r.globals, -1)
+	if err := machine.Run(); err != nil {
+		var exitErr *stdlib.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+		_, _ = fmt.Fprintln(r.out, err.Error())
+		return
+	}
+	r.constants = bytecode.Constants
+}
+
+func (r *repl) command(line string) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case ":help":
+		_, _ = fmt.Fprint(r.out, replHelp)
+	case ":reset":
+		r.reset()
+	case ":load":
+		r.load(arg)
+	case ":type":
+		r.typeOf(arg)
+	case ":dis":
+		r.disassemble(arg)
+	default:
+		_, _ = fmt.Fprintf(r.out,
+			"unknown command: %s (type :help for a list)\n", name)
+	}
+}
+
+func (r *repl) load(path string) {
+	if path == "" {
+		_, _ = fmt.Fprintln(r.out, "usage: :load <file>")
+		return
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		_, _ = fmt.Fprintln(r.out, err.Error())
+		return
+	}
+	if len(src) > 1 && string(src[:2]) == "#!" {
+		copy(src, "//")
+	}
+	file, srcFile, err := r.parse(filepath.Base(path), string(src))
+	if err != nil {
+		_, _ = fmt.Fprintln(r.out, err.Error())
+		return
+	}
+	importDir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		_, _ = fmt.Fprintln(r.out, err.Error())
+		return
+	}
+	r.exec(file, srcFile, importDir)
+}
+
+func (r *repl) typeOf(expr string) {
+	if expr == "" {
+		_, _ = fmt.Fprintln(r.out, "usage: :type <expr>")
+		return
+	}
+	file, srcFile, err := r.parse("repl", expr)
+	if err != nil {
+		_, _ = fmt.Fprintln(r.out, err.Error())
+		return
+	}
+	var stmt *parser.ExprStmt
+	if len(file.Stmts) == 1 {
+		stmt, _ = file.Stmts[0].(*parser.ExprStmt)
+	}
+	if stmt == nil {
+		_, _ = fmt.Fprintln(r.out, "not an expression: "+expr)
+		return
+	}
+	file.Stmts[0] = &parser.ExprStmt{
+		Expr: &parser.CallExpr{
+			Func: &parser.Ident{Name: "__repl_type__"},
+			Args: []parser.Expr{stmt.Expr},
+		},
+	}
+	r.exec(file, srcFile, "")
+}
+
+// disassemble prints the instructions code compiles to and the constants it
+// adds. The code is compiled in a block so that it defines nothing in the
+// session.
+func (r *repl) disassemble(code string) {
+	if code == "" {
+		_, _ = fmt.Fprintln(r.out, "usage: :dis <code>")
+		return
+	}
+	file, srcFile, err := r.parse("repl", code)
+	if err != nil {
+		_, _ = fmt.Fprintln(r.out, err.Error())
+		return
+	}
+	constants := r.constants[:len(r.constants):len(r.constants)]
+	c := z.NewCompiler(srcFile, r.symbolTable.Fork(true), constants,
+		r.modules, nil)
+	if err := c.Compile(file); err != nil {
+		_, _ = fmt.Fprintln(r.out, err.Error())
+		return
+	}
+
+	bytecode := c.Bytecode()
+	for _, l := range z.FormatInstructions(
+		bytecode.MainFunction.Instructions, 0) {
+		_, _ = fmt.Fprintln(r.out, l)
+	}
+	for cidx := len(r.constants); cidx < len(bytecode.Constants); cidx++ {
+		switch cn := bytecode.Constants[cidx].(type) {
+		case *z.CompiledFunction:
+			_, _ = fmt.Fprintf(r.out, "[% 3d] %s\n", cidx, cn)
+			for _, l := range z.FormatInstructions(cn.Instructions, 0) {
+				_, _ = fmt.Fprintf(r.out, "      %s\n", l)
+			}
+		default:
+			_, _ = fmt.Fprintf(r.out, "[% 3d] %s (%s)\n",
+				cidx, cn, cn.TypeName())
+		}
+	}
+}
+
+// completions returns the candidates for the word ending at pos in line and
+// the offset where that word starts.
+func (r *repl) completions(line string, pos int) (int, []string) {
+	start := pos
+	for start > 0 && isWordChar(line[start-1]) {
+		start--
+	}
+	word := line[start:pos]
+
+	var names []string
+	if strings.HasSuffix(line[:start], `import("`) {
+		names = r.modules.Names()
+	} else if dot := strings.LastIndexByte(word, '.'); dot >= 0 {
+		names = r.members(word[:dot])
+		start += dot + 1
+		word = word[dot+1:]
+	} else {
+		for _, name := range r.symbolTable.Names() {
+			if !strings.HasPrefix(name, "__") {
+				names = append(names, name)
+			}
+		}
+		for tok := token.Break; tok.IsKeyword(); tok++ {
+			names = append(names, tok.String())
+		}
+	}
+
+	var candidates []string
+	for _, name := range names {
+		if strings.HasPrefix(name, word) {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
+
+// members returns the keys of the map stored in the global variable name or,
+// if there's no such variable, the attributes of the builtin module name.
+func (r *repl) members(name string) []string {
+	var m map[string]z.Object
+	if symbol, _, ok := r.symbolTable.Resolve(name, false); ok {
+		if symbol.Scope != z.ScopeGlobal {
+			return nil
+		}
+		switch v := r.globals[symbol.Index].(type) {
+		case *z.Map:
+			m = v.Value
+		case *z.ImmutableMap:
+			m = v.Value
+		}
+	} else if mod := r.modules.GetBuiltinModule(name); mod != nil {
+		m = mod.Attrs
+	}
+	names := make([]string, 0, len(m))
+	for key := range m {
+		names = append(names, key)
+	}
+	return names
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '.' ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// isIncomplete reports whether err is caused by input that ends too early,
+// for example in the middle of a block or a raw string.
+func isIncomplete(err error) bool {
+	var list parser.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		return false
+	}
+	for _, e := range list {
+		switch {
+		case strings.HasSuffix(e.Msg, "found 'EOF'"),
+			e.Msg == "raw string literal not terminated",
+			e.Msg == "comment not terminated":
+			return true
+		}
+	}
+	return false
+}
+
+// errInterrupted is returned by a lineReader if the input is canceled with
+// Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// scanReader reads lines from a non-terminal input such as a pipe.
+type scanReader struct {
+	s   *bufio.Scanner
+	out io.Writer
+}
+
+func (r *scanReader) ReadLine(prompt string) (string, error) {
+	_, _ = fmt.Fprint(r.out, prompt)
+	if !r.s.Scan() {
+		if err := r.s.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.s.Text(), nil
+}
+
+// termReader reads lines from a terminal with line editing, history and
+// completion. The terminal is in raw mode only while a line is read, so
+// scripts print as usual.
+type termReader struct {
+	fd       int
+	in       *keyReader
+	out      io.Writer
+	history  term.History
+	complete func(line string, pos int) (int, []string)
+	t        *term.Terminal
+}
+
+func newTermReader(
+	f *os.File,
+	out io.Writer,
+	complete func(line string, pos int) (int, []string),
+) *termReader {
+	r := &termReader{
+		fd:       int(f.Fd()),
+		in:       &keyReader{r: f},
+		out:      out,
+		complete: complete,
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		r.history = loadHistory(filepath.Join(home, replHistoryFile),
+			replHistorySize)
+	}
+	r.init()
+	return r
+}
+
+// init creates the terminal. It's also used to discard the terminal's state
+// after Ctrl-C.
+func (r *termReader) init() {
+	r.t = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{r.in, r.out}, replPrompt)
+	if r.history != nil {
+		r.t.History = r.history
+	}
+	r.t.AutoCompleteCallback = r.autoComplete
+}
+
+func (r *termReader) autoComplete(
+	line string,
+	pos int,
+	key rune,
+) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	start, candidates := r.complete(line, pos)
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+	word := line[start:pos]
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(word) {
+		return line[:start] + prefix + line[pos:], start + len(prefix), true
+	}
+	if len(candidates) > 1 {
+		_, _ = r.t.Write([]byte(strings.Join(candidates, "  ") + "\n"))
+	}
+	return line, pos, true
+}
+
+func (r *termReader) ReadLine(prompt string) (string, error) {
+	if w, h, err := term.GetSize(r.fd); err == nil && w > 0 {
+		_ = r.t.SetSize(w, h)
+	}
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = term.Restore(r.fd, state) }()
+
+	r.t.SetPrompt(prompt)
+	line, err := r.t.ReadLine()
+	if r.in.interrupted {
+		r.in.interrupted = false
+		_, _ = r.out.Write([]byte("^C\r\n"))
+		r.init()
+		return "", errInterrupted
+	}
+	if errors.Is(err, term.ErrPasteIndicator) {
+		err = nil
+	}
+	return line, err
+}
+
+// keyReader turns line feeds into carriage returns, as input typed while a
+// script runs is read in cooked mode, where the terminal has already turned
+// Enter into a line feed. It also records Ctrl-C, which the terminal
+// reports like Ctrl-D.
+type keyReader struct {
+	r           io.Reader
+	interrupted bool
+}
+
+func (r *keyReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for i := 0; i < n; i++ {
+		switch p[i] {
+		case '\n':
+			p[i] = '\r'
+		case 3: // Ctrl-C
+			r.interrupted = true
+		}
+	}
+	return n, err
+}
+
+func commonPrefix(s []string) string {
+	prefix := s[0]
+	for _, v := range s[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// fileHistory is a term.History backed by a file holding one entry per line.
+// Entries are appended to the file as they are added; the file is trimmed to
+// the latest max entries when it's loaded.
+type fileHistory struct {
+	path    string
+	max     int
+	entries []string // oldest first
+}
+
+func loadHistory(path string, max int) *fileHistory {
+	h := &fileHistory{path: path, max: max}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > max {
+		h.entries = h.entries[len(h.entries)-max:]
+		data := strings.Join(h.entries, "\n") + "\n"
+		_ = os.WriteFile(path, []byte(data), 0600)
+	}
+	return h
+}
+
+// Add adds entry unless it's blank or repeats the latest entry.
+func (h *fileHistory) Add(entry string) {
+	if strings.TrimSpace(entry) == "" ||
+		len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > h.max {
+		h.entries = h.entries[1:]
+	}
+
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return
+	}
+	_, _ = f.WriteString(entry + "\n")
+	_ = f.Close()
+}
+
+// Len returns the number of entries.
+func (h *fileHistory) Len() int {
+	return len(h.entries)
+}
+
+// At returns the entry at idx, where 0 is the most recent one.
+func (h *fileHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
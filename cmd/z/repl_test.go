@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+)
+
+func runREPL(t *testing.T, input string) string {
+	t.Helper()
+	var out bytes.Buffer
+	modules := stdlib.GetModuleMap(stdlib.AllModuleNames()...)
+	RunREPL(modules, strings.NewReader(input), &out)
+	return out.String()
+}
+
+func TestREPL(t *testing.T) {
+	out := runREPL(t, "a := 1\na + 2\n")
+	require.Equal(t, ">> 1\n>> 3\n>> ", out)
+
+	// incomplete input continues with the secondary prompt
+	out = runREPL(t, "f := func(a) {\n\treturn a * 2\n}\nf(4)\n")
+	require.Equal(t, ">> .. .. <compiled-function f>\n>> 8\n>> ", out)
+
+	out = runREPL(t, "s := `a\nb`\n")
+	require.Equal(t, ">> .. a\nb\n>> ", out)
+
+	// errors are reported and the session continues
+	out = runREPL(t, "x := )\n1 + {}\nx := 2\n")
+	require.True(t, strings.Contains(out, "Parse Error"), out)
+	require.True(t, strings.Contains(out, "Runtime Error"), out)
+	require.True(t, strings.HasSuffix(out, ">> 2\n>> "))
+
+	// incomplete input at the end is reported
+	out = runREPL(t, "if true {\n")
+	require.True(t, strings.Contains(out, "found 'EOF'"), out)
+}
+
+func TestREPLCommands(t *testing.T) {
+	out := runREPL(t, ":help\n")
+	require.True(t, strings.Contains(out, ":load <file>"), out)
+
+	out = runREPL(t, ":type 1\n:type [1]\nm := {}\n:type m.a\n")
+	require.Equal(t, ">> int\n>> array\n>> {}\n>> undefined\n>> ", out)
+
+	out = runREPL(t, ":type a := 1\n")
+	require.True(t, strings.Contains(out, "not an expression"), out)
+
+	out = runREPL(t, "a := 1\n:reset\na\n")
+	require.True(t, strings.Contains(out, "unresolved reference 'a'"), out)
+
+	out = runREPL(t, ":dis x := 1 + 2\nx\n")
+	require.True(t, strings.Contains(out, "BINARYOP"), out)
+	require.True(t, strings.Contains(out, "(int)"), out)
+	require.True(t, strings.Contains(out, "unresolved reference 'x'"), out)
+
+	out = runREPL(t, ":dis func() { return 1 }\n")
+	require.True(t, strings.Contains(out, "compiled-function"), out)
+	require.True(t, strings.Contains(out, "RET "), out)
+
+	out = runREPL(t, ":unknown\n")
+	require.True(t, strings.Contains(out, "unknown command: :unknown"), out)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib.z"),
+		[]byte(`export func(x) { return x * 10 }`), 0644))
+	file := filepath.Join(dir, "main.z")
+	require.NoError(t, os.WriteFile(file,
+		[]byte("lib := import(\"./lib\")\ny := lib(2)\n"), 0644))
+	out = runREPL(t, ":load "+file+"\ny\n")
+	require.Equal(t, ">> >> 20\n>> ", out)
+
+	out = runREPL(t, ":load "+filepath.Join(dir, "missing.z")+"\n")
+	require.True(t, strings.Contains(out, "missing.z"), out)
+}
+
+func TestREPLCompletions(t *testing.T) {
+	modules := stdlib.GetModuleMap(stdlib.AllModuleNames()...)
+	r := newREPL(modules, &bytes.Buffer{})
+	r.eval(`conf := {name: 1, nested: 2, other: 3}`, false)
+	r.eval(`text := import("text")`, false)
+
+	start, candidates := r.completions("x := con", 8)
+	require.Equal(t, 5, start)
+	require.Equal(t, []string{"conf", "continue"}, candidates)
+
+	_, candidates = r.completions("len", 3)
+	require.Equal(t, []string{"len"}, candidates)
+
+	_, candidates = r.completions("__repl", 6)
+	require.Equal(t, 0, len(candidates))
+
+	start, candidates = r.completions("conf.n", 6)
+	require.Equal(t, 5, start)
+	require.Equal(t, []string{"name", "nested"}, candidates)
+
+	_, candidates = r.completions("text.has_p", 10)
+	require.Equal(t, []string{"has_prefix"}, candidates)
+
+	// modules that aren't imported yet
+	_, candidates = r.completions("math.ab", 7)
+	require.Equal(t, []string{"abs"}, candidates)
+
+	_, candidates = r.completions(`import("jso`, 11)
+	require.Equal(t, []string{"json"}, candidates)
+
+	require.Equal(t, "has_", commonPrefix([]string{"has_prefix", "has_suffix"}))
+}
+
+func TestFileHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h := loadHistory(path, 3)
+	for _, entry := range []string{"a", "b", "b", " ", "c", "d"} {
+		h.Add(entry)
+	}
+	require.Equal(t, 3, h.Len())
+	require.Equal(t, "d", h.At(0))
+	require.Equal(t, "b", h.At(2))
+
+	h = loadHistory(path, 3)
+	require.Equal(t, 3, h.Len())
+	require.Equal(t, "d", h.At(0))
+	require.Equal(t, "b", h.At(2))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "b\nc\nd\n", string(data))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/zhttp"
+)
+
+// Serve compiles the script given in args and serves HTTP requests with it.
+func Serve(modules *z.ModuleMap, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "Listen address")
+	_ = flags.Parse(args)
+
+	inputFile := flags.Arg(0)
+	if inputFile == "" {
+		return errors.New("missing script file")
+	}
+	src, err := os.ReadFile(inputFile)
+	if err != nil {
+		return err
+	}
+	if len(src) > 1 && string(src[:2]) == "#!" {
+		copy(src, "//")
+	}
+
+	s := zhttp.NewScript(src)
+	s.SetImports(modules)
+	s.EnableFileImport(true)
+	if err := s.SetImportDir(filepath.Dir(inputFile)); err != nil {
+		return err
+	}
+	loader, err := moduleLoader(filepath.Dir(inputFile))
+	if err != nil {
+		return err
+	}
+	if loader != nil {
+		s.SetModuleLoader(z.ChainLoader{loader,
+			&z.FileLoader{Dir: filepath.Dir(inputFile)}})
+	}
+	compiled, err := s.Compile()
+	if err != nil {
+		return err
+	}
+
+	handler := zhttp.NewHandler(compiled)
+	handler.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("%s %s: %s", r.Method, r.URL.Path, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+	}
+	log.Printf("serving %s on %s", inputFile, *addr)
+	return http.ListenAndServe(*addr, handler)
+}
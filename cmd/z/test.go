@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/ztest"
+)
+
+// errTestsFailed is returned by Test when some tests fail. The failures are
+// already reported.
+var errTestsFailed = errors.New("tests failed")
+
+// Test runs the tests in the files and directories given in args, the
+// current directory by default, and writes the report to out.
+func Test(modules *z.ModuleMap, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	run := flags.String("run", "", "Run only the tests matching the regexp")
+	format := flags.String("format", "text",
+		"Report format: text, tap or junit")
+	output := flags.String("o", "", "Write the report to a file")
+	verbose := flags.Bool("v", false, "Report passed tests too")
+	cover := flags.Bool("cover", false, "Report the coverage of the sources")
+	coverProfile := flags.String("coverprofile", "",
+		"Write a coverage profile to a file")
+	coverFormat := flags.String("coverformat", "go",
+		"Coverage profile format: go or lcov")
+	_ = flags.Parse(args)
+
+	runner := &ztest.Runner{Modules: modules}
+	loader, err := moduleLoader(".")
+	if err != nil {
+		return err
+	}
+	if loader != nil {
+		runner.ModuleLoader = loader
+	}
+	writeCoverage := z.WriteCoverProfile
+	switch *coverFormat {
+	case "go":
+	case "lcov":
+		writeCoverage = z.WriteLCOV
+	default:
+		return fmt.Errorf("unknown coverage format: %s", *coverFormat)
+	}
+	if *cover || *coverProfile != "" {
+		runner.Coverage = z.NewCoverage()
+	}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			return fmt.Errorf("invalid -run: %w", err)
+		}
+		runner.Match = re
+	}
+	var write func(w io.Writer, results []ztest.Result) error
+	switch *format {
+	case "text":
+		write = func(w io.Writer, results []ztest.Result) error {
+			return ztest.WriteText(w, results, *verbose)
+		}
+	case "tap":
+		write = ztest.WriteTAP
+	case "junit":
+		write = ztest.WriteJUnit
+	default:
+		return fmt.Errorf("unknown report format: %s", *format)
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := ztest.FindFiles(paths...)
+	if err != nil {
+		return err
+	}
+	results := runner.RunFiles(files...)
+
+	if *output != "" {
+		err = writeFile(*output, func(w io.Writer) error {
+			return write(w, results)
+		})
+	} else {
+		err = write(out, results)
+	}
+	if err != nil {
+		return err
+	}
+
+	if runner.Coverage != nil {
+		lines := ztest.CoverageLines(runner.Coverage)
+		// keep the machine readable reports on stdout valid
+		if *cover && (*format == "text" || *output != "") {
+			_, _ = fmt.Fprintf(out, "coverage: %.1f%% of lines\n",
+				z.CoveragePercent(lines))
+		}
+		if *coverProfile != "" {
+			err := writeFile(*coverProfile, func(w io.Writer) error {
+				return writeCoverage(w, lines)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if _, failed, _ := ztest.Summary(results); failed > 0 {
+		return errTestsFailed
+	}
+	return nil
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
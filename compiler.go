@@ -40,6 +40,14 @@ func (e *CompilerError) Error() string {
 	return fmt.Sprintf("Compile Error: %s\n\tat %s", e.Err.Error(), filePos)
 }
 
+// Snippet renders the source line of the error with a caret, see
+// parser.Snippet.
+func (e *CompilerError) Snippet() string {
+	pos := e.FileSet.Position(e.Node.Pos())
+	return parser.Snippet(e.FileSet.LineSource(e.Node.Pos()), pos.Line,
+		pos.Column)
+}
+
 // Compiler compiles the AST into a bytecode.
 type Compiler struct {
 	file            *parser.SourceFile
@@ -54,10 +62,15 @@ type Compiler struct {
 	modules         ModuleGetter
 	compiledModules map[string]*CompiledFunction
 	allowFileImport bool
+	traceImports    bool
+	moduleLoader    ModuleLoader
 	loops           []*loop
 	loopIndex       int
 	trace           io.Writer
 	indent          int
+	// funcName is the name given to the function or map literal compiled
+	// next, after the variable, map key or module it is bound to
+	funcName string
 }
 
 // NewCompiler creates a Compiler.
@@ -336,6 +349,7 @@ func (c *Compiler) Compile(node parser.Node) error {
 		}
 		c.emit(node, parser.OpArray, len(node.Elements))
 	case *parser.MapLit:
+		prefix := c.takeFuncName()
 		for _, elt := range node.Elements {
 			// key
 			key := "_"
@@ -352,6 +366,11 @@ func (c *Compiler) Compile(node parser.Node) error {
 				c.addConstant(&String{Value: key}))
 
 			// value
+			if prefix != "" {
+				c.nameFunc(elt.Value, prefix+"."+key)
+			} else {
+				c.nameFunc(elt.Value, key)
+			}
 			if err := c.Compile(elt.Value); err != nil {
 				return err
 			}
@@ -394,6 +413,7 @@ func (c *Compiler) Compile(node parser.Node) error {
 		}
 		c.emit(node, parser.OpSliceIndex)
 	case *parser.FuncLit:
+		name := c.takeFuncName()
 		c.enterScope()
 
 		for _, p := range node.Type.Params.List {
@@ -468,6 +488,7 @@ func (c *Compiler) Compile(node parser.Node) error {
 		}
 
 		compiledFunction := &CompiledFunction{
+			Name:          name,
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Type.Params.List),
@@ -513,6 +534,10 @@ func (c *Compiler) Compile(node parser.Node) error {
 			return c.errorf(node, "empty module name")
 		}
 
+		if c.traceImports {
+			c.emit(node, parser.OpImport,
+				c.addConstant(&String{Value: node.ModuleName}))
+		}
 		if mod := c.modules.Get(node.ModuleName); mod != nil {
 			v, err := mod.Import(node.ModuleName)
 			if err != nil {
@@ -533,6 +558,22 @@ func (c *Compiler) Compile(node parser.Node) error {
 			default:
 				panic(fmt.Errorf("invalid import value type: %T", v))
 			}
+		} else if c.moduleLoader != nil {
+			moduleSrc, modulePath, err := c.moduleLoader.Load(
+				node.ModuleName, c.modulePath)
+			if errors.Is(err, ErrModuleNotFound) {
+				return c.errorf(node, "module '%s' not found",
+					node.ModuleName)
+			} else if err != nil {
+				return c.errorf(node, "module load error: %s", err.Error())
+			}
+
+			compiled, err := c.compileModule(node, modulePath, moduleSrc, true)
+			if err != nil {
+				return err
+			}
+			c.emit(node, parser.OpConstant, c.addConstant(compiled))
+			c.emit(node, parser.OpCall, 0, 0)
 		} else if c.allowFileImport {
 			moduleName := node.ModuleName
 
@@ -567,6 +608,7 @@ func (c *Compiler) Compile(node parser.Node) error {
 		if c.parent == nil {
 			break
 		}
+		c.nameFunc(node.Result, moduleBaseName(c.modulePath))
 		if err := c.Compile(node.Result); err != nil {
 			return err
 		}
@@ -628,6 +670,20 @@ func (c *Compiler) EnableFileImport(enable bool) {
 	c.allowFileImport = enable
 }
 
+// EnableImportTrace enables or disables the instructions reporting the
+// imports to the tracer of the VM, see Tracer.Import. They are disabled by
+// default.
+func (c *Compiler) EnableImportTrace(enable bool) {
+	c.traceImports = enable
+}
+
+// SetModuleLoader sets the loader used to load the modules that are not found
+// in the module getter. When set, it is used instead of the local file
+// imports.
+func (c *Compiler) SetModuleLoader(loader ModuleLoader) {
+	c.moduleLoader = loader
+}
+
 // SetImportDir sets the initial import directory path for file imports.
 func (c *Compiler) SetImportDir(dir string) {
 	c.importDir = dir
@@ -708,6 +764,9 @@ func (c *Compiler) compileAssign(
 	}
 
 	// compile RHSs
+	if op == token.Assign || op == token.Define {
+		c.nameFunc(rhs[0], assignName(ident, selectors))
+	}
 	for _, expr := range rhs {
 		if err := c.Compile(expr); err != nil {
 			return err
@@ -1120,6 +1179,43 @@ func (c *Compiler) leaveScope() (
 	return
 }
 
+// nameFunc names the function or map literal expr, if it is one, when it is
+// compiled next.
+func (c *Compiler) nameFunc(expr parser.Expr, name string) {
+	switch expr.(type) {
+	case *parser.FuncLit, *parser.MapLit:
+		c.funcName = name
+	}
+}
+
+// takeFuncName returns the name set by nameFunc, once.
+func (c *Compiler) takeFuncName() string {
+	name := c.funcName
+	c.funcName = ""
+	return name
+}
+
+// assignName returns the name of the assignment target, e.g. "a.b" for
+// "a.b = x", or "" if a selector is not a string constant.
+func assignName(ident string, selectors []parser.Expr) string {
+	name := ident
+	for _, sel := range selectors {
+		s, ok := sel.(*parser.StringLit)
+		if !ok {
+			return ""
+		}
+		name += "." + s.Value
+	}
+	return name
+}
+
+// moduleBaseName returns the name of the module at modulePath, without the
+// directory and the extension of the module files.
+func moduleBaseName(modulePath string) string {
+	base := filepath.Base(modulePath)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
 func (c *Compiler) fork(
 	file *parser.SourceFile,
 	modulePath string,
@@ -1130,6 +1226,8 @@ func (c *Compiler) fork(
 	child.modulePath = modulePath // module file path
 	child.parent = c              // parent to set to current compiler
 	child.allowFileImport = c.allowFileImport
+	child.traceImports = c.traceImports
+	child.moduleLoader = c.moduleLoader
 	child.importDir = c.importDir
 	child.importFileExt = c.importFileExt
 	if isFile && c.importDir != "" {
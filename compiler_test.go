@@ -1251,6 +1251,68 @@ func() {
 				z.MakeInstruction(parser.OpReturn, 0)))))
 }
 
+func TestCompilerFunctionNames(t *testing.T) {
+	names := func(b *z.Bytecode) (res []string) {
+		for _, c := range b.Constants {
+			if fn, ok := c.(*z.CompiledFunction); ok {
+				res = append(res, fn.Name)
+			}
+		}
+		return
+	}
+
+	bytecode, _, err := traceCompile(`
+f := func() { g := func() {}; return func() {} }
+m := {a: func() {}, "b": {c: func() {}}, d: 1}
+m.e = func() {}
+m["f"] = func() {}
+m[1] = func() {}
+h := undefined
+h = func() {}
+h += func() {}
+k := [func() {}]`, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"g", "", "f", "m.a", "m.b.c", "m.e", "m.f",
+		"", "h", "", ""}, names(bytecode))
+
+	src := `x := import("mod1")`
+	modules := z.NewModuleMap()
+	modules.AddSourceModule("mod1", []byte(`
+export {add: func(a, b) { return a + b }, inner: func() {}}`))
+	fileSet := parser.NewFileSet()
+	file := fileSet.AddFile("test", -1, len(src))
+	parsed, err := parser.NewParser(file, []byte(src), nil).ParseFile()
+	require.NoError(t, err)
+	c := z.NewCompiler(file, nil, nil, modules, nil)
+	require.NoError(t, c.Compile(parsed))
+	require.Equal(t, []string{"mod1.add", "mod1.inner", ""},
+		names(c.Bytecode()))
+	for _, c := range c.Bytecode().Constants {
+		if fn, ok := c.(*z.CompiledFunction); ok {
+			require.Equal(t, "<compiled-function mod1.add>", fn.String())
+			break
+		}
+	}
+}
+
+func TestCompilerImportTrace(t *testing.T) {
+	src := `x := import("mod1")`
+	modules := z.NewModuleMap()
+	modules.AddBuiltinModule("mod1", map[string]z.Object{})
+	for _, enable := range []bool{false, true} {
+		fileSet := parser.NewFileSet()
+		file := fileSet.AddFile("test", -1, len(src))
+		parsed, err := parser.NewParser(file, []byte(src), nil).ParseFile()
+		require.NoError(t, err)
+		c := z.NewCompiler(file, nil, nil, modules, nil)
+		c.EnableImportTrace(enable)
+		require.NoError(t, c.Compile(parsed))
+		// the import instruction is only emitted for the tracers
+		insts := c.Bytecode().MainFunction.Instructions
+		require.Equal(t, enable, insts[0] == parser.OpImport)
+	}
+}
+
 func TestCompiler_custom_extension(t *testing.T) {
 	pathFileSource := "./testdata/issue286/test.mshk"
 
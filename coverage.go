@@ -0,0 +1,185 @@
+package z
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/diiyw/z/parser"
+)
+
+// Coverage records how many times the instructions of compiled functions
+// are executed by the VMs it's set to, see VM.SetCoverage. It's safe for
+// concurrent use by multiple VMs.
+type Coverage struct {
+	lock  sync.Mutex
+	funcs map[*byte]*coveredFunc
+}
+
+// coveredFunc holds the execution counts of the instructions of a compiled
+// function.
+type coveredFunc struct {
+	fn      *CompiledFunction
+	fileSet *parser.SourceFileSet
+	counts  []uint32
+}
+
+// NewCoverage creates a new Coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{funcs: make(map[*byte]*coveredFunc)}
+}
+
+// Add registers the functions of the bytecode, so that the lines of the
+// functions that are never called are reported as well.
+func (c *Coverage) Add(bytecode *Bytecode) {
+	c.counters(bytecode.MainFunction, bytecode.FileSet)
+	for _, cn := range bytecode.Constants {
+		if fn, ok := cn.(*CompiledFunction); ok {
+			c.counters(fn, bytecode.FileSet)
+		}
+	}
+}
+
+// counters returns the execution counts of the instructions of fn. Closures
+// share the instructions, and so the counts, of the function they're created
+// from. It returns nil for functions without source map, like the ones
+// created by VM.Call.
+func (c *Coverage) counters(
+	fn *CompiledFunction,
+	fileSet *parser.SourceFileSet,
+) []uint32 {
+	if len(fn.Instructions) == 0 || len(fn.SourceMap) == 0 ||
+		fileSet == nil {
+		return nil
+	}
+	key := &fn.Instructions[0]
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	f, ok := c.funcs[key]
+	if !ok {
+		f = &coveredFunc{
+			fn:      fn,
+			fileSet: fileSet,
+			counts:  make([]uint32, len(fn.Instructions)),
+		}
+		c.funcs[key] = f
+	}
+	return f.counts
+}
+
+// CoverageLine is the coverage of a source line.
+type CoverageLine struct {
+	File string
+	Line int
+	// StartColumn and EndColumn delimit the code of the instructions on the
+	// line. EndColumn is exclusive.
+	StartColumn int
+	EndColumn   int
+	// Count is the number of times the line was executed, that is the
+	// highest execution count of its instructions.
+	Count int
+}
+
+// Lines returns the coverage of the lines with instructions, sorted by file
+// and line.
+func (c *Coverage) Lines() []CoverageLine {
+	type lineKey struct {
+		file string
+		line int
+	}
+	lines := make(map[lineKey]*CoverageLine)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, f := range c.funcs {
+		for ip, pos := range f.fn.SourceMap {
+			if pos == parser.NoPos {
+				continue
+			}
+			p := f.fileSet.Position(pos)
+			if !p.IsValid() {
+				continue
+			}
+			count := int(atomic.LoadUint32(&f.counts[ip]))
+			k := lineKey{p.Filename, p.Line}
+			l, ok := lines[k]
+			if !ok {
+				lines[k] = &CoverageLine{
+					File:        p.Filename,
+					Line:        p.Line,
+					StartColumn: p.Column,
+					EndColumn:   p.Column + 1,
+					Count:       count,
+				}
+				continue
+			}
+			l.StartColumn = min(l.StartColumn, p.Column)
+			l.EndColumn = max(l.EndColumn, p.Column+1)
+			l.Count = max(l.Count, count)
+		}
+	}
+
+	res := make([]CoverageLine, 0, len(lines))
+	for _, l := range lines {
+		res = append(res, *l)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].File != res[j].File {
+			return res[i].File < res[j].File
+		}
+		return res[i].Line < res[j].Line
+	})
+	return res
+}
+
+// CoveragePercent returns the percentage of the lines that were executed.
+func CoveragePercent(lines []CoverageLine) float64 {
+	if len(lines) == 0 {
+		return 0
+	}
+	var covered int
+	for _, l := range lines {
+		if l.Count > 0 {
+			covered++
+		}
+	}
+	return 100 * float64(covered) / float64(len(lines))
+}
+
+// WriteCoverProfile writes the lines in the format of the Go cover
+// profiles, in count mode, with one block per line.
+func WriteCoverProfile(w io.Writer, lines []CoverageLine) error {
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintln(bw, "mode: count")
+	for _, l := range lines {
+		_, _ = fmt.Fprintf(bw, "%s:%d.%d,%d.%d 1 %d\n", l.File,
+			l.Line, l.StartColumn, l.Line, l.EndColumn, l.Count)
+	}
+	return bw.Flush()
+}
+
+// WriteLCOV writes the lines as an LCOV tracefile.
+func WriteLCOV(w io.Writer, lines []CoverageLine) error {
+	bw := bufio.NewWriter(w)
+	var found, hit int
+	for i, l := range lines {
+		if i == 0 || lines[i-1].File != l.File {
+			_, _ = fmt.Fprintf(bw, "SF:%s\n", l.File)
+		}
+		_, _ = fmt.Fprintf(bw, "DA:%d,%d\n", l.Line, l.Count)
+		found++
+		if l.Count > 0 {
+			hit++
+		}
+		if i == len(lines)-1 || lines[i+1].File != l.File {
+			_, _ = fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n",
+				found, hit)
+			found, hit = 0, 0
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,117 @@
+package z_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/parser"
+	"github.com/diiyw/z/require"
+)
+
+const coverageSrc = `sign := func(x) {
+	if x < 0 {
+		return -1
+	}
+	return 1
+}
+unused := func() {
+	return 0
+}
+for i := 0; i < 3; i++ {
+	sign(i)
+}
+calls := import("calls")
+calls.call(func() { sign(-1) })`
+
+func compileCoverage(t *testing.T) *z.Bytecode {
+	fileSet := parser.NewFileSet()
+	srcFile := fileSet.AddFile("cover.z", -1, len(coverageSrc))
+	p := parser.NewParser(srcFile, []byte(coverageSrc), nil)
+	file, err := p.ParseFile()
+	require.NoError(t, err)
+	modules := z.NewModuleMap()
+	modules.AddBuiltinModule("calls", map[string]z.Object{
+		"call": &z.VMFunction{
+			Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+				return vm.Call(args[0])
+			},
+		},
+	})
+	c := z.NewCompiler(srcFile, nil, nil, modules, nil)
+	require.NoError(t, c.Compile(file))
+	return c.Bytecode()
+}
+
+func TestCoverage(t *testing.T) {
+	bytecode := compileCoverage(t)
+	cov := z.NewCoverage()
+	cov.Add(bytecode)
+	v := z.NewVM(bytecode, nil, -1)
+	v.SetCoverage(cov)
+	require.NoError(t, v.Run())
+
+	counts := make(map[int]int)
+	for _, l := range cov.Lines() {
+		require.Equal(t, "cover.z", l.File)
+		require.True(t, l.StartColumn < l.EndColumn)
+		counts[l.Line] = l.Count
+	}
+	require.Equal(t, 4, counts[2])  // if x < 0
+	require.Equal(t, 1, counts[3])  // return -1, called by VM.Call
+	require.Equal(t, 3, counts[5])  // return 1
+	require.Equal(t, 0, counts[8])  // unused
+	require.Equal(t, 3, counts[11]) // sign(i)
+	require.Equal(t, 1, counts[14])
+
+	// running again adds up
+	v = z.NewVM(bytecode, nil, -1)
+	v.SetCoverage(cov)
+	require.NoError(t, v.Run())
+	lines := cov.Lines()
+	for _, l := range lines {
+		if l.Line == 3 {
+			require.Equal(t, 2, l.Count)
+		}
+	}
+
+	var b bytes.Buffer
+	lines = []z.CoverageLine{
+		{File: "a.z", Line: 1, StartColumn: 1, EndColumn: 8, Count: 2},
+		{File: "a.z", Line: 2, StartColumn: 5, EndColumn: 9, Count: 0},
+		{File: "b.z", Line: 3, StartColumn: 2, EndColumn: 3, Count: 1},
+	}
+	require.Equal(t, float64(200)/3, z.CoveragePercent(lines))
+	require.NoError(t, z.WriteCoverProfile(&b, lines))
+	require.Equal(t, `mode: count
+a.z:1.1,1.8 1 2
+a.z:2.5,2.9 1 0
+b.z:3.2,3.3 1 1
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, z.WriteLCOV(&b, lines))
+	require.Equal(t, `SF:a.z
+DA:1,2
+DA:2,0
+LF:2
+LH:1
+end_of_record
+SF:b.z
+DA:3,1
+LF:1
+LH:1
+end_of_record
+`, b.String())
+}
+
+func TestCoverageDisabled(t *testing.T) {
+	cov := z.NewCoverage()
+	require.Equal(t, 0, len(cov.Lines()))
+	require.Equal(t, float64(0), z.CoveragePercent(nil))
+
+	// VMs without coverage don't record anything
+	bytecode := compileCoverage(t)
+	require.NoError(t, z.NewVM(bytecode, nil, -1).Run())
+	require.Equal(t, 0, len(cov.Lines()))
+}
@@ -0,0 +1,282 @@
+package z
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/diiyw/z/token"
+)
+
+// DecimalDivisionScale is the number of decimal places added to the scale of
+// the operands for the result of a decimal division that is not exact.
+var DecimalDivisionScale int32 = 16
+
+// RoundingMode is a rounding mode of the decimals.
+type RoundingMode int
+
+// List of rounding modes.
+const (
+	RoundHalfEven RoundingMode = iota // to nearest, ties to even
+	RoundHalfUp                       // to nearest, ties away from zero
+	RoundHalfDown                     // to nearest, ties towards zero
+	RoundUp                           // away from zero
+	RoundDown                         // towards zero
+	RoundCeiling                      // towards positive infinity
+	RoundFloor                        // towards negative infinity
+)
+
+var roundingModeNames = [...]string{
+	RoundHalfEven: "half_even",
+	RoundHalfUp:   "half_up",
+	RoundHalfDown: "half_down",
+	RoundUp:       "up",
+	RoundDown:     "down",
+	RoundCeiling:  "ceiling",
+	RoundFloor:    "floor",
+}
+
+func (m RoundingMode) String() string {
+	if m < 0 || int(m) >= len(roundingModeNames) {
+		return "RoundingMode(" + strconv.Itoa(int(m)) + ")"
+	}
+	return roundingModeNames[m]
+}
+
+// ParseRoundingMode returns the rounding mode of the name, such as
+// "half_even" or "floor".
+func ParseRoundingMode(name string) (RoundingMode, error) {
+	for m, n := range roundingModeNames {
+		if n == name {
+			return RoundingMode(m), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown rounding mode: %s", name)
+}
+
+// maxDecimalExp is the maximum absolute exponent of the parsed decimals, as
+// the decimals are stored with all their digits.
+const maxDecimalExp = 1 << 16
+
+var bigTen = big.NewInt(10)
+
+// pow10 returns 10^n.
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// NewDecimal returns the decimal unscaled×10^-scale. A negative scale is
+// normalized to 0.
+func NewDecimal(unscaled *big.Int, scale int32) *Decimal {
+	v := new(big.Int).Set(unscaled)
+	if scale < 0 {
+		v.Mul(v, pow10(-scale))
+		scale = 0
+	}
+	return &Decimal{Unscaled: v, Scale: scale}
+}
+
+// ParseDecimal parses a decimal number, such as "-12.50" or "1.5e-3".
+func ParseDecimal(s string) (*Decimal, error) {
+	mantissa, exp := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		var err error
+		exp, err = strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil || exp > maxDecimalExp || exp < -maxDecimalExp {
+			return nil, fmt.Errorf("invalid decimal: %q", s)
+		}
+		mantissa = s[:i]
+	}
+	digits := mantissa
+	if digits != "" && (digits[0] == '+' || digits[0] == '-') {
+		digits = digits[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" || !isDigits(intPart) ||
+		!isDigits(fracPart) {
+		return nil, fmt.Errorf("invalid decimal: %q", s)
+	}
+	v, _ := new(big.Int).SetString(intPart+fracPart, 10)
+	if mantissa[0] == '-' {
+		v.Neg(v)
+	}
+	scale := int64(len(fracPart)) - exp
+	if scale > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid decimal: %q", s)
+	}
+	return NewDecimal(v, int32(scale)), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// decimalFromFloat returns the shortest decimal that converts back to f.
+func decimalFromFloat(f float64) (*Decimal, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("invalid decimal: %v", f)
+	}
+	return ParseDecimal(strconv.FormatFloat(f, 'e', -1, 64))
+}
+
+// divRound returns n/d rounded with the mode.
+func divRound(n, d *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	sign := n.Sign() * d.Sign()
+	// cmpHalf compares the remainder with the half of the divisor
+	cmpHalf := new(big.Int).Lsh(new(big.Int).Abs(r), 1).Cmp(new(big.Int).Abs(d))
+	var away bool
+	switch mode {
+	case RoundHalfEven:
+		away = cmpHalf > 0 || cmpHalf == 0 && q.Bit(0) == 1
+	case RoundHalfUp:
+		away = cmpHalf >= 0
+	case RoundHalfDown:
+		away = cmpHalf > 0
+	case RoundUp:
+		away = true
+	case RoundCeiling:
+		away = sign > 0
+	case RoundFloor:
+		away = sign < 0
+	}
+	if away {
+		q.Add(q, big.NewInt(int64(sign)))
+	}
+	return q
+}
+
+// rescale returns the unscaled value of d at a greater or equal scale.
+func (o *Decimal) rescale(scale int32) *big.Int {
+	if scale == o.Scale {
+		return o.Unscaled
+	}
+	return new(big.Int).Mul(o.Unscaled, pow10(scale-o.Scale))
+}
+
+// Round returns the decimal rounded to the number of decimal places with the
+// mode. The result has exactly the number of places if it's not negative.
+func (o *Decimal) Round(places int32, mode RoundingMode) *Decimal {
+	if places >= o.Scale {
+		return &Decimal{Unscaled: o.rescale(places), Scale: places}
+	}
+	v := divRound(o.Unscaled, pow10(o.Scale-places), mode)
+	return NewDecimal(v, places)
+}
+
+// Cmp compares the decimals and returns -1, 0 or +1.
+func (o *Decimal) Cmp(x *Decimal) int {
+	scale := max(o.Scale, x.Scale)
+	return o.rescale(scale).Cmp(x.rescale(scale))
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of the decimal.
+func (o *Decimal) Sign() int {
+	return o.Unscaled.Sign()
+}
+
+// Float64 returns the nearest float64 value of the decimal.
+func (o *Decimal) Float64() float64 {
+	f, _ := o.bigFloat().Float64()
+	return f
+}
+
+// BigInt returns the integer part of the decimal.
+func (o *Decimal) BigInt() *big.Int {
+	return new(big.Int).Quo(o.Unscaled, pow10(o.Scale))
+}
+
+// bigFloat returns the decimal as a big float precise enough to be formatted
+// with the decimal places of the decimal.
+func (o *Decimal) bigFloat() *big.Float {
+	prec := uint(o.Unscaled.BitLen()) + uint(o.Scale)*4 + 64
+	f := new(big.Float).SetPrec(prec).SetInt(o.Unscaled)
+	if o.Scale > 0 {
+		d := new(big.Float).SetPrec(prec).SetInt(pow10(o.Scale))
+		f.Quo(f, d)
+	}
+	return f
+}
+
+// trim removes the trailing zeros of the decimal places down to the scale.
+func (o *Decimal) trim(scale int32) *Decimal {
+	v, s := new(big.Int).Set(o.Unscaled), o.Scale
+	r := new(big.Int)
+	for s > scale {
+		q, _ := new(big.Int).QuoRem(v, bigTen, r)
+		if r.Sign() != 0 {
+			break
+		}
+		v, s = q, s-1
+	}
+	return &Decimal{Unscaled: v, Scale: s}
+}
+
+func (o *Decimal) add(x *Decimal) *Decimal {
+	scale := max(o.Scale, x.Scale)
+	v := new(big.Int).Add(o.rescale(scale), x.rescale(scale))
+	return &Decimal{Unscaled: v, Scale: scale}
+}
+
+func (o *Decimal) sub(x *Decimal) *Decimal {
+	scale := max(o.Scale, x.Scale)
+	v := new(big.Int).Sub(o.rescale(scale), x.rescale(scale))
+	return &Decimal{Unscaled: v, Scale: scale}
+}
+
+func (o *Decimal) mul(x *Decimal) *Decimal {
+	v := new(big.Int).Mul(o.Unscaled, x.Unscaled)
+	return &Decimal{Unscaled: v, Scale: o.Scale + x.Scale}
+}
+
+// quo returns o/x rounded half to even to DecimalDivisionScale more places
+// than the operands, without the trailing zeros that are not needed.
+func (o *Decimal) quo(x *Decimal) (*Decimal, error) {
+	if x.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	scale := max(o.Scale, x.Scale) + DecimalDivisionScale
+	n := new(big.Int).Mul(o.Unscaled, pow10(scale-o.Scale+x.Scale))
+	q := &Decimal{Unscaled: divRound(n, x.Unscaled, RoundHalfEven), Scale: scale}
+	return q.trim(max(o.Scale-x.Scale, 0)), nil
+}
+
+// rem returns the remainder of the truncated division o/x.
+func (o *Decimal) rem(x *Decimal) (*Decimal, error) {
+	if x.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	scale := max(o.Scale, x.Scale)
+	v := new(big.Int).Rem(o.rescale(scale), x.rescale(scale))
+	return &Decimal{Unscaled: v, Scale: scale}, nil
+}
+
+// compareResult returns the result of the comparison operator for the result
+// of a Cmp method.
+func compareResult(op token.Token, cmp int) Object {
+	var r bool
+	switch op {
+	case token.Less:
+		r = cmp < 0
+	case token.Greater:
+		r = cmp > 0
+	case token.LessEq:
+		r = cmp <= 0
+	case token.GreaterEq:
+		r = cmp >= 0
+	}
+	if r {
+		return TrueValue
+	}
+	return FalseValue
+}
@@ -0,0 +1,111 @@
+package z_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+)
+
+func TestParseDecimal(t *testing.T) {
+	for s, expected := range map[string]string{
+		"0":        "0",
+		"-12.50":   "-12.50",
+		"+.5":      "0.5",
+		"3.":       "3",
+		"1.5e-3":   "0.0015",
+		"1.5E2":    "150",
+		"-0.00":    "0.00",
+		"12e0":     "12",
+		"0.000001": "0.000001",
+	} {
+		d, err := z.ParseDecimal(s)
+		require.NoError(t, err, s)
+		require.Equal(t, expected, d.String(), s)
+	}
+	for _, s := range []string{"", "-", ".", "1..2", "1e", "e5", "1.5x", "1e99999999"} {
+		_, err := z.ParseDecimal(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestDecimal_Round(t *testing.T) {
+	modes := []z.RoundingMode{
+		z.RoundHalfEven, z.RoundHalfUp, z.RoundHalfDown, z.RoundUp,
+		z.RoundDown, z.RoundCeiling, z.RoundFloor,
+	}
+	for s, expected := range map[string][]string{
+		"2.5":  {"2", "3", "2", "3", "2", "3", "2"},
+		"3.5":  {"4", "4", "3", "4", "3", "4", "3"},
+		"-2.5": {"-2", "-3", "-2", "-3", "-2", "-2", "-3"},
+		"2.51": {"3", "3", "3", "3", "2", "3", "2"},
+		"-2.1": {"-2", "-2", "-2", "-3", "-2", "-2", "-3"},
+		"7":    {"7", "7", "7", "7", "7", "7", "7"},
+	} {
+		d, err := z.ParseDecimal(s)
+		require.NoError(t, err)
+		for i, mode := range modes {
+			require.Equal(t, expected[i], d.Round(0, mode).String(),
+				s+" "+mode.String())
+		}
+	}
+
+	d, _ := z.ParseDecimal("1.5")
+	require.Equal(t, "1.500", d.Round(3, z.RoundHalfEven).String())
+	d, _ = z.ParseDecimal("1250")
+	require.Equal(t, "1200", d.Round(-2, z.RoundHalfEven).String())
+
+	mode, err := z.ParseRoundingMode("ceiling")
+	require.NoError(t, err)
+	require.True(t, mode == z.RoundCeiling)
+	_, err = z.ParseRoundingMode("nearest")
+	require.Error(t, err)
+}
+
+func TestToBigInt(t *testing.T) {
+	for _, tc := range []struct {
+		o        z.Object
+		expected string
+	}{
+		{&z.Int{Value: -5}, "-5"},
+		{&z.Float{Value: 2.9}, "2"},
+		{&z.String{Value: "123456789012345678901234567890"}, "123456789012345678901234567890"},
+		{&z.Char{Value: 'A'}, "65"},
+		{z.TrueValue, "1"},
+		{z.NewDecimal(big.NewInt(-79), 1), "-7"},
+	} {
+		v, ok := z.ToBigInt(tc.o)
+		require.True(t, ok, tc.o.String())
+		require.Equal(t, tc.expected, v.String())
+	}
+	for _, o := range []z.Object{
+		&z.String{Value: "1.5"}, z.UndefinedValue, &z.Array{},
+	} {
+		_, ok := z.ToBigInt(o)
+		require.False(t, ok, o.String())
+	}
+}
+
+func TestToDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		o        z.Object
+		expected string
+	}{
+		{&z.Int{Value: -5}, "-5"},
+		{&z.Float{Value: 0.1}, "0.1"},
+		{&z.Float{Value: 1e21}, "1000000000000000000000"},
+		{&z.String{Value: "19.90"}, "19.90"},
+		{&z.BigInt{Value: big.NewInt(42)}, "42"},
+	} {
+		v, ok := z.ToDecimal(tc.o)
+		require.True(t, ok, tc.o.String())
+		require.Equal(t, tc.expected, v.String())
+	}
+	for _, o := range []z.Object{
+		&z.String{Value: "x"}, &z.Char{Value: 'A'}, z.UndefinedValue,
+	} {
+		_, ok := z.ToDecimal(o)
+		require.False(t, ok, o.String())
+	}
+}
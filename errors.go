@@ -3,6 +3,9 @@ package z
 import (
 	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/diiyw/z/parser"
 )
 
 var (
@@ -25,6 +28,9 @@ var (
 	// ErrInvalidIndexOnError represents an invalid index on error.
 	ErrInvalidIndexOnError = errors.New("invalid index on error")
 
+	// ErrInvalidIndexOnFunction represents an invalid index on function.
+	ErrInvalidIndexOnFunction = errors.New("invalid index on function")
+
 	// ErrInvalidOperator represents an error for invalid operator usage.
 	ErrInvalidOperator = errors.New("invalid operator")
 
@@ -50,6 +56,17 @@ var (
 	// required method.
 	ErrNotImplemented = errors.New("not implemented")
 
+	// ErrAborted is an error where the execution of a VM was aborted.
+	ErrAborted = errors.New("execution aborted")
+
+	// ErrDivisionByZero is an error where a big int or a decimal is divided
+	// by zero.
+	ErrDivisionByZero = errors.New("division by zero")
+
+	// ErrInvalidShiftCount is an error where a big int is shifted by a
+	// negative or too large count.
+	ErrInvalidShiftCount = errors.New("invalid shift count")
+
 	// ErrInvalidRangeStep is an error where the step parameter is less than or equal to 0 when using builtin range function.
 	ErrInvalidRangeStep = errors.New("range step must be greater than 0")
 )
@@ -65,3 +82,56 @@ func (e ErrInvalidArgumentType) Error() string {
 	return fmt.Sprintf("invalid type for argument '%s': expected %s, found %s",
 		e.Name, e.Expected, e.Found)
 }
+
+// RuntimeError is an error stopping the execution of a VM, with the call
+// stack at the time of the error.
+type RuntimeError struct {
+	// Err is the cause of the error.
+	Err error
+	// Frames are the call frames, innermost first, including the ones of
+	// the parent VMs when the error stops a function called by a host
+	// function.
+	Frames []StackFrame
+	// nested errors are the errors of child VMs, returned to the host
+	// functions of their parent
+	nested bool
+}
+
+// StackFrame is a call frame of a RuntimeError.
+type StackFrame struct {
+	// Func is the name of the function.
+	Func string
+	// Pos is the source position of the instruction executed in the frame.
+	Pos parser.SourceFilePos
+	// Source is the source line of Pos, if known.
+	Source string
+}
+
+func (e *RuntimeError) Error() string {
+	var b strings.Builder
+	if !e.nested {
+		b.WriteString("Runtime Error: ")
+	}
+	b.WriteString(e.Err.Error())
+	for _, f := range e.Frames {
+		b.WriteString("\n\tat ")
+		b.WriteString(f.Pos.String())
+	}
+	return b.String()
+}
+
+// Unwrap returns the cause of the error.
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// Snippet renders the source line of the innermost frame with a known
+// source, with a caret under the position of the error, see parser.Snippet.
+func (e *RuntimeError) Snippet() string {
+	for _, f := range e.Frames {
+		if f.Source != "" {
+			return parser.Snippet(f.Source, f.Pos.Line, f.Pos.Column)
+		}
+	}
+	return ""
+}
@@ -1,6 +1,8 @@
 package z
 
 import (
+	"fmt"
+	"math/big"
 	"strconv"
 	"sync"
 	"unicode/utf8"
@@ -871,6 +873,70 @@ func (p *pp) fmtFloat(v float64, size int, verb rune) {
 	}
 }
 
+// fmtSpec returns the format specifier of the verb with the current flags,
+// width and precision, to format the big numbers with the fmt package.
+func (p *pp) fmtSpec(verb rune) string {
+	spec := []byte{'%'}
+	for _, flag := range []struct {
+		on bool
+		c  byte
+	}{
+		{p.fmt.minus, '-'},
+		{p.fmt.plus, '+'},
+		{p.fmt.sharp, '#'},
+		{p.fmt.space, ' '},
+		{p.fmt.zero, '0'},
+	} {
+		if flag.on {
+			spec = append(spec, flag.c)
+		}
+	}
+	if p.fmt.widPresent {
+		spec = strconv.AppendInt(spec, int64(p.fmt.wid), 10)
+	}
+	if p.fmt.precPresent {
+		spec = append(spec, '.')
+		spec = strconv.AppendInt(spec, int64(p.fmt.prec), 10)
+	}
+	return string(utf8.AppendRune(spec, verb))
+}
+
+// fmtBigInt formats a big integer.
+func (p *pp) fmtBigInt(v *big.Int, verb rune) {
+	switch verb {
+	case 'd', 'b', 'o', 'O', 'x', 'X':
+		p.buf.WriteString(fmt.Sprintf(p.fmtSpec(verb), v))
+	case 's':
+		p.fmtString(v.String(), verb)
+	default:
+		p.badVerb(verb)
+	}
+}
+
+// fmtDecimal formats a decimal. Without precision, the 'f' verb formats all
+// the decimal places of the decimal, and the other verbs format the smallest
+// number of digits necessary to represent the value.
+func (p *pp) fmtDecimal(v *Decimal, verb rune) {
+	switch verb {
+	case 'f', 'F':
+		places := v.Scale
+		if p.fmt.precPresent {
+			places = int32(p.fmt.prec)
+		}
+		p.fmt.precPresent, p.fmt.prec = true, int(places)
+		// the value is rounded half to even before the conversion to a big
+		// float, which is precise enough to be formatted exactly
+		v = v.Round(places, RoundHalfEven)
+		p.buf.WriteString(fmt.Sprintf(p.fmtSpec('f'), v.bigFloat()))
+	case 'e', 'E', 'g', 'G':
+		p.buf.WriteString(fmt.Sprintf(p.fmtSpec(verb), v.bigFloat()))
+	case 's':
+		p.fmtString(v.String(), verb)
+	default:
+		p.badVerb(verb)
+	}
+}
+
 func (p *pp) fmtString(v string, verb rune) {
 	switch verb {
 	case 'v':
@@ -957,6 +1023,10 @@ func (p *pp) printArg(arg Object, verb rune) {
 		p.fmtFloat(f.Value, 64, verb)
 	case *Int:
 		p.fmtInteger(uint64(f.Value), signed, verb)
+	case *BigInt:
+		p.fmtBigInt(f.Value, verb)
+	case *Decimal:
+		p.fmtDecimal(f, verb)
 	case *String:
 		p.fmtString(f.Value, verb)
 	case *Bytes:
@@ -5,6 +5,7 @@ go 1.24.5
 require (
 	github.com/tliron/commonlog v0.2.20
 	github.com/tliron/glsp v0.2.2
+	golang.org/x/term v0.32.0
 )
 
 require (
@@ -24,5 +25,4 @@ require (
 	golang.org/x/crypto v0.39.0 // indirect
 	golang.org/x/net v0.40.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
-	golang.org/x/term v0.32.0 // indirect
 )
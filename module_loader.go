@@ -0,0 +1,150 @@
+package z
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrModuleNotFound is an error where a module loader cannot find the
+// requested module. ChainLoader tries the next loader on this error.
+var ErrModuleNotFound = errors.New("module not found")
+
+// ModuleLoader loads the source code of the modules that are not found in
+// the ModuleGetter of the compiler.
+type ModuleLoader interface {
+	// Load returns the source code of the module name imported by the
+	// module identified by importerPath, which is empty for the main script.
+	// The canonical path identifies the module: it is used for the cyclic
+	// import detection, the compiled modules cache, and as the importerPath
+	// of the modules it imports. Load should return an error wrapping
+	// ErrModuleNotFound if the module does not exist.
+	Load(name, importerPath string) (src []byte, canonicalPath string, err error)
+}
+
+// ModuleLoaderFunc is an adapter to use an ordinary function as a
+// ModuleLoader.
+type ModuleLoaderFunc func(name, importerPath string) ([]byte, string, error)
+
+// Load calls f(name, importerPath).
+func (f ModuleLoaderFunc) Load(
+	name, importerPath string,
+) ([]byte, string, error) {
+	return f(name, importerPath)
+}
+
+// FileLoader loads modules from the local file system. Module names are
+// relative to the directory of the importing module, or to Dir for the main
+// script. Canonical paths are absolute file paths.
+type FileLoader struct {
+	Dir  string
+	Exts []string
+}
+
+// Load reads the module file.
+func (l *FileLoader) Load(name, importerPath string) ([]byte, string, error) {
+	dir := l.Dir
+	if importerPath != "" && filepath.IsAbs(importerPath) {
+		dir = filepath.Dir(importerPath)
+	}
+	for _, file := range moduleFileNames(name, l.Exts) {
+		p, err := filepath.Abs(filepath.Join(dir, file))
+		if err != nil {
+			return nil, "", err
+		}
+		src, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+		return src, p, nil
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrModuleNotFound, name)
+}
+
+// FSLoader loads modules from a fs.FS, such as an embed.FS. Module names are
+// slash-separated paths relative to the directory of the importing module,
+// or to the root of the file system for the main script and names starting
+// with a slash. Canonical paths are paths in the file system.
+type FSLoader struct {
+	FS   fs.FS
+	Exts []string
+}
+
+// Load reads the module file.
+func (l *FSLoader) Load(name, importerPath string) ([]byte, string, error) {
+	dir := "."
+	if importerPath != "" && !strings.HasPrefix(name, "/") {
+		dir = path.Dir(importerPath)
+	}
+	for _, file := range moduleFileNames(name, l.Exts) {
+		p := path.Join(dir, strings.TrimPrefix(file, "/"))
+		if !fs.ValidPath(p) {
+			continue
+		}
+		src, err := fs.ReadFile(l.FS, p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+		return src, p, nil
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrModuleNotFound, name)
+}
+
+// MapLoader loads modules from an in-memory map of module names to source
+// code. Names are looked up as is, then with the SourceFileExtDefault
+// extension. Canonical paths are the map keys.
+type MapLoader map[string][]byte
+
+// Load returns the module source.
+func (l MapLoader) Load(name, _ string) ([]byte, string, error) {
+	keys := append([]string{name}, moduleFileNames(name, nil)...)
+	for _, key := range keys {
+		if src, ok := l[key]; ok {
+			return src, key, nil
+		}
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrModuleNotFound, name)
+}
+
+// ChainLoader tries the loaders in order and returns the first module found.
+// Errors other than ErrModuleNotFound stop the resolution.
+type ChainLoader []ModuleLoader
+
+// Load returns the module from the first loader that has it.
+func (l ChainLoader) Load(name, importerPath string) ([]byte, string, error) {
+	for _, loader := range l {
+		src, p, err := loader.Load(name, importerPath)
+		if errors.Is(err, ErrModuleNotFound) {
+			continue
+		}
+		return src, p, err
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrModuleNotFound, name)
+}
+
+// moduleFileNames returns the candidate file names of a module: the name
+// itself if it already has one of the extensions, or the name with each
+// extension appended.
+func moduleFileNames(name string, exts []string) []string {
+	if len(exts) == 0 {
+		exts = []string{SourceFileExtDefault}
+	}
+	for _, ext := range exts {
+		if strings.HasSuffix(name, ext) {
+			return []string{name}
+		}
+	}
+	var names []string
+	for _, ext := range exts {
+		names = append(names, name+ext)
+	}
+	return names
+}
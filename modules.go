@@ -1,5 +1,10 @@
 package z
 
+import (
+	"sort"
+	"sync"
+)
+
 // Importable interface represents importable module instance.
 type Importable interface {
 	// Import should return either an Object or module source code ([]byte).
@@ -34,6 +39,15 @@ func (m *ModuleMap) AddBuiltinModule(name string, attrs map[string]Object) {
 	m.m[name] = &BuiltinModule{Attrs: attrs}
 }
 
+// AddLazyModule adds a builtin module whose attributes are created by factory
+// the first time the module is imported.
+func (m *ModuleMap) AddLazyModule(
+	name string,
+	factory func() map[string]Object,
+) {
+	m.m[name] = &LazyModule{Factory: factory}
+}
+
 // AddSourceModule adds a source module.
 func (m *ModuleMap) AddSourceModule(name string, src []byte) {
 	m.m[name] = &SourceModule{Src: src}
@@ -51,10 +65,16 @@ func (m *ModuleMap) Get(name string) Importable {
 }
 
 // GetBuiltinModule returns a builtin module identified by name. It returns
-// if the name is not found or the module is not a builtin module.
+// if the name is not found or the module is not a builtin module. Lazy
+// modules are constructed if needed.
 func (m *ModuleMap) GetBuiltinModule(name string) *BuiltinModule {
-	mod, _ := m.m[name].(*BuiltinModule)
-	return mod
+	switch mod := m.m[name].(type) {
+	case *BuiltinModule:
+		return mod
+	case *LazyModule:
+		return mod.Module()
+	}
+	return nil
 }
 
 // GetSourceModule returns a source module identified by name. It returns if
@@ -80,6 +100,16 @@ func (m *ModuleMap) Len() int {
 	return len(m.m)
 }
 
+// Names returns the sorted names of all modules.
+func (m *ModuleMap) Names() []string {
+	names := make([]string, 0, len(m.m))
+	for name := range m.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // AddMap adds named modules from another module map.
 func (m *ModuleMap) AddMap(o *ModuleMap) {
 	for name, mod := range o.m {
@@ -96,3 +126,33 @@ type SourceModule struct {
 func (m *SourceModule) Import(_ string) (any, error) {
 	return m.Src, nil
 }
+
+// LazyModule is an importable builtin module whose attributes are created by
+// Factory the first time it is imported. Unlike BuiltinModule, the immutable
+// map of the module is created once and shared by all the compiled scripts
+// importing it, so the module attributes must be safe for concurrent use. The
+// module name of the first import is recorded in the shared map.
+type LazyModule struct {
+	Factory func() map[string]Object
+
+	once      sync.Once
+	module    *BuiltinModule
+	importing sync.Once
+	imported  *ImmutableMap
+}
+
+// Module returns the builtin module, calling Factory if needed.
+func (m *LazyModule) Module() *BuiltinModule {
+	m.once.Do(func() {
+		m.module = &BuiltinModule{Attrs: m.Factory()}
+	})
+	return m.module
+}
+
+// Import returns the shared immutable map of the module.
+func (m *LazyModule) Import(moduleName string) (any, error) {
+	m.importing.Do(func() {
+		m.imported = m.Module().AsImmutableMap(moduleName)
+	})
+	return m.imported, nil
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"math/big"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -263,6 +265,112 @@ func (o *Array) CanIterate() bool {
 	return true
 }
 
+// BigInt represents an arbitrary-precision integer value.
+type BigInt struct {
+	ObjectImpl
+	Value *big.Int
+}
+
+func (o *BigInt) String() string {
+	return o.Value.String()
+}
+
+// TypeName returns the name of the type.
+func (o *BigInt) TypeName() string {
+	return "bigint"
+}
+
+// BinaryOp returns another object that is the result of a given binary
+// operator and a right-hand side object. Ints and big ints give big ints,
+// floats give floats and decimals give decimals.
+func (o *BigInt) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	var y *big.Int
+	switch rhs := rhs.(type) {
+	case *BigInt:
+		y = rhs.Value
+	case *Int:
+		y = big.NewInt(rhs.Value)
+	case *Float:
+		f, _ := new(big.Float).SetInt(o.Value).Float64()
+		return (&Float{Value: f}).BinaryOp(op, rhs)
+	case *Decimal:
+		return NewDecimal(o.Value, 0).BinaryOp(op, rhs)
+	default:
+		return nil, ErrInvalidOperator
+	}
+
+	r := new(big.Int)
+	switch op {
+	case token.Add:
+		r.Add(o.Value, y)
+	case token.Sub:
+		r.Sub(o.Value, y)
+	case token.Mul:
+		r.Mul(o.Value, y)
+	case token.Quo:
+		if y.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		r.Quo(o.Value, y)
+	case token.Rem:
+		if y.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		r.Rem(o.Value, y)
+	case token.And:
+		r.And(o.Value, y)
+	case token.Or:
+		r.Or(o.Value, y)
+	case token.Xor:
+		r.Xor(o.Value, y)
+	case token.AndNot:
+		r.AndNot(o.Value, y)
+	case token.Shl, token.Shr:
+		if y.Sign() < 0 || !y.IsInt64() || y.Int64() > int64(MaxBytesLen)*8 {
+			return nil, ErrInvalidShiftCount
+		}
+		if op == token.Shl {
+			r.Lsh(o.Value, uint(y.Int64()))
+		} else {
+			r.Rsh(o.Value, uint(y.Int64()))
+		}
+	case token.Less, token.Greater, token.LessEq, token.GreaterEq:
+		return compareResult(op, o.Value.Cmp(y)), nil
+	default:
+		return nil, ErrInvalidOperator
+	}
+	return &BigInt{Value: r}, nil
+}
+
+// Copy returns a copy of the type.
+func (o *BigInt) Copy() Object {
+	return &BigInt{Value: new(big.Int).Set(o.Value)}
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (o *BigInt) IsFalsy() bool {
+	return o.Value.Sign() == 0
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object. Ints, floats and decimals of the same value are equal.
+func (o *BigInt) Equals(x Object) bool {
+	switch x := x.(type) {
+	case *BigInt:
+		return o.Value.Cmp(x.Value) == 0
+	case *Int:
+		return o.Value.IsInt64() && o.Value.Int64() == x.Value
+	case *Float:
+		if math.IsNaN(x.Value) {
+			return false
+		}
+		return new(big.Float).SetInt(o.Value).Cmp(big.NewFloat(x.Value)) == 0
+	case *Decimal:
+		return x.Equals(o)
+	}
+	return false
+}
+
 // Bool represents a boolean value.
 type Bool struct {
 	ObjectImpl
@@ -335,7 +443,7 @@ func (o *BuiltinFunction) String() string {
 
 // Copy returns a copy of the type.
 func (o *BuiltinFunction) Copy() Object {
-	return &BuiltinFunction{Value: o.Value}
+	return &BuiltinFunction{Value: o.Value, Name: o.Name}
 }
 
 // Equals returns true if the value of the type is equal to the value of
@@ -354,6 +462,11 @@ func (o *BuiltinFunction) CanCall() bool {
 	return true
 }
 
+// IndexGet returns the name of the function for the "name" index.
+func (o *BuiltinFunction) IndexGet(index Object) (Object, error) {
+	return functionIndexGet(o.Name, index)
+}
+
 // BuiltinModule is an importable module that's written in Go.
 type BuiltinModule struct {
 	Attrs map[string]Object
@@ -570,6 +683,10 @@ func (o *Char) Equals(x Object) bool {
 // CompiledFunction represents a compiled function.
 type CompiledFunction struct {
 	ObjectImpl
+	// Name is the name of the variable, map key or module the function is
+	// bound to in the source, e.g. "f" for "f := func() {}", or "" for the
+	// anonymous functions.
+	Name          string
 	Instructions  []byte
 	NumLocals     int // number of local variables (including function parameters)
 	NumParameters int
@@ -584,12 +701,16 @@ func (o *CompiledFunction) TypeName() string {
 }
 
 func (o *CompiledFunction) String() string {
+	if o.Name != "" {
+		return "<compiled-function " + o.Name + ">"
+	}
 	return "<compiled-function>"
 }
 
 // Copy returns a copy of the type.
 func (o *CompiledFunction) Copy() Object {
 	return &CompiledFunction{
+		Name:          o.Name,
 		Instructions:  append([]byte{}, o.Instructions...),
 		NumLocals:     o.NumLocals,
 		NumParameters: o.NumParameters,
@@ -615,11 +736,141 @@ func (o *CompiledFunction) SourcePos(ip int) parser.Pos {
 	return parser.NoPos
 }
 
+// startPos returns the source position of the first instruction of the
+// function with one.
+func (o *CompiledFunction) startPos() parser.Pos {
+	start := parser.NoPos
+	for _, pos := range o.SourceMap {
+		if pos != parser.NoPos && (start == parser.NoPos || pos < start) {
+			start = pos
+		}
+	}
+	return start
+}
+
+// traceName returns the name of the function in the traces and the
+// profiles: its Name, or for the anonymous functions, the position of its
+// first instruction, e.g. "func@lib.z:12".
+func (o *CompiledFunction) traceName(fileSet *parser.SourceFileSet) string {
+	if o.Name != "" {
+		return o.Name
+	}
+	start := o.startPos()
+	if start == parser.NoPos || fileSet == nil {
+		return "func"
+	}
+	pos := fileSet.Position(start)
+	return fmt.Sprintf("func@%s:%d", filepath.Base(pos.Filename), pos.Line)
+}
+
 // CanCall returns whether the Object can be Called.
 func (o *CompiledFunction) CanCall() bool {
 	return true
 }
 
+// IndexGet returns the name of the function for the "name" index.
+func (o *CompiledFunction) IndexGet(index Object) (Object, error) {
+	return functionIndexGet(o.Name, index)
+}
+
+// Decimal represents an arbitrary-precision decimal value: Unscaled×10^-Scale.
+// The scale is the number of decimal places and is never negative.
+type Decimal struct {
+	ObjectImpl
+	Unscaled *big.Int
+	Scale    int32
+}
+
+func (o *Decimal) String() string {
+	s := new(big.Int).Abs(o.Unscaled).String()
+	if o.Scale > 0 {
+		if n := int(o.Scale) - len(s) + 1; n > 0 {
+			s = strings.Repeat("0", n) + s
+		}
+		s = s[:len(s)-int(o.Scale)] + "." + s[len(s)-int(o.Scale):]
+	}
+	if o.Unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// TypeName returns the name of the type.
+func (o *Decimal) TypeName() string {
+	return "decimal"
+}
+
+// BinaryOp returns another object that is the result of a given binary
+// operator and a right-hand side object. Ints, big ints, floats and decimals
+// give decimals.
+func (o *Decimal) BinaryOp(op token.Token, rhs Object) (Object, error) {
+	var y *Decimal
+	switch rhs := rhs.(type) {
+	case *Decimal:
+		y = rhs
+	case *Int:
+		y = &Decimal{Unscaled: big.NewInt(rhs.Value)}
+	case *BigInt:
+		y = &Decimal{Unscaled: rhs.Value}
+	case *Float:
+		var err error
+		if y, err = decimalFromFloat(rhs.Value); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrInvalidOperator
+	}
+
+	switch op {
+	case token.Add:
+		return o.add(y), nil
+	case token.Sub:
+		return o.sub(y), nil
+	case token.Mul:
+		return o.mul(y), nil
+	case token.Quo:
+		return o.quo(y)
+	case token.Rem:
+		return o.rem(y)
+	case token.Less, token.Greater, token.LessEq, token.GreaterEq:
+		return compareResult(op, o.Cmp(y)), nil
+	}
+	return nil, ErrInvalidOperator
+}
+
+// Copy returns a copy of the type.
+func (o *Decimal) Copy() Object {
+	return &Decimal{Unscaled: new(big.Int).Set(o.Unscaled), Scale: o.Scale}
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (o *Decimal) IsFalsy() bool {
+	return o.Unscaled.Sign() == 0
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object. Decimals with different scales can be equal, such as 1.5
+// and 1.50, and so are ints, big ints and floats of the same value.
+func (o *Decimal) Equals(x Object) bool {
+	var y *Decimal
+	switch x := x.(type) {
+	case *Decimal:
+		y = x
+	case *Int:
+		y = &Decimal{Unscaled: big.NewInt(x.Value)}
+	case *BigInt:
+		y = &Decimal{Unscaled: x.Value}
+	case *Float:
+		var err error
+		if y, err = decimalFromFloat(x.Value); err != nil {
+			return false
+		}
+	default:
+		return false
+	}
+	return o.Cmp(y) == 0
+}
+
 // Error represents an error value.
 type Error struct {
 	ObjectImpl
@@ -777,6 +1028,15 @@ func (o *Float) BinaryOp(op token.Token, rhs Object) (Object, error) {
 			}
 			return FalseValue, nil
 		}
+	case *BigInt:
+		f, _ := new(big.Float).SetInt(rhs.Value).Float64()
+		return o.BinaryOp(op, &Float{Value: f})
+	case *Decimal:
+		x, err := decimalFromFloat(o.Value)
+		if err != nil {
+			return nil, err
+		}
+		return x.BinaryOp(op, rhs)
 	}
 	return nil, ErrInvalidOperator
 }
@@ -792,13 +1052,15 @@ func (o *Float) IsFalsy() bool {
 }
 
 // Equals returns true if the value of the type is equal to the value of
-// another object.
+// another object. Big ints and decimals of the same value are equal.
 func (o *Float) Equals(x Object) bool {
-	t, ok := x.(*Float)
-	if !ok {
-		return false
+	switch x := x.(type) {
+	case *Float:
+		return o.Value == x.Value
+	case *BigInt, *Decimal:
+		return x.Equals(o)
 	}
-	return o.Value == t.Value
+	return false
 }
 
 // ImmutableArray represents an immutable array of objects.
@@ -1153,6 +1415,10 @@ func (o *Int) BinaryOp(op token.Token, rhs Object) (Object, error) {
 			}
 			return FalseValue, nil
 		}
+	case *BigInt:
+		return (&BigInt{Value: big.NewInt(o.Value)}).BinaryOp(op, rhs)
+	case *Decimal:
+		return (&Decimal{Unscaled: big.NewInt(o.Value)}).BinaryOp(op, rhs)
 	}
 	return nil, ErrInvalidOperator
 }
@@ -1168,13 +1434,15 @@ func (o *Int) IsFalsy() bool {
 }
 
 // Equals returns true if the value of the type is equal to the value of
-// another object.
+// another object. Big ints and decimals of the same value are equal.
 func (o *Int) Equals(x Object) bool {
-	t, ok := x.(*Int)
-	if !ok {
-		return false
+	switch x := x.(type) {
+	case *Int:
+		return o.Value == x.Value
+	case *BigInt, *Decimal:
+		return x.Equals(o)
 	}
-	return o.Value == t.Value
+	return false
 }
 
 // Map represents a map of objects.
@@ -1610,3 +1878,62 @@ func (o *UserFunction) Call(args ...Object) (Object, error) {
 func (o *UserFunction) CanCall() bool {
 	return true
 }
+
+// IndexGet returns the name of the function for the "name" index.
+func (o *UserFunction) IndexGet(index Object) (Object, error) {
+	return functionIndexGet(o.Name, index)
+}
+
+// VMFunction represents a user function that receives the VM it is called
+// from. It allows Go functions to call back into the script, e.g. to invoke
+// a closure passed as an argument, using VM.Call. The VM is nil when the
+// function is called outside a VM.
+type VMFunction struct {
+	ObjectImpl
+	Name  string
+	Value CallableFuncVM
+}
+
+// TypeName returns the name of the type.
+func (o *VMFunction) TypeName() string {
+	return "user-function:" + o.Name
+}
+
+func (o *VMFunction) String() string {
+	return "<user-function>"
+}
+
+// Copy returns a copy of the type.
+func (o *VMFunction) Copy() Object {
+	return &VMFunction{Value: o.Value, Name: o.Name}
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *VMFunction) Equals(_ Object) bool {
+	return false
+}
+
+// Call invokes the function without a VM.
+func (o *VMFunction) Call(args ...Object) (Object, error) {
+	return o.Value(nil, args...)
+}
+
+// CanCall returns whether the Object can be Called.
+func (o *VMFunction) CanCall() bool {
+	return true
+}
+
+// IndexGet returns the name of the function for the "name" index.
+func (o *VMFunction) IndexGet(index Object) (Object, error) {
+	return functionIndexGet(o.Name, index)
+}
+
+// functionIndexGet returns the name of a function for the "name" index, the
+// only index of the functions.
+func functionIndexGet(name string, index Object) (Object, error) {
+	if strIdx, _ := ToString(index); strIdx != "name" {
+		return nil, ErrInvalidIndexOnFunction
+	}
+	return &String{Value: name}, nil
+}
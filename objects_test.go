@@ -1,6 +1,8 @@
 package z_test
 
 import (
+	"math"
+	"math/big"
 	"testing"
 
 	"github.com/diiyw/z"
@@ -41,6 +43,10 @@ func TestObject_TypeName(t *testing.T) {
 	require.Equal(t, "error", o.TypeName())
 	o = &z.Bytes{}
 	require.Equal(t, "bytes", o.TypeName())
+	o = &z.BigInt{}
+	require.Equal(t, "bigint", o.TypeName())
+	o = &z.Decimal{}
+	require.Equal(t, "decimal", o.TypeName())
 }
 
 func TestObject_IsFalsy(t *testing.T) {
@@ -683,6 +689,86 @@ func TestInt_BinaryOp(t *testing.T) {
 	}
 }
 
+func TestDecimal_BinaryOp(t *testing.T) {
+	d := func(s string) *z.Decimal {
+		v, err := z.ParseDecimal(s)
+		require.NoError(t, err)
+		return v
+	}
+	for _, tc := range []struct {
+		lhs      z.Object
+		op       token.Token
+		rhs      z.Object
+		expected string
+	}{
+		{d("1.10"), token.Add, d("2.205"), "3.305"},
+		{d("1.10"), token.Sub, &z.Int{Value: 2}, "-0.90"},
+		{d("1.5"), token.Mul, d("-0.20"), "-0.300"},
+		{d("1"), token.Quo, &z.Int{Value: 8}, "0.125"},
+		{d("2"), token.Quo, d("3"), "0.6666666666666667"},
+		{d("-7.5"), token.Rem, &z.Int{Value: 2}, "-1.5"},
+		{&z.Int{Value: 3}, token.Sub, d("0.5"), "2.5"},
+		{&z.Float{Value: 0.1}, token.Add, d("0.2"), "0.3"},
+		{&z.BigInt{Value: big.NewInt(2)}, token.Mul, d("1.25"), "2.50"},
+	} {
+		res, err := tc.lhs.BinaryOp(tc.op, tc.rhs)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, res.String())
+	}
+
+	testBinaryOp(t, d("1.50"), token.Less, d("1.6"), z.TrueValue)
+	testBinaryOp(t, d("1.50"), token.GreaterEq, d("1.5"), z.TrueValue)
+	testBinaryOp(t, &z.Int{Value: 2}, token.Greater, d("2.01"), z.FalseValue)
+
+	_, err := d("1").BinaryOp(token.Quo, d("0.0"))
+	require.Error(t, err)
+	_, err = d("1").BinaryOp(token.Add, &z.Float{Value: math.Inf(1)})
+	require.Error(t, err)
+	_, err = d("1").BinaryOp(token.Shl, &z.Int{Value: 1})
+	require.Error(t, err)
+}
+
+func TestBigInt_BinaryOp(t *testing.T) {
+	b := func(s string) *z.BigInt {
+		v, ok := new(big.Int).SetString(s, 10)
+		require.True(t, ok)
+		return &z.BigInt{Value: v}
+	}
+	for _, tc := range []struct {
+		lhs      z.Object
+		op       token.Token
+		rhs      z.Object
+		expected string
+	}{
+		{b("9223372036854775807"), token.Add, &z.Int{Value: 1}, "9223372036854775808"},
+		{&z.Int{Value: -3}, token.Mul, b("100000000000000000000"), "-300000000000000000000"},
+		{b("-7"), token.Quo, &z.Int{Value: 2}, "-3"},
+		{b("-7"), token.Rem, &z.Int{Value: 2}, "-1"},
+		{b("12"), token.And, b("10"), "8"},
+		{b("12"), token.Or, &z.Int{Value: 3}, "15"},
+		{b("12"), token.Xor, &z.Int{Value: 10}, "6"},
+		{b("12"), token.AndNot, &z.Int{Value: 4}, "8"},
+		{b("1"), token.Shl, &z.Int{Value: 64}, "18446744073709551616"},
+		{b("18446744073709551616"), token.Shr, &z.Int{Value: 63}, "2"},
+	} {
+		res, err := tc.lhs.BinaryOp(tc.op, tc.rhs)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, res.String())
+	}
+
+	testBinaryOp(t, b("3"), token.Mul, &z.Float{Value: 0.5}, &z.Float{Value: 1.5})
+	testBinaryOp(t, &z.Float{Value: 0.5}, token.Add, b("3"), &z.Float{Value: 3.5})
+	testBinaryOp(t, b("3"), token.Less, &z.Int{Value: 4}, z.TrueValue)
+	testBinaryOp(t, &z.Int{Value: 4}, token.LessEq, b("3"), z.FalseValue)
+
+	_, err := b("1").BinaryOp(token.Rem, &z.Int{Value: 0})
+	require.Error(t, err)
+	_, err = b("1").BinaryOp(token.Shl, &z.Int{Value: -1})
+	require.Error(t, err)
+	_, err = b("1").BinaryOp(token.Add, &z.String{Value: "1"})
+	require.Error(t, err)
+}
+
 func TestMap_Index(t *testing.T) {
 	m := &z.Map{Value: make(map[string]z.Object)}
 	k := &z.Int{Value: 1}
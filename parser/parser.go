@@ -24,6 +24,8 @@ var stmtStart = map[token.Token]bool{
 type Error struct {
 	Pos SourceFilePos
 	Msg string
+	// Source is the source line of Pos, if known.
+	Source string
 }
 
 func (e Error) Error() string {
@@ -33,12 +35,17 @@ func (e Error) Error() string {
 	return fmt.Sprintf("Parse Error: %s", e.Msg)
 }
 
+// Snippet renders the source line of the error with a caret, see Snippet.
+func (e Error) Snippet() string {
+	return Snippet(e.Source, e.Pos.Line, e.Pos.Column)
+}
+
 // ErrorList is a collection of parser errors.
 type ErrorList []*Error
 
 // Add adds a new parser error to the collection.
 func (p *ErrorList) Add(pos SourceFilePos, msg string) {
-	*p = append(*p, &Error{pos, msg})
+	*p = append(*p, &Error{Pos: pos, Msg: msg})
 }
 
 // Len returns the number of elements in the collection.
@@ -81,6 +88,15 @@ func (p ErrorList) Error() string {
 	return fmt.Sprintf("%s (and %d more errors)", p[0], len(p)-1)
 }
 
+// Snippet renders the source line of the first error with a caret, see
+// Snippet.
+func (p ErrorList) Snippet() string {
+	if len(p) == 0 {
+		return ""
+	}
+	return p[0].Snippet()
+}
+
 // Err returns an error.
 func (p ErrorList) Err() error {
 	if len(p) == 0 {
@@ -108,6 +124,7 @@ type Parser struct {
 
 // NewParser creates a Parser.
 func NewParser(file *SourceFile, src []byte, trace io.Writer) *Parser {
+	file.src = src
 	p := &Parser{
 		file:     file,
 		trace:    trace != nil,
@@ -131,6 +148,11 @@ func (p *Parser) ParseFile() (file *File, err error) {
 		}
 
 		p.errors.Sort()
+		for _, e := range p.errors {
+			if e.Pos.Filename == p.file.Name {
+				e.Source = p.file.LineSource(e.Pos.Line)
+			}
+		}
 		err = p.errors.Err()
 	}()
 
@@ -30,6 +30,27 @@ func TestParserErrorList(t *testing.T) {
 		list.Error())
 }
 
+func TestParserErrorSnippet(t *testing.T) {
+	src := "a := 1\n\tb := 2 + )\r\nc := 3"
+	fileSet := NewFileSet()
+	file := fileSet.AddFile("test", -1, len(src))
+	p := NewParser(file, []byte(src), nil)
+	_, err := p.ParseFile()
+	require.Error(t, err)
+	list := err.(ErrorList)
+	require.Equal(t, "\tb := 2 + )", list[0].Source)
+	require.Equal(t, " 2 | \tb := 2 + )\n   | \t         ^\n", list.Snippet())
+	require.Equal(t, "a := 1", file.LineSource(1))
+	require.Equal(t, "c := 3", fileSet.LineSource(file.LineStart(3)))
+	require.Equal(t, "", file.LineSource(4))
+
+	// the source of the files not parsed is unknown
+	require.Equal(t, "", fileSet.AddFile("other", -1, 10).LineSource(1))
+	require.Equal(t, "", ErrorList{}.Snippet())
+	// the caret is put right after the end of the line at most
+	require.Equal(t, " 10 | x\n    |  ^\n", Snippet("x", 10, 3))
+}
+
 func TestParseArray(t *testing.T) {
 	expectParse(t, "[1, 2, 3]", func(p pfn) []Stmt {
 		return stmts(
@@ -1,8 +1,10 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"sort"
+	"strings"
 )
 
 // SourceFilePos represents a position information in the file.
@@ -103,6 +105,19 @@ func (s *SourceFileSet) Position(p Pos) (pos SourceFilePos) {
 	return
 }
 
+// LineSource returns the source line of the position p, see
+// SourceFile.LineSource.
+func (s *SourceFileSet) LineSource(p Pos) string {
+	if p == NoPos {
+		return ""
+	}
+	f := s.file(p)
+	if f == nil {
+		return ""
+	}
+	return f.LineSource(f.position(p).Line)
+}
+
 func (s *SourceFileSet) file(p Pos) *SourceFile {
 	// common case: p is in last file
 	f := s.LastFile
@@ -140,6 +155,8 @@ type SourceFile struct {
 	// Lines contains the offset of the first character for each line
 	// (the first entry is always 0)
 	Lines []int
+	// source of the file, set by NewParser
+	src []byte
 }
 
 // Set returns SourceFileSet.
@@ -160,6 +177,25 @@ func (f *SourceFile) AddLine(offset int) {
 	}
 }
 
+// LineSource returns the source of the line, without the line terminator,
+// or "" if the source of the file is unknown. The source is known for the
+// files parsed in this process, not for the ones decoded with compiled
+// bytecode.
+func (f *SourceFile) LineSource(line int) string {
+	if line < 1 || line > len(f.Lines) || f.src == nil {
+		return ""
+	}
+	start := f.Lines[line-1]
+	if start > len(f.src) {
+		return ""
+	}
+	src := f.src[start:]
+	if end := bytes.IndexByte(src, '\n'); end >= 0 {
+		src = src[:end]
+	}
+	return string(bytes.TrimSuffix(src, []byte{'\r'}))
+}
+
 // LineStart returns the position of the first character in the line.
 func (f *SourceFile) LineStart(line int) Pos {
 	if line < 1 {
@@ -228,3 +264,31 @@ func searchInts(a []int, x int) int {
 	}
 	return i - 1
 }
+
+// Snippet renders a source line, with its line number, and a caret under the
+// column, for the error messages:
+//
+//	3 | x := 1 + {}
+//	  |        ^
+//
+// The caret is put right after the end of the line if column is past it. It
+// returns "" if source is empty.
+func Snippet(source string, line, column int) string {
+	if source == "" {
+		return ""
+	}
+	num := fmt.Sprintf(" %d ", line)
+	var b strings.Builder
+	b.WriteString(num + "| " + source + "\n")
+	b.WriteString(strings.Repeat(" ", len(num)) + "| ")
+	// keep the tabs so the caret is aligned
+	for i := 0; i < column-1 && i < len(source); i++ {
+		if source[i] == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteString("^\n")
+	return b.String()
+}
@@ -0,0 +1,351 @@
+package z
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/diiyw/z/parser"
+)
+
+// DefaultProfileInterval is the sampling interval of the profilers created
+// with a zero interval.
+const DefaultProfileInterval = 10 * time.Millisecond
+
+// Profiler attributes the time spent and the objects allocated by the VMs
+// it's set to, see VM.SetProfiler, to the script functions and the source
+// lines of their call stacks. Time is sampled: every interval, each running
+// VM charges the time elapsed and the objects allocated since the last sample
+// to its current call stack, which is only walked then. Allocations are
+// counted exactly, but their stacks are sampled with the time. Use
+// WriteProfile to write the profile in the pprof format, which
+// `go tool pprof` can render.
+//
+// Script functions are named after the name they are bound to, and the
+// anonymous ones after the position of their definition, e.g.
+// "func@lib.z:12". The main function of a VM created with NewVM is named
+// "main".
+type Profiler struct {
+	interval time.Duration
+	gen      uint64 // incremented every interval while started
+	stop     chan struct{}
+	started  time.Time
+	duration time.Duration
+
+	lock      sync.Mutex
+	funcs     map[*byte]*profileFunc
+	locations map[profileLocKey]*profileLoc
+	samples   map[string]*profileSample
+	order     []*profileSample
+}
+
+// profileFrame is a call frame of a VM.
+type profileFrame struct {
+	fn *CompiledFunction
+	ip int
+}
+
+type profileFunc struct {
+	id   uint64
+	name string
+	file string
+	line int
+}
+
+type profileLocKey struct {
+	fn  *byte
+	pos parser.Pos
+}
+
+type profileLoc struct {
+	id     uint64
+	fn     *profileFunc
+	line   int
+	column int
+}
+
+type profileSample struct {
+	locations []uint64 // leaf first
+	ticks     int64
+	allocs    int64
+}
+
+// NewProfiler creates a profiler sampling every interval, or every
+// DefaultProfileInterval if interval is zero.
+func NewProfiler(interval time.Duration) *Profiler {
+	if interval <= 0 {
+		interval = DefaultProfileInterval
+	}
+	return &Profiler{
+		interval:  interval,
+		funcs:     make(map[*byte]*profileFunc),
+		locations: make(map[profileLocKey]*profileLoc),
+		samples:   make(map[string]*profileSample),
+	}
+}
+
+// Start starts sampling time.
+func (p *Profiler) Start() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.started = time.Now()
+	go func(stop chan struct{}) {
+		t := time.NewTicker(p.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				atomic.AddUint64(&p.gen, 1)
+			case <-stop:
+				return
+			}
+		}
+	}(p.stop)
+}
+
+// Stop stops sampling time. Allocations are still counted.
+func (p *Profiler) Stop() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	p.stop = nil
+	p.duration += time.Since(p.started)
+}
+
+// ticks returns the number of intervals elapsed since Start.
+func (p *Profiler) ticks() uint64 {
+	return atomic.LoadUint64(&p.gen)
+}
+
+// add charges the time of ticks intervals and allocs allocations to the
+// call stack.
+func (p *Profiler) add(
+	fileSet *parser.SourceFileSet,
+	stack []profileFrame,
+	ticks, allocs int64,
+) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	ids := make([]uint64, 0, len(stack))
+	key := make([]byte, 0, len(stack)*binary.MaxVarintLen64)
+	for _, f := range stack {
+		loc := p.location(fileSet, f)
+		if loc == nil {
+			continue
+		}
+		ids = append(ids, loc.id)
+		key = binary.AppendUvarint(key, loc.id)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	s, ok := p.samples[string(key)]
+	if !ok {
+		s = &profileSample{locations: ids}
+		p.samples[string(key)] = s
+		p.order = append(p.order, s)
+	}
+	s.ticks += ticks
+	s.allocs += allocs
+}
+
+// location returns the location of a frame. It returns nil for the frames
+// of functions without source map, like the ones created by VM.Call.
+func (p *Profiler) location(
+	fileSet *parser.SourceFileSet,
+	f profileFrame,
+) *profileLoc {
+	if len(f.fn.Instructions) == 0 || len(f.fn.SourceMap) == 0 ||
+		fileSet == nil {
+		return nil
+	}
+	fnKey := &f.fn.Instructions[0]
+	k := profileLocKey{fn: fnKey, pos: f.fn.SourcePos(f.ip)}
+	if loc, ok := p.locations[k]; ok {
+		return loc
+	}
+
+	fn, ok := p.funcs[fnKey]
+	if !ok {
+		fn = p.newFunc(fileSet, f.fn, "")
+		p.funcs[fnKey] = fn
+	}
+	pos := fileSet.Position(k.pos)
+	loc := &profileLoc{
+		id:     uint64(len(p.locations) + 1),
+		fn:     fn,
+		line:   pos.Line,
+		column: pos.Column,
+	}
+	p.locations[k] = loc
+	return loc
+}
+
+// newFunc describes the function fn. Unless name is given, the function is
+// named after the name it's bound to, or the position of its first
+// instruction if it's anonymous.
+func (p *Profiler) newFunc(
+	fileSet *parser.SourceFileSet,
+	fn *CompiledFunction,
+	name string,
+) *profileFunc {
+	if name == "" {
+		name = fn.traceName(fileSet)
+	}
+	f := &profileFunc{id: uint64(len(p.funcs) + 1), name: name}
+	if start := fn.startPos(); start != parser.NoPos {
+		pos := fileSet.Position(start)
+		f.file = pos.Filename
+		f.line = pos.Line
+	}
+	return f
+}
+
+// nameFunc names the function fn unless it's already known.
+func (p *Profiler) nameFunc(
+	fileSet *parser.SourceFileSet,
+	fn *CompiledFunction,
+	name string,
+) {
+	if len(fn.Instructions) == 0 || fileSet == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.funcs[&fn.Instructions[0]]; !ok {
+		p.funcs[&fn.Instructions[0]] = p.newFunc(fileSet, fn, name)
+	}
+}
+
+// WriteProfile writes the profile in the gzipped protocol buffer format of
+// pprof. Its sample types are "samples" (count), "cpu" (nanoseconds), the
+// default, and "alloc_objects" (count).
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	table := []string{""}
+	stringIndex := map[string]int64{"": 0}
+	str := func(s string) int64 {
+		if idx, ok := stringIndex[s]; ok {
+			return idx
+		}
+		idx := int64(len(table))
+		table = append(table, s)
+		stringIndex[s] = idx
+		return idx
+	}
+	valueType := func(typ, unit string) []byte {
+		var b protoBuffer
+		b.int64Field(1, str(typ))
+		b.int64Field(2, str(unit))
+		return b
+	}
+
+	var b protoBuffer
+	b.bytesField(1, valueType("samples", "count"))
+	b.bytesField(1, valueType("cpu", "nanoseconds"))
+	b.bytesField(1, valueType("alloc_objects", "count"))
+	interval := p.interval.Nanoseconds()
+	for _, s := range p.order {
+		var sb protoBuffer
+		sb.packedField(1, s.locations)
+		sb.packedField(2, []uint64{uint64(s.ticks),
+			uint64(s.ticks * interval), uint64(s.allocs)})
+		b.bytesField(2, sb)
+	}
+
+	locations := make([]*profileLoc, len(p.locations))
+	for _, loc := range p.locations {
+		locations[loc.id-1] = loc
+	}
+	for _, loc := range locations {
+		var lb protoBuffer
+		lb.int64Field(1, int64(loc.fn.id))
+		lb.int64Field(2, int64(loc.line))
+		lb.int64Field(3, int64(loc.column))
+		var locb protoBuffer
+		locb.int64Field(1, int64(loc.id))
+		locb.bytesField(4, lb)
+		b.bytesField(4, locb)
+	}
+
+	funcs := make([]*profileFunc, len(p.funcs))
+	for _, fn := range p.funcs {
+		funcs[fn.id-1] = fn
+	}
+	for _, fn := range funcs {
+		var fb protoBuffer
+		fb.int64Field(1, int64(fn.id))
+		fb.int64Field(2, str(fn.name))
+		fb.int64Field(3, str(fn.name))
+		fb.int64Field(4, str(fn.file))
+		fb.int64Field(5, int64(fn.line))
+		b.bytesField(5, fb)
+	}
+
+	duration := p.duration
+	if p.stop != nil {
+		duration += time.Since(p.started)
+	}
+	periodType := valueType("cpu", "nanoseconds")
+	defaultType := str("cpu")
+	for _, s := range table {
+		b.bytesField(6, []byte(s))
+	}
+	if !p.started.IsZero() {
+		b.int64Field(9, p.started.UnixNano())
+	}
+	b.int64Field(10, duration.Nanoseconds())
+	b.bytesField(11, periodType)
+	b.int64Field(12, interval)
+	b.int64Field(14, defaultType)
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// protoBuffer encodes protocol buffer messages.
+type protoBuffer []byte
+
+func (b *protoBuffer) key(field, wireType int) {
+	*b = binary.AppendUvarint(*b, uint64(field<<3|wireType))
+}
+
+// int64Field appends a varint field, unless it's zero.
+func (b *protoBuffer) int64Field(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	b.key(field, 0)
+	*b = binary.AppendUvarint(*b, uint64(v))
+}
+
+func (b *protoBuffer) bytesField(field int, v []byte) {
+	b.key(field, 2)
+	*b = binary.AppendUvarint(*b, uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+// packedField appends a packed repeated varint field.
+func (b *protoBuffer) packedField(field int, vs []uint64) {
+	var packed []byte
+	for _, v := range vs {
+		packed = binary.AppendUvarint(packed, v)
+	}
+	b.bytesField(field, packed)
+}
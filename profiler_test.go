@@ -0,0 +1,187 @@
+package z_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/parser"
+	"github.com/diiyw/z/require"
+)
+
+const profileSrc = `fill := func(n) {
+	a := []
+	for i := 0; i < n; i++ {
+		a = append(a, [i])
+	}
+	return a
+}
+fill(10)
+calls := import("calls")
+calls.call(func() {
+	fill(5)
+})`
+
+// protoField is a field of an encoded protocol buffer message.
+type protoField struct {
+	num   int
+	value uint64
+	data  []byte
+}
+
+func decodeProto(t *testing.T, b []byte) (fields []protoField) {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		require.True(t, n > 0)
+		b = b[n:]
+		f := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.value, n = binary.Uvarint(b)
+			require.True(t, n > 0)
+			b = b[n:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			require.True(t, n > 0 && int(l) <= len(b)-n)
+			f.data = b[n : n+int(l)]
+			b = b[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type: %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return
+}
+
+func decodePacked(t *testing.T, b []byte) (values []uint64) {
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		require.True(t, n > 0)
+		values = append(values, v)
+		b = b[n:]
+	}
+	return
+}
+
+// decodeProfile returns the allocations of the samples of a pprof profile by
+// stack, the frames formatted as "function:line" and separated by spaces,
+// innermost first.
+func decodeProfile(t *testing.T, r io.Reader) (map[string]int, []string) {
+	zr, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	var table []string
+	var samples [][]uint64
+	var sampleValues [][]uint64
+	funcs := make(map[uint64]uint64)        // id: name
+	locations := make(map[uint64][2]uint64) // id: function, line
+	for _, f := range decodeProto(t, b) {
+		switch f.num {
+		case 2:
+			var locs, values []uint64
+			for _, sf := range decodeProto(t, f.data) {
+				switch sf.num {
+				case 1:
+					locs = decodePacked(t, sf.data)
+				case 2:
+					values = decodePacked(t, sf.data)
+				}
+			}
+			samples = append(samples, locs)
+			sampleValues = append(sampleValues, values)
+		case 4:
+			var id uint64
+			var line [2]uint64
+			for _, lf := range decodeProto(t, f.data) {
+				switch lf.num {
+				case 1:
+					id = lf.value
+				case 4:
+					for _, ll := range decodeProto(t, lf.data) {
+						if ll.num <= 2 {
+							line[ll.num-1] = ll.value
+						}
+					}
+				}
+			}
+			locations[id] = line
+		case 5:
+			var id, name uint64
+			for _, ff := range decodeProto(t, f.data) {
+				switch ff.num {
+				case 1:
+					id = ff.value
+				case 2:
+					name = ff.value
+				}
+			}
+			funcs[id] = name
+		case 6:
+			table = append(table, string(f.data))
+		}
+	}
+
+	allocs := make(map[string]int)
+	for i, locs := range samples {
+		var frames []string
+		for _, id := range locs {
+			loc := locations[id]
+			frames = append(frames,
+				fmt.Sprintf("%s:%d", table[funcs[loc[0]]], loc[1]))
+		}
+		require.Equal(t, 3, len(sampleValues[i]))
+		allocs[strings.Join(frames, " ")] += int(sampleValues[i][2])
+	}
+	return allocs, table
+}
+
+func TestProfiler(t *testing.T) {
+	fileSet := parser.NewFileSet()
+	srcFile := fileSet.AddFile("prof.z", -1, len(profileSrc))
+	p := parser.NewParser(srcFile, []byte(profileSrc), nil)
+	file, err := p.ParseFile()
+	require.NoError(t, err)
+	modules := z.NewModuleMap()
+	modules.AddBuiltinModule("calls", map[string]z.Object{
+		"call": &z.VMFunction{
+			Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+				return vm.Call(args[0])
+			},
+		},
+	})
+	c := z.NewCompiler(srcFile, nil, nil, modules, nil)
+	require.NoError(t, c.Compile(file))
+
+	profiler := z.NewProfiler(time.Millisecond)
+	v := z.NewVM(c.Bytecode(), nil, -1)
+	v.SetProfiler(profiler)
+	profiler.Start()
+	require.NoError(t, v.Run())
+	profiler.Stop()
+
+	var b bytes.Buffer
+	require.NoError(t, profiler.WriteProfile(&b))
+	allocs, table := decodeProfile(t, &b)
+	require.Equal(t, "", table[0])
+	for _, s := range []string{"samples", "cpu", "nanoseconds",
+		"alloc_objects", "count", "prof.z"} {
+		require.True(t, strings.Contains(strings.Join(table, "\n"), s), s)
+	}
+
+	// the allocations are counted exactly, including the ones of the closure
+	// called by VM.Call on a child VM, and charged to the sampled call
+	// stacks
+	total := 0
+	for _, n := range allocs {
+		total += n
+	}
+	require.Equal(t, 65, total)
+}
@@ -2,7 +2,6 @@ package z
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"path/filepath"
 	"sync"
@@ -19,6 +18,8 @@ type Script struct {
 	maxConstObjects  int
 	enableFileImport bool
 	importDir        string
+	moduleLoader     ModuleLoader
+	tracer           Tracer
 }
 
 // NewScript creates a Script instance with an input script.
@@ -59,6 +60,13 @@ func (s *Script) SetImports(modules ModuleGetter) {
 	s.modules = modules
 }
 
+// SetModuleLoader sets the loader used to load the modules that are not found
+// in the import modules, e.g. from an embed.FS or a database. When set, it is
+// used instead of the local file imports.
+func (s *Script) SetModuleLoader(loader ModuleLoader) {
+	s.moduleLoader = loader
+}
+
 // SetImportDir sets the initial import directory for script files.
 func (s *Script) SetImportDir(dir string) error {
 	dir, err := filepath.Abs(dir)
@@ -76,6 +84,13 @@ func (s *Script) SetMaxAllocs(n int64) {
 	s.maxAllocs = n
 }
 
+// SetTracer sets the tracer receiving the execution events of the compiled
+// script. RunContext passes its context to the tracer. It must be called
+// before Compile for the imports to be traced.
+func (s *Script) SetTracer(t Tracer) {
+	s.tracer = t
+}
+
 // SetMaxConstObjects sets the maximum number of objects in the compiled
 // constants.
 func (s *Script) SetMaxConstObjects(n int) {
@@ -106,7 +121,9 @@ func (s *Script) Compile() (*Compiled, error) {
 
 	c := NewCompiler(srcFile, symbolTable, nil, s.modules, nil)
 	c.EnableFileImport(s.enableFileImport)
+	c.EnableImportTrace(s.tracer != nil)
 	c.SetImportDir(s.importDir)
+	c.SetModuleLoader(s.moduleLoader)
 	if err := c.Compile(file); err != nil {
 		return nil, err
 	}
@@ -139,6 +156,7 @@ func (s *Script) Compile() (*Compiled, error) {
 		bytecode:      bytecode,
 		globals:       globals,
 		maxAllocs:     s.maxAllocs,
+		tracer:        s.tracer,
 	}, nil
 }
 
@@ -200,15 +218,21 @@ type Compiled struct {
 	bytecode      *Bytecode
 	globals       []Object
 	maxAllocs     int64
+	tracer        Tracer
 	lock          sync.RWMutex
 }
 
-// Run executes the compiled script in the virtual machine.
+// Run executes the compiled script in the virtual machine. The functions
+// spawned by the script are aborted when it returns.
 func (c *Compiled) Run() error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
 	v := NewVM(c.bytecode, c.globals, c.maxAllocs)
+	if c.tracer != nil {
+		v.SetTracer(context.Background(), c.tracer)
+	}
+	defer v.Abort()
 	return v.Run()
 }
 
@@ -218,18 +242,14 @@ func (c *Compiled) RunContext(ctx context.Context) (err error) {
 	defer c.lock.Unlock()
 
 	v := NewVM(c.bytecode, c.globals, c.maxAllocs)
+	if c.tracer != nil {
+		v.SetTracer(ctx, c.tracer)
+	}
 	ch := make(chan error, 1)
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				switch e := r.(type) {
-				case string:
-					ch <- errors.New(e)
-				case error:
-					ch <- e
-				default:
-					ch <- fmt.Errorf("unknown panic: %v", e)
-				}
+				ch <- panicError(r)
 			}
 		}()
 		ch <- v.Run()
@@ -241,6 +261,7 @@ func (c *Compiled) RunContext(ctx context.Context) (err error) {
 		<-ch
 		err = ctx.Err()
 	case err = <-ch:
+		v.Abort()
 	}
 	return
 }
@@ -256,6 +277,7 @@ func (c *Compiled) Clone() *Compiled {
 		bytecode:      c.bytecode,
 		globals:       make([]Object, len(c.globals)),
 		maxAllocs:     c.maxAllocs,
+		tracer:        c.tracer,
 	}
 	// copy global objects
 	for idx, g := range c.globals {
@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/diiyw/z"
@@ -366,6 +369,104 @@ func TestScriptSourceModule(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestScriptLazyModule(t *testing.T) {
+	calls := 0
+	mods := z.NewModuleMap()
+	mods.AddLazyModule("lazy", func() map[string]z.Object {
+		calls++
+		return map[string]z.Object{"answer": &z.Int{Value: 42}}
+	})
+
+	scr := z.NewScript([]byte(`out := 1`))
+	scr.SetImports(mods)
+	_, err := scr.Compile()
+	require.NoError(t, err)
+	require.Equal(t, 0, calls)
+
+	scr = z.NewScript([]byte(`mod := import("lazy"); out := mod.answer`))
+	scr.SetImports(mods)
+	c1, err := scr.Run()
+	require.NoError(t, err)
+	require.Equal(t, int64(42), c1.Get("out").Value())
+	c2, err := scr.Run()
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	// the module object is shared by the compiled scripts
+	require.True(t, c1.Get("mod").Object() == c2.Get("mod").Object())
+	require.Equal(t, "lazy", c1.Get("mod").Object().(*z.ImmutableMap).
+		Value["__module_name__"].(*z.String).Value)
+	require.NotNil(t, mods.GetBuiltinModule("lazy"))
+}
+
+func TestScriptModuleLoader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main/util.z":     {Data: []byte(`export import("./lib/math").add(1, 2)`)},
+		"main/lib/math.z": {Data: []byte(`export { add: func(a, b) { return a + b } }`)},
+		"cyclic.z":        {Data: []byte(`export import("/cyclic")`)},
+	}
+	scr := z.NewScript([]byte(`out := import("main/util")`))
+	scr.SetModuleLoader(&z.FSLoader{FS: fsys})
+	c, err := scr.Run()
+	require.NoError(t, err)
+	require.Equal(t, int64(3), c.Get("out").Value())
+
+	scr = z.NewScript([]byte(`out := import("cyclic")`))
+	scr.SetModuleLoader(&z.FSLoader{FS: fsys})
+	_, err = scr.Run()
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "cyclic module import"))
+
+	scr = z.NewScript([]byte(`out := import("missing")`))
+	scr.SetModuleLoader(&z.FSLoader{FS: fsys})
+	_, err = scr.Run()
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "module 'missing' not found"))
+
+	// chained resolution: module map first, then the loaders in order
+	mods := z.NewModuleMap()
+	mods.AddSourceModule("a", []byte(`export "map"`))
+	scr = z.NewScript([]byte(`out := import("a") + import("b") + import("c")`))
+	scr.SetImports(mods)
+	scr.SetModuleLoader(z.ChainLoader{
+		z.MapLoader{"a": []byte(`export "x"`), "b.z": []byte(`export "mem"`)},
+		z.ModuleLoaderFunc(func(name, _ string) ([]byte, string, error) {
+			if name == "c" {
+				return []byte(`export "func"`), "db:c", nil
+			}
+			return nil, "", z.ErrModuleNotFound
+		}),
+	})
+	c, err = scr.Run()
+	require.NoError(t, err)
+	require.Equal(t, "mapmemfunc", c.Get("out").Value())
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "a.z"),
+		[]byte(`export import("./b")`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "b.z"),
+		[]byte(`export "file"`), 0644))
+	scr = z.NewScript([]byte(`out := import("lib/a")`))
+	scr.SetModuleLoader(&z.FileLoader{Dir: dir})
+	c, err = scr.Run()
+	require.NoError(t, err)
+	require.Equal(t, "file", c.Get("out").Value())
+
+	// loader errors other than not found stop the resolution
+	loadErr := errors.New("connection refused")
+	scr = z.NewScript([]byte(`out := import("a")`))
+	scr.SetModuleLoader(z.ChainLoader{
+		z.ModuleLoaderFunc(func(string, string) ([]byte, string, error) {
+			return nil, "", loadErr
+		}),
+		z.MapLoader{"a": []byte(`export 1`)},
+	})
+	_, err = scr.Run()
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), loadErr.Error()))
+}
+
 func BenchmarkArrayIndex(b *testing.B) {
 	bench(b.N, `a := [1, 2, 3, 4, 5, 6, 7, 8, 9];
         for i := 0; i < 1000; i++ {
@@ -0,0 +1,577 @@
+package stdlib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/diiyw/z"
+)
+
+var argsModule = (&argsLib{}).module()
+
+// argsLib creates the args module functions, parsing args, the program
+// arguments of the script starting with the script itself, os.Args if nil.
+// The help and the usage errors are written to stdout and stderr, os.Stdout
+// and os.Stderr if nil.
+type argsLib struct {
+	args   []string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (l *argsLib) module() map[string]z.Object {
+	return map[string]z.Object{
+		"parse": &z.UserFunction{
+			Name:  "parse",
+			Value: l.parse,
+		}, // parse(spec map, args array(string)) => map
+		"usage": &z.UserFunction{
+			Name:  "usage",
+			Value: l.usage,
+		}, // usage(spec map) => string
+	}
+}
+
+// argFlag is a flag of an args spec.
+type argFlag struct {
+	key      string // key of the value in the result map
+	long     string // key with dashes instead of underscores
+	short    string
+	typ      string
+	help     string
+	def      z.Object
+	required bool
+	multiple bool
+}
+
+// argPositional is a positional argument of an args spec.
+type argPositional struct {
+	name     string
+	typ      string
+	help     string
+	def      z.Object
+	required bool
+	variadic bool
+}
+
+// argSpec is the spec map given to args.parse and args.usage.
+type argSpec struct {
+	name        string
+	description string
+	flags       []*argFlag
+	positional  []*argPositional
+}
+
+// argUsageError is an invalid command line.
+type argUsageError struct {
+	msg string
+}
+
+func (e *argUsageError) Error() string {
+	return e.msg
+}
+
+func (l *argsLib) parse(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	spec, err := l.spec(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var argv []string
+	if len(args) == 2 {
+		values, err := arrayArg(args[1], "second")
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			s, ok := z.ToString(v)
+			if !ok {
+				return nil, z.ErrInvalidArgumentType{
+					Name:     "second",
+					Expected: "array(string)",
+					Found:    v.TypeName(),
+				}
+			}
+			argv = append(argv, s)
+		}
+	} else if len(l.programArgs()) > 0 {
+		argv = l.programArgs()[1:]
+	}
+
+	res, err := spec.parse(argv)
+	var usageErr *argUsageError
+	switch {
+	case errors.Is(err, errArgsHelp):
+		_, _ = io.WriteString(writerOr(l.stdout, os.Stdout), spec.usage())
+		return nil, &ExitError{Code: 0}
+	case errors.As(err, &usageErr):
+		_, _ = fmt.Fprintf(writerOr(l.stderr, os.Stderr), "%s: %s\n\n%s",
+			spec.name, usageErr.msg, spec.usage())
+		return nil, &ExitError{Code: 2}
+	case err != nil:
+		return nil, err
+	}
+	return res, nil
+}
+
+func (l *argsLib) usage(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	spec, err := l.spec(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return &z.String{Value: spec.usage()}, nil
+}
+
+func (l *argsLib) programArgs() []string {
+	if l.args != nil {
+		return l.args
+	}
+	return os.Args
+}
+
+func writerOr(w, def io.Writer) io.Writer {
+	if w != nil {
+		return w
+	}
+	return def
+}
+
+// spec reads the spec map: its name, description, flags and positional
+// arguments. The name defaults to the base name of the script without its
+// extension.
+func (l *argsLib) spec(o z.Object) (*argSpec, error) {
+	m, ok := mapArg(o)
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "map",
+			Found:    o.TypeName(),
+		}
+	}
+	spec := &argSpec{}
+	if args := l.programArgs(); len(args) > 0 {
+		spec.name = strings.TrimSuffix(filepath.Base(args[0]),
+			filepath.Ext(args[0]))
+	}
+	if v, ok := m["name"]; ok {
+		spec.name, _ = z.ToString(v)
+	}
+	if v, ok := m["description"]; ok {
+		spec.description, _ = z.ToString(v)
+	}
+
+	shorts := map[string]bool{"h": true}
+	if v, ok := m["flags"]; ok {
+		flags, ok := mapArg(v)
+		if !ok {
+			return nil, fmt.Errorf("args: flags must be a map, found %s",
+				v.TypeName())
+		}
+		keys := make([]string, 0, len(flags))
+		for key := range flags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			opts, ok := mapArg(flags[key])
+			if !ok {
+				return nil, fmt.Errorf("args: flag %s must be a map, "+
+					"found %s", key, flags[key].TypeName())
+			}
+			f := &argFlag{key: key, long: strings.ReplaceAll(key, "_", "-")}
+			if f.long == "help" {
+				return nil, errors.New("args: the help flag is reserved")
+			}
+			f.short, _ = z.ToString(optionOr(opts, "short", z.UndefinedValue))
+			if f.short != "" {
+				if len(f.short) != 1 || shorts[f.short] {
+					return nil, fmt.Errorf("args: invalid short flag: %s",
+						f.short)
+				}
+				shorts[f.short] = true
+			}
+			if err := readArgOptions(opts, "flag "+key, &f.typ, &f.help,
+				&f.def, &f.required); err != nil {
+				return nil, err
+			}
+			f.multiple = !optionOr(opts, "multiple", z.FalseValue).IsFalsy()
+			if f.multiple && f.typ == "bool" {
+				return nil, fmt.Errorf("args: flag %s: bool flags can't be "+
+					"multiple", key)
+			}
+			spec.flags = append(spec.flags, f)
+		}
+	}
+
+	if v, ok := m["positional"]; ok {
+		values, err := arrayArg(v, "positional")
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			opts, ok := mapArg(v)
+			if !ok {
+				return nil, fmt.Errorf("args: positional argument %d must "+
+					"be a map, found %s", i, v.TypeName())
+			}
+			p := &argPositional{}
+			p.name, _ = z.ToString(optionOr(opts, "name", z.UndefinedValue))
+			if p.name == "" {
+				return nil, fmt.Errorf("args: positional argument %d has "+
+					"no name", i)
+			}
+			p.required = true
+			if err := readArgOptions(opts, "argument "+p.name, &p.typ,
+				&p.help, &p.def, &p.required); err != nil {
+				return nil, err
+			}
+			p.variadic = !optionOr(opts, "variadic", z.FalseValue).IsFalsy()
+			if i > 0 && spec.positional[i-1].variadic {
+				return nil, fmt.Errorf("args: argument %s follows the "+
+					"variadic argument %s", p.name, spec.positional[i-1].name)
+			}
+			spec.positional = append(spec.positional, p)
+		}
+	}
+	return spec, nil
+}
+
+// readArgOptions reads the type, help, default and required options of a
+// flag or a positional argument. An argument with a default is not
+// required by default.
+func readArgOptions(
+	opts map[string]z.Object,
+	what string,
+	typ, help *string,
+	def *z.Object,
+	required *bool,
+) error {
+	*typ, _ = z.ToString(optionOr(opts, "type", &z.String{Value: "string"}))
+	switch *typ {
+	case "string", "int", "float", "bool":
+	default:
+		return fmt.Errorf("args: %s: unknown type: %s", what, *typ)
+	}
+	*help, _ = z.ToString(optionOr(opts, "help", &z.String{Value: ""}))
+	if v, ok := opts["default"]; ok {
+		*def = v
+		*required = false
+	}
+	if v, ok := opts["required"]; ok {
+		*required = !v.IsFalsy()
+	}
+	return nil
+}
+
+// optionOr returns the option name, or def if it isn't set.
+func optionOr(opts map[string]z.Object, name string, def z.Object) z.Object {
+	if v, ok := opts[name]; ok {
+		return v
+	}
+	return def
+}
+
+var errArgsHelp = errors.New("help requested")
+
+func argsError(format string, a ...any) error {
+	return &argUsageError{msg: fmt.Sprintf(format, a...)}
+}
+
+// parse parses the command line arguments argv: the flags, in any order
+// with the positional arguments until "--", and the positional arguments.
+// Flags are given as "--name value", "--name=value", "-n value", "-nvalue"
+// or "-n=value"; the bool flags take no value unless given with "=", and
+// their short forms can be grouped, as in "-vx".
+func (s *argSpec) parse(argv []string) (*z.Map, error) {
+	res := &z.Map{Value: make(map[string]z.Object)}
+	byLong := make(map[string]*argFlag)
+	byShort := make(map[string]*argFlag)
+	for _, f := range s.flags {
+		byLong[f.long] = f
+		if f.short != "" {
+			byShort[f.short] = f
+		}
+	}
+	set := func(f *argFlag, name, value string) error {
+		v, err := convertArg(f.typ, value)
+		if err != nil {
+			return argsError("invalid value %q for flag %s: %s", value,
+				name, err)
+		}
+		if !f.multiple {
+			res.Value[f.key] = v
+			return nil
+		}
+		arr, _ := res.Value[f.key].(*z.Array)
+		if arr == nil {
+			arr = &z.Array{}
+			res.Value[f.key] = arr
+		}
+		arr.Value = append(arr.Value, v)
+		return nil
+	}
+
+	var positional []string
+	for i := 0; i < len(argv); i++ {
+		arg := argv[i]
+		switch {
+		case arg == "--":
+			positional = append(positional, argv[i+1:]...)
+			i = len(argv)
+		case arg == "-h" || arg == "--help":
+			return nil, errArgsHelp
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			f := byLong[name]
+			if f == nil {
+				return nil, argsError("unknown flag: --%s", name)
+			}
+			if !hasValue && f.typ == "bool" {
+				value, hasValue = "true", true
+			}
+			if !hasValue {
+				if i+1 == len(argv) {
+					return nil, argsError("flag needs a value: --%s", name)
+				}
+				i++
+				value = argv[i]
+			}
+			if err := set(f, "--"+name, value); err != nil {
+				return nil, err
+			}
+		case len(arg) > 1 && arg[0] == '-' &&
+			(byShort[arg[1:2]] != nil || !isNumber(arg)):
+			shorts := arg[1:]
+			for j := 0; j < len(shorts); j++ {
+				name := "-" + shorts[j:j+1]
+				f := byShort[shorts[j:j+1]]
+				if f == nil {
+					return nil, argsError("unknown flag: %s", name)
+				}
+				value := strings.TrimPrefix(shorts[j+1:], "=")
+				if f.typ == "bool" && (value == "" || j+1 < len(shorts) &&
+					shorts[j+1] != '=') {
+					value = "true"
+				} else {
+					if value == "" {
+						if i+1 == len(argv) {
+							return nil, argsError("flag needs a value: %s",
+								name)
+						}
+						i++
+						value = argv[i]
+					}
+					j = len(shorts)
+				}
+				if err := set(f, name, value); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	for _, f := range s.flags {
+		if _, ok := res.Value[f.key]; ok {
+			continue
+		}
+		switch {
+		case f.required:
+			return nil, argsError("missing flag: --%s", f.long)
+		case f.def != nil:
+			res.Value[f.key] = f.def
+		case f.multiple:
+			res.Value[f.key] = &z.Array{}
+		case f.typ == "bool":
+			res.Value[f.key] = z.FalseValue
+		default:
+			res.Value[f.key] = z.UndefinedValue
+		}
+	}
+
+	for _, p := range s.positional {
+		if p.variadic {
+			arr := &z.Array{}
+			for _, value := range positional {
+				v, err := convertArg(p.typ, value)
+				if err != nil {
+					return nil, argsError("invalid value %q for argument "+
+						"%s: %s", value, p.name, err)
+				}
+				arr.Value = append(arr.Value, v)
+			}
+			positional = nil
+			if len(arr.Value) == 0 && p.required {
+				return nil, argsError("missing argument: %s", p.name)
+			}
+			if len(arr.Value) == 0 && p.def != nil {
+				res.Value[p.name] = p.def
+			} else {
+				res.Value[p.name] = arr
+			}
+			continue
+		}
+		if len(positional) == 0 {
+			switch {
+			case p.required:
+				return nil, argsError("missing argument: %s", p.name)
+			case p.def != nil:
+				res.Value[p.name] = p.def
+			default:
+				res.Value[p.name] = z.UndefinedValue
+			}
+			continue
+		}
+		v, err := convertArg(p.typ, positional[0])
+		if err != nil {
+			return nil, argsError("invalid value %q for argument %s: %s",
+				positional[0], p.name, err)
+		}
+		res.Value[p.name] = v
+		positional = positional[1:]
+	}
+	if len(positional) > 0 {
+		return nil, argsError("unexpected argument: %s", positional[0])
+	}
+	return res, nil
+}
+
+// isNumber reports whether arg is a number, such as a negative number given
+// as a positional argument.
+func isNumber(arg string) bool {
+	_, err := strconv.ParseFloat(arg, 64)
+	return err == nil
+}
+
+// convertArg converts a command line value to typ.
+func convertArg(typ, value string) (z.Object, error) {
+	switch typ {
+	case "int":
+		i, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return nil, errors.New("not an int")
+		}
+		return &z.Int{Value: i}, nil
+	case "float":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, errors.New("not a float")
+		}
+		return &z.Float{Value: f}, nil
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("not a bool")
+		}
+		if b {
+			return z.TrueValue, nil
+		}
+		return z.FalseValue, nil
+	}
+	if len(value) > z.MaxStringLen {
+		return nil, z.ErrStringLimit
+	}
+	return &z.String{Value: value}, nil
+}
+
+// usage returns the help of the command line:
+//
+//	Usage: greet [options] name [files...]
+//
+//	Greets people.
+//
+//	Options:
+//	  -n, --count int   number of greetings (default 1)
+//	  -h, --help        show this help
+//
+//	Arguments:
+//	  name   who to greet
+func (s *argSpec) usage() string {
+	var b strings.Builder
+	b.WriteString("Usage: " + s.name + " [options]")
+	for _, p := range s.positional {
+		name := p.name
+		if p.variadic {
+			name += "..."
+		}
+		if !p.required {
+			name = "[" + name + "]"
+		}
+		b.WriteString(" " + name)
+	}
+	b.WriteString("\n")
+	if s.description != "" {
+		b.WriteString("\n" + s.description + "\n")
+	}
+
+	var rows [][2]string
+	for _, f := range s.flags {
+		name := "    --" + f.long
+		if f.short != "" {
+			name = "-" + f.short + ", --" + f.long
+		}
+		if f.typ != "bool" {
+			name += " " + f.typ
+		}
+		help := f.help
+		if f.def != nil {
+			def := f.def.String()
+			if s, ok := f.def.(*z.String); ok {
+				def = strconv.Quote(s.Value)
+			}
+			help = strings.TrimSpace(help + " (default " + def + ")")
+		}
+		if f.multiple {
+			help = strings.TrimSpace(help + " (repeatable)")
+		}
+		rows = append(rows, [2]string{name, help})
+	}
+	rows = append(rows, [2]string{"-h, --help", "show this help"})
+	b.WriteString("\nOptions:\n")
+	writeArgRows(&b, rows)
+
+	rows = nil
+	for _, p := range s.positional {
+		help := p.help
+		if p.def != nil {
+			help = strings.TrimSpace(help + " (default " + p.def.String() +
+				")")
+		}
+		rows = append(rows, [2]string{p.name, help})
+	}
+	if len(rows) > 0 {
+		b.WriteString("\nArguments:\n")
+		writeArgRows(&b, rows)
+	}
+	return b.String()
+}
+
+// writeArgRows writes the names and the help of the flags or arguments in
+// aligned columns.
+func writeArgRows(b *strings.Builder, rows [][2]string) {
+	width := 0
+	for _, row := range rows {
+		if len(row[0]) > width {
+			width = len(row[0])
+		}
+	}
+	for _, row := range rows {
+		line := "  " + row[0]
+		if row[1] != "" {
+			line += strings.Repeat(" ", width-len(row[0])+3) + row[1]
+		}
+		b.WriteString(line + "\n")
+	}
+}
@@ -0,0 +1,174 @@
+package stdlib_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+)
+
+const argsSpec = `{
+	name: "greet",
+	description: "Greets people.",
+	flags: {
+		count: {short: "n", type: "int", default: 1, help: "number of greetings"},
+		loud: {short: "l", type: "bool", help: "shout"},
+		tag: {short: "t", multiple: true},
+		dry_run: {type: "bool"},
+		ratio: {type: "float"}
+	},
+	positional: [
+		{name: "name", help: "who to greet"},
+		{name: "files", variadic: true, required: false}
+	]
+}`
+
+// runArgs runs src with the program arguments args and returns its out
+// variable, its error and what it wrote to stdout and stderr.
+func runArgs(
+	t *testing.T,
+	src string,
+	args ...string,
+) (out z.Object, err error, stdout, stderr string) {
+	outFile, err := os.CreateTemp(t.TempDir(), "stdout")
+	require.NoError(t, err)
+	errFile, err := os.CreateTemp(t.TempDir(), "stderr")
+	require.NoError(t, err)
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outFile, errFile
+	defer func() { os.Stdout, os.Stderr = oldStdout, oldStderr }()
+
+	s := z.NewScript([]byte(src))
+	s.SetImports(stdlib.GetModuleMapWithOptions(stdlib.Options{
+		Args: append([]string{"/bin/greet.z"}, args...),
+	}, "args", "os"))
+	c, err := s.Run()
+	if c != nil {
+		out = c.Get("out").Object()
+	}
+	_ = outFile.Close()
+	_ = errFile.Close()
+	o, rerr := os.ReadFile(outFile.Name())
+	require.NoError(t, rerr)
+	e, rerr := os.ReadFile(errFile.Name())
+	require.NoError(t, rerr)
+	return out, err, string(o), string(e)
+}
+
+func exitCode(t *testing.T, err error) int {
+	var exitErr *stdlib.ExitError
+	require.True(t, errors.As(err, &exitErr), fmt.Sprint(err))
+	return exitErr.Code
+}
+
+func TestArgsParse(t *testing.T) {
+	src := `a := import("args").parse(` + argsSpec + `)
+out := format("%v|%v|%v|%v|%v|%v|%v", a.name, a.files, a.count, a.loud,
+	a.tag, a.dry_run, a.ratio)`
+	for args, expected := range map[string]string{
+		"bob":                          `"bob"|[]|1|false|[]|false|<undefined>`,
+		"-n 3 bob a b":                 `"bob"|["a", "b"]|3|false|[]|false|<undefined>`,
+		"bob --count=2 -lt x -t=y":     `"bob"|[]|2|true|["x", "y"]|false|<undefined>`,
+		"-ln5 --dry-run --ratio -1 b":  `"b"|[]|5|true|[]|true|-1`,
+		"--loud=false -n0x10 -- -b -c": `"-b"|["-c"]|16|false|[]|false|<undefined>`,
+		"-2 -3":                        `"-2"|["-3"]|1|false|[]|false|<undefined>`,
+	} {
+		out, err, _, _ := runArgs(t, src, strings.Fields(args)...)
+		require.NoError(t, err, args)
+		s, _ := z.ToString(out)
+		require.Equal(t, expected, s, args)
+	}
+
+	// the arguments can be given to parse
+	out, err, _, _ := runArgs(t, `args := import("args")
+out := args.parse({positional: [{name: "n", type: "int", default: 7}]}, [])
+out = string(out.n) + args.parse({flags: {x: {}}}, ["--x", "y"]).x`)
+	require.NoError(t, err)
+	require.Equal(t, `"7y"`, out.String())
+}
+
+func TestArgsParseErrors(t *testing.T) {
+	src := `import("args").parse(` + argsSpec + `)`
+	_, err, stdout, _ := runArgs(t, src, "--help")
+	require.Equal(t, 0, exitCode(t, err))
+	require.Equal(t, `Usage: greet [options] name [files...]
+
+Greets people.
+
+Options:
+  -n, --count int     number of greetings (default 1)
+      --dry-run
+  -l, --loud          shout
+      --ratio float
+  -t, --tag string    (repeatable)
+  -h, --help          show this help
+
+Arguments:
+  name    who to greet
+  files
+`, stdout)
+
+	for args, msg := range map[string]string{
+		"":                "missing argument: name",
+		"a -n":            "flag needs a value: -n",
+		"a --count x":     `invalid value "x" for flag --count: not an int`,
+		"a --loud=maybe":  `invalid value "maybe" for flag --loud: not a bool`,
+		"a -x":            "unknown flag: -x",
+		"a --verbose":     "unknown flag: --verbose",
+		"a --ratio=1e":    `invalid value "1e" for flag --ratio: not a float`,
+		"a --tag":         "flag needs a value: --tag",
+		"--count=1 -- -h": "",
+	} {
+		_, err, stdout, stderr := runArgs(t, src, strings.Fields(args)...)
+		if msg == "" {
+			require.NoError(t, err, args)
+			continue
+		}
+		require.Equal(t, 2, exitCode(t, err), args)
+		require.Equal(t, "", stdout, args)
+		require.True(t, strings.HasPrefix(stderr,
+			"greet: "+msg+"\n\nUsage: greet [options]"), stderr)
+	}
+
+	_, err, _, stderr := runArgs(t,
+		`import("args").parse({positional: [{name: "a", type: "int"}]})`,
+		"1", "2")
+	require.Equal(t, 2, exitCode(t, err))
+	require.True(t, strings.HasPrefix(stderr,
+		"greet: unexpected argument: 2\n"), stderr)
+
+	// invalid specs are runtime errors
+	for _, spec := range []string{
+		`1`,
+		`{flags: []}`,
+		`{flags: {help: {}}}`,
+		`{flags: {a: {short: "h"}}}`,
+		`{flags: {a: {short: "x"}, b: {short: "x"}}}`,
+		`{flags: {a: {type: "list"}}}`,
+		`{flags: {a: {type: "bool", multiple: true}}}`,
+		`{positional: [{}]}`,
+		`{positional: [{name: "a", variadic: true}, {name: "b"}]}`,
+	} {
+		_, err, _, _ := runArgs(t, `import("args").usage(`+spec+`)`)
+		require.Error(t, err, spec)
+		var exitErr *stdlib.ExitError
+		require.False(t, errors.As(err, &exitErr), spec)
+	}
+}
+
+func TestOSExit(t *testing.T) {
+	_, err, _, _ := runArgs(t, `os := import("os")
+os.exit(3)
+out := 1`)
+	require.Equal(t, 3, exitCode(t, err))
+	require.Equal(t, "exit status 3", (&stdlib.ExitError{Code: 3}).Error())
+
+	out, err, _, _ := runArgs(t, `out := import("os").args()`, "a")
+	require.NoError(t, err)
+	require.Equal(t, `["/bin/greet.z", "a"]`, out.String())
+}
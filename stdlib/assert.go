@@ -0,0 +1,263 @@
+package stdlib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/diiyw/z"
+)
+
+// ErrAssertion is wrapped by the errors of the failed assert module
+// assertions.
+var ErrAssertion = errors.New("assertion failed")
+
+// ErrSkip is wrapped by the error of assert.skip. Test runners report tests
+// stopped by it as skipped.
+var ErrSkip = errors.New("skipped")
+
+var assertModule = map[string]z.Object{
+	"ok": &z.UserFunction{
+		Name:  "ok",
+		Value: assertOK,
+	},
+	"fail": &z.UserFunction{
+		Name:  "fail",
+		Value: assertFail,
+	},
+	"equal": &z.UserFunction{
+		Name:  "equal",
+		Value: assertEqual,
+	},
+	"not_equal": &z.UserFunction{
+		Name:  "not_equal",
+		Value: assertNotEqual,
+	},
+	"deep_equal": &z.UserFunction{
+		Name:  "deep_equal",
+		Value: assertDeepEqual,
+	},
+	"approx": &z.UserFunction{
+		Name:  "approx",
+		Value: assertApprox,
+	},
+	"raises": &z.VMFunction{
+		Name:  "raises",
+		Value: assertRaises,
+	},
+	"skip": &z.UserFunction{
+		Name:  "skip",
+		Value: assertSkip,
+	},
+}
+
+// assertError returns the error of a failed assertion. The optional message
+// given by the script, msgArgs[0], prefixes the description.
+func assertError(msgArgs []z.Object, format string, args ...any) error {
+	desc := fmt.Sprintf(format, args...)
+	if len(msgArgs) > 0 {
+		msg, ok := z.ToString(msgArgs[0])
+		if !ok {
+			return z.ErrInvalidArgumentType{
+				Name:     "message",
+				Expected: "string(compatible)",
+				Found:    msgArgs[0].TypeName(),
+			}
+		}
+		desc = msg + ": " + desc
+	}
+	return fmt.Errorf("%w: %s", ErrAssertion, desc)
+}
+
+func assertOK(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if args[0].IsFalsy() {
+		return nil, assertError(args[1:], "%s is falsy", args[0])
+	}
+	return z.UndefinedValue, nil
+}
+
+func assertFail(args ...z.Object) (z.Object, error) {
+	if len(args) > 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if len(args) == 0 {
+		return nil, ErrAssertion
+	}
+	return nil, assertError(args, "fail")
+}
+
+func assertEqual(args ...z.Object) (z.Object, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if !args[0].Equals(args[1]) {
+		return nil, assertError(args[2:], "expected %s, got %s",
+			args[1], args[0])
+	}
+	return z.UndefinedValue, nil
+}
+
+func assertNotEqual(args ...z.Object) (z.Object, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if args[0].Equals(args[1]) {
+		return nil, assertError(args[2:], "unexpected %s", args[0])
+	}
+	return z.UndefinedValue, nil
+}
+
+func assertDeepEqual(args ...z.Object) (z.Object, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if !deepEqual(args[0], args[1]) {
+		return nil, assertError(args[2:], "expected %s, got %s",
+			args[1], args[0])
+	}
+	return z.UndefinedValue, nil
+}
+
+// deepEqual is like the '==' operator, except that errors are equal if
+// their values are, and that it also applies to the elements of arrays and
+// maps.
+func deepEqual(a, b z.Object) bool {
+	switch a := a.(type) {
+	case *z.Array, *z.ImmutableArray:
+		av, _ := arrayArg(a, "")
+		bv, err := arrayArg(b, "")
+		if err != nil || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !deepEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case *z.Map, *z.ImmutableMap:
+		am, _ := mapArg(a)
+		bm, ok := mapArg(b)
+		if !ok || len(am) != len(bm) {
+			return false
+		}
+		for k, av := range am {
+			bv, ok := bm[k]
+			if !ok || !deepEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	case *z.Error:
+		b, ok := b.(*z.Error)
+		return ok && deepEqual(a.Value, b.Value)
+	}
+	return a.Equals(b)
+}
+
+func mapArg(o z.Object) (map[string]z.Object, bool) {
+	switch o := o.(type) {
+	case *z.Map:
+		return o.Value, true
+	case *z.ImmutableMap:
+		return o.Value, true
+	}
+	return nil, false
+}
+
+func assertApprox(args ...z.Object) (z.Object, error) {
+	if len(args) < 2 || len(args) > 4 {
+		return nil, z.ErrWrongNumArguments
+	}
+	names := []string{"first", "second", "third"}
+	values := []float64{0, 0, 1e-9}
+	for i := 0; i < len(args) && i < 3; i++ {
+		f, ok := z.ToFloat64(args[i])
+		if !ok {
+			return nil, z.ErrInvalidArgumentType{
+				Name:     names[i],
+				Expected: "float(compatible)",
+				Found:    args[i].TypeName(),
+			}
+		}
+		values[i] = f
+	}
+	actual, expected, tolerance := values[0], values[1], values[2]
+	if !(math.Abs(actual-expected) <= tolerance) {
+		var msgArgs []z.Object
+		if len(args) == 4 {
+			msgArgs = args[3:]
+		}
+		return nil, assertError(msgArgs, "expected %v ± %v, got %v",
+			expected, tolerance, actual)
+	}
+	return z.UndefinedValue, nil
+}
+
+// assertRaises calls fn and fails unless it stops with a runtime error or
+// returns an error value. If given, the message of the error must contain
+// substr. It returns the message of the error.
+func assertRaises(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	fn, err := funcArg(args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	var substr string
+	if len(args) == 2 {
+		s, ok := z.ToString(args[1])
+		if !ok {
+			return nil, z.ErrInvalidArgumentType{
+				Name:     "second",
+				Expected: "string(compatible)",
+				Found:    args[1].TypeName(),
+			}
+		}
+		substr = s
+	}
+
+	var msg string
+	ret, err := vm.Call(fn)
+	switch {
+	case errors.Is(err, ErrSkip):
+		return nil, err
+	case err != nil:
+		// drop the source positions of the trace
+		msg, _, _ = strings.Cut(err.Error(), "\n\tat ")
+	default:
+		e, ok := ret.(*z.Error)
+		if !ok {
+			return nil, assertError(nil, "no error raised, got %s", ret)
+		}
+		msg, _ = z.ToString(e.Value)
+	}
+	if !strings.Contains(msg, substr) {
+		return nil, assertError(nil, "expected error containing %q, got %q",
+			substr, msg)
+	}
+	return &z.String{Value: msg}, nil
+}
+
+func assertSkip(args ...z.Object) (z.Object, error) {
+	if len(args) > 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if len(args) == 0 {
+		return nil, ErrSkip
+	}
+	reason, ok := z.ToString(args[0])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "string(compatible)",
+			Found:    args[0].TypeName(),
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSkip, reason)
+}
@@ -0,0 +1,80 @@
+package stdlib_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+)
+
+func TestAssert(t *testing.T) {
+	module(t, "assert").call("ok", true).expect(z.UndefinedValue)
+	module(t, "assert").call("ok", 0).expectError()
+	module(t, "assert").call("ok").expectError()
+	module(t, "assert").call("fail").expectError()
+	module(t, "assert").call("equal", 1, 1).expect(z.UndefinedValue)
+	module(t, "assert").call("equal", 1, 2).expectError()
+	module(t, "assert").call("equal", 1, 1.0).expectError()
+	module(t, "assert").call("not_equal", 1, 2).expect(z.UndefinedValue)
+	module(t, "assert").call("not_equal", "a", "a").expectError()
+	module(t, "assert").call("deep_equal", ARR{1, MAP{"a": "b"}},
+		IARR{1, IMAP{"a": "b"}}).expect(z.UndefinedValue)
+	module(t, "assert").call("deep_equal", ARR{1}, ARR{1, 2}).expectError()
+	module(t, "assert").call("approx", 0.1, 0.1000000000001).
+		expect(z.UndefinedValue)
+	module(t, "assert").call("approx", 1, 1.5, 0.5).expect(z.UndefinedValue)
+	module(t, "assert").call("approx", 1, 1.5).expectError()
+	module(t, "assert").call("approx", "a", 1).expectError()
+	module(t, "assert").call("skip", "later").expectError()
+
+	expect(t, `
+assert := import("assert")
+out := assert.raises(func() { return 1 + {} })
+`, "invalid operation: int + map")
+	expect(t, `
+assert := import("assert")
+out := assert.raises(func() { return error("oops") }, "oop")
+`, "oops")
+	expect(t, `
+assert := import("assert")
+assert.deep_equal([error(1)], [error(1)])
+assert.approx(import("decimal").new("0.3"), 0.1 + 0.2)
+out := true
+`, true)
+	expectRuntimeError(t, `
+assert := import("assert")
+assert.raises(func() { return 1 })
+`)
+	expectRuntimeError(t, `
+assert := import("assert")
+assert.raises(func() { return error("oops") }, "other")
+`)
+	expectRuntimeError(t, `
+assert := import("assert")
+assert.equal([error(1)], [error(1)])
+`)
+}
+
+func TestAssertErrors(t *testing.T) {
+	run := func(input string) error {
+		s := z.NewScript([]byte(input))
+		s.SetImports(stdlib.GetModuleMap("assert"))
+		_, err := s.Run()
+		return err
+	}
+
+	err := run(`import("assert").equal(1 + 1, 3, "sum")`)
+	require.True(t, errors.Is(err, stdlib.ErrAssertion))
+	require.Equal(t, "Runtime Error: assertion failed: sum: expected 3, "+
+		"got 2\n\tat (main):1:1", err.Error())
+
+	err = run(`import("assert").skip("not yet")`)
+	require.True(t, errors.Is(err, stdlib.ErrSkip))
+	require.False(t, errors.Is(err, stdlib.ErrAssertion))
+
+	// skips are not caught by raises
+	err = run(`assert := import("assert"); assert.raises(assert.skip)`)
+	require.True(t, errors.Is(err, stdlib.ErrSkip))
+}
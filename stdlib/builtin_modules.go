@@ -6,13 +6,36 @@ import (
 
 // BuiltinModules are builtin type standard library modules.
 var BuiltinModules = map[string]map[string]z.Object{
-	"math":   mathModule,
-	"os":     osModule,
-	"text":   textModule,
-	"times":  timesModule,
-	"rand":   randModule,
-	"fmt":    fmtModule,
-	"json":   jsonModule,
-	"base64": base64Module,
-	"hex":    hexModule,
+	"math":        mathModule,
+	"os":          osModule,
+	"text":        textModule,
+	"times":       timesModule,
+	"rand":        randModule,
+	"fmt":         fmtModule,
+	"json":        jsonModule,
+	"base64":      base64Module,
+	"hex":         hexModule,
+	"sync":        syncModule,
+	"csv":         csvModule,
+	"yaml":        yamlModule,
+	"toml":        tomlModule,
+	"crypto":      cryptoModule,
+	"http":        httpModule,
+	"template":    templateModule,
+	"collections": collectionsModule,
+	"decimal":     decimalModule,
+	"path":        pathModule,
+	"url":         urlModule,
+	"assert":      assertModule,
+	"args":        argsModule,
+}
+
+// BuiltinModuleFactories are the constructors of the builtin modules that
+// GetModuleMap calls the first time a module is imported. The modules of
+// BuiltinModules without a constructor are imported as they are.
+var BuiltinModuleFactories = map[string]func() map[string]z.Object{
+	"os":   (&osLib{}).module,
+	"path": (&pathLib{}).module,
+	"http": (&httpLib{}).module,
+	"args": (&argsLib{}).module,
 }
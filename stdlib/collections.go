@@ -0,0 +1,237 @@
+package stdlib
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/token"
+)
+
+var collectionsModule = map[string]z.Object{
+	"sort": &z.VMFunction{
+		Name:  "sort",
+		Value: collectionsSort,
+	},
+	"sort_by": &z.VMFunction{
+		Name:  "sort_by",
+		Value: collectionsSortBy,
+	},
+	"set": &z.UserFunction{
+		Name:  "set",
+		Value: collectionsSet,
+	},
+	"deque": &z.UserFunction{
+		Name:  "deque",
+		Value: collectionsDeque,
+	},
+	"heap": &z.UserFunction{
+		Name:  "heap",
+		Value: collectionsHeap,
+	},
+}
+
+// collectionsSort returns a sorted copy of the array. The optional cmp
+// function reports whether its first argument goes before the second one.
+func collectionsSort(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	values, err := arrayArg(args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	var cmp z.Object
+	if len(args) == 2 {
+		if cmp, err = funcArg(args[1], "second"); err != nil {
+			return nil, err
+		}
+	}
+	sorted := append([]z.Object(nil), values...)
+	err = stableSort(len(sorted), func(i, j int) (bool, error) {
+		return collectionsLess(vm, cmp, sorted[i], sorted[j])
+	}, func(i, j int) {
+		sorted[i], sorted[j] = sorted[j], sorted[i]
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &z.Array{Value: sorted}, nil
+}
+
+// collectionsSortBy returns a copy of the array sorted by the keys returned
+// by the key function.
+func collectionsSortBy(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	values, err := arrayArg(args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	keyFn, err := funcArg(args[1], "second")
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]z.Object(nil), values...)
+	keys := make([]z.Object, len(sorted))
+	for i, v := range sorted {
+		if keys[i], err = vm.Call(keyFn, v); err != nil {
+			return nil, err
+		}
+	}
+	err = stableSort(len(sorted), func(i, j int) (bool, error) {
+		return collectionsLess(vm, nil, keys[i], keys[j])
+	}, func(i, j int) {
+		sorted[i], sorted[j] = sorted[j], sorted[i]
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &z.Array{Value: sorted}, nil
+}
+
+// stableSort sorts n elements with the less function, stopping at the first
+// error of less.
+func stableSort(
+	n int,
+	less func(i, j int) (bool, error),
+	swap func(i, j int),
+) error {
+	var err error
+	sort.Stable(funcSorter{n: n, swap: swap, less: func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var ok bool
+		ok, err = less(i, j)
+		return ok
+	}})
+	return err
+}
+
+type funcSorter struct {
+	n    int
+	less func(i, j int) bool
+	swap func(i, j int)
+}
+
+func (s funcSorter) Len() int           { return s.n }
+func (s funcSorter) Less(i, j int) bool { return s.less(i, j) }
+func (s funcSorter) Swap(i, j int)      { s.swap(i, j) }
+
+// collectionsLess reports whether a goes before b. The cmp function returns
+// a bool, or an int that is negative if a goes before b. Without cmp, the
+// values are compared with the '<' operator.
+func collectionsLess(vm *z.VM, cmp, a, b z.Object) (bool, error) {
+	if cmp == nil {
+		res, err := a.BinaryOp(token.Less, b)
+		if err != nil {
+			return false, fmt.Errorf("cannot compare %s and %s",
+				a.TypeName(), b.TypeName())
+		}
+		return !res.IsFalsy(), nil
+	}
+	res, err := vm.Call(cmp, a, b)
+	if err != nil {
+		return false, err
+	}
+	if i, ok := res.(*z.Int); ok {
+		return i.Value < 0, nil
+	}
+	return !res.IsFalsy(), nil
+}
+
+// arrayArg returns the elements of an array or an immutable array argument.
+func arrayArg(arg z.Object, name string) ([]z.Object, error) {
+	switch arg := arg.(type) {
+	case *z.Array:
+		return arg.Value, nil
+	case *z.ImmutableArray:
+		return arg.Value, nil
+	}
+	return nil, z.ErrInvalidArgumentType{
+		Name:     name,
+		Expected: "array",
+		Found:    arg.TypeName(),
+	}
+}
+
+// funcArg returns a callable argument.
+func funcArg(arg z.Object, name string) (z.Object, error) {
+	if !arg.CanCall() {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     name,
+			Expected: "callable",
+			Found:    arg.TypeName(),
+		}
+	}
+	return arg, nil
+}
+
+// iterableArg returns the values of an iterable argument, such as an array
+// or a set.
+func iterableArg(arg z.Object, name string) ([]z.Object, error) {
+	if values, err := arrayArg(arg, name); err == nil {
+		return values, nil
+	}
+	if !arg.CanIterate() {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     name,
+			Expected: "iterable",
+			Found:    arg.TypeName(),
+		}
+	}
+	var values []z.Object
+	for it := arg.Iterate(); it.Next(); {
+		values = append(values, it.Value())
+	}
+	return values, nil
+}
+
+// hashKey is the key of a value in a set.
+type hashKey struct {
+	kind byte
+	n    int64
+	s    string
+}
+
+// hashKeyOf returns the key of a hashable value: an int, a float, a big int,
+// a decimal, a char, a bool, a string, bytes, a time or undefined.
+func hashKeyOf(o z.Object) (hashKey, error) {
+	switch o := o.(type) {
+	case *z.Int:
+		return hashKey{kind: 'i', n: o.Value}, nil
+	case *z.Float:
+		return hashKey{kind: 'f', n: int64(math.Float64bits(o.Value))}, nil
+	case *z.BigInt:
+		return hashKey{kind: 'B', s: o.Value.String()}, nil
+	case *z.Decimal:
+		// equal decimals of different scales have the same key
+		s := o.String()
+		if strings.Contains(s, ".") {
+			s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+		}
+		return hashKey{kind: 'd', s: s}, nil
+	case *z.Char:
+		return hashKey{kind: 'c', n: int64(o.Value)}, nil
+	case *z.Bool:
+		if o.IsFalsy() {
+			return hashKey{kind: 'b'}, nil
+		}
+		return hashKey{kind: 'b', n: 1}, nil
+	case *z.String:
+		return hashKey{kind: 's', s: o.Value}, nil
+	case *z.Bytes:
+		return hashKey{kind: 'y', s: string(o.Value)}, nil
+	case *z.Time:
+		return hashKey{kind: 't', s: o.Value.UTC().Format(time.RFC3339Nano)}, nil
+	case *z.Undefined:
+		return hashKey{kind: 'u'}, nil
+	}
+	return hashKey{}, fmt.Errorf("unhashable type: %s", o.TypeName())
+}
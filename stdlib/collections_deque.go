@@ -0,0 +1,273 @@
+package stdlib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diiyw/z"
+)
+
+// Deque is a double-ended queue created by the 'deque' function of the
+// collections module. The values are stored in a ring buffer.
+type Deque struct {
+	z.ObjectImpl
+	buf     []z.Object
+	head    int
+	n       int
+	methods map[string]z.Object
+}
+
+func collectionsDeque(args ...z.Object) (z.Object, error) {
+	if len(args) > 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	d := newDeque()
+	if len(args) == 1 {
+		values, err := iterableArg(args[0], "first")
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			d.pushBack(v)
+		}
+	}
+	return d, nil
+}
+
+// TypeName returns the name of the type.
+func (o *Deque) TypeName() string {
+	return "deque"
+}
+
+func (o *Deque) String() string {
+	elements := make([]string, o.n)
+	for i := range elements {
+		elements[i] = o.at(i).String()
+	}
+	return fmt.Sprintf("deque([%s])", strings.Join(elements, ", "))
+}
+
+// Copy returns a copy of the deque.
+func (o *Deque) Copy() z.Object {
+	c := newDeque()
+	for i := 0; i < o.n; i++ {
+		c.pushBack(o.at(i).Copy())
+	}
+	return c
+}
+
+// IsFalsy returns true if the deque is empty.
+func (o *Deque) IsFalsy() bool {
+	return o.n == 0
+}
+
+// Equals returns true if the deques have equal values in the same order.
+func (o *Deque) Equals(x z.Object) bool {
+	other, ok := x.(*Deque)
+	if !ok || o.n != other.n {
+		return false
+	}
+	for i := 0; i < o.n; i++ {
+		if !o.at(i).Equals(other.at(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// IndexGet returns the value at an int index, counted from the front, or the
+// deque method identified by a string index.
+func (o *Deque) IndexGet(index z.Object) (z.Object, error) {
+	switch index := index.(type) {
+	case *z.Int:
+		if index.Value < 0 || index.Value >= int64(o.n) {
+			return z.UndefinedValue, nil
+		}
+		return o.at(int(index.Value)), nil
+	case *z.String:
+		if m, ok := o.methods[index.Value]; ok {
+			return m, nil
+		}
+		return z.UndefinedValue, nil
+	}
+	return nil, z.ErrInvalidIndexType
+}
+
+// IndexSet sets the value at an index, counted from the front.
+func (o *Deque) IndexSet(index, value z.Object) error {
+	idx, ok := z.ToInt(index)
+	if !ok {
+		return z.ErrInvalidIndexType
+	}
+	if idx < 0 || idx >= o.n {
+		return z.ErrIndexOutOfBounds
+	}
+	o.buf[(o.head+idx)%len(o.buf)] = value
+	return nil
+}
+
+// Iterate returns an iterator of the values of the deque, from the front.
+func (o *Deque) Iterate() z.Iterator {
+	return (&z.Array{Value: o.values()}).Iterate()
+}
+
+// CanIterate returns true.
+func (o *Deque) CanIterate() bool {
+	return true
+}
+
+func (o *Deque) at(i int) z.Object {
+	return o.buf[(o.head+i)%len(o.buf)]
+}
+
+func (o *Deque) values() []z.Object {
+	values := make([]z.Object, o.n)
+	for i := range values {
+		values[i] = o.at(i)
+	}
+	return values
+}
+
+func (o *Deque) grow() {
+	if o.n < len(o.buf) {
+		return
+	}
+	buf := make([]z.Object, max(8, 2*len(o.buf)))
+	for i := 0; i < o.n; i++ {
+		buf[i] = o.at(i)
+	}
+	o.buf = buf
+	o.head = 0
+}
+
+func (o *Deque) pushBack(v z.Object) {
+	o.grow()
+	o.buf[(o.head+o.n)%len(o.buf)] = v
+	o.n++
+}
+
+func (o *Deque) pushFront(v z.Object) {
+	o.grow()
+	o.head = (o.head - 1 + len(o.buf)) % len(o.buf)
+	o.buf[o.head] = v
+	o.n++
+}
+
+func (o *Deque) popBack() z.Object {
+	if o.n == 0 {
+		return z.UndefinedValue
+	}
+	idx := (o.head + o.n - 1) % len(o.buf)
+	v := o.buf[idx]
+	o.buf[idx] = nil
+	o.n--
+	return v
+}
+
+func (o *Deque) popFront() z.Object {
+	if o.n == 0 {
+		return z.UndefinedValue
+	}
+	v := o.buf[o.head]
+	o.buf[o.head] = nil
+	o.head = (o.head + 1) % len(o.buf)
+	o.n--
+	return v
+}
+
+func newDeque() *Deque {
+	d := &Deque{}
+	d.methods = map[string]z.Object{
+		// push_back(values...)
+		"push_back": &z.UserFunction{
+			Name: "push_back",
+			Value: func(args ...z.Object) (z.Object, error) {
+				for _, v := range args {
+					d.pushBack(v)
+				}
+				return z.UndefinedValue, nil
+			},
+		},
+		// push_front(values...)
+		"push_front": &z.UserFunction{
+			Name: "push_front",
+			Value: func(args ...z.Object) (z.Object, error) {
+				for _, v := range args {
+					d.pushFront(v)
+				}
+				return z.UndefinedValue, nil
+			},
+		},
+		// pop_back() => object
+		"pop_back": &z.UserFunction{
+			Name: "pop_back",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				return d.popBack(), nil
+			},
+		},
+		// pop_front() => object
+		"pop_front": &z.UserFunction{
+			Name: "pop_front",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				return d.popFront(), nil
+			},
+		},
+		// back() => object
+		"back": &z.UserFunction{
+			Name: "back",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				if d.n == 0 {
+					return z.UndefinedValue, nil
+				}
+				return d.at(d.n - 1), nil
+			},
+		},
+		// front() => object
+		"front": &z.UserFunction{
+			Name: "front",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				if d.n == 0 {
+					return z.UndefinedValue, nil
+				}
+				return d.at(0), nil
+			},
+		},
+		// len() => int
+		"len": &z.UserFunction{
+			Name: "len",
+			Value: FuncARI(func() int {
+				return d.n
+			}),
+		},
+		// clear()
+		"clear": &z.UserFunction{
+			Name: "clear",
+			Value: FuncAR(func() {
+				d.buf, d.head, d.n = nil, 0, 0
+			}),
+		},
+		// values() => array
+		"values": &z.UserFunction{
+			Name: "values",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				return &z.Array{Value: d.values()}, nil
+			},
+		},
+	}
+	return d
+}
@@ -0,0 +1,220 @@
+package stdlib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diiyw/z"
+)
+
+// Heap is a priority queue created by the 'heap' function of the collections
+// module. The smallest value, according to the comparison function of the
+// heap, is popped first.
+type Heap struct {
+	z.ObjectImpl
+	values  []z.Object
+	cmp     z.Object
+	methods map[string]z.Object
+}
+
+func collectionsHeap(args ...z.Object) (z.Object, error) {
+	if len(args) > 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	var cmp z.Object
+	if len(args) == 1 {
+		var err error
+		if cmp, err = funcArg(args[0], "first"); err != nil {
+			return nil, err
+		}
+	}
+	return newHeap(cmp), nil
+}
+
+// TypeName returns the name of the type.
+func (o *Heap) TypeName() string {
+	return "heap"
+}
+
+func (o *Heap) String() string {
+	elements := make([]string, len(o.values))
+	for i, v := range o.values {
+		elements[i] = v.String()
+	}
+	return fmt.Sprintf("heap([%s])", strings.Join(elements, ", "))
+}
+
+// Copy returns a copy of the heap.
+func (o *Heap) Copy() z.Object {
+	c := newHeap(o.cmp)
+	for _, v := range o.values {
+		c.values = append(c.values, v.Copy())
+	}
+	return c
+}
+
+// IsFalsy returns true if the heap is empty.
+func (o *Heap) IsFalsy() bool {
+	return len(o.values) == 0
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *Heap) Equals(x z.Object) bool {
+	return o == x
+}
+
+// IndexGet returns the heap method identified by the index.
+func (o *Heap) IndexGet(index z.Object) (z.Object, error) {
+	name, ok := index.(*z.String)
+	if !ok {
+		return nil, z.ErrInvalidIndexType
+	}
+	if m, ok := o.methods[name.Value]; ok {
+		return m, nil
+	}
+	return z.UndefinedValue, nil
+}
+
+// Iterate returns an iterator of the values of the heap, in no particular
+// order.
+func (o *Heap) Iterate() z.Iterator {
+	return (&z.Array{Value: append([]z.Object(nil), o.values...)}).Iterate()
+}
+
+// CanIterate returns true.
+func (o *Heap) CanIterate() bool {
+	return true
+}
+
+func (o *Heap) less(vm *z.VM, i, j int) (bool, error) {
+	return collectionsLess(vm, o.cmp, o.values[i], o.values[j])
+}
+
+func (o *Heap) push(vm *z.VM, v z.Object) error {
+	o.values = append(o.values, v)
+	for j := len(o.values) - 1; j > 0; {
+		i := (j - 1) / 2
+		less, err := o.less(vm, j, i)
+		if err != nil {
+			return err
+		}
+		if !less {
+			break
+		}
+		o.values[i], o.values[j] = o.values[j], o.values[i]
+		j = i
+	}
+	return nil
+}
+
+func (o *Heap) pop(vm *z.VM) (z.Object, error) {
+	n := len(o.values) - 1
+	if n < 0 {
+		return z.UndefinedValue, nil
+	}
+	top := o.values[0]
+	o.values[0] = o.values[n]
+	o.values[n] = nil
+	o.values = o.values[:n]
+	for i := 0; ; {
+		j := 2*i + 1
+		if j >= n {
+			break
+		}
+		if j+1 < n {
+			less, err := o.less(vm, j+1, j)
+			if err != nil {
+				return nil, err
+			}
+			if less {
+				j++
+			}
+		}
+		less, err := o.less(vm, j, i)
+		if err != nil {
+			return nil, err
+		}
+		if !less {
+			break
+		}
+		o.values[i], o.values[j] = o.values[j], o.values[i]
+		i = j
+	}
+	return top, nil
+}
+
+func newHeap(cmp z.Object) *Heap {
+	h := &Heap{cmp: cmp}
+	h.methods = map[string]z.Object{
+		// push(values...)
+		"push": &z.VMFunction{
+			Name: "push",
+			Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+				for _, v := range args {
+					if err := h.push(vm, v); err != nil {
+						return nil, err
+					}
+				}
+				return z.UndefinedValue, nil
+			},
+		},
+		// pop() => object
+		"pop": &z.VMFunction{
+			Name: "pop",
+			Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				return h.pop(vm)
+			},
+		},
+		// peek() => object
+		"peek": &z.UserFunction{
+			Name: "peek",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				if len(h.values) == 0 {
+					return z.UndefinedValue, nil
+				}
+				return h.values[0], nil
+			},
+		},
+		// len() => int
+		"len": &z.UserFunction{
+			Name: "len",
+			Value: FuncARI(func() int {
+				return len(h.values)
+			}),
+		},
+		// clear()
+		"clear": &z.UserFunction{
+			Name: "clear",
+			Value: FuncAR(func() {
+				h.values = nil
+			}),
+		},
+		// values() => array
+		"values": &z.VMFunction{
+			Name: "values",
+			Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				sorted := append([]z.Object(nil), h.values...)
+				err := stableSort(len(sorted), func(i, j int) (bool, error) {
+					return collectionsLess(vm, h.cmp, sorted[i], sorted[j])
+				}, func(i, j int) {
+					sorted[i], sorted[j] = sorted[j], sorted[i]
+				})
+				if err != nil {
+					return nil, err
+				}
+				return &z.Array{Value: sorted}, nil
+			},
+		},
+	}
+	return h
+}
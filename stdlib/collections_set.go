@@ -0,0 +1,314 @@
+package stdlib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/token"
+)
+
+// Set is a set of hashable values created by the 'set' function of the
+// collections module. The values are iterated in insertion order.
+type Set struct {
+	z.ObjectImpl
+	values  []z.Object
+	index   map[hashKey]int
+	methods map[string]z.Object
+}
+
+func collectionsSet(args ...z.Object) (z.Object, error) {
+	if len(args) > 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	s := newSet()
+	if len(args) == 1 {
+		values, err := iterableArg(args[0], "first")
+		if err != nil {
+			return nil, err
+		}
+		if err := s.add(values...); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// TypeName returns the name of the type.
+func (o *Set) TypeName() string {
+	return "set"
+}
+
+func (o *Set) String() string {
+	elements := make([]string, len(o.values))
+	for i, v := range o.values {
+		elements[i] = v.String()
+	}
+	return fmt.Sprintf("set([%s])", strings.Join(elements, ", "))
+}
+
+// BinaryOp returns the union ('|'), the intersection ('&'), the difference
+// ('-') or the symmetric difference ('^') of two sets.
+func (o *Set) BinaryOp(op token.Token, rhs z.Object) (z.Object, error) {
+	other, ok := rhs.(*Set)
+	if !ok {
+		return nil, z.ErrInvalidOperator
+	}
+	res := newSet()
+	switch op {
+	case token.Or:
+		_ = res.add(o.values...)
+		_ = res.add(other.values...)
+	case token.And:
+		for _, v := range o.values {
+			if other.has(v) {
+				_ = res.add(v)
+			}
+		}
+	case token.Sub:
+		for _, v := range o.values {
+			if !other.has(v) {
+				_ = res.add(v)
+			}
+		}
+	case token.Xor:
+		for _, v := range o.values {
+			if !other.has(v) {
+				_ = res.add(v)
+			}
+		}
+		for _, v := range other.values {
+			if !o.has(v) {
+				_ = res.add(v)
+			}
+		}
+	default:
+		return nil, z.ErrInvalidOperator
+	}
+	return res, nil
+}
+
+// Copy returns a copy of the set.
+func (o *Set) Copy() z.Object {
+	c := newSet()
+	for _, v := range o.values {
+		_ = c.add(v.Copy())
+	}
+	return c
+}
+
+// IsFalsy returns true if the set is empty.
+func (o *Set) IsFalsy() bool {
+	return len(o.values) == 0
+}
+
+// Equals returns true if the sets have the same values.
+func (o *Set) Equals(x z.Object) bool {
+	other, ok := x.(*Set)
+	if !ok || len(o.values) != len(other.values) {
+		return false
+	}
+	for _, v := range o.values {
+		if !other.has(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// IndexGet returns the set method identified by the index.
+func (o *Set) IndexGet(index z.Object) (z.Object, error) {
+	name, ok := index.(*z.String)
+	if !ok {
+		return nil, z.ErrInvalidIndexType
+	}
+	if m, ok := o.methods[name.Value]; ok {
+		return m, nil
+	}
+	return z.UndefinedValue, nil
+}
+
+// Iterate returns an iterator of the values of the set.
+func (o *Set) Iterate() z.Iterator {
+	return (&z.Array{Value: append([]z.Object(nil), o.values...)}).Iterate()
+}
+
+// CanIterate returns true.
+func (o *Set) CanIterate() bool {
+	return true
+}
+
+func (o *Set) has(v z.Object) bool {
+	key, err := hashKeyOf(v)
+	if err != nil {
+		return false
+	}
+	_, ok := o.index[key]
+	return ok
+}
+
+func (o *Set) add(values ...z.Object) error {
+	for _, v := range values {
+		key, err := hashKeyOf(v)
+		if err != nil {
+			return err
+		}
+		if _, ok := o.index[key]; !ok {
+			o.index[key] = len(o.values)
+			o.values = append(o.values, v)
+		}
+	}
+	return nil
+}
+
+func (o *Set) remove(v z.Object) (bool, error) {
+	key, err := hashKeyOf(v)
+	if err != nil {
+		return false, err
+	}
+	idx, ok := o.index[key]
+	if !ok {
+		return false, nil
+	}
+	delete(o.index, key)
+	o.values = append(o.values[:idx], o.values[idx+1:]...)
+	for i := idx; i < len(o.values); i++ {
+		k, _ := hashKeyOf(o.values[i])
+		o.index[k] = i
+	}
+	return true, nil
+}
+
+// sets returns the sets of the values of the iterable arguments.
+func sets(args []z.Object) ([]*Set, error) {
+	res := make([]*Set, len(args))
+	for i, arg := range args {
+		if s, ok := arg.(*Set); ok {
+			res[i] = s
+			continue
+		}
+		values, err := iterableArg(arg, fmt.Sprintf("#%d", i+1))
+		if err != nil {
+			return nil, err
+		}
+		res[i] = newSet()
+		if err := res[i].add(values...); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func newSet() *Set {
+	s := &Set{index: make(map[hashKey]int)}
+	// setOp returns a method applying the binary operator to the set and
+	// the arguments.
+	setOp := func(name string, op token.Token) *z.UserFunction {
+		return &z.UserFunction{
+			Name: name,
+			Value: func(args ...z.Object) (z.Object, error) {
+				others, err := sets(args)
+				if err != nil {
+					return nil, err
+				}
+				var res z.Object = s.Copy()
+				for _, other := range others {
+					if res, err = res.BinaryOp(op, other); err != nil {
+						return nil, err
+					}
+				}
+				return res, nil
+			},
+		}
+	}
+	s.methods = map[string]z.Object{
+		// add(values...)
+		"add": &z.UserFunction{
+			Name: "add",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if err := s.add(args...); err != nil {
+					return nil, err
+				}
+				return z.UndefinedValue, nil
+			},
+		},
+		// remove(value) => bool
+		"remove": &z.UserFunction{
+			Name: "remove",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 1 {
+					return nil, z.ErrWrongNumArguments
+				}
+				removed, err := s.remove(args[0])
+				if err != nil {
+					return nil, err
+				}
+				return z.FromInterface(removed)
+			},
+		},
+		// contains(value) => bool
+		"contains": &z.UserFunction{
+			Name: "contains",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 1 {
+					return nil, z.ErrWrongNumArguments
+				}
+				if _, err := hashKeyOf(args[0]); err != nil {
+					return nil, err
+				}
+				return z.FromInterface(s.has(args[0]))
+			},
+		},
+		// is_subset(other) => bool
+		"is_subset": &z.UserFunction{
+			Name: "is_subset",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 1 {
+					return nil, z.ErrWrongNumArguments
+				}
+				others, err := sets(args)
+				if err != nil {
+					return nil, err
+				}
+				for _, v := range s.values {
+					if !others[0].has(v) {
+						return z.FalseValue, nil
+					}
+				}
+				return z.TrueValue, nil
+			},
+		},
+		// union(others...) => set
+		"union": setOp("union", token.Or),
+		// intersection(others...) => set
+		"intersection": setOp("intersection", token.And),
+		// difference(others...) => set
+		"difference": setOp("difference", token.Sub),
+		// len() => int
+		"len": &z.UserFunction{
+			Name: "len",
+			Value: FuncARI(func() int {
+				return len(s.values)
+			}),
+		},
+		// clear()
+		"clear": &z.UserFunction{
+			Name: "clear",
+			Value: FuncAR(func() {
+				s.values = nil
+				s.index = make(map[hashKey]int)
+			}),
+		},
+		// values() => array
+		"values": &z.UserFunction{
+			Name: "values",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				return &z.Array{Value: append([]z.Object(nil), s.values...)}, nil
+			},
+		},
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package stdlib_test
+
+import (
+	"testing"
+)
+
+func TestCollectionsSort(t *testing.T) {
+	expect(t, `
+c := import("collections")
+a := [3, 1, 2]
+out := [c.sort(a), a, c.sort(["b", "c", "a"], func(x, y) { return x > y }),
+	c.sort([2, 3, 1], func(x, y) { return x - y }), c.sort([])]
+out = string(out)
+`, `[[1, 2, 3], [3, 1, 2], ["c", "b", "a"], [1, 2, 3], []]`)
+
+	// sorting is stable
+	expect(t, `
+c := import("collections")
+people := [{n: "b", a: 30}, {n: "a", a: 25}, {n: "c", a: 30}, {n: "d", a: 25}]
+out := ""
+for p in c.sort_by(people, func(p) { return p.a }) { out += p.n }
+for p in c.sort(people, func(x, y) { return x.a > y.a }) { out += p.n }
+`, "adbcbcad")
+
+	expectRuntimeError(t, `import("collections").sort([1, "a"])`)
+	expectRuntimeError(t, `import("collections").sort(1)`)
+	expectRuntimeError(t, `import("collections").sort([1, 2], 1)`)
+	expectRuntimeError(t, `import("collections").sort_by([1, 2])`)
+}
+
+func TestCollectionsSet(t *testing.T) {
+	expect(t, `
+c := import("collections")
+s := c.set([1, 2, 3, 2])
+t := c.set([3, 4])
+out := [string(s), string(s | t), string(s & t), string(s - t), string(s ^ t),
+	s.contains(2), s.contains("2"), s.len(), s == c.set([3, 2, 1]), s == t,
+	string(s.union([5], t)), string(s.intersection(t)), string(s.difference([1])),
+	s.is_subset(s | t), s.is_subset(t), c.set() ? 1 : 0]
+out = string(out)
+`, `["set([1, 2, 3])", "set([1, 2, 3, 4])", "set([3])", "set([1, 2])", `+
+		`"set([1, 2, 4])", true, false, 3, true, false, "set([1, 2, 3, 5, 4])", `+
+		`"set([3])", "set([2, 3])", true, false, 0]`)
+
+	expect(t, `
+c := import("collections")
+s := c.set(["a", 'a', 1, 1.0, true, undefined, bytes("a")])
+out := [s.len(), s.remove(1), s.remove(1), s.len()]
+s.add("b", "a")
+cp := copy(s)
+cp.clear()
+for x in s { out = append(out, type_name(x)) }
+out = append(out, s.len(), cp.len())
+out = string(out)
+`, `[7, true, false, 6, "string", "char", "float", "bool", "undefined", `+
+		`"bytes", "string", 7, 0]`)
+
+	expect(t, `
+c := import("collections")
+dec := import("decimal")
+s := c.set([dec.new("1.50"), dec.new("1.5"), dec.new(15), dec.bigint(15), 15])
+out := [s.len(), s.contains(dec.new("1.500")), s.contains(dec.bigint(15))]
+out = string(out)
+`, `[4, true, true]`)
+
+	expectRuntimeError(t, `import("collections").set([[1]])`)
+	expectRuntimeError(t, `import("collections").set().add({})`)
+	expectRuntimeError(t, `import("collections").set() | [1]`)
+	expectRuntimeError(t, `import("collections").set(1)`)
+}
+
+func TestCollectionsDeque(t *testing.T) {
+	expect(t, `
+c := import("collections")
+d := c.deque([1, 2])
+d.push_front(0)
+d.push_back(3, 4)
+out := [string(d), d.pop_front(), d.pop_back(), d.front(), d.back(), d[1],
+	d[5], d.len()]
+d[0] = 10
+for i := 0; i < 20; i++ { d.push_front(i) }
+out = append(out, d.len(), d.front(), d.back(), d[20])
+d.clear()
+out = append(out, d.pop_front(), d.pop_back(), d.front(), d ? 1 : 0)
+out = string(out)
+`, `["deque([0, 1, 2, 3, 4])", 0, 4, 1, 3, 2, <undefined>, 3, 23, 19, 3, 10, `+
+		`<undefined>, <undefined>, <undefined>, 0]`)
+
+	expect(t, `
+c := import("collections")
+d := c.deque(c.set([1, 2]))
+out := [d == c.deque([1, 2]), d == c.deque([2, 1]), d.values()]
+out = string(out)
+`, `[true, false, [1, 2]]`)
+
+	expectRuntimeError(t, `import("collections").deque()[0] = 1`)
+}
+
+func TestCollectionsHeap(t *testing.T) {
+	expect(t, `
+c := import("collections")
+h := c.heap()
+h.push(5, 1, 4, 2, 3, 2)
+out := [h.values(), h.peek(), h.len()]
+for h.len() > 0 { out = append(out, h.pop()) }
+out = append(out, h.pop(), h.peek())
+out = string(out)
+`, `[[1, 2, 2, 3, 4, 5], 1, 6, 1, 2, 2, 3, 4, 5, <undefined>, <undefined>]`)
+
+	expect(t, `
+c := import("collections")
+h := c.heap(func(a, b) { return a.p > b.p })
+h.push({p: 1, v: "low"}, {p: 9, v: "high"}, {p: 5, v: "mid"})
+out := [h.pop().v, h.pop().v, h.pop().v]
+out = string(out)
+`, `["high", "mid", "low"]`)
+
+	expectRuntimeError(t, `h := import("collections").heap(); h.push(1, "a")`)
+	expectRuntimeError(t, `import("collections").heap(1)`)
+}
@@ -0,0 +1,187 @@
+package stdlib
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
+	"math/big"
+
+	"github.com/diiyw/z"
+)
+
+var cryptoModule = map[string]z.Object{
+	"md5": &z.UserFunction{
+		Name:  "md5",
+		Value: cryptoDigest(md5.New),
+	},
+	"sha1": &z.UserFunction{
+		Name:  "sha1",
+		Value: cryptoDigest(sha1.New),
+	},
+	"sha256": &z.UserFunction{
+		Name:  "sha256",
+		Value: cryptoDigest(sha256.New),
+	},
+	"sha512": &z.UserFunction{
+		Name:  "sha512",
+		Value: cryptoDigest(sha512.New),
+	},
+	"crc32": &z.UserFunction{
+		Name: "crc32",
+		Value: cryptoDigest(func() hash.Hash {
+			return crc32.NewIEEE()
+		}),
+	},
+	"fnv": &z.UserFunction{
+		Name: "fnv",
+		Value: cryptoDigest(func() hash.Hash {
+			return fnv.New64a()
+		}),
+	},
+	"hmac": &z.UserFunction{
+		Name:  "hmac",
+		Value: cryptoHMAC,
+	},
+	"compare": &z.UserFunction{
+		Name:  "compare",
+		Value: cryptoCompare,
+	},
+	"random_bytes": &z.UserFunction{
+		Name:  "random_bytes",
+		Value: cryptoRandomBytes,
+	},
+	"random_int": &z.UserFunction{
+		Name:  "random_int",
+		Value: cryptoRandomInt,
+	},
+	"hex": &z.UserFunction{
+		Name:  "hex",
+		Value: FuncAYRS(hex.EncodeToString),
+	},
+	"base64": &z.UserFunction{
+		Name:  "base64",
+		Value: FuncAYRS(base64.StdEncoding.EncodeToString),
+	},
+}
+
+// cryptoHashes are the hash algorithms of hmac.
+var cryptoHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// cryptoDigest returns a function computing the digest of its argument.
+func cryptoDigest(newHash func() hash.Hash) z.CallableFunc {
+	return func(args ...z.Object) (ret z.Object, err error) {
+		if len(args) != 1 {
+			return nil, z.ErrWrongNumArguments
+		}
+		data, err := dataArg(args[0], "first")
+		if err != nil {
+			return nil, err
+		}
+		h := newHash()
+		h.Write(data)
+		return &z.Bytes{Value: h.Sum(nil)}, nil
+	}
+}
+
+func cryptoHMAC(args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 3 {
+		return nil, z.ErrWrongNumArguments
+	}
+	alg, ok := z.ToString(args[0])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "string(compatible)",
+			Found:    args[0].TypeName(),
+		}
+	}
+	newHash, ok := cryptoHashes[alg]
+	if !ok {
+		return nil, fmt.Errorf("unknown hmac algorithm: %s", alg)
+	}
+	key, err := dataArg(args[1], "second")
+	if err != nil {
+		return nil, err
+	}
+	data, err := dataArg(args[2], "third")
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(newHash, key)
+	mac.Write(data)
+	return &z.Bytes{Value: mac.Sum(nil)}, nil
+}
+
+func cryptoCompare(args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	a, err := dataArg(args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	b, err := dataArg(args[1], "second")
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(a, b) == 1 {
+		return z.TrueValue, nil
+	}
+	return z.FalseValue, nil
+}
+
+func cryptoRandomBytes(args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	n, ok := z.ToInt(args[0])
+	if !ok || n < 0 {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "non-negative int(compatible)",
+			Found:    args[0].TypeName(),
+		}
+	}
+	if n > z.MaxBytesLen {
+		return nil, z.ErrBytesLimit
+	}
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return wrapError(err), nil
+	}
+	return &z.Bytes{Value: b}, nil
+}
+
+func cryptoRandomInt(args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	n, ok := z.ToInt64(args[0])
+	if !ok || n <= 0 {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "positive int(compatible)",
+			Found:    args[0].TypeName(),
+		}
+	}
+	v, err := rand.Int(rand.Reader, big.NewInt(n))
+	if err != nil {
+		return wrapError(err), nil
+	}
+	return &z.Int{Value: v.Int64()}, nil
+}
@@ -0,0 +1,52 @@
+package stdlib_test
+
+import (
+	"testing"
+
+	"github.com/diiyw/z"
+)
+
+func TestCrypto(t *testing.T) {
+	digest := func(fn, data, expected string) {
+		t.Helper()
+		expect(t, `
+crypto := import("crypto")
+out := crypto.hex(crypto.`+fn+`("`+data+`"))
+`, expected)
+	}
+	digest("md5", "abc", "900150983cd24fb0d6963f7d28e17f72")
+	digest("sha1", "abc", "a9993e364706816aba3e25717850c26c9cd0d89d")
+	digest("sha256", "abc",
+		"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")
+	digest("sha512", "",
+		"cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce"+
+			"47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e")
+	digest("crc32", "abc", "352441c2")
+	digest("fnv", "abc", "e71fa2190541574b")
+
+	module(t, "crypto").call("md5", []byte("abc")).
+		expect([]byte{0x90, 0x01, 0x50, 0x98, 0x3c, 0xd2, 0x4f, 0xb0,
+			0xd6, 0x96, 0x3f, 0x7d, 0x28, 0xe1, 0x7f, 0x72})
+	module(t, "crypto").call("md5", 1).expectError()
+	module(t, "crypto").call("base64", "hi").expect("aGk=")
+
+	expect(t, `
+crypto := import("crypto")
+out := crypto.hex(crypto.hmac("sha256", "key", "The quick brown fox jumps over the lazy dog"))
+`, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8")
+	module(t, "crypto").call("hmac", "sha3", "key", "data").expectError()
+
+	module(t, "crypto").call("compare", "abc", []byte("abc")).expect(true)
+	module(t, "crypto").call("compare", "abc", "abd").expect(false)
+	module(t, "crypto").call("compare", "abc", "ab").expect(false)
+
+	expect(t, `
+crypto := import("crypto")
+b := crypto.random_bytes(16)
+n := crypto.random_int(10)
+out := len(b) == 16 && len(crypto.random_bytes(0)) == 0 && n >= 0 && n < 10
+`, true)
+	module(t, "crypto").call("random_bytes", -1).expectError()
+	module(t, "crypto").call("random_bytes", z.MaxBytesLen+1).expectError()
+	module(t, "crypto").call("random_int", 0).expectError()
+}
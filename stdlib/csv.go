@@ -0,0 +1,560 @@
+package stdlib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/diiyw/z"
+)
+
+var csvModule = map[string]z.Object{
+	"reader": &z.VMFunction{
+		Name:  "reader",
+		Value: csvNewReader,
+	},
+	"writer": &z.VMFunction{
+		Name:  "writer",
+		Value: csvNewWriter,
+	},
+	"decode": &z.UserFunction{
+		Name:  "decode",
+		Value: csvDecode,
+	},
+	"encode": &z.UserFunction{
+		Name:  "encode",
+		Value: csvEncode,
+	},
+}
+
+// csvOptions are the options of the readers and the writers.
+type csvOptions struct {
+	delimiter        rune
+	comment          rune
+	lazyQuotes       bool
+	trimLeadingSpace bool
+	fieldsPerRecord  int
+	useCRLF          bool
+	// header reports whether the rows are maps keyed by the header fields.
+	header bool
+	// columns are the header fields given as option.
+	columns []string
+}
+
+func csvOptionsArg(args []z.Object, idx int) (*csvOptions, error) {
+	opts := &csvOptions{delimiter: ','}
+	if len(args) <= idx {
+		return opts, nil
+	}
+	var m map[string]z.Object
+	switch arg := args[idx].(type) {
+	case *z.Map:
+		m = arg.Value
+	case *z.ImmutableMap:
+		m = arg.Value
+	default:
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "options",
+			Expected: "map",
+			Found:    args[idx].TypeName(),
+		}
+	}
+	for key, value := range m {
+		var err error
+		switch key {
+		case "delimiter":
+			opts.delimiter, err = csvRuneOption(key, value)
+		case "comment":
+			opts.comment, err = csvRuneOption(key, value)
+		case "lazy_quotes":
+			opts.lazyQuotes = !value.IsFalsy()
+		case "trim_leading_space":
+			opts.trimLeadingSpace = !value.IsFalsy()
+		case "use_crlf":
+			opts.useCRLF = !value.IsFalsy()
+		case "fields_per_record":
+			n, ok := z.ToInt(value)
+			if !ok {
+				return nil, z.ErrInvalidArgumentType{
+					Name:     key,
+					Expected: "int(compatible)",
+					Found:    value.TypeName(),
+				}
+			}
+			opts.fieldsPerRecord = n
+		case "header":
+			switch value := value.(type) {
+			case *z.Array:
+				opts.columns, err = stringArray(value.Value, key)
+				opts.header = true
+			case *z.ImmutableArray:
+				opts.columns, err = stringArray(value.Value, key)
+				opts.header = true
+			default:
+				opts.header = !value.IsFalsy()
+			}
+		default:
+			return nil, fmt.Errorf("unknown csv option: %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+func csvRuneOption(name string, value z.Object) (rune, error) {
+	switch value := value.(type) {
+	case *z.Char:
+		return value.Value, nil
+	case *z.String:
+		if utf8.RuneCountInString(value.Value) == 1 {
+			r, _ := utf8.DecodeRuneInString(value.Value)
+			return r, nil
+		}
+	}
+	return 0, z.ErrInvalidArgumentType{
+		Name:     name,
+		Expected: "char",
+		Found:    value.TypeName(),
+	}
+}
+
+// csvReader is a CSV reader. Iterating over the reader reads the remaining
+// rows.
+type csvReader struct {
+	z.ObjectImpl
+	r         *csv.Reader
+	objReader *objectReader
+	opts      *csvOptions
+	header    []string
+	row       int
+	methods   map[string]z.Object
+}
+
+func newCSVReader(
+	r io.Reader,
+	objReader *objectReader,
+	opts *csvOptions,
+) *csvReader {
+	cr := csv.NewReader(r)
+	cr.Comma = opts.delimiter
+	cr.Comment = opts.comment
+	cr.LazyQuotes = opts.lazyQuotes
+	cr.TrimLeadingSpace = opts.trimLeadingSpace
+	cr.FieldsPerRecord = opts.fieldsPerRecord
+	o := &csvReader{
+		r:         cr,
+		objReader: objReader,
+		opts:      opts,
+		header:    opts.columns,
+	}
+	o.methods = map[string]z.Object{
+		// read() => array/map/undefined/error
+		"read": o.method("read", func() (z.Object, error) {
+			row, err := o.next()
+			if err == io.EOF {
+				return z.UndefinedValue, nil
+			}
+			return row, err
+		}),
+		// read_all() => array/error
+		"read_all": o.method("read_all", o.readAll),
+		// header() => array/undefined
+		"header": &z.UserFunction{
+			Name: "header",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				if o.header == nil {
+					return z.UndefinedValue, nil
+				}
+				arr := make([]z.Object, len(o.header))
+				for idx, name := range o.header {
+					arr[idx] = &z.String{Value: name}
+				}
+				return &z.Array{Value: arr}, nil
+			},
+		},
+		// offset() => int
+		"offset": &z.UserFunction{
+			Name:  "offset",
+			Value: FuncARI64(cr.InputOffset),
+		},
+	}
+	return o
+}
+
+// method returns a reader method calling fn.
+func (o *csvReader) method(
+	name string,
+	fn func() (z.Object, error),
+) *z.VMFunction {
+	return boundMethod(name, o.objReader, nil,
+		func(args ...z.Object) (z.Object, error) {
+			if len(args) != 0 {
+				return nil, z.ErrWrongNumArguments
+			}
+			return fn()
+		})
+}
+
+// next reads the next row. It returns io.EOF at the end of the input.
+func (o *csvReader) next() (z.Object, error) {
+	if o.opts.header && o.header == nil {
+		header, err := o.r.Read()
+		if err != nil {
+			return nil, err
+		}
+		o.header = header
+	}
+	record, err := o.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	o.row++
+	return o.makeRow(record)
+}
+
+func (o *csvReader) makeRow(record []string) (z.Object, error) {
+	if o.opts.header {
+		m := make(map[string]z.Object, len(o.header))
+		for idx, field := range record {
+			if idx >= len(o.header) {
+				break
+			}
+			if len(field) > z.MaxStringLen {
+				return nil, z.ErrStringLimit
+			}
+			m[o.header[idx]] = &z.String{Value: field}
+		}
+		return &z.Map{Value: m}, nil
+	}
+	arr := make([]z.Object, len(record))
+	for idx, field := range record {
+		if len(field) > z.MaxStringLen {
+			return nil, z.ErrStringLimit
+		}
+		arr[idx] = &z.String{Value: field}
+	}
+	return &z.Array{Value: arr}, nil
+}
+
+func (o *csvReader) readAll() (z.Object, error) {
+	rows := []z.Object{}
+	for {
+		row, err := o.next()
+		if err == io.EOF {
+			return &z.Array{Value: rows}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// TypeName returns the name of the type.
+func (o *csvReader) TypeName() string {
+	return "csv-reader"
+}
+
+func (o *csvReader) String() string {
+	return "<csv-reader>"
+}
+
+// Copy returns the reader itself: copies of a reader share the same input.
+func (o *csvReader) Copy() z.Object {
+	return o
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *csvReader) Equals(x z.Object) bool {
+	return o == x
+}
+
+// IndexGet returns the reader method identified by the index.
+func (o *csvReader) IndexGet(index z.Object) (z.Object, error) {
+	name, ok := index.(*z.String)
+	if !ok {
+		return nil, z.ErrInvalidIndexType
+	}
+	return o.methods[name.Value], nil
+}
+
+// CanIterate returns true: iterating over the reader reads the rows.
+func (o *csvReader) CanIterate() bool {
+	return true
+}
+
+// Iterate returns an iterator reading the remaining rows. A read error is
+// returned as an error value and ends the iteration.
+func (o *csvReader) Iterate() z.Iterator {
+	return &csvIterator{r: o}
+}
+
+type csvIterator struct {
+	z.ObjectImpl
+	r     *csvReader
+	key   int
+	value z.Object
+	done  bool
+}
+
+// TypeName returns the name of the type.
+func (i *csvIterator) TypeName() string {
+	return "csv-iterator"
+}
+
+func (i *csvIterator) String() string {
+	return "<csv-iterator>"
+}
+
+// Next returns true if a row was read.
+func (i *csvIterator) Next() bool {
+	if i.done {
+		return false
+	}
+	row, err := i.r.next()
+	if err == io.EOF {
+		i.done = true
+		return false
+	}
+	i.key = i.r.row - 1
+	if err != nil {
+		row, i.done = wrapError(err), true
+	}
+	i.value = row
+	return true
+}
+
+// Key returns the index of the row.
+func (i *csvIterator) Key() z.Object {
+	return &z.Int{Value: int64(i.key)}
+}
+
+// Value returns the row.
+func (i *csvIterator) Value() z.Object {
+	return i.value
+}
+
+func csvNewReader(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	r, objReader, err := readerArg(vm, args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	opts, err := csvOptionsArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return newCSVReader(r, objReader, opts), nil
+}
+
+func csvDecode(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	var r io.Reader
+	switch arg := args[0].(type) {
+	case *z.Bytes:
+		r = bytes.NewReader(arg.Value)
+	case *z.String:
+		r = bytes.NewReader([]byte(arg.Value))
+	default:
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "bytes/string",
+			Found:    args[0].TypeName(),
+		}
+	}
+	opts, err := csvOptionsArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := newCSVReader(r, nil, opts).readAll()
+	if err != nil {
+		return wrapError(err), nil
+	}
+	return rows, nil
+}
+
+// csvWriter writes rows, arrays or maps, to a csv.Writer.
+type csvWriter struct {
+	w       *csv.Writer
+	opts    *csvOptions
+	started bool
+}
+
+func newCSVWriter(w io.Writer, opts *csvOptions) *csvWriter {
+	cw := csv.NewWriter(w)
+	cw.Comma = opts.delimiter
+	cw.UseCRLF = opts.useCRLF
+	return &csvWriter{w: cw, opts: opts}
+}
+
+func (w *csvWriter) write(row z.Object) error {
+	var record []string
+	switch row := row.(type) {
+	case *z.Array:
+		record = csvRecord(row.Value)
+	case *z.ImmutableArray:
+		record = csvRecord(row.Value)
+	case *z.Map:
+		record = w.mapRecord(row.Value)
+	case *z.ImmutableMap:
+		record = w.mapRecord(row.Value)
+	default:
+		return z.ErrInvalidArgumentType{
+			Name:     "row",
+			Expected: "array/map",
+			Found:    row.TypeName(),
+		}
+	}
+	if !w.started {
+		w.started = true
+		if w.opts.columns != nil {
+			if err := w.w.Write(w.opts.columns); err != nil {
+				return err
+			}
+		}
+	}
+	return w.w.Write(record)
+}
+
+// mapRecord returns the fields of the map in the order of the header
+// columns. Without columns, the sorted keys of the first map are used.
+func (w *csvWriter) mapRecord(m map[string]z.Object) []string {
+	if w.opts.columns == nil && !w.started {
+		for key := range m {
+			w.opts.columns = append(w.opts.columns, key)
+		}
+		sort.Strings(w.opts.columns)
+	}
+	record := make([]string, len(w.opts.columns))
+	for idx, column := range w.opts.columns {
+		if v, ok := m[column]; ok {
+			record[idx] = csvField(v)
+		}
+	}
+	return record
+}
+
+func (w *csvWriter) writeAll(rows z.Object) error {
+	var arr []z.Object
+	switch rows := rows.(type) {
+	case *z.Array:
+		arr = rows.Value
+	case *z.ImmutableArray:
+		arr = rows.Value
+	default:
+		return z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "array",
+			Found:    rows.TypeName(),
+		}
+	}
+	for _, row := range arr {
+		if err := w.write(row); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func csvRecord(values []z.Object) []string {
+	record := make([]string, len(values))
+	for idx, v := range values {
+		record[idx] = csvField(v)
+	}
+	return record
+}
+
+func csvField(v z.Object) string {
+	if v == z.UndefinedValue {
+		return ""
+	}
+	if s, ok := z.ToString(v); ok {
+		return s
+	}
+	return v.String()
+}
+
+func csvNewWriter(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	objWriter, err := writerArg(vm, args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	opts, err := csvOptionsArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	w := newCSVWriter(objWriter, opts)
+
+	// method returns a writer method calling fn.
+	method := func(name string, fn func(args ...z.Object) error) z.Object {
+		return boundMethod(name, nil, objWriter,
+			func(args ...z.Object) (z.Object, error) {
+				return z.TrueValue, fn(args...)
+			})
+	}
+	return &z.ImmutableMap{
+		Value: map[string]z.Object{
+			// write(row array/map) => true/error
+			"write": method("write", func(args ...z.Object) error {
+				if len(args) != 1 {
+					return z.ErrWrongNumArguments
+				}
+				return w.write(args[0])
+			}),
+			// write_all(rows array) => true/error
+			"write_all": method("write_all", func(args ...z.Object) error {
+				if len(args) != 1 {
+					return z.ErrWrongNumArguments
+				}
+				return w.writeAll(args[0])
+			}),
+			// flush() => true/error
+			"flush": method("flush", func(args ...z.Object) error {
+				if len(args) != 0 {
+					return z.ErrWrongNumArguments
+				}
+				w.w.Flush()
+				return w.w.Error()
+			}),
+		},
+	}, nil
+}
+
+func csvEncode(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	opts, err := csvOptionsArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = newCSVWriter(&buf, opts).writeAll(args[0])
+	var argErr z.ErrInvalidArgumentType
+	if errors.As(err, &argErr) {
+		return nil, err
+	} else if err != nil {
+		return wrapError(err), nil
+	}
+	if buf.Len() > z.MaxStringLen {
+		return nil, z.ErrStringLimit
+	}
+	return &z.String{Value: buf.String()}, nil
+}
@@ -0,0 +1,89 @@
+package stdlib_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+)
+
+func TestCSVDecodeEncode(t *testing.T) {
+	module(t, "csv").call("decode", "a,b\n1,\"x, y\"\n").
+		expect(ARR{ARR{"a", "b"}, ARR{"1", "x, y"}})
+	module(t, "csv").call("decode", "a,b\n1,2\n", MAP{"header": true}).
+		expect(ARR{MAP{"a": "1", "b": "2"}})
+	module(t, "csv").call("decode", "1\t2\n# c\n3\t4\n",
+		MAP{"delimiter": "\t", "comment": '#'}).
+		expect(ARR{ARR{"1", "2"}, ARR{"3", "4"}})
+	module(t, "csv").call("decode", "1,2\n3\n").
+		expect(&z.Error{Value: &z.String{
+			Value: "record on line 2: wrong number of fields"}})
+	module(t, "csv").call("decode", "1,2\n3\n",
+		MAP{"fields_per_record": -1}).
+		expect(ARR{ARR{"1", "2"}, ARR{"3"}})
+	module(t, "csv").call("decode", `a "b",c`, MAP{"lazy_quotes": true}).
+		expect(ARR{ARR{`a "b"`, "c"}})
+	module(t, "csv").call("decode", "a,b", MAP{"foo": 1}).expectError()
+
+	module(t, "csv").call("encode", ARR{ARR{"a", 1}, ARR{"x, y", true}}).
+		expect("a,1\n\"x, y\",true\n")
+	module(t, "csv").call("encode", ARR{MAP{"b": 2, "a": 1}},
+		MAP{"delimiter": ';', "use_crlf": true}).
+		expect("a;b\r\n1;2\r\n")
+	module(t, "csv").call("encode", ARR{MAP{"b": 2, "a": 1}},
+		MAP{"header": ARR{"b", "a"}}).
+		expect("b,a\n2,1\n")
+	module(t, "csv").call("encode", ARR{1}).expectError()
+}
+
+func TestCSVReader(t *testing.T) {
+	expect(t, `
+csv := import("csv")
+r := csv.reader("name,age\nfoo,10\nbar,20\n", {header: true})
+out := ""
+for i, row in r {
+	out += string(i) + row.name + row.age
+}
+out += string(r.header()) + string(r.read())
+`, `0foo101bar20["name", "age"]<undefined>`)
+
+	expect(t, `
+csv := import("csv")
+r := csv.reader("a\n\"b\n")
+out := string([r.read(), is_error(r.read())])
+`, `[["a"], true]`)
+
+	expect(t, `
+csv := import("csv")
+out := []
+for row in csv.reader("a\n\"b\n") {
+	out = append(out, is_error(row))
+}
+out = string(out)
+`, "[false, true]")
+}
+
+func TestCSVFile(t *testing.T) {
+	name := filepath.ToSlash(filepath.Join(t.TempDir(), "data.csv"))
+
+	expect(t, `
+csv := import("csv")
+os := import("os")
+f := os.create("`+name+`")
+w := csv.writer(f, {header: ["id", "name"]})
+w.write({id: 1, name: "foo"})
+w.write_all([{id: 2, name: "bar, baz"}])
+w.flush()
+f.close()
+f = os.open("`+name+`")
+rows := csv.reader(f, {header: true}).read_all()
+f.close()
+out := rows[0].id + rows[0].name + rows[1].id + rows[1].name
+`, "1foo2bar, baz")
+
+	b, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.Equal(t, "id,name\n1,foo\n2,\"bar, baz\"\n", string(b))
+}
@@ -0,0 +1,177 @@
+package stdlib
+
+import (
+	"errors"
+	"math"
+	"math/big"
+
+	"github.com/diiyw/z"
+)
+
+var decimalModule = map[string]z.Object{
+	"new": &z.UserFunction{
+		Name:  "new",
+		Value: decimalNew,
+	},
+	"bigint": &z.UserFunction{
+		Name:  "bigint",
+		Value: decimalBigInt,
+	},
+	"is_decimal": &z.UserFunction{
+		Name:  "is_decimal",
+		Value: decimalIsDecimal,
+	},
+	"is_bigint": &z.UserFunction{
+		Name:  "is_bigint",
+		Value: decimalIsBigInt,
+	},
+	"round": &z.UserFunction{
+		Name:  "round",
+		Value: decimalRound,
+	},
+	"scale": &z.UserFunction{
+		Name:  "scale",
+		Value: decimalScale,
+	},
+	"abs": &z.UserFunction{
+		Name:  "abs",
+		Value: decimalAbs,
+	},
+}
+
+// decimalNew converts a value to a decimal. It returns the optional second
+// argument, or undefined, if the value cannot be converted.
+func decimalNew(args ...z.Object) (z.Object, error) {
+	argsLen := len(args)
+	if !(argsLen == 1 || argsLen == 2) {
+		return nil, z.ErrWrongNumArguments
+	}
+	if _, ok := args[0].(*z.Decimal); ok {
+		return args[0], nil
+	}
+	if v, ok := z.ToDecimal(args[0]); ok {
+		return v, nil
+	}
+	if argsLen == 2 {
+		return args[1], nil
+	}
+	return z.UndefinedValue, nil
+}
+
+// decimalBigInt converts a value to a big int. It returns the optional second
+// argument, or undefined, if the value cannot be converted.
+func decimalBigInt(args ...z.Object) (z.Object, error) {
+	argsLen := len(args)
+	if !(argsLen == 1 || argsLen == 2) {
+		return nil, z.ErrWrongNumArguments
+	}
+	if _, ok := args[0].(*z.BigInt); ok {
+		return args[0], nil
+	}
+	if v, ok := z.ToBigInt(args[0]); ok {
+		return &z.BigInt{Value: v}, nil
+	}
+	if argsLen == 2 {
+		return args[1], nil
+	}
+	return z.UndefinedValue, nil
+}
+
+func decimalIsDecimal(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if _, ok := args[0].(*z.Decimal); ok {
+		return z.TrueValue, nil
+	}
+	return z.FalseValue, nil
+}
+
+func decimalIsBigInt(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if _, ok := args[0].(*z.BigInt); ok {
+		return z.TrueValue, nil
+	}
+	return z.FalseValue, nil
+}
+
+// decimalRound returns the decimal rounded to a number of decimal places with
+// an optional rounding mode, "half_even" by default.
+func decimalRound(args ...z.Object) (z.Object, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, z.ErrWrongNumArguments
+	}
+	d, err := decimalArg(args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	places, ok := z.ToInt64(args[1])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "second",
+			Expected: "int(compatible)",
+			Found:    args[1].TypeName(),
+		}
+	}
+	if places > math.MaxInt16 || places < math.MinInt16 {
+		return wrapError(errors.New("decimal places out of range")), nil
+	}
+	mode := z.RoundHalfEven
+	if len(args) == 3 {
+		name, ok := args[2].(*z.String)
+		if !ok {
+			return nil, z.ErrInvalidArgumentType{
+				Name:     "third",
+				Expected: "string",
+				Found:    args[2].TypeName(),
+			}
+		}
+		if mode, err = z.ParseRoundingMode(name.Value); err != nil {
+			return wrapError(err), nil
+		}
+	}
+	return d.Round(int32(places), mode), nil
+}
+
+// decimalScale returns the number of decimal places of the decimal.
+func decimalScale(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	d, err := decimalArg(args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	return &z.Int{Value: int64(d.Scale)}, nil
+}
+
+// decimalAbs returns the absolute value of a big int or a decimal.
+func decimalAbs(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if i, ok := args[0].(*z.BigInt); ok {
+		return &z.BigInt{Value: new(big.Int).Abs(i.Value)}, nil
+	}
+	d, err := decimalArg(args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	return &z.Decimal{Unscaled: new(big.Int).Abs(d.Unscaled), Scale: d.Scale}, nil
+}
+
+// decimalArg returns the decimal value of an argument convertible to a
+// decimal.
+func decimalArg(arg z.Object, name string) (*z.Decimal, error) {
+	d, ok := z.ToDecimal(arg)
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     name,
+			Expected: "decimal(compatible)",
+			Found:    arg.TypeName(),
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,54 @@
+package stdlib_test
+
+import "testing"
+
+func TestDecimal(t *testing.T) {
+	module(t, "decimal").call("scale", "1.500").expect(3)
+	module(t, "decimal").call("scale", 7).expect(0)
+	module(t, "decimal").call("scale", ARR{}).expectError()
+	module(t, "decimal").call("round").expectError()
+	module(t, "decimal").call("round", "x", 2).expectError()
+	module(t, "decimal").call("round", "1.5", "a").expectError()
+	module(t, "decimal").call("new").expectError()
+	module(t, "decimal").call("bigint", "x", 0).expect(0)
+	module(t, "decimal").call("is_decimal", "1.5").expect(false)
+	module(t, "decimal").call("is_bigint", 1).expect(false)
+
+	expect(t, `
+dec := import("decimal")
+out := format("%s %s %s %s %s", dec.round("2.345", 2), dec.round("2.345", 2, "half_up"),
+	dec.round(-2.5, 0, "floor"), dec.round(1250, -2), dec.round("1.5", 3))
+`, "2.34 2.35 -3 1200 1.500")
+	expect(t, `
+dec := import("decimal")
+out := string(dec.round(dec.new(2) / 3, 4, "down"))
+`, "0.6666")
+	expect(t, `
+dec := import("decimal")
+out := format("%s %s", dec.abs(dec.new("-1.50")), dec.abs(dec.bigint(-5)))
+`, "1.50 5")
+	expect(t, `
+dec := import("decimal")
+out := is_error(dec.round(1, 1, "nearest"))
+`, true)
+	expect(t, `
+dec := import("decimal")
+out := is_error(dec.round(1, 100000))
+`, true)
+}
+
+func TestDecimalEquals(t *testing.T) {
+	expect(t, `
+dec := import("decimal")
+out := string([dec.new("1.50") == 1.5, 1.5 == dec.new("1.50"), dec.new(2) == 2,
+	2 == dec.new("2.0"), dec.new(3) == dec.bigint(3), dec.bigint(3) == dec.new(3),
+	dec.bigint(4) == 4, 4 == dec.bigint(4), dec.bigint(5) == 5.0, 5.0 == dec.bigint(5)])
+`, "[true, true, true, true, true, true, true, true, true, true]")
+	expect(t, `
+dec := import("decimal")
+big := dec.bigint(1) << 64
+out := string([dec.new("1.5") == 1, 1 == dec.new("1.5"), dec.new("0.1") == 0.1000001,
+	dec.bigint(2) == 2.5, 2.5 == dec.bigint(2), big == 0, big + 1 == float(big),
+	dec.new(1) != 1, dec.bigint(1) != 1, dec.new(1) == "1"])
+`, "[false, false, false, false, false, false, false, false, false, false]")
+}
@@ -1,9 +1,25 @@
 package stdlib
 
 import (
+	"fmt"
+
 	"github.com/diiyw/z"
 )
 
+// ExitError is the error stopping a script that called os.exit, or that
+// args.parse stopped after writing the help or a usage error. os.exit no
+// longer exits the host process: the run returns a *ExitError instead, which
+// embedders must handle, e.g. with errors.As. Hosts running scripts as
+// programs, like the z command, exit with Code; the error is not an error of
+// the script.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
 func wrapError(err error) z.Object {
 	if err == nil {
 		return z.TrueValue
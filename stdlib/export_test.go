@@ -0,0 +1,9 @@
+package stdlib
+
+// ForgetSharedModule removes the module shared by the module maps for the
+// builtin module name, so that a test can remove the module it added.
+func ForgetSharedModule(name string) {
+	sharedModulesLock.Lock()
+	defer sharedModulesLock.Unlock()
+	delete(sharedModules, name)
+}
@@ -0,0 +1,824 @@
+package stdlib
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errReadOnlyFS  = errors.New("read-only file system")
+	errUnsupported = errors.New("operation not supported")
+	errIsDir       = errors.New("is a directory")
+	errNotDir      = errors.New("not a directory")
+	errNotEmpty    = errors.New("directory not empty")
+)
+
+// FS is the file system used by the file functions of the os module. OSFS
+// is used by default; MemFS and ReadOnlyFS can be used instead for testing
+// and sandboxing using GetModuleMapWithOptions.
+type FS interface {
+	Open(name string) (File, error)
+	Create(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (os.FileInfo, error)
+	Mkdir(name string, perm os.FileMode) error
+	MkdirAll(name string, perm os.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldpath, newpath string) error
+	Truncate(name string, size int64) error
+	Chmod(name string, mode os.FileMode) error
+	Chown(name string, uid, gid int) error
+	Lchown(name string, uid, gid int) error
+	Link(oldname, newname string) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+}
+
+// File is an open file of a FS. *os.File implements File.
+type File interface {
+	io.ReadWriteSeeker
+	io.Closer
+	io.StringWriter
+	Name() string
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Readdirnames(n int) ([]string, error)
+	Chmod(mode os.FileMode) error
+	Chown(uid, gid int) error
+	Chdir() error
+}
+
+// OSFS is the FS of the operating system.
+type OSFS struct{}
+
+// Open opens the named file for reading.
+func (OSFS) Open(name string) (File, error) {
+	return osFile(os.Open(name))
+}
+
+// Create creates or truncates the named file.
+func (OSFS) Create(name string) (File, error) {
+	return osFile(os.Create(name))
+}
+
+// OpenFile opens the named file with the specified flag and perm.
+func (OSFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return osFile(os.OpenFile(name, flag, perm))
+}
+
+// ReadFile reads the named file.
+func (OSFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// Stat returns a FileInfo describing the named file.
+func (OSFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// Mkdir creates a new directory.
+func (OSFS) Mkdir(name string, perm os.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+
+// MkdirAll creates a directory along with any necessary parents.
+func (OSFS) MkdirAll(name string, perm os.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+// Remove removes the named file or empty directory.
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// RemoveAll removes the named file or directory and any children it
+// contains.
+func (OSFS) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+// Rename renames oldpath to newpath.
+func (OSFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// Truncate changes the size of the named file.
+func (OSFS) Truncate(name string, size int64) error {
+	return os.Truncate(name, size)
+}
+
+// Chmod changes the mode of the named file.
+func (OSFS) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+// Chown changes the numeric uid and gid of the named file.
+func (OSFS) Chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
+
+// Lchown changes the numeric uid and gid of the named file without following
+// symbolic links.
+func (OSFS) Lchown(name string, uid, gid int) error {
+	return os.Lchown(name, uid, gid)
+}
+
+// Link creates newname as a hard link to the oldname file.
+func (OSFS) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
+// Symlink creates newname as a symbolic link to oldname.
+func (OSFS) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+// Readlink returns the destination of the named symbolic link.
+func (OSFS) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+func osFile(f *os.File, err error) (File, error) {
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// MemFS is an in-memory FS. Relative paths are relative to the root
+// directory. Links and file ownership are not supported. Use NewMemFS to
+// create one.
+type MemFS struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	name    string
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (n *memNode) info() os.FileInfo {
+	return &memFileInfo{
+		name:    n.name,
+		size:    int64(len(n.data)),
+		mode:    n.mode,
+		modTime: n.modTime,
+	}
+}
+
+// NewMemFS returns an empty in-memory FS.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		nodes: map[string]*memNode{
+			"/": {name: "/", mode: os.ModeDir | 0755, modTime: time.Now()},
+		},
+	}
+}
+
+// WriteFile creates or replaces the named file, creating the parent
+// directories if needed. It is meant to populate the file system.
+func (m *MemFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := m.MkdirAll(path.Dir(memPath(name)), 0755); err != nil {
+		return err
+	}
+	f, err := m.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Open opens the named file for reading.
+func (m *MemFS) Open(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Create creates or truncates the named file.
+func (m *MemFS) Create(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// OpenFile opens the named file with the specified flag and perm.
+func (m *MemFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := memPath(name)
+	n, ok := m.nodes[p]
+	switch {
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case !ok:
+		if err := m.checkParent("open", name, p); err != nil {
+			return nil, err
+		}
+		n = &memNode{
+			name:    path.Base(p),
+			mode:    perm.Perm(),
+			modTime: time.Now(),
+		}
+		m.nodes[p] = n
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case n.mode.IsDir() && flag&(os.O_WRONLY|os.O_RDWR) != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+	case flag&os.O_TRUNC != 0 && flag&(os.O_WRONLY|os.O_RDWR) != 0:
+		n.data = nil
+		n.modTime = time.Now()
+	}
+	return &memFile{fs: m, node: n, name: name, path: p, flag: flag}, nil
+}
+
+// ReadFile reads the named file.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+	return append([]byte(nil), n.data...), nil
+}
+
+// Stat returns a FileInfo describing the named file.
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(), nil
+}
+
+// Mkdir creates a new directory.
+func (m *MemFS) Mkdir(name string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := memPath(name)
+	if _, ok := m.nodes[p]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := m.checkParent("mkdir", name, p); err != nil {
+		return err
+	}
+	m.nodes[p] = &memNode{
+		name:    path.Base(p),
+		mode:    os.ModeDir | perm.Perm(),
+		modTime: time.Now(),
+	}
+	return nil
+}
+
+// MkdirAll creates a directory along with any necessary parents.
+func (m *MemFS) MkdirAll(name string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := "/"
+	for _, elem := range strings.Split(memPath(name), "/") {
+		if elem == "" {
+			continue
+		}
+		p = path.Join(p, elem)
+		n, ok := m.nodes[p]
+		if !ok {
+			m.nodes[p] = &memNode{
+				name:    elem,
+				mode:    os.ModeDir | perm.Perm(),
+				modTime: time.Now(),
+			}
+		} else if !n.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDir}
+		}
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := memPath(name)
+	if _, err := m.lookup("remove", name); err != nil {
+		return err
+	}
+	if p == "/" {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if len(m.children(p)) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	delete(m.nodes, p)
+	return nil
+}
+
+// RemoveAll removes the named file or directory and any children it
+// contains. It returns nil if the file does not exist.
+func (m *MemFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := memPath(name)
+	for key := range m.nodes {
+		if key != "/" && memContains(p, key) {
+			delete(m.nodes, key)
+		}
+	}
+	return nil
+}
+
+// Rename renames oldpath to newpath, replacing newpath if it is a file.
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	src, dst := memPath(oldpath), memPath(newpath)
+	n, err := m.lookup("rename", oldpath)
+	if err != nil {
+		return err
+	}
+	if err := m.checkParent("rename", newpath, dst); err != nil {
+		return err
+	}
+	if src == dst {
+		return nil
+	}
+	if d, ok := m.nodes[dst]; ok && (d.mode.IsDir() || n.mode.IsDir()) {
+		return &fs.PathError{Op: "rename", Path: newpath, Err: fs.ErrExist}
+	}
+	if memContains(src, dst) {
+		return &fs.PathError{Op: "rename", Path: newpath, Err: fs.ErrInvalid}
+	}
+	for key, node := range m.nodes {
+		if memContains(src, key) {
+			delete(m.nodes, key)
+			m.nodes[dst+strings.TrimPrefix(key, src)] = node
+		}
+	}
+	n.name = path.Base(dst)
+	return nil
+}
+
+// Truncate changes the size of the named file.
+func (m *MemFS) Truncate(name string, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, err := m.lookup("truncate", name)
+	if err != nil {
+		return err
+	}
+	if n.mode.IsDir() {
+		return &fs.PathError{Op: "truncate", Path: name, Err: errIsDir}
+	}
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: name, Err: fs.ErrInvalid}
+	}
+	n.resize(size)
+	return nil
+}
+
+// Chmod changes the permission bits of the named file.
+func (m *MemFS) Chmod(name string, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, err := m.lookup("chmod", name)
+	if err != nil {
+		return err
+	}
+	n.mode = n.mode.Type() | mode.Perm()
+	return nil
+}
+
+// Chown is not supported.
+func (m *MemFS) Chown(name string, _, _ int) error {
+	return &fs.PathError{Op: "chown", Path: name, Err: errUnsupported}
+}
+
+// Lchown is not supported.
+func (m *MemFS) Lchown(name string, _, _ int) error {
+	return &fs.PathError{Op: "lchown", Path: name, Err: errUnsupported}
+}
+
+// Link is not supported.
+func (m *MemFS) Link(_, newname string) error {
+	return &fs.PathError{Op: "link", Path: newname, Err: errUnsupported}
+}
+
+// Symlink is not supported.
+func (m *MemFS) Symlink(_, newname string) error {
+	return &fs.PathError{Op: "symlink", Path: newname, Err: errUnsupported}
+}
+
+// Readlink is not supported.
+func (m *MemFS) Readlink(name string) (string, error) {
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: errUnsupported}
+}
+
+func (m *MemFS) lookup(op, name string) (*memNode, error) {
+	n, ok := m.nodes[memPath(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return n, nil
+}
+
+func (m *MemFS) checkParent(op, name, p string) error {
+	parent, ok := m.nodes[path.Dir(p)]
+	if !ok {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: errNotDir}
+	}
+	return nil
+}
+
+// children returns the sorted names of the entries of the directory p.
+func (m *MemFS) children(p string) []string {
+	var names []string
+	for key := range m.nodes {
+		if key != "/" && path.Dir(key) == p {
+			names = append(names, path.Base(key))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (n *memNode) resize(size int64) {
+	if size <= int64(len(n.data)) {
+		n.data = n.data[:size]
+	} else {
+		n.data = append(n.data, make([]byte, size-int64(len(n.data)))...)
+	}
+	n.modTime = time.Now()
+}
+
+// memPath returns the clean absolute slash-separated path of name.
+func memPath(name string) string {
+	return path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+}
+
+// memContains reports whether p is dir or is inside dir.
+func memContains(dir, p string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+type memFile struct {
+	fs     *MemFS
+	node   *memNode
+	name   string
+	path   string
+	flag   int
+	offset int64
+	dirPos int
+	closed bool
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) check(op string, write bool) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	mode := f.flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+	if write && mode == os.O_RDONLY || !write && mode == os.O_WRONLY {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrPermission}
+	}
+	if f.node.mode.IsDir() && op != "readdirent" {
+		return &fs.PathError{Op: op, Path: f.name, Err: errIsDir}
+	}
+	return nil
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	if f.offset >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.node.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	end := f.offset + int64(len(b))
+	if end > int64(len(f.node.data)) {
+		f.node.resize(end)
+	}
+	copy(f.node.data[f.offset:], b)
+	f.node.modTime = time.Now()
+	f.offset = end
+	return len(b), nil
+}
+
+func (f *memFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	return f.node.info(), nil
+}
+
+func (f *memFile) Sync() error {
+	return nil
+}
+
+func (f *memFile) Readdirnames(n int) ([]string, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return nil, &fs.PathError{Op: "readdirent", Path: f.name, Err: fs.ErrClosed}
+	}
+	if !f.node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdirent", Path: f.name, Err: errNotDir}
+	}
+	names := f.fs.children(f.path)
+	if f.dirPos > len(names) {
+		f.dirPos = len(names)
+	}
+	names = names[f.dirPos:]
+	if n > 0 {
+		if len(names) == 0 {
+			return nil, io.EOF
+		}
+		if len(names) > n {
+			names = names[:n]
+		}
+	}
+	f.dirPos += len(names)
+	return names, nil
+}
+
+func (f *memFile) Chmod(mode os.FileMode) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	f.node.mode = f.node.mode.Type() | mode.Perm()
+	return nil
+}
+
+func (f *memFile) Chown(_, _ int) error {
+	return &fs.PathError{Op: "chown", Path: f.name, Err: errUnsupported}
+}
+
+func (f *memFile) Chdir() error {
+	return &fs.PathError{Op: "chdir", Path: f.name, Err: errUnsupported}
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() os.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() any           { return nil }
+
+// ReadOnlyFS returns a FS reading from fsys, such as an embed.FS. Names are
+// slash-separated paths relative to the root of fsys, with or without a
+// leading slash. All the operations modifying the file system fail.
+func ReadOnlyFS(fsys fs.FS) FS {
+	return &readOnlyFS{fsys: fsys}
+}
+
+type readOnlyFS struct {
+	fsys fs.FS
+}
+
+func (r *readOnlyFS) Open(name string) (File, error) {
+	p, err := readOnlyPath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := r.fsys.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyFile{File: f, name: name}, nil
+}
+
+func (r *readOnlyFS) Create(name string) (File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: errReadOnlyFS}
+}
+
+func (r *readOnlyFS) OpenFile(
+	name string,
+	flag int,
+	_ os.FileMode,
+) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errReadOnlyFS}
+	}
+	return r.Open(name)
+}
+
+func (r *readOnlyFS) ReadFile(name string) ([]byte, error) {
+	p, err := readOnlyPath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(r.fsys, p)
+}
+
+func (r *readOnlyFS) Stat(name string) (os.FileInfo, error) {
+	p, err := readOnlyPath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(r.fsys, p)
+}
+
+func (r *readOnlyFS) Readlink(name string) (string, error) {
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: errUnsupported}
+}
+
+func (r *readOnlyFS) Mkdir(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: errReadOnlyFS}
+}
+
+func (r *readOnlyFS) MkdirAll(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: name, Err: errReadOnlyFS}
+}
+
+func (r *readOnlyFS) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: errReadOnlyFS}
+}
+
+func (r *readOnlyFS) RemoveAll(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: errReadOnlyFS}
+}
+
+func (r *readOnlyFS) Rename(_, newpath string) error {
+	return &fs.PathError{Op: "rename", Path: newpath, Err: errReadOnlyFS}
+}
+
+func (r *readOnlyFS) Truncate(name string, _ int64) error {
+	return &fs.PathError{Op: "truncate", Path: name, Err: errReadOnlyFS}
+}
+
+func (r *readOnlyFS) Chmod(name string, _ os.FileMode) error {
+	return &fs.PathError{Op: "chmod", Path: name, Err: errReadOnlyFS}
+}
+
+func (r *readOnlyFS) Chown(name string, _, _ int) error {
+	return &fs.PathError{Op: "chown", Path: name, Err: errReadOnlyFS}
+}
+
+func (r *readOnlyFS) Lchown(name string, _, _ int) error {
+	return &fs.PathError{Op: "lchown", Path: name, Err: errReadOnlyFS}
+}
+
+func (r *readOnlyFS) Link(_, newname string) error {
+	return &fs.PathError{Op: "link", Path: newname, Err: errReadOnlyFS}
+}
+
+func (r *readOnlyFS) Symlink(_, newname string) error {
+	return &fs.PathError{Op: "symlink", Path: newname, Err: errReadOnlyFS}
+}
+
+// readOnlyPath converts name to a fs.FS path.
+func readOnlyPath(op, name string) (string, error) {
+	p := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if p == "" {
+		p = "."
+	}
+	if !fs.ValidPath(p) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return p, nil
+}
+
+type readOnlyFile struct {
+	fs.File
+	name string
+}
+
+func (f *readOnlyFile) Name() string {
+	return f.name
+}
+
+func (f *readOnlyFile) Write([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errReadOnlyFS}
+}
+
+func (f *readOnlyFile) WriteString(string) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.name, Err: errReadOnlyFS}
+}
+
+func (f *readOnlyFile) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errUnsupported}
+}
+
+func (f *readOnlyFile) Sync() error {
+	return nil
+}
+
+func (f *readOnlyFile) Readdirnames(n int) ([]string, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdirent", Path: f.name, Err: errNotDir}
+	}
+	entries, err := d.ReadDir(n)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, err
+}
+
+func (f *readOnlyFile) Chmod(os.FileMode) error {
+	return &fs.PathError{Op: "chmod", Path: f.name, Err: errReadOnlyFS}
+}
+
+func (f *readOnlyFile) Chown(_, _ int) error {
+	return &fs.PathError{Op: "chown", Path: f.name, Err: errReadOnlyFS}
+}
+
+func (f *readOnlyFile) Chdir() error {
+	return &fs.PathError{Op: "chdir", Path: f.name, Err: errUnsupported}
+}
@@ -0,0 +1,98 @@
+package stdlib_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+)
+
+func runWithFS(fsys stdlib.FS, input string) (*z.Compiled, error) {
+	s := z.NewScript([]byte(input))
+	s.SetImports(stdlib.GetModuleMapWithOptions(
+		stdlib.Options{FS: fsys}, "os"))
+	return s.Run()
+}
+
+func TestMemFS(t *testing.T) {
+	m := stdlib.NewMemFS()
+	require.NoError(t, m.WriteFile("/data/a.txt", []byte("foo"), 0644))
+
+	c, err := runWithFS(m, `
+os := import("os")
+out := string(os.read_file("/data/a.txt"))
+os.mkdir_all("/out/sub", 0755)
+f := os.create("/out/sub/b.txt")
+f.write_string("hello")
+f.seek(0, os.seek_set)
+buf := bytes(5)
+f.read(buf)
+out += string(buf)
+f.close()
+f = os.open_file("/out/sub/b.txt", os.o_wronly|os.o_append, 0)
+f.write_string("!")
+f.close()
+out += string(os.read_file("/out/sub/b.txt"))
+out += string(os.stat("/out/sub/b.txt").size)
+os.rename("/out/sub", "/out/moved")
+out += string(os.open("/out").readdirnames(0))
+out += string(is_error(os.stat("/out/sub/b.txt")))
+os.remove_all("/out")
+out += string(is_error(os.read_file("/out/moved/b.txt")))
+`)
+	require.NoError(t, err)
+	require.Equal(t, `foohellohello!6["moved"]truetrue`, c.Get("out").Value())
+
+	_, err = os.Stat("/out/moved")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = m.Open("/missing")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+	_, err = m.Create("/missing/a.txt")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+	_, err = m.OpenFile("/data/a.txt", os.O_CREATE|os.O_EXCL, 0644)
+	require.True(t, errors.Is(err, fs.ErrExist))
+	require.Error(t, m.Remove("/data"))
+	require.NoError(t, m.Truncate("/data/a.txt", 1))
+
+	f, err := m.Open("/data/a.txt")
+	require.NoError(t, err)
+	b, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, []byte("f"), b)
+	_, err = f.Write([]byte("x"))
+	require.True(t, errors.Is(err, fs.ErrPermission))
+	require.NoError(t, f.Close())
+
+	info, err := m.Stat("data")
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+}
+
+func TestReadOnlyFS(t *testing.T) {
+	fsys := stdlib.ReadOnlyFS(fstest.MapFS{
+		"conf/app.json": {Data: []byte(`{"debug": true}`)},
+		"conf/db.json":  {Data: []byte(`{}`)},
+	})
+
+	c, err := runWithFS(fsys, `
+os := import("os")
+out := string(os.read_file("/conf/app.json"))
+f := os.open("conf")
+out += string(f.readdirnames(0))
+f.close()
+out += string(os.stat("conf/db.json").size)
+out += string(is_error(os.create("conf/new.json")))
+out += string(is_error(os.remove("conf/app.json")))
+out += string(is_error(os.open_file("conf/app.json", os.o_rdwr, 0)))
+`)
+	require.NoError(t, err)
+	require.Equal(t, `{"debug": true}["app.json", "db.json"]2truetruetrue`,
+		c.Get("out").Value())
+}
@@ -747,6 +747,76 @@ func FuncASSRB(fn func(string, string) bool) z.CallableFunc {
 	}
 }
 
+// FuncASSRSE transform a function of 'func(string, string) (string, error)'
+// signature into CallableFunc type. User function will return an error if
+// underlying native function returns an error.
+func FuncASSRSE(fn func(string, string) (string, error)) z.CallableFunc {
+	return func(args ...z.Object) (z.Object, error) {
+		if len(args) != 2 {
+			return nil, z.ErrWrongNumArguments
+		}
+		s1, ok := z.ToString(args[0])
+		if !ok {
+			return nil, z.ErrInvalidArgumentType{
+				Name:     "first",
+				Expected: "string(compatible)",
+				Found:    args[0].TypeName(),
+			}
+		}
+		s2, ok := z.ToString(args[1])
+		if !ok {
+			return nil, z.ErrInvalidArgumentType{
+				Name:     "second",
+				Expected: "string(compatible)",
+				Found:    args[1].TypeName(),
+			}
+		}
+		res, err := fn(s1, s2)
+		if err != nil {
+			return wrapError(err), nil
+		}
+		if len(res) > z.MaxStringLen {
+			return nil, z.ErrStringLimit
+		}
+		return &z.String{Value: res}, nil
+	}
+}
+
+// FuncASSRBE transform a function of 'func(string, string) (bool, error)'
+// signature into CallableFunc type. User function will return an error if
+// underlying native function returns an error.
+func FuncASSRBE(fn func(string, string) (bool, error)) z.CallableFunc {
+	return func(args ...z.Object) (z.Object, error) {
+		if len(args) != 2 {
+			return nil, z.ErrWrongNumArguments
+		}
+		s1, ok := z.ToString(args[0])
+		if !ok {
+			return nil, z.ErrInvalidArgumentType{
+				Name:     "first",
+				Expected: "string(compatible)",
+				Found:    args[0].TypeName(),
+			}
+		}
+		s2, ok := z.ToString(args[1])
+		if !ok {
+			return nil, z.ErrInvalidArgumentType{
+				Name:     "second",
+				Expected: "string(compatible)",
+				Found:    args[1].TypeName(),
+			}
+		}
+		res, err := fn(s1, s2)
+		if err != nil {
+			return wrapError(err), nil
+		}
+		if res {
+			return z.TrueValue, nil
+		}
+		return z.FalseValue, nil
+	}
+}
+
 // FuncASsSRS transform a function of 'func([]string, string) string' signature
 // into CallableFunc type.
 func FuncASsSRS(fn func([]string, string) string) z.CallableFunc {
@@ -499,6 +499,42 @@ func TestFuncASSRB(t *testing.T) {
 	require.Equal(t, z.ErrWrongNumArguments, err)
 }
 
+func TestFuncASSRSE(t *testing.T) {
+	uf := stdlib.FuncASSRSE(func(a, b string) (string, error) {
+		if a == "" {
+			return "", errors.New("empty")
+		}
+		return a + b, nil
+	})
+	ret, err := funcCall(uf,
+		&z.String{Value: "foo"}, &z.String{Value: "bar"})
+	require.NoError(t, err)
+	require.Equal(t, &z.String{Value: "foobar"}, ret)
+	ret, err = funcCall(uf, &z.String{Value: ""}, &z.String{Value: "bar"})
+	require.NoError(t, err)
+	require.Equal(t, &z.Error{Value: &z.String{Value: "empty"}}, ret)
+	_, err = funcCall(uf, &z.String{Value: "foo"})
+	require.Equal(t, z.ErrWrongNumArguments, err)
+}
+
+func TestFuncASSRBE(t *testing.T) {
+	uf := stdlib.FuncASSRBE(func(a, b string) (bool, error) {
+		if a == "" {
+			return false, errors.New("empty")
+		}
+		return len(a) > len(b), nil
+	})
+	ret, err := funcCall(uf,
+		&z.String{Value: "123"}, &z.String{Value: "12"})
+	require.NoError(t, err)
+	require.Equal(t, z.TrueValue, ret)
+	ret, err = funcCall(uf, &z.String{Value: ""}, &z.String{Value: "12"})
+	require.NoError(t, err)
+	require.Equal(t, &z.Error{Value: &z.String{Value: "empty"}}, ret)
+	_, err = funcCall(uf, &z.String{Value: "foo"})
+	require.Equal(t, z.ErrWrongNumArguments, err)
+}
+
 func TestFuncAIRS(t *testing.T) {
 	uf := stdlib.FuncAIRS(func(a int) string { return strconv.Itoa(a) })
 	ret, err := funcCall(uf, &z.Int{Value: 55})
@@ -0,0 +1,368 @@
+package stdlib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/stdlib/json"
+)
+
+var httpModule = (&httpLib{}).module()
+
+// httpLib creates the http module functions that send requests through the
+// transport to the allowed hosts. Without hosts, requests are denied unless
+// the transport is set.
+type httpLib struct {
+	policy    *Policy
+	transport http.RoundTripper
+	hosts     []string
+}
+
+func (l *httpLib) module() map[string]z.Object {
+	return map[string]z.Object{
+		"get": &z.VMFunction{
+			Name:  "get",
+			Value: l.get,
+		}, // get(url string, options map) => imap(response)/error
+		"post": &z.VMFunction{
+			Name:  "post",
+			Value: l.post,
+		}, // post(url string, body object, options map) => imap(response)/error
+		"request": &z.VMFunction{
+			Name:  "request",
+			Value: l.request,
+		}, // request(method string, url string, options map) => imap(response)/error
+	}
+}
+
+func (l *httpLib) get(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	return l.do(vm, http.MethodGet, args[0], nil, args[1:])
+}
+
+func (l *httpLib) post(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, z.ErrWrongNumArguments
+	}
+	return l.do(vm, http.MethodPost, args[0], args[1], args[2:])
+}
+
+func (l *httpLib) request(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, z.ErrWrongNumArguments
+	}
+	method, ok := z.ToString(args[0])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "string(compatible)",
+			Found:    args[0].TypeName(),
+		}
+	}
+	return l.do(vm, strings.ToUpper(method), args[1], nil, args[2:])
+}
+
+// httpOptions are the options of a request.
+type httpOptions struct {
+	headers http.Header
+	query   url.Values
+	body    z.Object
+	timeout time.Duration
+}
+
+func httpOptionsArg(args []z.Object) (*httpOptions, error) {
+	opts := &httpOptions{headers: make(http.Header), query: make(url.Values)}
+	if len(args) == 0 {
+		return opts, nil
+	}
+	var m map[string]z.Object
+	switch arg := args[0].(type) {
+	case *z.Map:
+		m = arg.Value
+	case *z.ImmutableMap:
+		m = arg.Value
+	default:
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "options",
+			Expected: "map",
+			Found:    arg.TypeName(),
+		}
+	}
+	for key, value := range m {
+		var err error
+		switch key {
+		case "headers":
+			err = httpValues(key, value, func(k, v string) {
+				opts.headers.Add(k, v)
+			})
+		case "query":
+			err = httpValues(key, value, opts.query.Add)
+		case "body":
+			opts.body = value
+		case "timeout":
+			n, ok := z.ToInt64(value)
+			if !ok {
+				return nil, z.ErrInvalidArgumentType{
+					Name:     key,
+					Expected: "int(compatible)",
+					Found:    value.TypeName(),
+				}
+			}
+			opts.timeout = time.Duration(n)
+		default:
+			return nil, fmt.Errorf("unknown http option: %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// httpValues calls add for each value of a map of strings or arrays of
+// strings.
+func httpValues(name string, arg z.Object, add func(key, value string)) error {
+	var m map[string]z.Object
+	switch arg := arg.(type) {
+	case *z.Map:
+		m = arg.Value
+	case *z.ImmutableMap:
+		m = arg.Value
+	default:
+		return z.ErrInvalidArgumentType{
+			Name:     name,
+			Expected: "map",
+			Found:    arg.TypeName(),
+		}
+	}
+	for key, value := range m {
+		values := []z.Object{value}
+		switch value := value.(type) {
+		case *z.Array:
+			values = value.Value
+		case *z.ImmutableArray:
+			values = value.Value
+		}
+		for _, v := range values {
+			s, ok := z.ToString(v)
+			if !ok {
+				return z.ErrInvalidArgumentType{
+					Name:     name + "." + key,
+					Expected: "string(compatible)",
+					Found:    v.TypeName(),
+				}
+			}
+			add(key, s)
+		}
+	}
+	return nil
+}
+
+// httpBody returns the reader of a request body: strings and bytes are sent
+// as is, other objects are encoded as JSON.
+func httpBody(body z.Object, headers http.Header) (io.Reader, error) {
+	switch body := body.(type) {
+	case nil, *z.Undefined:
+		return nil, nil
+	case *z.String:
+		return strings.NewReader(body.Value), nil
+	case *z.Bytes:
+		return bytes.NewReader(body.Value), nil
+	}
+	b, err := json.Encode(body)
+	if err != nil {
+		return nil, err
+	}
+	if headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", "application/json")
+	}
+	return bytes.NewReader(b), nil
+}
+
+// checkHost returns an error if requests to the URL host are denied.
+func (l *httpLib) checkHost(u *url.URL) error {
+	if err := l.policy.CheckNetwork("http.request", u.Host); err != nil {
+		return err
+	}
+	if len(l.hosts) == 0 && l.transport != nil {
+		return nil
+	}
+	for _, pattern := range l.hosts {
+		if httpMatchHost(pattern, u) {
+			return nil
+		}
+	}
+	reason := "host not allowed"
+	if len(l.hosts) == 0 {
+		reason = "no hosts allowed, see stdlib.Options.HTTPHosts"
+	}
+	return &PolicyError{Op: "http.request", Resource: u.Host, Reason: reason}
+}
+
+// httpMatchHost reports whether the URL host matches the pattern: a host
+// name, a "*." prefixed domain matching its sub domains, optionally
+// followed by a port, or "*" matching all hosts.
+func httpMatchHost(pattern string, u *url.URL) bool {
+	if host, port, err := net.SplitHostPort(pattern); err == nil {
+		urlPort := u.Port()
+		if urlPort == "" {
+			urlPort = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+		}
+		if port != urlPort {
+			return false
+		}
+		pattern = host
+	}
+	hostname := strings.ToLower(u.Hostname())
+	pattern = strings.ToLower(strings.Trim(pattern, "[]"))
+	if domain, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(hostname, domain)
+	}
+	return hostname == pattern
+}
+
+func (l *httpLib) do(
+	vm *z.VM,
+	method string,
+	urlArg z.Object,
+	body z.Object,
+	optArgs []z.Object,
+) (z.Object, error) {
+	rawURL, ok := z.ToString(urlArg)
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "url",
+			Expected: "string(compatible)",
+			Found:    urlArg.TypeName(),
+		}
+	}
+	opts, err := httpOptionsArg(optArgs)
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		body = opts.body
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	if len(opts.query) > 0 {
+		query := u.Query()
+		for key, values := range opts.query {
+			query[key] = append(query[key], values...)
+		}
+		u.RawQuery = query.Encode()
+	}
+	if err := l.checkHost(u); err != nil {
+		return nil, err
+	}
+	r, err := httpBody(body, opts.headers)
+	if err != nil {
+		return wrapError(err), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if opts.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	if vm != nil {
+		// abort the request with the VM
+		go func() {
+			select {
+			case <-vm.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	for key, values := range opts.headers {
+		req.Header[key] = values
+	}
+	client := &http.Client{
+		Transport: l.transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return l.checkHost(req.URL)
+		},
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+		var b []byte
+		b, err = io.ReadAll(io.LimitReader(resp.Body, int64(z.MaxBytesLen)+1))
+		if err == nil {
+			if len(b) > z.MaxBytesLen {
+				return nil, z.ErrBytesLimit
+			}
+			return httpResponse(resp, b), nil
+		}
+	}
+	if vm != nil {
+		select {
+		case <-vm.Done():
+			return nil, z.ErrAborted
+		default:
+		}
+	}
+	if errors.Is(err, ErrDenied) {
+		return nil, err
+	}
+	return wrapError(err), nil
+}
+
+func httpResponse(resp *http.Response, body []byte) z.Object {
+	headers := make(map[string]z.Object, len(resp.Header))
+	for key, values := range resp.Header {
+		headers[key] = &z.String{Value: strings.Join(values, ", ")}
+	}
+	return &z.ImmutableMap{
+		Value: map[string]z.Object{
+			"status":      &z.Int{Value: int64(resp.StatusCode)},
+			"status_text": &z.String{Value: resp.Status},
+			"headers":     &z.ImmutableMap{Value: headers},
+			"body":        &z.Bytes{Value: body},
+			"url":         &z.String{Value: resp.Request.URL.String()},
+			"text": &z.UserFunction{
+				Name: "text",
+				Value: FuncARS(func() string {
+					return string(body)
+				}),
+			},
+			"json": &z.UserFunction{
+				Name: "json",
+				Value: func(args ...z.Object) (z.Object, error) {
+					if len(args) != 0 {
+						return nil, z.ErrWrongNumArguments
+					}
+					v, err := json.Decode(body)
+					if err != nil {
+						return wrapError(err), nil
+					}
+					return v, nil
+				},
+			},
+		},
+	}
+}
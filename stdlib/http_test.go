@@ -0,0 +1,136 @@
+package stdlib_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+)
+
+func newHTTPServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Add("X-Values", "a")
+		w.Header().Add("X-Values", "b")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, "%s %s %s %s", r.URL.RawQuery,
+			r.Header.Get("X-Token"), r.Header.Get("Content-Type"), body)
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"a": [1, 2]}`)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func runHTTP(opts stdlib.Options, srv *httptest.Server, input string) (*z.Compiled, error) {
+	s := z.NewScript([]byte(input))
+	_ = s.Add("base", srv.URL)
+	s.SetImports(stdlib.GetModuleMapWithOptions(opts, "http", "times"))
+	return s.Run()
+}
+
+func TestHTTP(t *testing.T) {
+	srv := newHTTPServer(t)
+	opts := stdlib.Options{HTTPTransport: srv.Client().Transport}
+
+	c, err := runHTTP(opts, srv, `
+http := import("http")
+r := http.get(base + "/echo?x=1", {query: {y: ["2", 3]}, headers: {"X-Token": "t"}})
+out := [r.status, r.status_text, r.headers["X-Method"], r.headers["X-Values"], r.text()]
+r = http.post(base + "/echo", {a: 1})
+out += [r.headers["X-Method"], string(r.body)]
+r = http.request("put", base + "/echo", {body: bytes("raw"), headers: {"Content-Type": "text/plain"}})
+out += [r.headers["X-Method"], r.text()]
+out += [http.get(base + "/json").json().a[1], r.url == base + "/echo"]
+out = string(out)
+`)
+	require.NoError(t, err)
+	require.Equal(t, `[201, "201 Created", "GET", "a, b", "x=1&y=2&y=3 t  ", `+
+		`"POST", "  application/json {\"a\":1}", "PUT", "  text/plain raw", 2, true]`,
+		c.Get("out").String())
+
+	c, err = runHTTP(opts, srv, `
+http := import("http")
+times := import("times")
+out := [
+	is_error(http.get(base + "/slow", {timeout: 50 * times.millisecond})),
+	is_error(http.get("http://[::1"))
+]
+`)
+	require.NoError(t, err)
+	require.Equal(t, `[true, true]`, c.Get("out").String())
+
+	_, err = runHTTP(opts, srv, `import("http").get(base, {foo: 1})`)
+	require.Error(t, err)
+	_, err = runHTTP(opts, srv, `import("http").get(base, {headers: "x"})`)
+	require.Error(t, err)
+}
+
+func TestHTTPHosts(t *testing.T) {
+	srv := newHTTPServer(t)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	opts := stdlib.Options{
+		HTTPTransport: srv.Client().Transport,
+		HTTPHosts:     []string{u.Host},
+	}
+	c, err := runHTTP(opts, srv, `out := import("http").get(base + "/json").status`)
+	require.NoError(t, err)
+	require.Equal(t, int64(200), c.Get("out").Value())
+
+	_, err = runHTTP(opts, srv, `import("http").get("http://example.com/")`)
+	require.True(t, errors.Is(err, stdlib.ErrDenied), err)
+
+	// redirects are checked
+	_, err = runHTTP(opts, srv,
+		`import("http").get(base + "/redirect?to=http://example.com/")`)
+	require.True(t, errors.Is(err, stdlib.ErrDenied), err)
+
+	opts.HTTPHosts = []string{"localhost", "*.example.com:443"}
+	_, err = runHTTP(opts, srv, `import("http").get(base)`)
+	require.True(t, errors.Is(err, stdlib.ErrDenied), err)
+
+	// requests are denied by default
+	_, err = runHTTP(stdlib.Options{}, srv, `import("http").get(base)`)
+	require.True(t, errors.Is(err, stdlib.ErrDenied), err)
+	s := z.NewScript([]byte(`import("http").get("http://169.254.169.254/")`))
+	s.SetImports(stdlib.GetModuleMap("http"))
+	_, err = s.Run()
+	require.True(t, errors.Is(err, stdlib.ErrDenied), err)
+	opts = stdlib.Options{HTTPHosts: []string{"*"}}
+	_, err = runHTTP(opts, srv, `import("http").get(base)`)
+	require.NoError(t, err)
+
+	// network access denied by the policy
+	opts = stdlib.Options{
+		HTTPTransport: srv.Client().Transport,
+		Policy:        &stdlib.Policy{},
+	}
+	_, err = runHTTP(opts, srv, `import("http").get(base)`)
+	require.True(t, errors.Is(err, stdlib.ErrDenied), err)
+	opts.Policy.AllowNetwork = true
+	_, err = runHTTP(opts, srv, `import("http").get(base)`)
+	require.NoError(t, err)
+}
@@ -0,0 +1,168 @@
+package stdlib
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/diiyw/z"
+)
+
+// objectReader is an io.Reader calling the 'read(buf bytes) => int/error'
+// method of an object, such as a file of the os module. The VM is the one
+// calling the function using the reader; it is used to call the method.
+type objectReader struct {
+	vm   *z.VM
+	read z.Object
+	// err is the run-time error raised by the read method, if any.
+	err error
+}
+
+func (r *objectReader) Read(p []byte) (int, error) {
+	res, err := r.vm.Call(r.read, &z.Bytes{Value: p})
+	if err != nil {
+		r.err = err
+		return 0, err
+	}
+	switch res := res.(type) {
+	case *z.Int:
+		return int(res.Value), nil
+	case *z.Error:
+		msg, _ := z.ToString(res.Value)
+		if msg == io.EOF.Error() {
+			return 0, io.EOF
+		}
+		return 0, errors.New(msg)
+	}
+	return 0, errors.New("read: int or error expected, found " +
+		res.TypeName())
+}
+
+// readerArg returns a reader for the argument: bytes and strings are read
+// directly, other objects must have a 'read' method.
+func readerArg(
+	vm *z.VM,
+	arg z.Object,
+	name string,
+) (io.Reader, *objectReader, error) {
+	switch arg := arg.(type) {
+	case *z.Bytes:
+		return bytes.NewReader(arg.Value), nil, nil
+	case *z.String:
+		return strings.NewReader(arg.Value), nil, nil
+	case *z.Map, *z.ImmutableMap:
+		read, _ := arg.IndexGet(&z.String{Value: "read"})
+		if read != nil && read.CanCall() {
+			r := &objectReader{vm: vm, read: read}
+			return r, r, nil
+		}
+	}
+	return nil, nil, z.ErrInvalidArgumentType{
+		Name:     name,
+		Expected: "bytes/string/reader",
+		Found:    arg.TypeName(),
+	}
+}
+
+// objectWriter is an io.Writer calling the 'write(buf bytes) => int/error'
+// method of an object, such as a file of the os module.
+type objectWriter struct {
+	vm    *z.VM
+	write z.Object
+	// err is the run-time error raised by the write method, if any.
+	err error
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	res, err := w.vm.Call(w.write, &z.Bytes{Value: p})
+	if err != nil {
+		w.err = err
+		return 0, err
+	}
+	switch res := res.(type) {
+	case *z.Int:
+		if int(res.Value) < len(p) {
+			return int(res.Value), io.ErrShortWrite
+		}
+		return int(res.Value), nil
+	case *z.Error:
+		msg, _ := z.ToString(res.Value)
+		return 0, errors.New(msg)
+	}
+	return 0, errors.New("write: int or error expected, found " +
+		res.TypeName())
+}
+
+// writerArg returns a writer for an object with a 'write' method.
+func writerArg(vm *z.VM, arg z.Object, name string) (*objectWriter, error) {
+	switch arg.(type) {
+	case *z.Map, *z.ImmutableMap:
+		write, _ := arg.IndexGet(&z.String{Value: "write"})
+		if write != nil && write.CanCall() {
+			return &objectWriter{vm: vm, write: write}, nil
+		}
+	}
+	return nil, z.ErrInvalidArgumentType{
+		Name:     name,
+		Expected: "writer",
+		Found:    arg.TypeName(),
+	}
+}
+
+// boundMethod returns a function calling fn with the object reader r and
+// the object writer w bound to the calling VM, either of them may be nil.
+// The methods of a reader or a writer object may be called by another VM
+// than the one that created it, such as the VM of a callback, and the
+// 'read' and 'write' methods of the source must run in that VM. A run-time
+// error raised by the source is returned as is, as are invalid arguments;
+// the other errors of fn are returned as error objects.
+func boundMethod(
+	name string,
+	r *objectReader,
+	w *objectWriter,
+	fn func(args ...z.Object) (z.Object, error),
+) *z.VMFunction {
+	return &z.VMFunction{
+		Name: name,
+		Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+			if r != nil {
+				r.vm = vm
+			}
+			if w != nil {
+				w.vm = vm
+			}
+			res, err := fn(args...)
+			if r != nil && r.err != nil {
+				return nil, r.err
+			}
+			if w != nil && w.err != nil {
+				return nil, w.err
+			}
+			var argErr z.ErrInvalidArgumentType
+			if errors.As(err, &argErr) ||
+				errors.Is(err, z.ErrWrongNumArguments) {
+				return nil, err
+			}
+			if err != nil {
+				return wrapError(err), nil
+			}
+			return res, nil
+		},
+	}
+}
+
+// dataArg returns the content of a bytes or a string argument.
+func dataArg(arg z.Object, name string) ([]byte, error) {
+	switch arg := arg.(type) {
+	case *z.Bytes:
+		return arg.Value, nil
+	case *z.String:
+		return []byte(arg.Value), nil
+	}
+	return nil, z.ErrInvalidArgumentType{
+		Name:     name,
+		Expected: "bytes/string",
+		Found:    arg.TypeName(),
+	}
+}
@@ -3,6 +3,7 @@ package stdlib
 import (
 	"bytes"
 	gojson "encoding/json"
+	"fmt"
 
 	"github.com/diiyw/z"
 	"github.com/diiyw/z/stdlib/json"
@@ -25,6 +26,14 @@ var jsonModule = map[string]z.Object{
 		Name:  "html_escape",
 		Value: jsonHTMLEscape,
 	},
+	"decoder": &z.VMFunction{
+		Name:  "decoder",
+		Value: jsonDecoder,
+	},
+	"query": &z.UserFunction{
+		Name:  "query",
+		Value: jsonQuery,
+	},
 }
 
 func jsonDecode(args ...z.Object) (ret z.Object, err error) {
@@ -59,11 +68,19 @@ func jsonDecode(args ...z.Object) (ret z.Object, err error) {
 }
 
 func jsonEncode(args ...z.Object) (ret z.Object, err error) {
-	if len(args) != 1 {
+	if len(args) != 1 && len(args) != 2 {
 		return nil, z.ErrWrongNumArguments
 	}
 
-	b, err := json.Encode(args[0])
+	var opts json.EncodeOptions
+	if len(args) == 2 {
+		opts, err = jsonEncodeOptions(args[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := json.EncodeWithOptions(args[0], opts)
 	if err != nil {
 		return &z.Error{Value: &z.String{Value: err.Error()}}, nil
 	}
@@ -144,3 +161,108 @@ func jsonHTMLEscape(args ...z.Object) (ret z.Object, err error) {
 		}
 	}
 }
+
+func jsonEncodeOptions(arg z.Object) (opts json.EncodeOptions, err error) {
+	var m map[string]z.Object
+	switch arg := arg.(type) {
+	case *z.Map:
+		m = arg.Value
+	case *z.ImmutableMap:
+		m = arg.Value
+	default:
+		return opts, z.ErrInvalidArgumentType{
+			Name:     "second",
+			Expected: "map",
+			Found:    arg.TypeName(),
+		}
+	}
+	for key, value := range m {
+		switch key {
+		case "sort_keys":
+			opts.SortKeys = !value.IsFalsy()
+		case "preserve_float":
+			opts.PreserveFloat = !value.IsFalsy()
+		case "indent", "prefix":
+			s, ok := z.ToString(value)
+			if !ok {
+				return opts, z.ErrInvalidArgumentType{
+					Name:     key,
+					Expected: "string(compatible)",
+					Found:    value.TypeName(),
+				}
+			}
+			if key == "indent" {
+				opts.Indent = s
+			} else {
+				opts.Prefix = s
+			}
+		default:
+			return opts, fmt.Errorf("unknown encode option: %s", key)
+		}
+	}
+	return opts, nil
+}
+
+func jsonQuery(args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+
+	path, ok := z.ToString(args[1])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "path",
+			Expected: "string(compatible)",
+			Found:    args[1].TypeName(),
+		}
+	}
+
+	res, err := json.Query(args[0], path)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	return &z.Array{Value: res}, nil
+}
+
+func jsonDecoder(vm *z.VM, args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+
+	r, objReader, err := readerArg(vm, args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(r)
+
+	// next returns a decoder method calling fn.
+	next := func(name string, fn func() (z.Object, error)) *z.VMFunction {
+		return boundMethod(name, objReader, nil,
+			func(args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				return fn()
+			})
+	}
+	return &z.ImmutableMap{
+		Value: map[string]z.Object{
+			// decode() => object/error
+			"decode": next("decode", dec.Decode),
+			// token() => object/error
+			"token": next("token", dec.Token),
+			// more() => bool
+			"more": next("more", func() (z.Object, error) {
+				if dec.More() {
+					return z.TrueValue, nil
+				}
+				return z.FalseValue, nil
+			}),
+			// offset() => int
+			"offset": &z.UserFunction{
+				Name:  "offset",
+				Value: FuncARI64(dec.InputOffset),
+			},
+		},
+	}, nil
+}
@@ -9,8 +9,10 @@ package json
 import (
 	"bytes"
 	"encoding/base64"
+	gojson "encoding/json"
 	"errors"
 	"math"
+	"sort"
 	"strconv"
 	"unicode/utf8"
 
@@ -124,75 +126,63 @@ var safeSet = [utf8.RuneSelf]bool{
 
 var hex = "0123456789abcdef"
 
+// EncodeOptions controls the output of EncodeWithOptions.
+type EncodeOptions struct {
+	// SortKeys sorts the keys of the maps.
+	SortKeys bool
+
+	// Prefix and Indent, if either is set, are used to indent the output
+	// like Go's json.Indent.
+	Prefix string
+	Indent string
+
+	// PreserveFloat encodes the floats with an integer value with a decimal
+	// point (e.g. 1.0) so they are decoded as floats.
+	PreserveFloat bool
+}
+
 // Encode returns the JSON encoding of the object.
 func Encode(o z.Object) ([]byte, error) {
-	var b []byte
+	return encode(nil, o, &EncodeOptions{})
+}
 
+// EncodeWithOptions returns the JSON encoding of the object using the
+// options.
+func EncodeWithOptions(o z.Object, opts EncodeOptions) ([]byte, error) {
+	b, err := encode(nil, o, &opts)
+	if err != nil || opts.Prefix == "" && opts.Indent == "" {
+		return b, err
+	}
+	var dst bytes.Buffer
+	if err := gojson.Indent(&dst, b, opts.Prefix, opts.Indent); err != nil {
+		return nil, err
+	}
+	return dst.Bytes(), nil
+}
+
+func encode(b []byte, o z.Object, opts *EncodeOptions) ([]byte, error) {
+	var err error
 	switch o := o.(type) {
 	case *z.Array:
-		b = append(b, '[')
-		len1 := len(o.Value) - 1
-		for idx, elem := range o.Value {
-			eb, err := Encode(elem)
-			if err != nil {
-				return nil, err
-			}
-			b = append(b, eb...)
-			if idx < len1 {
-				b = append(b, ',')
-			}
+		b, err = encodeArray(b, o.Value, opts)
+		if err != nil {
+			return nil, err
 		}
-		b = append(b, ']')
 	case *z.ImmutableArray:
-		b = append(b, '[')
-		len1 := len(o.Value) - 1
-		for idx, elem := range o.Value {
-			eb, err := Encode(elem)
-			if err != nil {
-				return nil, err
-			}
-			b = append(b, eb...)
-			if idx < len1 {
-				b = append(b, ',')
-			}
+		b, err = encodeArray(b, o.Value, opts)
+		if err != nil {
+			return nil, err
 		}
-		b = append(b, ']')
 	case *z.Map:
-		b = append(b, '{')
-		len1 := len(o.Value) - 1
-		idx := 0
-		for key, value := range o.Value {
-			b = encodeString(b, key)
-			b = append(b, ':')
-			eb, err := Encode(value)
-			if err != nil {
-				return nil, err
-			}
-			b = append(b, eb...)
-			if idx < len1 {
-				b = append(b, ',')
-			}
-			idx++
+		b, err = encodeMap(b, o.Value, opts)
+		if err != nil {
+			return nil, err
 		}
-		b = append(b, '}')
 	case *z.ImmutableMap:
-		b = append(b, '{')
-		len1 := len(o.Value) - 1
-		idx := 0
-		for key, value := range o.Value {
-			b = encodeString(b, key)
-			b = append(b, ':')
-			eb, err := Encode(value)
-			if err != nil {
-				return nil, err
-			}
-			b = append(b, eb...)
-			if idx < len1 {
-				b = append(b, ',')
-			}
-			idx++
+		b, err = encodeMap(b, o.Value, opts)
+		if err != nil {
+			return nil, err
 		}
-		b = append(b, '}')
 	case *z.Bool:
 		if o.IsFalsy() {
 			b = strconv.AppendBool(b, false)
@@ -235,9 +225,16 @@ func Encode(o z.Object) ([]byte, error) {
 			}
 		}
 
+		if opts.PreserveFloat && fmt == 'f' && !bytes.ContainsRune(y, '.') {
+			y = append(y, ".0"...)
+		}
 		b = append(b, y...)
 	case *z.Int:
 		b = strconv.AppendInt(b, o.Value, 10)
+	case *z.BigInt:
+		b = o.Value.Append(b, 10)
+	case *z.Decimal:
+		b = append(b, o.String()...)
 	case *z.String:
 		// string encoding bug is fixed with newly introduced function
 		// encodeString(). See: https://github.com/diiyw/z/issues/268
@@ -256,6 +253,49 @@ func Encode(o z.Object) ([]byte, error) {
 	return b, nil
 }
 
+func encodeArray(b []byte, arr []z.Object, opts *EncodeOptions) ([]byte, error) {
+	b = append(b, '[')
+	for idx, elem := range arr {
+		if idx > 0 {
+			b = append(b, ',')
+		}
+		var err error
+		b, err = encode(b, elem, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(b, ']'), nil
+}
+
+func encodeMap(
+	b []byte,
+	m map[string]z.Object,
+	opts *EncodeOptions,
+) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	if opts.SortKeys {
+		sort.Strings(keys)
+	}
+	b = append(b, '{')
+	for idx, key := range keys {
+		if idx > 0 {
+			b = append(b, ',')
+		}
+		b = encodeString(b, key)
+		b = append(b, ':')
+		var err error
+		b, err = encode(b, m[key], opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
 // encodeString encodes given string as JSON string according to
 // https://www.json.org/img/string.png
 // Implementation is inspired by https://github.com/json-iterator/go
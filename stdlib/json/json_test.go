@@ -2,6 +2,8 @@ package json_test
 
 import (
 	gojson "encoding/json"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/diiyw/z"
@@ -109,3 +111,46 @@ func testJSONEncodeDecode(t *testing.T, v any) {
 
 	require.Equal(t, vj, aj)
 }
+
+func TestDecoder(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader("{\"a\": [1, 2.5]}\n\"b\"\n"))
+	v, err := dec.Decode()
+	require.NoError(t, err)
+	b, err := json.Encode(v)
+	require.NoError(t, err)
+	require.Equal(t, `{"a":[1,2.5]}`, string(b))
+	v, err = dec.Decode()
+	require.NoError(t, err)
+	require.Equal(t, "b", z.ToInterface(v))
+	_, err = dec.Decode()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestEncodeWithOptions(t *testing.T) {
+	o, err := z.FromInterface(MAP{"c": 3.0, "a": ARR{1, 1e21}, "b": -0.5})
+	require.NoError(t, err)
+	b, err := json.EncodeWithOptions(o, json.EncodeOptions{
+		SortKeys:      true,
+		PreserveFloat: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, `{"a":[1,1e+21],"b":-0.5,"c":3.0}`, string(b))
+
+	a, err := json.Decode(b)
+	require.NoError(t, err)
+	require.Equal(t, 3.0, z.ToInterface(a).(MAP)["c"].(float64))
+}
+
+func TestQuery(t *testing.T) {
+	o, err := json.Decode([]byte(`{"a": [{"b": 1}, {"b": 2}], "c": {"b": 3}}`))
+	require.NoError(t, err)
+	res, err := json.Query(o, "$..b")
+	require.NoError(t, err)
+	require.Equal(t, 3, len(res))
+	require.Equal(t, int64(3), z.ToInterface(res[2]))
+
+	for _, path := range []string{"a", "$.", "$[", "$[1:2:0]", "$[?(@.a ~ 1)]"} {
+		_, err := json.Query(o, path)
+		require.Error(t, err, path)
+	}
+}
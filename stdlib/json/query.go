@@ -0,0 +1,505 @@
+package json
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/diiyw/z"
+)
+
+// Query evaluates the JSONPath expression path against the decoded value o
+// and returns the matching values in document order. Map members are
+// visited in key order.
+//
+// Supported syntax: the root '$', members '.name' and ['name'], wildcards
+// '.*' and [*], recursive descent '..name' and '..*', indexes [n] (negative
+// indexes count from the end), slices [start:end:step], unions [a,b] of
+// names or indexes, and filters [?(@.name op value)] where op is one of
+// ==, !=, <, <=, > and >=, or [?(@.name)] to test for existence.
+func Query(o z.Object, path string) ([]z.Object, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []z.Object{o}
+	for _, s := range steps {
+		var next []z.Object
+		for _, node := range nodes {
+			if s.recursive {
+				for _, d := range descendants(node) {
+					next = s.apply(next, d)
+				}
+			} else {
+				next = s.apply(next, node)
+			}
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+type pathStep struct {
+	recursive bool
+	wildcard  bool
+	names     []string
+	indexes   []int
+	slice     *[3]*int
+	filter    *pathFilter
+}
+
+type pathFilter struct {
+	path  []string
+	op    string
+	value z.Object
+}
+
+func (s *pathStep) apply(out []z.Object, o z.Object) []z.Object {
+	switch {
+	case s.wildcard:
+		return append(out, children(o)...)
+	case s.names != nil:
+		m := mapValue(o)
+		for _, name := range s.names {
+			if v, ok := m[name]; ok {
+				out = append(out, v)
+			}
+		}
+	case s.indexes != nil:
+		arr, ok := arrayValue(o)
+		if !ok {
+			return out
+		}
+		for _, idx := range s.indexes {
+			if idx < 0 {
+				idx += len(arr)
+			}
+			if idx >= 0 && idx < len(arr) {
+				out = append(out, arr[idx])
+			}
+		}
+	case s.slice != nil:
+		arr, ok := arrayValue(o)
+		if !ok {
+			return out
+		}
+		start, end, step := sliceBounds(s.slice, len(arr))
+		if step > 0 {
+			for i := start; i < end; i += step {
+				out = append(out, arr[i])
+			}
+		} else {
+			for i := start; i > end; i += step {
+				out = append(out, arr[i])
+			}
+		}
+	case s.filter != nil:
+		for _, child := range children(o) {
+			if s.filter.match(child) {
+				out = append(out, child)
+			}
+		}
+	}
+	return out
+}
+
+func (f *pathFilter) match(o z.Object) bool {
+	for _, name := range f.path {
+		m := mapValue(o)
+		v, ok := m[name]
+		if !ok {
+			return false
+		}
+		o = v
+	}
+	if f.op == "" {
+		return true
+	}
+	switch f.op {
+	case "==":
+		return o.Equals(f.value) || numberCompare(o, f.value) == 0
+	case "!=":
+		return !o.Equals(f.value) && numberCompare(o, f.value) != 0
+	}
+	c := numberCompare(o, f.value)
+	if c == -2 {
+		s1, ok1 := o.(*z.String)
+		s2, ok2 := f.value.(*z.String)
+		if !ok1 || !ok2 {
+			return false
+		}
+		c = strings.Compare(s1.Value, s2.Value)
+	}
+	switch f.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// numberCompare compares two numbers, returning -2 if either of the values
+// is not a number.
+func numberCompare(a, b z.Object) int {
+	x, ok1 := numberValue(a)
+	y, ok2 := numberValue(b)
+	switch {
+	case !ok1 || !ok2:
+		return -2
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func numberValue(o z.Object) (float64, bool) {
+	switch o := o.(type) {
+	case *z.Int:
+		return float64(o.Value), true
+	case *z.Float:
+		return o.Value, true
+	}
+	return 0, false
+}
+
+func mapValue(o z.Object) map[string]z.Object {
+	switch o := o.(type) {
+	case *z.Map:
+		return o.Value
+	case *z.ImmutableMap:
+		return o.Value
+	}
+	return nil
+}
+
+func arrayValue(o z.Object) ([]z.Object, bool) {
+	switch o := o.(type) {
+	case *z.Array:
+		return o.Value, true
+	case *z.ImmutableArray:
+		return o.Value, true
+	}
+	return nil, false
+}
+
+// children returns the elements of an array or the values of a map in key
+// order.
+func children(o z.Object) []z.Object {
+	if arr, ok := arrayValue(o); ok {
+		return arr
+	}
+	m := mapValue(o)
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]z.Object, len(keys))
+	for idx, key := range keys {
+		values[idx] = m[key]
+	}
+	return values
+}
+
+// descendants returns o and all the values it contains.
+func descendants(o z.Object) []z.Object {
+	res := []z.Object{o}
+	for _, child := range children(o) {
+		res = append(res, descendants(child)...)
+	}
+	return res
+}
+
+func sliceBounds(s *[3]*int, n int) (start, end, step int) {
+	step = 1
+	if s[2] != nil {
+		step = *s[2]
+	}
+	norm := func(i int) int {
+		if i < 0 {
+			i += n
+		}
+		if i < 0 {
+			return -1
+		}
+		if i > n {
+			return n
+		}
+		return i
+	}
+	if step > 0 {
+		start, end = 0, n
+		if s[0] != nil {
+			start = max(norm(*s[0]), 0)
+		}
+		if s[1] != nil {
+			end = max(norm(*s[1]), 0)
+		}
+		return start, end, step
+	}
+	start, end = n-1, -1
+	if s[0] != nil {
+		start = min(norm(*s[0]), n-1)
+	}
+	if s[1] != nil {
+		end = norm(*s[1])
+	}
+	return start, end, step
+}
+
+// pathParser parses a JSONPath expression.
+type pathParser struct {
+	src string
+	pos int
+}
+
+func parsePath(src string) ([]*pathStep, error) {
+	p := &pathParser{src: strings.TrimSpace(src)}
+	if !strings.HasPrefix(p.src, "$") {
+		return nil, p.errorf("path must start with '$'")
+	}
+	p.pos = 1
+	var steps []*pathStep
+	for p.pos < len(p.src) {
+		step, err := p.step()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func (p *pathParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid JSONPath at offset %d: %s", p.pos,
+		fmt.Sprintf(format, args...))
+}
+
+func (p *pathParser) step() (*pathStep, error) {
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], ".."):
+		p.pos += 2
+		var step *pathStep
+		var err error
+		if p.pos < len(p.src) && p.src[p.pos] == '[' {
+			step, err = p.bracket()
+		} else {
+			step, err = p.member()
+		}
+		if err != nil {
+			return nil, err
+		}
+		step.recursive = true
+		return step, nil
+	case p.src[p.pos] == '.':
+		p.pos++
+		return p.member()
+	case p.src[p.pos] == '[':
+		return p.bracket()
+	}
+	return nil, p.errorf("unexpected %q", p.src[p.pos])
+}
+
+func (p *pathParser) member() (*pathStep, error) {
+	if p.pos < len(p.src) && p.src[p.pos] == '*' {
+		p.pos++
+		return &pathStep{wildcard: true}, nil
+	}
+	name := p.ident()
+	if name == "" {
+		return nil, p.errorf("member name expected")
+	}
+	return &pathStep{names: []string{name}}, nil
+}
+
+func (p *pathParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '.' || c == '[' || c == ' ' || c == ')' || c == '=' ||
+			c == '!' || c == '<' || c == '>' {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *pathParser) bracket() (*pathStep, error) {
+	end := p.closing()
+	if end < 0 {
+		return nil, p.errorf("missing ']'")
+	}
+	inner := strings.TrimSpace(p.src[p.pos+1 : end])
+	p.pos++
+	var step *pathStep
+	var err error
+	switch {
+	case inner == "*":
+		step = &pathStep{wildcard: true}
+	case strings.HasPrefix(inner, "?"):
+		step, err = p.filter(inner)
+	case strings.HasPrefix(inner, "'") || strings.HasPrefix(inner, `"`):
+		step = &pathStep{names: []string{}}
+		for _, part := range splitUnion(inner) {
+			name, ok := unquoteName(part)
+			if !ok {
+				return nil, p.errorf("invalid member name %s", part)
+			}
+			step.names = append(step.names, name)
+		}
+	case strings.Contains(inner, ":"):
+		step, err = p.slice(inner)
+	default:
+		step = &pathStep{indexes: []int{}}
+		for _, part := range splitUnion(inner) {
+			idx, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, p.errorf("invalid index %q", part)
+			}
+			step.indexes = append(step.indexes, idx)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.pos = end + 1
+	return step, nil
+}
+
+// closing returns the position of the ']' closing the bracket at p.pos,
+// skipping quoted strings.
+func (p *pathParser) closing() int {
+	var quote byte
+	for i := p.pos + 1; i < len(p.src); i++ {
+		c := p.src[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *pathParser) slice(inner string) (*pathStep, error) {
+	parts := strings.Split(inner, ":")
+	if len(parts) > 3 {
+		return nil, p.errorf("invalid slice %q", inner)
+	}
+	var bounds [3]*int
+	for idx, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, p.errorf("invalid slice %q", inner)
+		}
+		bounds[idx] = &n
+	}
+	if bounds[2] != nil && *bounds[2] == 0 {
+		return nil, p.errorf("slice step cannot be zero")
+	}
+	return &pathStep{slice: &bounds}, nil
+}
+
+func (p *pathParser) filter(inner string) (*pathStep, error) {
+	expr := strings.TrimSpace(inner[1:])
+	if !strings.HasPrefix(expr, "(") || !strings.HasSuffix(expr, ")") {
+		return nil, p.errorf("invalid filter %q", inner)
+	}
+	expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	if !strings.HasPrefix(expr, "@") {
+		return nil, p.errorf("filter must start with '@'")
+	}
+	f := &pathFilter{}
+	sub := &pathParser{src: expr, pos: 1}
+	for sub.pos < len(sub.src) && sub.src[sub.pos] == '.' {
+		sub.pos++
+		name := sub.ident()
+		if name == "" {
+			return nil, p.errorf("invalid filter %q", inner)
+		}
+		f.path = append(f.path, name)
+	}
+	rest := strings.TrimSpace(sub.src[sub.pos:])
+	if rest == "" {
+		return &pathStep{filter: f}, nil
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(rest, op) {
+			f.op = op
+			break
+		}
+	}
+	if f.op == "" {
+		return nil, p.errorf("invalid filter %q", inner)
+	}
+	literal := strings.TrimSpace(rest[len(f.op):])
+	if strings.HasPrefix(literal, "'") {
+		name, ok := unquoteName(literal)
+		if !ok {
+			return nil, p.errorf("invalid filter value %s", literal)
+		}
+		f.value = &z.String{Value: name}
+	} else {
+		v, err := Decode([]byte(literal))
+		if err != nil {
+			return nil, p.errorf("invalid filter value %s", literal)
+		}
+		f.value = v
+	}
+	return &pathStep{filter: f}, nil
+}
+
+// splitUnion splits the comma separated members of a union, ignoring the
+// commas inside quoted names.
+func splitUnion(s string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ',':
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// unquoteName unquotes a single or double quoted member name.
+func unquoteName(s string) (string, bool) {
+	if len(s) < 2 || s[0] != s[len(s)-1] || (s[0] != '\'' && s[0] != '"') {
+		return "", false
+	}
+	if s[0] == '\'' {
+		s = `"` + strings.ReplaceAll(strings.ReplaceAll(s[1:len(s)-1],
+			`\'`, `'`), `"`, `\"`) + `"`
+	}
+	return unquote([]byte(s))
+}
@@ -0,0 +1,82 @@
+package json
+
+import (
+	gojson "encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/diiyw/z"
+)
+
+// Decoder reads and decodes a stream of JSON values, such as newline
+// delimited JSON, from an input stream. Values can be decoded one by one or
+// token by token.
+type Decoder struct {
+	dec *gojson.Decoder
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	dec := gojson.NewDecoder(r)
+	dec.UseNumber()
+	return &Decoder{dec: dec}
+}
+
+// Decode reads the next JSON value from the input. It returns io.EOF at the
+// end of the input.
+func (d *Decoder) Decode() (z.Object, error) {
+	var raw gojson.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return Decode(raw)
+}
+
+// Token returns the next JSON token in the input stream. Delimiters are
+// returned as Char values ('[', ']', '{' and '}'), null as Undefined. Commas
+// and colons are skipped. It returns io.EOF at the end of the input.
+func (d *Decoder) Token() (z.Object, error) {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case gojson.Delim:
+		return &z.Char{Value: rune(tok)}, nil
+	case gojson.Number:
+		return parseNumber(string(tok)), nil
+	case string:
+		return &z.String{Value: tok}, nil
+	case bool:
+		if tok {
+			return z.TrueValue, nil
+		}
+		return z.FalseValue, nil
+	}
+	return z.UndefinedValue, nil
+}
+
+// More reports whether there is another element in the current array or
+// object, or another value in the input stream.
+func (d *Decoder) More() bool {
+	return d.dec.More()
+}
+
+// InputOffset returns the input stream byte offset of the current decoder
+// position.
+func (d *Decoder) InputOffset() int64 {
+	return d.dec.InputOffset()
+}
+
+// parseNumber returns an Int or a Float depending on the notation of the
+// number, the same way Decode does.
+func parseNumber(s string) z.Object {
+	if !strings.ContainsAny(s, ".eE") {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return &z.Int{Value: n}
+		}
+	}
+	n, _ := strconv.ParseFloat(s, 64)
+	return &z.Float{Value: n}
+}
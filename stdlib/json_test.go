@@ -1,6 +1,14 @@
 package stdlib_test
 
-import "testing"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/z/require"
+)
 
 func TestJSON(t *testing.T) {
 	module(t, "json").call("encode", 5).
@@ -82,3 +90,103 @@ func TestJSON(t *testing.T) {
 		expect([]byte(
 			`{"M":"\u003chtml\u003efoo \u0026\u2028 \u2029\u003c/html\u003e"}`))
 }
+
+func TestJSONEncodeOptions(t *testing.T) {
+	module(t, "json").
+		call("encode", MAP{"b": 1.0, "a": ARR{2, 2.5}},
+			MAP{"sort_keys": true, "preserve_float": true}).
+		expect([]byte(`{"a":[2,2.5],"b":1.0}`))
+	module(t, "json").
+		call("encode", MAP{"b": 1, "a": 2},
+			MAP{"sort_keys": true, "indent": "  "}).
+		expect([]byte("{\n  \"a\": 2,\n  \"b\": 1\n}"))
+	module(t, "json").call("encode", 1.0).expect([]byte("1"))
+	module(t, "json").call("encode", 1, MAP{"foo": true}).expectError()
+}
+
+func TestJSONEncodeBigNumbers(t *testing.T) {
+	expect(t, `
+json := import("json")
+dec := import("decimal")
+out := string(json.encode({a: dec.bigint(1) << 70, b: [dec.new("-1.50"), dec.new("0.001")]},
+	{sort_keys: true}))
+`, `{"a":1180591620717411303424,"b":[-1.50,0.001]}`)
+}
+
+func TestJSONQuery(t *testing.T) {
+	doc := `{"items": [{"id": 1, "price": 10}, {"id": 2, "price": 25},
+		{"id": 3, "tags": ["a", "b"]}], "meta": {"id": "m"}}`
+	for _, c := range []struct {
+		path     string
+		expected string
+	}{
+		{`$.items[*].id`, `[1, 2, 3]`},
+		{`$['items'][0].price`, `[10]`},
+		{`$.items[-1].tags[*]`, `["a", "b"]`},
+		{`$.items[0:2].id`, `[1, 2]`},
+		{`$.items[::-1].id`, `[3, 2, 1]`},
+		{`$.items[0,2].id`, `[1, 3]`},
+		{`$..id`, `[1, 2, 3, "m"]`},
+		{`$.items[?(@.price > 15)].id`, `[2]`},
+		{`$.items[?(@.tags)].id`, `[3]`},
+		{`$.meta[?(@ == 'm')]`, `["m"]`},
+		{`$.missing.id`, `[]`},
+	} {
+		expect(t, `json := import("json")
+out := string(json.query(json.decode(`+"`"+doc+"`"+`), "`+c.path+`"))`,
+			c.expected)
+	}
+	expect(t, `out := is_error(import("json").query({}, "items"))`, true)
+	expect(t, `out := is_error(import("json").query({}, "$.a[1"))`, true)
+}
+
+func TestJSONDecoder(t *testing.T) {
+	expect(t, `
+json := import("json")
+dec := json.decoder("{\"id\": 1}\n{\"id\": 2.5}\n\n[3]\n")
+out := []
+for dec.more() {
+	out = append(out, dec.decode())
+}
+out = string(out) + string(is_error(dec.decode()))
+`, `[{id: 1}, {id: 2.5}, [3]]true`)
+
+	expect(t, `
+json := import("json")
+dec := json.decoder(bytes("[{\"a\": null}, true, \"x\"]"))
+out := []
+for tok := dec.token(); !is_error(tok); tok = dec.token() {
+	out = append(out, tok)
+}
+out = string(out)
+`, `[[, {, "a", <undefined>, }, true, "x", ]]`)
+
+	expect(t, `
+json := import("json")
+dec := json.decoder("[1, 2] {")
+dec.decode()
+out := string(dec.offset()) + string(is_error(dec.decode()))
+`, "6true")
+}
+
+func TestJSONDecoderFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.ndjson")
+	var lines []string
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, fmt.Sprintf(`{"n": %d}`, i))
+	}
+	require.NoError(t, os.WriteFile(name,
+		[]byte(strings.Join(lines, "\n")), 0644))
+
+	expect(t, `
+json := import("json")
+os := import("os")
+f := os.open("`+filepath.ToSlash(name)+`")
+dec := json.decoder(f)
+out := 0
+for dec.more() {
+	out += dec.decode().n
+}
+f.close()
+`, int64(499500))
+}
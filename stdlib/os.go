@@ -5,204 +5,224 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/diiyw/z"
 )
 
-var osModule = map[string]z.Object{
-	"platform":            &z.String{Value: runtime.GOOS},
-	"arch":                &z.String{Value: runtime.GOARCH},
-	"o_rdonly":            &z.Int{Value: int64(os.O_RDONLY)},
-	"o_wronly":            &z.Int{Value: int64(os.O_WRONLY)},
-	"o_rdwr":              &z.Int{Value: int64(os.O_RDWR)},
-	"o_append":            &z.Int{Value: int64(os.O_APPEND)},
-	"o_create":            &z.Int{Value: int64(os.O_CREATE)},
-	"o_excl":              &z.Int{Value: int64(os.O_EXCL)},
-	"o_sync":              &z.Int{Value: int64(os.O_SYNC)},
-	"o_trunc":             &z.Int{Value: int64(os.O_TRUNC)},
-	"mode_dir":            &z.Int{Value: int64(os.ModeDir)},
-	"mode_append":         &z.Int{Value: int64(os.ModeAppend)},
-	"mode_exclusive":      &z.Int{Value: int64(os.ModeExclusive)},
-	"mode_temporary":      &z.Int{Value: int64(os.ModeTemporary)},
-	"mode_symlink":        &z.Int{Value: int64(os.ModeSymlink)},
-	"mode_device":         &z.Int{Value: int64(os.ModeDevice)},
-	"mode_named_pipe":     &z.Int{Value: int64(os.ModeNamedPipe)},
-	"mode_socket":         &z.Int{Value: int64(os.ModeSocket)},
-	"mode_setuid":         &z.Int{Value: int64(os.ModeSetuid)},
-	"mode_setgui":         &z.Int{Value: int64(os.ModeSetgid)},
-	"mode_char_device":    &z.Int{Value: int64(os.ModeCharDevice)},
-	"mode_sticky":         &z.Int{Value: int64(os.ModeSticky)},
-	"mode_type":           &z.Int{Value: int64(os.ModeType)},
-	"mode_perm":           &z.Int{Value: int64(os.ModePerm)},
-	"path_separator":      &z.Char{Value: os.PathSeparator},
-	"path_list_separator": &z.Char{Value: os.PathListSeparator},
-	"dev_null":            &z.String{Value: os.DevNull},
-	"seek_set":            &z.Int{Value: int64(io.SeekStart)},
-	"seek_cur":            &z.Int{Value: int64(io.SeekCurrent)},
-	"seek_end":            &z.Int{Value: int64(io.SeekEnd)},
-	"args": &z.UserFunction{
-		Name:  "args",
-		Value: osArgs,
-	}, // args() => array(string)
-	"chdir": &z.UserFunction{
-		Name:  "chdir",
-		Value: FuncASRE(os.Chdir),
-	}, // chdir(dir string) => error
-	"chmod": osFuncASFmRE("chmod", os.Chmod), // chmod(name string, mode int) => error
-	"chown": &z.UserFunction{
-		Name:  "chown",
-		Value: FuncASIIRE(os.Chown),
-	}, // chown(name string, uid int, gid int) => error
-	"clearenv": &z.UserFunction{
-		Name:  "clearenv",
-		Value: FuncAR(os.Clearenv),
-	}, // clearenv()
-	"environ": &z.UserFunction{
-		Name:  "environ",
-		Value: FuncARSs(os.Environ),
-	}, // environ() => array(string)
-	"exit": &z.UserFunction{
-		Name:  "exit",
-		Value: FuncAIR(os.Exit),
-	}, // exit(code int)
-	"expand_env": &z.UserFunction{
-		Name:  "expand_env",
-		Value: osExpandEnv,
-	}, // expand_env(s string) => string
-	"getegid": &z.UserFunction{
-		Name:  "getegid",
-		Value: FuncARI(os.Getegid),
-	}, // getegid() => int
-	"getenv": &z.UserFunction{
-		Name:  "getenv",
-		Value: FuncASRS(os.Getenv),
-	}, // getenv(s string) => string
-	"geteuid": &z.UserFunction{
-		Name:  "geteuid",
-		Value: FuncARI(os.Geteuid),
-	}, // geteuid() => int
-	"getgid": &z.UserFunction{
-		Name:  "getgid",
-		Value: FuncARI(os.Getgid),
-	}, // getgid() => int
-	"getgroups": &z.UserFunction{
-		Name:  "getgroups",
-		Value: FuncARIsE(os.Getgroups),
-	}, // getgroups() => array(string)/error
-	"getpagesize": &z.UserFunction{
-		Name:  "getpagesize",
-		Value: FuncARI(os.Getpagesize),
-	}, // getpagesize() => int
-	"getpid": &z.UserFunction{
-		Name:  "getpid",
-		Value: FuncARI(os.Getpid),
-	}, // getpid() => int
-	"getppid": &z.UserFunction{
-		Name:  "getppid",
-		Value: FuncARI(os.Getppid),
-	}, // getppid() => int
-	"getuid": &z.UserFunction{
-		Name:  "getuid",
-		Value: FuncARI(os.Getuid),
-	}, // getuid() => int
-	"getwd": &z.UserFunction{
-		Name:  "getwd",
-		Value: FuncARSE(os.Getwd),
-	}, // getwd() => string/error
-	"hostname": &z.UserFunction{
-		Name:  "hostname",
-		Value: FuncARSE(os.Hostname),
-	}, // hostname() => string/error
-	"lchown": &z.UserFunction{
-		Name:  "lchown",
-		Value: FuncASIIRE(os.Lchown),
-	}, // lchown(name string, uid int, gid int) => error
-	"link": &z.UserFunction{
-		Name:  "link",
-		Value: FuncASSRE(os.Link),
-	}, // link(oldname string, newname string) => error
-	"lookup_env": &z.UserFunction{
-		Name:  "lookup_env",
-		Value: osLookupEnv,
-	}, // lookup_env(key string) => string/false
-	"mkdir":     osFuncASFmRE("mkdir", os.Mkdir),        // mkdir(name string, perm int) => error
-	"mkdir_all": osFuncASFmRE("mkdir_all", os.MkdirAll), // mkdir_all(name string, perm int) => error
-	"readlink": &z.UserFunction{
-		Name:  "readlink",
-		Value: FuncASRSE(os.Readlink),
-	}, // readlink(name string) => string/error
-	"remove": &z.UserFunction{
-		Name:  "remove",
-		Value: FuncASRE(os.Remove),
-	}, // remove(name string) => error
-	"remove_all": &z.UserFunction{
-		Name:  "remove_all",
-		Value: FuncASRE(os.RemoveAll),
-	}, // remove_all(name string) => error
-	"rename": &z.UserFunction{
-		Name:  "rename",
-		Value: FuncASSRE(os.Rename),
-	}, // rename(oldpath string, newpath string) => error
-	"setenv": &z.UserFunction{
-		Name:  "setenv",
-		Value: FuncASSRE(os.Setenv),
-	}, // setenv(key string, value string) => error
-	"symlink": &z.UserFunction{
-		Name:  "symlink",
-		Value: FuncASSRE(os.Symlink),
-	}, // symlink(oldname string newname string) => error
-	"temp_dir": &z.UserFunction{
-		Name:  "temp_dir",
-		Value: FuncARS(os.TempDir),
-	}, // temp_dir() => string
-	"truncate": &z.UserFunction{
-		Name:  "truncate",
-		Value: FuncASI64RE(os.Truncate),
-	}, // truncate(name string, size int) => error
-	"unsetenv": &z.UserFunction{
-		Name:  "unsetenv",
-		Value: FuncASRE(os.Unsetenv),
-	}, // unsetenv(key string) => error
-	"create": &z.UserFunction{
-		Name:  "create",
-		Value: osCreate,
-	}, // create(name string) => imap(file)/error
-	"open": &z.UserFunction{
-		Name:  "open",
-		Value: osOpen,
-	}, // open(name string) => imap(file)/error
-	"open_file": &z.UserFunction{
-		Name:  "open_file",
-		Value: osOpenFile,
-	}, // open_file(name string, flag int, perm int) => imap(file)/error
-	"find_process": &z.UserFunction{
-		Name:  "find_process",
-		Value: osFindProcess,
-	}, // find_process(pid int) => imap(process)/error
-	"start_process": &z.UserFunction{
-		Name:  "start_process",
-		Value: osStartProcess,
-	}, // start_process(name string, argv array(string), dir string, env array(string)) => imap(process)/error
-	"exec_look_path": &z.UserFunction{
-		Name:  "exec_look_path",
-		Value: FuncASRSE(exec.LookPath),
-	}, // exec_look_path(file) => string/error
-	"exec": &z.UserFunction{
-		Name:  "exec",
-		Value: osExec,
-	}, // exec(name, args...) => command
-	"stat": &z.UserFunction{
-		Name:  "stat",
-		Value: osStat,
-	}, // stat(name) => imap(fileinfo)/error
-	"read_file": &z.UserFunction{
-		Name:  "read_file",
-		Value: osReadFile,
-	}, // readfile(name) => array(byte)/error
+var osModule = (&osLib{}).module()
+
+// osLib creates the os module functions that consult the policy and access
+// the file system fs. os.args returns args, os.Args if nil.
+type osLib struct {
+	policy *Policy
+	fs     FS
+	args   []string
+}
+
+func (l *osLib) module() map[string]z.Object {
+	if l.fs == nil {
+		l.fs = OSFS{}
+	}
+	return map[string]z.Object{
+		"platform":            &z.String{Value: runtime.GOOS},
+		"arch":                &z.String{Value: runtime.GOARCH},
+		"o_rdonly":            &z.Int{Value: int64(os.O_RDONLY)},
+		"o_wronly":            &z.Int{Value: int64(os.O_WRONLY)},
+		"o_rdwr":              &z.Int{Value: int64(os.O_RDWR)},
+		"o_append":            &z.Int{Value: int64(os.O_APPEND)},
+		"o_create":            &z.Int{Value: int64(os.O_CREATE)},
+		"o_excl":              &z.Int{Value: int64(os.O_EXCL)},
+		"o_sync":              &z.Int{Value: int64(os.O_SYNC)},
+		"o_trunc":             &z.Int{Value: int64(os.O_TRUNC)},
+		"mode_dir":            &z.Int{Value: int64(os.ModeDir)},
+		"mode_append":         &z.Int{Value: int64(os.ModeAppend)},
+		"mode_exclusive":      &z.Int{Value: int64(os.ModeExclusive)},
+		"mode_temporary":      &z.Int{Value: int64(os.ModeTemporary)},
+		"mode_symlink":        &z.Int{Value: int64(os.ModeSymlink)},
+		"mode_device":         &z.Int{Value: int64(os.ModeDevice)},
+		"mode_named_pipe":     &z.Int{Value: int64(os.ModeNamedPipe)},
+		"mode_socket":         &z.Int{Value: int64(os.ModeSocket)},
+		"mode_setuid":         &z.Int{Value: int64(os.ModeSetuid)},
+		"mode_setgui":         &z.Int{Value: int64(os.ModeSetgid)},
+		"mode_char_device":    &z.Int{Value: int64(os.ModeCharDevice)},
+		"mode_sticky":         &z.Int{Value: int64(os.ModeSticky)},
+		"mode_type":           &z.Int{Value: int64(os.ModeType)},
+		"mode_perm":           &z.Int{Value: int64(os.ModePerm)},
+		"path_separator":      &z.Char{Value: os.PathSeparator},
+		"path_list_separator": &z.Char{Value: os.PathListSeparator},
+		"dev_null":            &z.String{Value: os.DevNull},
+		"seek_set":            &z.Int{Value: int64(io.SeekStart)},
+		"seek_cur":            &z.Int{Value: int64(io.SeekCurrent)},
+		"seek_end":            &z.Int{Value: int64(io.SeekEnd)},
+		"args": &z.UserFunction{
+			Name:  "args",
+			Value: l.osArgs,
+		}, // args() => array(string)
+		"chdir": &z.UserFunction{
+			Name:  "chdir",
+			Value: l.guard(FuncASRE(os.Chdir), l.chdir("os.chdir"), 0),
+		}, // chdir(dir string) => error
+		"chmod": l.guardFunc(osFuncASFmRE("chmod", l.fs.Chmod),
+			l.path("os.chmod", AccessWrite), 0), // chmod(name string, mode int) => error
+		"chown": &z.UserFunction{
+			Name:  "chown",
+			Value: l.guard(FuncASIIRE(l.fs.Chown), l.path("os.chown", AccessWrite), 0),
+		}, // chown(name string, uid int, gid int) => error
+		"clearenv": &z.UserFunction{
+			Name:  "clearenv",
+			Value: l.clearenv,
+		}, // clearenv()
+		"environ": &z.UserFunction{
+			Name:  "environ",
+			Value: FuncARSs(l.environ),
+		}, // environ() => array(string)
+		"exit": &z.UserFunction{
+			Name:  "exit",
+			Value: l.guard(osExit, l.process("os.exit")),
+		}, // exit(code int)
+		"expand_env": &z.UserFunction{
+			Name:  "expand_env",
+			Value: l.expandEnv,
+		}, // expand_env(s string) => string
+		"getegid": &z.UserFunction{
+			Name:  "getegid",
+			Value: FuncARI(os.Getegid),
+		}, // getegid() => int
+		"getenv": &z.UserFunction{
+			Name:  "getenv",
+			Value: l.guard(FuncASRS(os.Getenv), l.env("os.getenv"), 0),
+		}, // getenv(s string) => string
+		"geteuid": &z.UserFunction{
+			Name:  "geteuid",
+			Value: FuncARI(os.Geteuid),
+		}, // geteuid() => int
+		"getgid": &z.UserFunction{
+			Name:  "getgid",
+			Value: FuncARI(os.Getgid),
+		}, // getgid() => int
+		"getgroups": &z.UserFunction{
+			Name:  "getgroups",
+			Value: FuncARIsE(os.Getgroups),
+		}, // getgroups() => array(string)/error
+		"getpagesize": &z.UserFunction{
+			Name:  "getpagesize",
+			Value: FuncARI(os.Getpagesize),
+		}, // getpagesize() => int
+		"getpid": &z.UserFunction{
+			Name:  "getpid",
+			Value: FuncARI(os.Getpid),
+		}, // getpid() => int
+		"getppid": &z.UserFunction{
+			Name:  "getppid",
+			Value: FuncARI(os.Getppid),
+		}, // getppid() => int
+		"getuid": &z.UserFunction{
+			Name:  "getuid",
+			Value: FuncARI(os.Getuid),
+		}, // getuid() => int
+		"getwd": &z.UserFunction{
+			Name:  "getwd",
+			Value: FuncARSE(os.Getwd),
+		}, // getwd() => string/error
+		"hostname": &z.UserFunction{
+			Name:  "hostname",
+			Value: FuncARSE(os.Hostname),
+		}, // hostname() => string/error
+		"lchown": &z.UserFunction{
+			Name:  "lchown",
+			Value: l.guard(FuncASIIRE(l.fs.Lchown), l.path("os.lchown", AccessWrite), 0),
+		}, // lchown(name string, uid int, gid int) => error
+		"link": &z.UserFunction{
+			Name:  "link",
+			Value: l.guard(FuncASSRE(l.fs.Link), l.path("os.link", AccessWrite), 0, 1),
+		}, // link(oldname string, newname string) => error
+		"lookup_env": &z.UserFunction{
+			Name:  "lookup_env",
+			Value: l.guard(osLookupEnv, l.env("os.lookup_env"), 0),
+		}, // lookup_env(key string) => string/false
+		"mkdir": l.guardFunc(osFuncASFmRE("mkdir", l.fs.Mkdir),
+			l.path("os.mkdir", AccessWrite), 0), // mkdir(name string, perm int) => error
+		"mkdir_all": l.guardFunc(osFuncASFmRE("mkdir_all", l.fs.MkdirAll),
+			l.path("os.mkdir_all", AccessWrite), 0), // mkdir_all(name string, perm int) => error
+		"readlink": &z.UserFunction{
+			Name:  "readlink",
+			Value: l.guard(FuncASRSE(l.fs.Readlink), l.path("os.readlink", AccessRead), 0),
+		}, // readlink(name string) => string/error
+		"remove": &z.UserFunction{
+			Name:  "remove",
+			Value: l.guard(FuncASRE(l.fs.Remove), l.path("os.remove", AccessWrite), 0),
+		}, // remove(name string) => error
+		"remove_all": &z.UserFunction{
+			Name:  "remove_all",
+			Value: l.guard(FuncASRE(l.fs.RemoveAll), l.path("os.remove_all", AccessWrite), 0),
+		}, // remove_all(name string) => error
+		"rename": &z.UserFunction{
+			Name:  "rename",
+			Value: l.guard(FuncASSRE(l.fs.Rename), l.path("os.rename", AccessWrite), 0, 1),
+		}, // rename(oldpath string, newpath string) => error
+		"setenv": &z.UserFunction{
+			Name:  "setenv",
+			Value: l.guard(FuncASSRE(os.Setenv), l.env("os.setenv"), 0),
+		}, // setenv(key string, value string) => error
+		"symlink": &z.UserFunction{
+			Name:  "symlink",
+			Value: l.guard(FuncASSRE(l.fs.Symlink), l.path("os.symlink", AccessWrite), 1),
+		}, // symlink(oldname string newname string) => error
+		"temp_dir": &z.UserFunction{
+			Name:  "temp_dir",
+			Value: FuncARS(os.TempDir),
+		}, // temp_dir() => string
+		"truncate": &z.UserFunction{
+			Name:  "truncate",
+			Value: l.guard(FuncASI64RE(l.fs.Truncate), l.path("os.truncate", AccessWrite), 0),
+		}, // truncate(name string, size int) => error
+		"unsetenv": &z.UserFunction{
+			Name:  "unsetenv",
+			Value: l.guard(FuncASRE(os.Unsetenv), l.env("os.unsetenv"), 0),
+		}, // unsetenv(key string) => error
+		"create": &z.UserFunction{
+			Name:  "create",
+			Value: l.create,
+		}, // create(name string) => imap(file)/error
+		"open": &z.UserFunction{
+			Name:  "open",
+			Value: l.open,
+		}, // open(name string) => imap(file)/error
+		"open_file": &z.UserFunction{
+			Name:  "open_file",
+			Value: l.openFile,
+		}, // open_file(name string, flag int, perm int) => imap(file)/error
+		"find_process": &z.UserFunction{
+			Name:  "find_process",
+			Value: l.guard(osFindProcess, l.process("os.find_process")),
+		}, // find_process(pid int) => imap(process)/error
+		"start_process": &z.UserFunction{
+			Name:  "start_process",
+			Value: l.startProcess,
+		}, // start_process(name string, argv array(string), dir string, env array(string)) => imap(process)/error
+		"exec_look_path": &z.UserFunction{
+			Name:  "exec_look_path",
+			Value: l.guard(FuncASRSE(exec.LookPath), l.exec("os.exec_look_path"), 0),
+		}, // exec_look_path(file) => string/error
+		"exec": &z.UserFunction{
+			Name:  "exec",
+			Value: l.guard(l.execCommand, l.exec("os.exec"), 0),
+		}, // exec(name, args...) => command
+		"stat": &z.UserFunction{
+			Name:  "stat",
+			Value: l.guard(l.stat, l.path("os.stat", AccessRead), 0),
+		}, // stat(name) => imap(fileinfo)/error
+		"read_file": &z.UserFunction{
+			Name:  "read_file",
+			Value: l.guard(l.readFile, l.path("os.read_file", AccessRead), 0),
+		}, // readfile(name) => array(byte)/error
+	}
 }
 
-func osReadFile(args ...z.Object) (ret z.Object, err error) {
+func (l *osLib) readFile(args ...z.Object) (ret z.Object, err error) {
 	if len(args) != 1 {
 		return nil, z.ErrWrongNumArguments
 	}
@@ -214,7 +234,7 @@ func osReadFile(args ...z.Object) (ret z.Object, err error) {
 			Found:    args[0].TypeName(),
 		}
 	}
-	bytes, err := os.ReadFile(fname)
+	bytes, err := l.fs.ReadFile(fname)
 	if err != nil {
 		return wrapError(err), nil
 	}
@@ -224,7 +244,7 @@ func osReadFile(args ...z.Object) (ret z.Object, err error) {
 	return &z.Bytes{Value: bytes}, nil
 }
 
-func osStat(args ...z.Object) (ret z.Object, err error) {
+func (l *osLib) stat(args ...z.Object) (ret z.Object, err error) {
 	if len(args) != 1 {
 		return nil, z.ErrWrongNumArguments
 	}
@@ -236,10 +256,15 @@ func osStat(args ...z.Object) (ret z.Object, err error) {
 			Found:    args[0].TypeName(),
 		}
 	}
-	stat, err := os.Stat(fname)
+	stat, err := l.fs.Stat(fname)
 	if err != nil {
 		return wrapError(err), nil
 	}
+	return fileInfoMap(stat), nil
+}
+
+// fileInfoMap returns the immutable map of the file info returned by stat.
+func fileInfoMap(stat os.FileInfo) *z.ImmutableMap {
 	fstat := &z.ImmutableMap{
 		Value: map[string]z.Object{
 			"name":  &z.String{Value: stat.Name()},
@@ -253,10 +278,10 @@ func osStat(args ...z.Object) (ret z.Object, err error) {
 	} else {
 		fstat.Value["directory"] = z.FalseValue
 	}
-	return fstat, nil
+	return fstat
 }
 
-func osCreate(args ...z.Object) (z.Object, error) {
+func (l *osLib) create(args ...z.Object) (z.Object, error) {
 	if len(args) != 1 {
 		return nil, z.ErrWrongNumArguments
 	}
@@ -268,14 +293,17 @@ func osCreate(args ...z.Object) (z.Object, error) {
 			Found:    args[0].TypeName(),
 		}
 	}
-	res, err := os.Create(s1)
+	if err := l.path("os.create", AccessWrite)(s1); err != nil {
+		return nil, err
+	}
+	res, err := l.fs.Create(s1)
 	if err != nil {
 		return wrapError(err), nil
 	}
-	return makeOSFile(res), nil
+	return l.makeFile(res), nil
 }
 
-func osOpen(args ...z.Object) (z.Object, error) {
+func (l *osLib) open(args ...z.Object) (z.Object, error) {
 	if len(args) != 1 {
 		return nil, z.ErrWrongNumArguments
 	}
@@ -287,14 +315,17 @@ func osOpen(args ...z.Object) (z.Object, error) {
 			Found:    args[0].TypeName(),
 		}
 	}
-	res, err := os.Open(s1)
+	if err := l.path("os.open", AccessRead)(s1); err != nil {
+		return nil, err
+	}
+	res, err := l.fs.Open(s1)
 	if err != nil {
 		return wrapError(err), nil
 	}
-	return makeOSFile(res), nil
+	return l.makeFile(res), nil
 }
 
-func osOpenFile(args ...z.Object) (z.Object, error) {
+func (l *osLib) openFile(args ...z.Object) (z.Object, error) {
 	if len(args) != 3 {
 		return nil, z.ErrWrongNumArguments
 	}
@@ -322,19 +353,53 @@ func osOpenFile(args ...z.Object) (z.Object, error) {
 			Found:    args[2].TypeName(),
 		}
 	}
-	res, err := os.OpenFile(s1, i2, os.FileMode(i3))
+	access := AccessRead
+	switch {
+	case i2&(os.O_WRONLY|os.O_RDWR) == os.O_WRONLY:
+		access = AccessWrite
+	case i2&os.O_RDWR != 0:
+		access = AccessReadWrite
+	}
+	if i2&(os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		access |= AccessWrite
+	}
+	if err := l.path("os.open_file", access)(s1); err != nil {
+		return nil, err
+	}
+	res, err := l.fs.OpenFile(s1, i2, os.FileMode(i3))
 	if err != nil {
 		return wrapError(err), nil
 	}
-	return makeOSFile(res), nil
+	return l.makeFile(res), nil
 }
 
-func osArgs(args ...z.Object) (z.Object, error) {
+// osExit stops the script with an ExitError instead of exiting the host
+// program.
+func osExit(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	code, ok := z.ToInt(args[0])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "int(compatible)",
+			Found:    args[0].TypeName(),
+		}
+	}
+	return nil, &ExitError{Code: code}
+}
+
+func (l *osLib) osArgs(args ...z.Object) (z.Object, error) {
 	if len(args) != 0 {
 		return nil, z.ErrWrongNumArguments
 	}
+	osArgs := l.args
+	if osArgs == nil {
+		osArgs = os.Args
+	}
 	arr := &z.Array{}
-	for _, osArg := range os.Args {
+	for _, osArg := range osArgs {
 		if len(osArg) > z.MaxStringLen {
 			return nil, z.ErrStringLimit
 		}
@@ -396,7 +461,7 @@ func osLookupEnv(args ...z.Object) (z.Object, error) {
 	return &z.String{Value: res}, nil
 }
 
-func osExpandEnv(args ...z.Object) (z.Object, error) {
+func (l *osLib) expandEnv(args ...z.Object) (z.Object, error) {
 	if len(args) != 1 {
 		return nil, z.ErrWrongNumArguments
 	}
@@ -410,8 +475,12 @@ func osExpandEnv(args ...z.Object) (z.Object, error) {
 	}
 	var vlen int
 	var failed bool
+	var denied error
 	s := os.Expand(s1, func(k string) string {
-		if failed {
+		if failed || denied != nil {
+			return ""
+		}
+		if denied = l.policy.CheckEnv("os.expand_env", k); denied != nil {
 			return ""
 		}
 		v := os.Getenv(k)
@@ -425,13 +494,16 @@ func osExpandEnv(args ...z.Object) (z.Object, error) {
 		}
 		return v
 	})
+	if denied != nil {
+		return nil, denied
+	}
 	if failed || len(s) > z.MaxStringLen {
 		return nil, z.ErrStringLimit
 	}
 	return &z.String{Value: s}, nil
 }
 
-func osExec(args ...z.Object) (z.Object, error) {
+func (l *osLib) execCommand(args ...z.Object) (z.Object, error) {
 	if len(args) == 0 {
 		return nil, z.ErrWrongNumArguments
 	}
@@ -455,7 +527,7 @@ func osExec(args ...z.Object) (z.Object, error) {
 		}
 		execArgs = append(execArgs, execArg)
 	}
-	return makeOSExecCommand(exec.Command(name, execArgs...)), nil
+	return l.makeExecCommand(exec.Command(name, execArgs...)), nil
 }
 
 func osFindProcess(args ...z.Object) (z.Object, error) {
@@ -477,7 +549,11 @@ func osFindProcess(args ...z.Object) (z.Object, error) {
 	return makeOSProcess(proc), nil
 }
 
-func osStartProcess(args ...z.Object) (z.Object, error) {
+// startProcess starts a process. With a policy, the executable, its working
+// directory and its environment are checked, the names without a path
+// separator are looked up in PATH, and an empty environment is replaced by
+// the environment visible to the script.
+func (l *osLib) startProcess(args ...z.Object) (z.Object, error) {
 	if len(args) != 4 {
 		return nil, z.ErrWrongNumArguments
 	}
@@ -539,6 +615,26 @@ func osStartProcess(args ...z.Object) (z.Object, error) {
 		}
 	}
 
+	if l.policy != nil {
+		const op = "os.start_process"
+		if filepath.Base(name) == name {
+			if name, err = exec.LookPath(name); err != nil {
+				return wrapError(err), nil
+			}
+		}
+		if err := l.policy.CheckExec(op, name); err != nil {
+			return nil, err
+		}
+		if dir != "" {
+			if err := l.policy.CheckPath(op, dir, AccessRead); err != nil {
+				return nil, err
+			}
+		}
+		if env, err = l.checkEnv(op, env); err != nil {
+			return nil, err
+		}
+	}
+
 	proc, err := os.StartProcess(name, argv, &os.ProcAttr{
 		Dir: dir,
 		Env: env,
@@ -564,3 +660,123 @@ func stringArray(arr []z.Object, argName string) ([]string, error) {
 	}
 	return sarr, nil
 }
+
+func (l *osLib) environ() []string {
+	if l.policy == nil {
+		return os.Environ()
+	}
+	env := []string{}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if l.policy.envAllowed(name) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// checkEnv checks the names of the environment of a process started by op,
+// returning the environment visible to the script if env is empty so that
+// the process doesn't inherit the whole environment.
+func (l *osLib) checkEnv(op string, env []string) ([]string, error) {
+	if len(env) == 0 {
+		return l.environ(), nil
+	}
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if err := l.policy.CheckEnv(op, name); err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
+
+func (l *osLib) clearenv(args ...z.Object) (z.Object, error) {
+	if len(args) != 0 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if l.policy == nil {
+		os.Clearenv()
+		return z.UndefinedValue, nil
+	}
+	// only clear the variables visible to the script
+	for _, kv := range l.environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		_ = os.Unsetenv(name)
+	}
+	return z.UndefinedValue, nil
+}
+
+// guard returns fn checking the policy first: check is called with the
+// string arguments found at the indexes idxs. Arguments of other types are
+// left to fn to report.
+func (l *osLib) guard(
+	fn z.CallableFunc,
+	check func(string) error,
+	idxs ...int,
+) z.CallableFunc {
+	if l.policy == nil {
+		return fn
+	}
+	return func(args ...z.Object) (z.Object, error) {
+		if len(idxs) == 0 {
+			if err := check(""); err != nil {
+				return nil, err
+			}
+		}
+		for _, idx := range idxs {
+			if idx >= len(args) {
+				continue
+			}
+			if s, ok := z.ToString(args[idx]); ok {
+				if err := check(s); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return fn(args...)
+	}
+}
+
+// guardFunc is like guard for a user function.
+func (l *osLib) guardFunc(
+	fn *z.UserFunction,
+	check func(string) error,
+	idxs ...int,
+) *z.UserFunction {
+	fn.Value = l.guard(fn.Value, check, idxs...)
+	return fn
+}
+
+func (l *osLib) path(op string, access Access) func(string) error {
+	return func(name string) error {
+		return l.policy.checkPath(l.fs, op, name, access)
+	}
+}
+
+func (l *osLib) env(op string) func(string) error {
+	return func(name string) error {
+		return l.policy.CheckEnv(op, name)
+	}
+}
+
+func (l *osLib) exec(op string) func(string) error {
+	return func(name string) error {
+		return l.policy.CheckExec(op, name)
+	}
+}
+
+func (l *osLib) process(op string) func(string) error {
+	return func(resource string) error {
+		return l.policy.CheckProcessControl(op, resource)
+	}
+}
+
+func (l *osLib) chdir(op string) func(string) error {
+	return func(dir string) error {
+		if err := l.policy.CheckProcessControl(op, dir); err != nil {
+			return err
+		}
+		return l.policy.CheckPath(op, dir, AccessRead)
+	}
+}
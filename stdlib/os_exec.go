@@ -6,7 +6,11 @@ import (
 	"github.com/diiyw/z"
 )
 
-func makeOSExecCommand(cmd *exec.Cmd) *z.ImmutableMap {
+func (l *osLib) makeExecCommand(cmd *exec.Cmd) *z.ImmutableMap {
+	if l.policy != nil {
+		// only the environment visible to the script is inherited
+		cmd.Env = l.environ()
+	}
 	return &z.ImmutableMap{
 		Value: map[string]z.Object{
 			// combined_output() => bytes/error
@@ -49,6 +53,10 @@ func makeOSExecCommand(cmd *exec.Cmd) *z.ImmutableMap {
 							Found:    args[0].TypeName(),
 						}
 					}
+					err := l.policy.CheckExec("os.exec.set_path", s1)
+					if err != nil {
+						return nil, err
+					}
 					cmd.Path = s1
 					return z.UndefinedValue, nil
 				},
@@ -68,6 +76,11 @@ func makeOSExecCommand(cmd *exec.Cmd) *z.ImmutableMap {
 							Found:    args[0].TypeName(),
 						}
 					}
+					err := l.policy.CheckPath("os.exec.set_dir", s1,
+						AccessRead)
+					if err != nil {
+						return nil, err
+					}
 					cmd.Dir = s1
 					return z.UndefinedValue, nil
 				},
@@ -100,6 +113,12 @@ func makeOSExecCommand(cmd *exec.Cmd) *z.ImmutableMap {
 							Found:    arg0.TypeName(),
 						}
 					}
+					if l.policy != nil {
+						env, err = l.checkEnv("os.exec.set_env", env)
+						if err != nil {
+							return nil, err
+						}
+					}
 					cmd.Env = env
 					return z.UndefinedValue, nil
 				},
@@ -6,18 +6,26 @@ import (
 	"github.com/diiyw/z"
 )
 
-func makeOSFile(file *os.File) *z.ImmutableMap {
+func (l *osLib) makeFile(file File) *z.ImmutableMap {
+	checkWrite := func(op string) func(string) error {
+		return func(string) error {
+			return l.path(op, AccessWrite)(file.Name())
+		}
+	}
 	return &z.ImmutableMap{
 		Value: map[string]z.Object{
 			// chdir() => true/error
 			"chdir": &z.UserFunction{
-				Name:  "chdir",
-				Value: FuncARE(file.Chdir),
+				Name: "chdir",
+				Value: l.guard(FuncARE(file.Chdir), func(string) error {
+					return l.chdir("os.file.chdir")(file.Name())
+				}),
 			}, //
 			// chown(uid int, gid int) => true/error
 			"chown": &z.UserFunction{
-				Name:  "chown",
-				Value: FuncAIIRE(file.Chown),
+				Name: "chown",
+				Value: l.guard(FuncAIIRE(file.Chown),
+					checkWrite("os.file.chown")),
 			}, //
 			// close() => error
 			"close": &z.UserFunction{
@@ -57,7 +65,7 @@ func makeOSFile(file *os.File) *z.ImmutableMap {
 			// chmod(mode int) => error
 			"chmod": &z.UserFunction{
 				Name: "chmod",
-				Value: func(args ...z.Object) (z.Object, error) {
+				Value: l.guard(func(args ...z.Object) (z.Object, error) {
 					if len(args) != 1 {
 						return nil, z.ErrWrongNumArguments
 					}
@@ -70,7 +78,7 @@ func makeOSFile(file *os.File) *z.ImmutableMap {
 						}
 					}
 					return wrapError(file.Chmod(os.FileMode(i1))), nil
-				},
+				}, checkWrite("os.file.chmod")),
 			},
 			// seek(offset int, whence int) => int/error
 			"seek": &z.UserFunction{
@@ -109,7 +117,7 @@ func makeOSFile(file *os.File) *z.ImmutableMap {
 					if len(args) != 0 {
 						return nil, z.ErrWrongNumArguments
 					}
-					return osStat(&z.String{Value: file.Name()})
+					return l.stat(&z.String{Value: file.Name()})
 				},
 			},
 		},
@@ -0,0 +1,339 @@
+package stdlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/diiyw/z"
+)
+
+var pathModule = (&pathLib{}).module()
+
+// The errors returned by a walk callback to skip a directory or to stop
+// walking. As the module values are copied on import, they are recognized by
+// their value.
+const (
+	pathSkipDir = "skip_dir"
+	pathSkipAll = "skip_all"
+)
+
+// pathLib creates the path module functions. Like the os module, the
+// functions reading directories consult the policy and access the file
+// system fs.
+type pathLib struct {
+	policy *Policy
+	fs     FS
+}
+
+func (l *pathLib) module() map[string]z.Object {
+	if l.fs == nil {
+		l.fs = OSFS{}
+	}
+	return map[string]z.Object{
+		"separator": &z.Char{Value: filepath.Separator},
+		"skip_dir":  &z.Error{Value: &z.String{Value: pathSkipDir}},
+		"skip_all":  &z.Error{Value: &z.String{Value: pathSkipAll}},
+		"join": &z.UserFunction{
+			Name:  "join",
+			Value: pathJoin,
+		}, // join(elems...) => string
+		"clean": &z.UserFunction{
+			Name:  "clean",
+			Value: FuncASRS(filepath.Clean),
+		}, // clean(path) => string
+		"base": &z.UserFunction{
+			Name:  "base",
+			Value: FuncASRS(filepath.Base),
+		}, // base(path) => string
+		"dir": &z.UserFunction{
+			Name:  "dir",
+			Value: FuncASRS(filepath.Dir),
+		}, // dir(path) => string
+		"ext": &z.UserFunction{
+			Name:  "ext",
+			Value: FuncASRS(filepath.Ext),
+		}, // ext(path) => string
+		"is_abs": &z.UserFunction{
+			Name:  "is_abs",
+			Value: pathIsAbs,
+		}, // is_abs(path) => bool
+		"abs": &z.UserFunction{
+			Name:  "abs",
+			Value: FuncASRSE(filepath.Abs),
+		}, // abs(path) => string/error
+		"rel": &z.UserFunction{
+			Name:  "rel",
+			Value: FuncASSRSE(filepath.Rel),
+		}, // rel(base, target) => string/error
+		"match": &z.UserFunction{
+			Name:  "match",
+			Value: FuncASSRBE(filepath.Match),
+		}, // match(pattern, name) => bool/error
+		"glob": &z.UserFunction{
+			Name:  "glob",
+			Value: l.glob,
+		}, // glob(pattern) => array(string)/error
+		"walk": &z.VMFunction{
+			Name:  "walk",
+			Value: l.walk,
+		}, // walk(root, fn) => true/error
+	}
+}
+
+func pathJoin(args ...z.Object) (z.Object, error) {
+	elems := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := z.ToString(arg)
+		if !ok {
+			return nil, z.ErrInvalidArgumentType{
+				Name:     fmt.Sprintf("elems[%d]", i),
+				Expected: "string(compatible)",
+				Found:    arg.TypeName(),
+			}
+		}
+		elems[i] = s
+	}
+	s := filepath.Join(elems...)
+	if len(s) > z.MaxStringLen {
+		return nil, z.ErrStringLimit
+	}
+	return &z.String{Value: s}, nil
+}
+
+func pathIsAbs(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	s, ok := z.ToString(args[0])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "string(compatible)",
+			Found:    args[0].TypeName(),
+		}
+	}
+	if filepath.IsAbs(s) {
+		return z.TrueValue, nil
+	}
+	return z.FalseValue, nil
+}
+
+// allowed reports whether the policy allows reading the file.
+func (l *pathLib) allowed(op, name string) bool {
+	return l.policy.checkPath(l.fs, op, name, AccessRead) == nil
+}
+
+// readDirNames returns the sorted names of the directory entries.
+func (l *pathLib) readDirNames(dir string) ([]string, error) {
+	f, err := l.fs.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	_ = f.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// glob returns the names of the files matching the pattern, as
+// filepath.Glob does but using the file system of the module. The files the
+// policy denies reading are not matched.
+func (l *pathLib) glob(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	pattern, ok := z.ToString(args[0])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "string(compatible)",
+			Found:    args[0].TypeName(),
+		}
+	}
+	matches, err := l.globPattern(pattern)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	arr := &z.Array{Value: make([]z.Object, 0, len(matches))}
+	for _, m := range matches {
+		arr.Value = append(arr.Value, &z.String{Value: m})
+	}
+	return arr, nil
+}
+
+func (l *pathLib) globPattern(pattern string) ([]string, error) {
+	// check the syntax of the pattern
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasGlobMeta(pattern) {
+		if _, err := l.fs.Stat(pattern); err != nil ||
+			!l.allowed("path.glob", pattern) {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+	dir, file := filepath.Split(pattern)
+	dir = cleanGlobPath(dir)
+	if !hasGlobMeta(dir) {
+		return l.globDir(dir, file, nil), nil
+	}
+	if dir == pattern {
+		return nil, filepath.ErrBadPattern
+	}
+	dirs, err := l.globPattern(dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, d := range dirs {
+		matches = l.globDir(d, file, matches)
+	}
+	return matches, nil
+}
+
+// globDir appends the files of the directory matching the pattern to
+// matches. The errors reading the directory are ignored.
+func (l *pathLib) globDir(dir, pattern string, matches []string) []string {
+	if fi, err := l.fs.Stat(dir); err != nil || !fi.IsDir() ||
+		!l.allowed("path.glob", dir) {
+		return matches
+	}
+	names, _ := l.readDirNames(dir)
+	for _, n := range names {
+		if ok, _ := filepath.Match(pattern, n); ok {
+			name := filepath.Join(dir, n)
+			if l.policy == nil || l.allowed("path.glob", name) {
+				matches = append(matches, name)
+			}
+		}
+	}
+	return matches
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// cleanGlobPath prepares the directory of a pattern for globbing.
+func cleanGlobPath(path string) string {
+	switch path {
+	case "":
+		return "."
+	case string(filepath.Separator):
+		return path
+	}
+	return path[:len(path)-1]
+}
+
+// walk walks the file tree rooted at root, calling fn(path, info) for each
+// file or directory in lexical order, starting with root. The callback can
+// return skip_dir to skip the directory, or the remaining files of the
+// directory of a file, skip_all to stop walking, or an error to stop walking
+// and return the error. Symbolic links are not followed. If a policy is set,
+// reading root must be allowed and the files the policy denies reading are
+// skipped.
+func (l *pathLib) walk(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	root, ok := z.ToString(args[0])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "string(compatible)",
+			Found:    args[0].TypeName(),
+		}
+	}
+	fn, err := funcArg(args[1], "second")
+	if err != nil {
+		return nil, err
+	}
+	err = l.policy.checkPath(l.fs, "path.walk", root, AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := l.fs.Stat(root)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	res, err := l.walkFile(vm, fn, root, fi)
+	if err != nil {
+		return nil, err
+	}
+	if walkSkip(res) != "" {
+		return z.TrueValue, nil
+	}
+	return res, nil
+}
+
+// walkSkip returns skip_dir or skip_all if the result of a walk callback is
+// one of these errors.
+func walkSkip(res z.Object) string {
+	if e, ok := res.(*z.Error); ok {
+		if s, ok := e.Value.(*z.String); ok &&
+			(s.Value == pathSkipDir || s.Value == pathSkipAll) {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// walkFile calls fn for the file and walks the directory. It returns true,
+// skip_dir, skip_all or the error returned by fn or by reading a directory.
+func (l *pathLib) walkFile(
+	vm *z.VM,
+	fn z.Object,
+	name string,
+	fi os.FileInfo,
+) (z.Object, error) {
+	res, err := vm.Call(fn, &z.String{Value: name}, fileInfoMap(fi))
+	if err != nil {
+		return nil, err
+	}
+	if _, isErr := res.(*z.Error); isErr {
+		return res, nil
+	}
+	if !fi.IsDir() {
+		return z.TrueValue, nil
+	}
+	if _, err := l.fs.Readlink(name); err == nil {
+		// a link to a directory
+		return z.TrueValue, nil
+	}
+	names, err := l.readDirNames(name)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	for _, n := range names {
+		path := filepath.Join(name, n)
+		if l.policy != nil && !l.allowed("path.walk", path) {
+			continue
+		}
+		fi, err := l.fs.Stat(path)
+		if err != nil {
+			// a broken link or a file removed while walking
+			continue
+		}
+		res, err := l.walkFile(vm, fn, path, fi)
+		if err != nil {
+			return nil, err
+		}
+		if res == z.TrueValue {
+			continue
+		}
+		if walkSkip(res) != pathSkipDir {
+			return res, nil
+		}
+		if !fi.IsDir() {
+			return z.TrueValue, nil
+		}
+	}
+	return z.TrueValue, nil
+}
@@ -0,0 +1,162 @@
+package stdlib_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+)
+
+func TestPath(t *testing.T) {
+	module(t, "path").call("join", "a", "b/../c", "d.txt").expect("a/c/d.txt")
+	module(t, "path").call("join").expect("")
+	module(t, "path").call("clean", "/a//b/./c/..").expect("/a/b")
+	module(t, "path").call("base", "/a/b.txt").expect("b.txt")
+	module(t, "path").call("dir", "/a/b.txt").expect("/a")
+	module(t, "path").call("ext", "a.tar.gz").expect(".gz")
+	module(t, "path").call("is_abs", "/a").expect(true)
+	module(t, "path").call("is_abs", "a").expect(false)
+	module(t, "path").call("rel", "/a", "/a/b/c").expect("b/c")
+	module(t, "path").call("rel", "/a", "b").
+		expect(&z.Error{Value: &z.String{
+			Value: "Rel: can't make b relative to /a"}})
+	module(t, "path").call("match", "*.go", "x.go").expect(true)
+	module(t, "path").call("match", "*.go", "x/y.go").expect(false)
+	module(t, "path").call("match", "[", "x").
+		expect(&z.Error{Value: &z.String{Value: "syntax error in pattern"}})
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	module(t, "path").call("abs", "x").expect(filepath.Join(wd, "x"))
+}
+
+func TestPathGlobWalk(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a/x.go", "a/b/y.go", "a/c/z.go", "t.txt"} {
+		name = filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(t, os.WriteFile(name, nil, 0644))
+	}
+
+	run := func(input string) string {
+		s := z.NewScript([]byte(input))
+		_ = s.Add("dir", dir)
+		s.SetImports(stdlib.GetModuleMap("path"))
+		c, err := s.Run()
+		require.NoError(t, err)
+		return c.Get("out").String()
+	}
+
+	require.Equal(t, `["a/b/y.go", "a/c/z.go", "a/x.go", "t.txt"]`, run(`
+path := import("path")
+out := []
+for p in path.glob(dir + "/*/*/*.go") + path.glob(dir + "/*/*.go") + path.glob(dir + "/t.txt") {
+	out = append(out, path.rel(dir, p))
+}
+out = string(out)
+`))
+	require.Equal(t, `[[], "syntax error in pattern"]`, run(`
+path := import("path")
+out := string([path.glob(dir + "/none/*"), path.glob(dir + "/[").value])
+`))
+
+	require.Equal(t, `[".:true", "a:true", "a/b:true", "a/c:true", "a/c/z.go:false", "a/x.go:false", "t.txt:false"]`, run(`
+path := import("path")
+out := []
+res := path.walk(dir, func(p, info) {
+	out = append(out, path.rel(dir, p) + ":" + string(info.directory))
+	if info.name == "b" {
+		return path.skip_dir
+	}
+})
+out = is_error(res) ? res : string(out)
+`))
+	require.Equal(t, `["a", "a/b", "a/b/y.go"]`, run(`
+path := import("path")
+out := []
+path.walk(dir + "/a", func(p, info) {
+	out = append(out, path.rel(dir, p))
+	if info.name == "y.go" {
+		return path.skip_all
+	}
+})
+out = string(out)
+`))
+	require.Equal(t, `[error: "stop", "a/x.go"]`, run(`
+path := import("path")
+out := []
+res := path.walk(dir, func(p, info) {
+	if path.base(p) == "x.go" {
+		out = append(out, path.rel(dir, p))
+		return error("stop")
+	}
+})
+out = string([res] + out)
+`))
+	require.Equal(t, `true`, run(`
+path := import("path")
+out := path.walk(dir + "/a/x.go", func(p, info) { return path.skip_dir })
+`))
+	expectRuntimeError(t, `import("path").walk(".", func(p, info) { return 1 + {} })`)
+	expectRuntimeError(t, `import("path").walk(".", 1)`)
+}
+
+func TestPathPolicy(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	require.NoError(t, os.MkdirAll(filepath.Join(data, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(data, "sub", "a.txt"),
+		nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.txt"),
+		nil, 0644))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "secret.txt"),
+		filepath.Join(data, "link.txt")))
+
+	policy := &stdlib.Policy{
+		Paths: []stdlib.PathRule{{Path: data, Access: stdlib.AccessRead}},
+	}
+	run := func(input string) (*z.Compiled, error) {
+		s := z.NewScript([]byte(input))
+		_ = s.Add("dir", dir)
+		s.SetImports(stdlib.GetModuleMapWithOptions(
+			stdlib.Options{Policy: policy}, "path"))
+		return s.Run()
+	}
+
+	c, err := run(`
+path := import("path")
+out := []
+path.walk(dir + "/data", func(p, info) { out = append(out, path.rel(dir, p)) })
+out = append(out, len(path.glob(dir + "/*.txt")), len(path.glob(dir + "/data/*.txt")),
+	len(path.glob(dir + "/data/sub/*")))
+out = string(out)
+`)
+	require.NoError(t, err)
+	require.Equal(t, `["data", "data/sub", "data/sub/a.txt", 0, 0, 1]`,
+		c.Get("out").String())
+
+	_, err = run(`import("path").walk(dir, func(p, info) {})`)
+	require.True(t, errors.Is(err, stdlib.ErrDenied))
+}
+
+func TestPathMemFS(t *testing.T) {
+	mem := stdlib.NewMemFS()
+	require.NoError(t, mem.WriteFile("/src/a.z", nil, 0644))
+	require.NoError(t, mem.WriteFile("/src/lib/b.z", nil, 0644))
+
+	s := z.NewScript([]byte(`
+path := import("path")
+out := []
+path.walk("/src", func(p, info) { out = append(out, p) })
+out = string(out + path.glob("/src/*/*.z"))
+`))
+	s.SetImports(stdlib.GetModuleMapWithOptions(stdlib.Options{FS: mem}, "path"))
+	c, err := s.Run()
+	require.NoError(t, err)
+	require.Equal(t, `["/src", "/src/a.z", "/src/lib", "/src/lib/b.z", "/src/lib/b.z"]`,
+		c.Get("out").String())
+}
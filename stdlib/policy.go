@@ -0,0 +1,274 @@
+package stdlib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrDenied is wrapped by the errors returned when an operation is denied by
+// a Policy.
+var ErrDenied = errors.New("denied by policy")
+
+// Access is a set of file access modes.
+type Access int
+
+// File access modes.
+const (
+	AccessRead Access = 1 << iota
+	AccessWrite
+
+	AccessReadWrite = AccessRead | AccessWrite
+)
+
+func (a Access) String() string {
+	switch a {
+	case AccessRead:
+		return "read"
+	case AccessWrite:
+		return "write"
+	case AccessReadWrite:
+		return "read-write"
+	}
+	return "none"
+}
+
+// PathRule grants access to a file or to a directory and all its contents.
+type PathRule struct {
+	Path   string
+	Access Access
+}
+
+// AuditEvent describes an operation checked by a Policy.
+type AuditEvent struct {
+	// Op is the name of the module function, e.g. "os.remove".
+	Op string
+	// Resource is the path, executable, environment variable name or host
+	// the operation is about.
+	Resource string
+	// Allowed reports whether the operation was allowed.
+	Allowed bool
+}
+
+// PolicyError is the error returned when an operation is denied by a
+// Policy. It wraps ErrDenied.
+type PolicyError struct {
+	Op       string
+	Resource string
+	Reason   string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s %q: %s: %s", e.Op, e.Resource, ErrDenied, e.Reason)
+}
+
+// Unwrap returns ErrDenied.
+func (e *PolicyError) Unwrap() error {
+	return ErrDenied
+}
+
+// Policy restricts the capabilities of the standard library modules. Unlike
+// including or excluding a whole module, a policy grants access to specific
+// paths, executables and environment variables; everything else is denied.
+// Denied operations fail with a run-time error wrapping a *PolicyError. Use
+// GetModuleMapWithOptions to create modules using a policy.
+type Policy struct {
+	// Paths are the files and directories that scripts can access. Relative
+	// paths are relative to the working directory when the policy is
+	// checked. Symbolic links are resolved before checking access. With a
+	// virtual file system, see Options.FS, the paths of its files are
+	// relative to its root instead.
+	Paths []PathRule
+
+	// Executables are the programs that scripts can run, given either as
+	// names looked up in PATH or as absolute paths. An executable matches
+	// the commands with the same name and the commands resolving to the
+	// same file. Commands given as relative paths are denied.
+	Executables []string
+
+	// Env are the names of the environment variables that scripts can read
+	// and modify. Other variables are hidden from os.environ.
+	Env []string
+
+	// AllowNetwork allows network access.
+	AllowNetwork bool
+
+	// AllowProcessControl allows finding and signaling processes that were
+	// not started by the script, changing the working directory, and
+	// stopping the script with an exit code.
+	AllowProcessControl bool
+
+	// Audit, if set, is called for every operation checked by the policy.
+	Audit func(AuditEvent)
+}
+
+func (p *Policy) audit(op, resource string, err error) error {
+	if p != nil && p.Audit != nil {
+		p.Audit(AuditEvent{Op: op, Resource: resource, Allowed: err == nil})
+	}
+	return err
+}
+
+// CheckPath returns an error if the access to the named file of the file
+// system of the operating system is denied.
+func (p *Policy) CheckPath(op, name string, access Access) error {
+	return p.checkPath(OSFS{}, op, name, access)
+}
+
+// checkPath returns an error if the access to the named file of fsys is
+// denied. The paths of a virtual file system, any FS but OSFS, are resolved
+// in its own namespace: relative to its root, without symbolic links.
+func (p *Policy) checkPath(fsys FS, op, name string, access Access) error {
+	if p == nil {
+		return nil
+	}
+	resolve, contains := resolvePolicyPath, policyContains
+	if _, ok := fsys.(OSFS); !ok {
+		resolve, contains = resolveVirtualPath, memContains
+	}
+	target, err := resolve(name)
+	if err != nil {
+		return p.audit(op, name, &PolicyError{
+			Op: op, Resource: name, Reason: err.Error(),
+		})
+	}
+	for _, rule := range p.Paths {
+		if rule.Access&access != access {
+			continue
+		}
+		dir, err := resolve(rule.Path)
+		if err != nil {
+			continue
+		}
+		if contains(dir, target) {
+			return p.audit(op, name, nil)
+		}
+	}
+	return p.audit(op, name, &PolicyError{
+		Op: op, Resource: name, Reason: access.String() + " access denied",
+	})
+}
+
+// CheckExec returns an error if running the named executable is denied. A
+// name without a path separator is looked up in PATH. Relative paths are
+// denied, as they are resolved in the working directory of the started
+// process.
+func (p *Policy) CheckExec(op, name string) error {
+	if p == nil {
+		return nil
+	}
+	if filepath.Base(name) != name && !filepath.IsAbs(name) {
+		return p.audit(op, name, &PolicyError{
+			Op: op, Resource: name, Reason: "relative executable path",
+		})
+	}
+	resolved := lookExecutable(name)
+	for _, allowed := range p.Executables {
+		if allowed == name ||
+			resolved != "" && lookExecutable(allowed) == resolved {
+			return p.audit(op, name, nil)
+		}
+	}
+	return p.audit(op, name, &PolicyError{
+		Op: op, Resource: name, Reason: "executable not allowed",
+	})
+}
+
+// lookExecutable returns the absolute path of the named executable with the
+// symbolic links resolved, or "" if it is not found.
+func lookExecutable(name string) string {
+	path, err := exec.LookPath(name)
+	if err != nil || !filepath.IsAbs(path) {
+		return ""
+	}
+	resolved, err := resolvePolicyPath(path)
+	if err != nil {
+		return ""
+	}
+	return resolved
+}
+
+// CheckEnv returns an error if the access to the named environment variable
+// is denied.
+func (p *Policy) CheckEnv(op, name string) error {
+	if p == nil || p.envAllowed(name) {
+		return p.audit(op, name, nil)
+	}
+	return p.audit(op, name, &PolicyError{
+		Op: op, Resource: name, Reason: "environment variable not allowed",
+	})
+}
+
+// CheckNetwork returns an error if the network access to the host is denied.
+func (p *Policy) CheckNetwork(op, host string) error {
+	if p == nil || p.AllowNetwork {
+		return p.audit(op, host, nil)
+	}
+	return p.audit(op, host, &PolicyError{
+		Op: op, Resource: host, Reason: "network access not allowed",
+	})
+}
+
+// CheckProcessControl returns an error if the process control operation is
+// denied.
+func (p *Policy) CheckProcessControl(op, resource string) error {
+	if p == nil || p.AllowProcessControl {
+		return p.audit(op, resource, nil)
+	}
+	return p.audit(op, resource, &PolicyError{
+		Op: op, Resource: resource, Reason: "process control not allowed",
+	})
+}
+
+func (p *Policy) envAllowed(name string) bool {
+	if p == nil {
+		return true
+	}
+	for _, allowed := range p.Env {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// policyContains reports whether the path p is dir or is inside dir.
+func policyContains(dir, p string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir,
+		string(filepath.Separator))+string(filepath.Separator))
+}
+
+// resolveVirtualPath returns the path of name in a virtual file system,
+// where it is cleaned relative to the root.
+func resolveVirtualPath(name string) (string, error) {
+	return memPath(name), nil
+}
+
+// resolvePolicyPath returns the absolute path of name with the symbolic
+// links resolved. Only the existing part of the path is resolved so the path
+// of a file about to be created can be checked.
+func resolvePolicyPath(name string) (string, error) {
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		dir := filepath.Dir(path)
+		if dir == path {
+			return filepath.Join(append([]string{path}, rest...)...), nil
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = dir
+	}
+}
@@ -0,0 +1,217 @@
+package stdlib_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+)
+
+func runWithPolicy(policy *stdlib.Policy, input string) (*z.Compiled, error) {
+	s := z.NewScript([]byte(input))
+	s.SetImports(stdlib.GetModuleMapWithOptions(
+		stdlib.Options{Policy: policy}, "os"))
+	return s.Run()
+}
+
+func TestPolicyPaths(t *testing.T) {
+	dir := t.TempDir()
+	ro := filepath.Join(dir, "ro")
+	rw := filepath.Join(dir, "rw")
+	require.NoError(t, os.Mkdir(ro, 0755))
+	require.NoError(t, os.Mkdir(rw, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(ro, "a.txt"),
+		[]byte("foo"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret"),
+		[]byte("bar"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "secret"),
+		filepath.Join(rw, "link")))
+
+	var events []stdlib.AuditEvent
+	policy := &stdlib.Policy{
+		Paths: []stdlib.PathRule{
+			{Path: ro, Access: stdlib.AccessRead},
+			{Path: rw, Access: stdlib.AccessReadWrite},
+		},
+		Audit: func(e stdlib.AuditEvent) { events = append(events, e) },
+	}
+
+	run := func(input string) (*z.Compiled, error) {
+		s := z.NewScript([]byte(input))
+		_ = s.Add("ro", ro)
+		_ = s.Add("rw", rw)
+		_ = s.Add("dir", dir)
+		s.SetImports(stdlib.GetModuleMapWithOptions(
+			stdlib.Options{Policy: policy}, "os"))
+		return s.Run()
+	}
+
+	c, err := run(`
+os := import("os")
+out := string(os.read_file(ro + "/a.txt"))
+f := os.create(rw + "/b.txt")
+f.write_string("baz")
+f.close()
+out += string(os.read_file(rw + "/b.txt"))
+out += string(os.stat(ro).directory)
+`)
+	require.NoError(t, err)
+	require.Equal(t, "foobaztrue", c.Get("out").Value())
+	require.Equal(t, "os.read_file", events[0].Op)
+	require.Equal(t, ro+"/a.txt", events[0].Resource)
+	require.True(t, events[0].Allowed)
+
+	for _, input := range []string{
+		`import("os").read_file(dir + "/secret")`,
+		`import("os").create(ro + "/c.txt")`,
+		`import("os").remove(ro + "/a.txt")`,
+		`import("os").open_file(ro + "/a.txt", import("os").o_rdwr, 0)`,
+		`import("os").read_file(rw + "/link")`,
+		`import("os").read_file(rw + "/../secret")`,
+		`import("os").rename(rw + "/b.txt", dir + "/b.txt")`,
+	} {
+		events = nil
+		_, err = run(input)
+		require.Error(t, err, input)
+		var policyErr *stdlib.PolicyError
+		require.True(t, errors.As(err, &policyErr), input)
+		require.True(t, errors.Is(err, stdlib.ErrDenied), input)
+		require.False(t, events[len(events)-1].Allowed, input)
+	}
+	_, err = os.Stat(filepath.Join(ro, "a.txt"))
+	require.NoError(t, err)
+}
+
+func TestPolicyMemFS(t *testing.T) {
+	fsys := stdlib.NewMemFS()
+	require.NoError(t, fsys.MkdirAll("data", 0755))
+	require.NoError(t, fsys.MkdirAll("out", 0755))
+	require.NoError(t, fsys.WriteFile("data/a.txt", []byte("foo"), 0644))
+	require.NoError(t, fsys.WriteFile("secret", []byte("bar"), 0644))
+	// the relative policy paths are relative to the root of the file system,
+	// not to the working directory
+	policy := &stdlib.Policy{Paths: []stdlib.PathRule{
+		{Path: "data", Access: stdlib.AccessRead},
+		{Path: "/out", Access: stdlib.AccessReadWrite},
+	}}
+	run := func(input string) (*z.Compiled, error) {
+		s := z.NewScript([]byte(input))
+		s.SetImports(stdlib.GetModuleMapWithOptions(
+			stdlib.Options{Policy: policy, FS: fsys}, "os", "path"))
+		return s.Run()
+	}
+
+	c, err := run(`
+os := import("os")
+out := string(os.read_file("data/a.txt")) + string(os.read_file("/data/a.txt"))
+f := os.create("out/b.txt")
+f.write_string("baz")
+f.close()
+out += string(os.read_file("/out/b.txt"))
+out += string(import("path").glob("data/*"))
+`)
+	require.NoError(t, err)
+	require.Equal(t, `foofoobaz["data/a.txt"]`, c.Get("out").Value())
+
+	for _, input := range []string{
+		`import("os").read_file("secret")`,
+		`import("os").read_file("/secret")`,
+		`import("os").read_file("data/../secret")`,
+		`import("os").create("data/c.txt")`,
+	} {
+		_, err = run(input)
+		require.True(t, errors.Is(err, stdlib.ErrDenied), input)
+	}
+}
+
+func TestPolicyEnvExec(t *testing.T) {
+	t.Setenv("Z_POLICY_ALLOWED", "yes")
+	t.Setenv("Z_POLICY_HIDDEN", "no")
+	policy := &stdlib.Policy{
+		Env:         []string{"Z_POLICY_ALLOWED"},
+		Executables: []string{"echo"},
+	}
+
+	c, err := runWithPolicy(policy, `
+os := import("os")
+out := os.getenv("Z_POLICY_ALLOWED") + os.expand_env(" $Z_POLICY_ALLOWED ")
+out += string(os.environ())
+out += string(os.exec("echo", "hi").output())
+`)
+	require.NoError(t, err)
+	require.Equal(t, `yes yes ["Z_POLICY_ALLOWED=yes"]hi`+"\n",
+		c.Get("out").Value())
+
+	for _, input := range []string{
+		`import("os").getenv("Z_POLICY_HIDDEN")`,
+		`import("os").setenv("Z_POLICY_HIDDEN", "x")`,
+		`import("os").expand_env("$Z_POLICY_HIDDEN")`,
+		`import("os").exec("ls")`,
+		`import("os").exec_look_path("sh")`,
+		`import("os").exec("echo").set_path("/bin/sh")`,
+		`import("os").exit(1)`,
+		`import("os").chdir("/")`,
+		`import("os").find_process(1)`,
+	} {
+		_, err = runWithPolicy(policy, input)
+		require.True(t, errors.Is(err, stdlib.ErrDenied), input)
+	}
+	require.Equal(t, "no", os.Getenv("Z_POLICY_HIDDEN"))
+
+	// nil policy allows everything
+	c, err = runWithPolicy(nil,
+		`out := import("os").getenv("Z_POLICY_HIDDEN")`)
+	require.NoError(t, err)
+	require.Equal(t, "no", c.Get("out").Value())
+}
+
+func TestPolicyProcesses(t *testing.T) {
+	t.Setenv("Z_POLICY_ALLOWED", "yes")
+	t.Setenv("Z_POLICY_HIDDEN", "no")
+	dir := t.TempDir()
+	// a script can't run its own sh by starting sh in its directory
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sh"),
+		[]byte("#!/bin/sh\ntouch "+filepath.Join(dir, "pwned")+"\n"), 0755))
+	policy := &stdlib.Policy{
+		Paths:       []stdlib.PathRule{{Path: dir, Access: stdlib.AccessRead}},
+		Env:         []string{"Z_POLICY_ALLOWED"},
+		Executables: []string{"sh", "env"},
+	}
+
+	// the processes only inherit the environment visible to the script
+	c, err := runWithPolicy(policy, `
+os := import("os")
+out := string(os.exec("env").output())
+cmd := os.exec("env")
+cmd.set_env(["Z_POLICY_ALLOWED=set"])
+out += string(cmd.output())
+p := os.start_process("sh", ["sh", "-c", "env > env.txt"], "`+dir+`", [])
+p.wait()
+`)
+	require.NoError(t, err)
+	require.Equal(t, "Z_POLICY_ALLOWED=yes\nZ_POLICY_ALLOWED=set\n",
+		c.Get("out").Value())
+	env, err := os.ReadFile(filepath.Join(dir, "env.txt"))
+	require.NoError(t, err)
+	require.False(t, strings.Contains(string(env), "Z_POLICY_HIDDEN"),
+		string(env))
+	_, err = os.Stat(filepath.Join(dir, "pwned"))
+	require.True(t, os.IsNotExist(err))
+
+	for _, input := range []string{
+		`import("os").exec("./sh")`,
+		`import("os").exec("env").set_env(["Z_POLICY_HIDDEN=x"])`,
+		`import("os").start_process("./sh", ["sh"], "` + dir + `", [])`,
+		`import("os").start_process("sh", ["sh"], "/", [])`,
+		`import("os").start_process("sh", ["sh"], "` + dir + `",
+			["Z_POLICY_HIDDEN=x"])`,
+	} {
+		_, err = runWithPolicy(policy, input)
+		require.True(t, errors.Is(err, stdlib.ErrDenied), input)
+	}
+}
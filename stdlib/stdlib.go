@@ -3,15 +3,28 @@ package stdlib
 //go:generate go run gensrcmods.go
 
 import (
+	"net/http"
+	"sync"
+
 	"github.com/diiyw/z"
 )
 
+var (
+	sharedModulesLock sync.Mutex
+	sharedModules     = make(map[string]*z.LazyModule)
+)
+
 // AllModuleNames returns a list of all default module names.
 func AllModuleNames() []string {
 	var names []string
 	for name := range BuiltinModules {
 		names = append(names, name)
 	}
+	for name := range BuiltinModuleFactories {
+		if BuiltinModules[name] == nil {
+			names = append(names, name)
+		}
+	}
 	for name := range SourceModules {
 		names = append(names, name)
 	}
@@ -19,12 +32,13 @@ func AllModuleNames() []string {
 }
 
 // GetModuleMap returns the module map that includes all modules
-// for the given module names.
+// for the given module names. Builtin modules are constructed when they are
+// first imported, and shared by all the module maps returned by GetModuleMap.
 func GetModuleMap(names ...string) *z.ModuleMap {
 	modules := z.NewModuleMap()
 	for _, name := range names {
-		if mod := BuiltinModules[name]; mod != nil {
-			modules.AddBuiltinModule(name, mod)
+		if mod := sharedModule(name); mod != nil {
+			modules.Add(name, mod)
 		}
 		if mod := SourceModules[name]; mod != "" {
 			modules.AddSourceModule(name, []byte(mod))
@@ -32,3 +46,97 @@ func GetModuleMap(names ...string) *z.ModuleMap {
 	}
 	return modules
 }
+
+// Options configures the modules created by GetModuleMapWithOptions.
+//
+// With any options, os.exit does not exit the host process: it stops the
+// script, whose run returns a *ExitError with the exit code that embedders
+// must handle.
+type Options struct {
+	// Policy restricts the capabilities of the modules. A nil policy allows
+	// everything.
+	Policy *Policy
+
+	// FS is the file system used by the os and path modules. A nil FS uses
+	// the file system of the operating system.
+	FS FS
+
+	// HTTPTransport sends the requests of the http module. A nil transport
+	// uses http.DefaultTransport.
+	HTTPTransport http.RoundTripper
+
+	// HTTPHosts are the hosts the http module can send requests to, also
+	// checked on redirects. A host is a name or an IP address, a "*."
+	// prefixed domain matching its sub domains, optionally followed by a
+	// port (e.g. "api.example.com", "*.example.com", "127.0.0.1:8080"), or
+	// "*" matching all hosts. An empty list denies all hosts, unless
+	// HTTPTransport is set to control the requests. Network access must
+	// also be allowed by the policy. The http module of GetModuleMap denies
+	// all requests.
+	HTTPHosts []string
+
+	// Args are the program arguments returned by os.args and parsed by the
+	// args module, starting with the script. Nil uses os.Args.
+	Args []string
+}
+
+// optionModules are the builtin module constructors that depend on Options.
+var optionModules = map[string]func(opts Options) map[string]z.Object{
+	"os": func(opts Options) map[string]z.Object {
+		return (&osLib{
+			policy: opts.Policy,
+			fs:     opts.FS,
+			args:   opts.Args,
+		}).module()
+	},
+	"args": func(opts Options) map[string]z.Object {
+		return (&argsLib{args: opts.Args}).module()
+	},
+	"path": func(opts Options) map[string]z.Object {
+		return (&pathLib{policy: opts.Policy, fs: opts.FS}).module()
+	},
+	"http": func(opts Options) map[string]z.Object {
+		return (&httpLib{
+			policy:    opts.Policy,
+			transport: opts.HTTPTransport,
+			hosts:     opts.HTTPHosts,
+		}).module()
+	},
+}
+
+// GetModuleMapWithOptions is like GetModuleMap but the modules depending on
+// the options are constructed for the given options. They are not shared
+// with other module maps.
+func GetModuleMapWithOptions(opts Options, names ...string) *z.ModuleMap {
+	modules := GetModuleMap(names...)
+	for _, name := range names {
+		if factory := optionModules[name]; factory != nil {
+			modules.AddLazyModule(name, func() map[string]z.Object {
+				return factory(opts)
+			})
+		}
+	}
+	return modules
+}
+
+// sharedModule returns the lazy module shared by the module maps for the
+// builtin module name, or nil if there is no such module.
+func sharedModule(name string) *z.LazyModule {
+	sharedModulesLock.Lock()
+	defer sharedModulesLock.Unlock()
+
+	if mod, ok := sharedModules[name]; ok {
+		return mod
+	}
+	factory := BuiltinModuleFactories[name]
+	if factory == nil {
+		attrs := BuiltinModules[name]
+		if attrs == nil {
+			return nil
+		}
+		factory = func() map[string]z.Object { return attrs }
+	}
+	mod := &z.LazyModule{Factory: factory}
+	sharedModules[name] = mod
+	return mod
+}
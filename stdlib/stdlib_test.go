@@ -94,6 +94,34 @@ func TestGetModules(t *testing.T) {
 	require.NotNil(t, mods.Get("text"))
 }
 
+func TestGetModulesLazy(t *testing.T) {
+	calls := 0
+	stdlib.BuiltinModuleFactories["lazy_test"] = func() map[string]z.Object {
+		calls++
+		return map[string]z.Object{"x": &z.Int{Value: 1}}
+	}
+	defer func() {
+		delete(stdlib.BuiltinModuleFactories, "lazy_test")
+		stdlib.ForgetSharedModule("lazy_test")
+		require.Nil(t, stdlib.GetModuleMap("lazy_test").Get("lazy_test"))
+	}()
+
+	// the module is constructed once, when first imported
+	s := z.NewScript([]byte(`out := 1`))
+	s.SetImports(stdlib.GetModuleMap("lazy_test"))
+	_, err := s.Compile()
+	require.NoError(t, err)
+	require.Equal(t, 0, calls)
+	for i := 0; i < 2; i++ {
+		s = z.NewScript([]byte(`out := import("lazy_test").x`))
+		s.SetImports(stdlib.GetModuleMap("lazy_test"))
+		c, err := s.Run()
+		require.NoError(t, err)
+		require.Equal(t, 1, c.Get("out").Int())
+	}
+	require.Equal(t, 1, calls)
+}
+
 type callres struct {
 	t *testing.T
 	o any
@@ -240,3 +268,10 @@ func expect(t *testing.T, input string, expected any) {
 	require.NotNil(t, v)
 	require.Equal(t, expected, v.Value())
 }
+
+func expectRuntimeError(t *testing.T, input string) {
+	s := z.NewScript([]byte(input))
+	s.SetImports(stdlib.GetModuleMap(stdlib.AllModuleNames()...))
+	_, err := s.Run()
+	require.Error(t, err, input)
+}
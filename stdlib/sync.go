@@ -0,0 +1,536 @@
+package stdlib
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/diiyw/z"
+)
+
+var syncModule = map[string]z.Object{
+	"go": &z.VMFunction{
+		Name:  "go",
+		Value: syncGo,
+	},
+	"chan": &z.UserFunction{
+		Name:  "chan",
+		Value: syncChan,
+	},
+	"select": &z.VMFunction{
+		Name:  "select",
+		Value: syncSelect,
+	},
+	"mutex": &z.UserFunction{
+		Name:  "mutex",
+		Value: syncMutex,
+	},
+	"wait_group": &z.VMFunction{
+		Name:  "wait_group",
+		Value: syncWaitGroup,
+	},
+}
+
+var (
+	errSyncTimeout         = errors.New("timeout")
+	errSyncClosedChan      = errors.New("send on closed channel")
+	errSyncUnlocked        = errors.New("unlock of unlocked mutex")
+	errSyncNegativeCounter = errors.New("negative wait group counter")
+)
+
+// Channel is a channel that can be shared between the functions spawned
+// using the 'go' function of the sync module. Values are copied when they are
+// sent unless they are immutable.
+type Channel struct {
+	z.ObjectImpl
+	ch      chan z.Object
+	methods map[string]z.Object
+}
+
+// TypeName returns the name of the type.
+func (o *Channel) TypeName() string {
+	return "channel"
+}
+
+func (o *Channel) String() string {
+	return "<channel>"
+}
+
+// Copy returns the channel itself: copies of a channel share the same
+// underlying channel.
+func (o *Channel) Copy() z.Object {
+	return o
+}
+
+// Equals returns true if the value of the type is equal to the value of
+// another object.
+func (o *Channel) Equals(x z.Object) bool {
+	return o == x
+}
+
+// IndexGet returns the channel method identified by the index.
+func (o *Channel) IndexGet(index z.Object) (z.Object, error) {
+	name, ok := index.(*z.String)
+	if !ok {
+		return nil, z.ErrInvalidIndexType
+	}
+	return o.methods[name.Value], nil
+}
+
+// shareable returns a value that can be safely handed over to another
+// goroutine: immutable scalar values and channels are returned as is, all
+// the other values are deep-copied.
+func shareable(o z.Object) z.Object {
+	switch o := o.(type) {
+	case *z.Int, *z.Float, *z.String, *z.Char, *z.Bool, *z.Undefined,
+		*z.Time, *Channel:
+		return o
+	case *z.CompiledFunction:
+		free := make([]*z.ObjectPtr, len(o.Free))
+		for idx, p := range o.Free {
+			var val z.Object = z.UndefinedValue
+			if p.Value != nil && *p.Value != nil {
+				val = shareable(*p.Value)
+			}
+			free[idx] = &z.ObjectPtr{Value: &val}
+		}
+		cl := *o
+		cl.Free = free
+		return &cl
+	}
+	return o.Copy()
+}
+
+// timeoutArg returns the optional timeout argument, in nanoseconds like the
+// durations of the times module, found at the index idx of args. It returns
+// a nil channel (no timeout) if the argument is omitted.
+func timeoutArg(args []z.Object, idx int) (<-chan time.Time, error) {
+	if len(args) <= idx {
+		return nil, nil
+	}
+	ns, ok := z.ToInt64(args[idx])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "timeout",
+			Expected: "int(compatible)",
+			Found:    args[idx].TypeName(),
+		}
+	}
+	return time.After(time.Duration(ns)), nil
+}
+
+func syncGo(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) < 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if vm == nil {
+		return nil, errors.New("go: called outside VM")
+	}
+	fn, ok := args[0].(*z.CompiledFunction)
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "compiled-function",
+			Found:    args[0].TypeName(),
+		}
+	}
+	fnArgs := make([]z.Object, len(args)-1)
+	for idx, arg := range args[1:] {
+		fnArgs[idx] = shareable(arg)
+	}
+
+	spawned := vm.Spawn(fn, fnArgs...)
+	finished := make(chan struct{})
+	var res z.SpawnResult
+	go func() {
+		res = <-spawned
+		close(finished)
+	}()
+	return &z.ImmutableMap{
+		Value: map[string]z.Object{
+			// wait() => object/error
+			"wait": &z.VMFunction{
+				Name: "wait",
+				Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+					if len(args) != 0 {
+						return nil, z.ErrWrongNumArguments
+					}
+					select {
+					case <-finished:
+					case <-vm.Done():
+						return nil, z.ErrAborted
+					}
+					if res.Err != nil {
+						return wrapError(res.Err), nil
+					}
+					return res.Value, nil
+				},
+			},
+			// done() => bool
+			"done": &z.UserFunction{
+				Name: "done",
+				Value: FuncARB(func() bool {
+					select {
+					case <-finished:
+						return true
+					default:
+						return false
+					}
+				}),
+			},
+		},
+	}, nil
+}
+
+func syncChan(args ...z.Object) (z.Object, error) {
+	if len(args) > 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	size := 0
+	if len(args) == 1 {
+		var ok bool
+		size, ok = z.ToInt(args[0])
+		if !ok || size < 0 {
+			return nil, z.ErrInvalidArgumentType{
+				Name:     "size",
+				Expected: "int(compatible)",
+				Found:    args[0].TypeName(),
+			}
+		}
+	}
+	return makeSyncChannel(make(chan z.Object, size)), nil
+}
+
+func makeSyncChannel(ch chan z.Object) *Channel {
+	var closeLock sync.Mutex
+	closed := false
+	c := &Channel{ch: ch}
+	c.methods = map[string]z.Object{
+		// send(value, timeout int) => true/false/error
+		"send": &z.VMFunction{
+			Name: "send",
+			Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+				if len(args) != 1 && len(args) != 2 {
+					return nil, z.ErrWrongNumArguments
+				}
+				timeout, err := timeoutArg(args, 1)
+				if err != nil {
+					return nil, err
+				}
+				closeLock.Lock()
+				isClosed := closed
+				closeLock.Unlock()
+				if isClosed {
+					return wrapError(errSyncClosedChan), nil
+				}
+				return channelSend(vm, ch, shareable(args[0]), timeout)
+			},
+		},
+		// recv(timeout int) => object/error
+		"recv": &z.VMFunction{
+			Name: "recv",
+			Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+				if len(args) > 1 {
+					return nil, z.ErrWrongNumArguments
+				}
+				timeout, err := timeoutArg(args, 0)
+				if err != nil {
+					return nil, err
+				}
+				select {
+				case v, ok := <-ch:
+					if !ok {
+						return z.UndefinedValue, nil
+					}
+					return v, nil
+				case <-timeout:
+					return wrapError(errSyncTimeout), nil
+				case <-vm.Done():
+					return nil, z.ErrAborted
+				}
+			},
+		},
+		// close()
+		"close": &z.UserFunction{
+			Name: "close",
+			Value: func(args ...z.Object) (z.Object, error) {
+				if len(args) != 0 {
+					return nil, z.ErrWrongNumArguments
+				}
+				closeLock.Lock()
+				defer closeLock.Unlock()
+				if !closed {
+					closed = true
+					close(ch)
+				}
+				return z.UndefinedValue, nil
+			},
+		},
+		// len() => int
+		"len": &z.UserFunction{
+			Name: "len",
+			Value: FuncARI(func() int {
+				return len(ch)
+			}),
+		},
+		// cap() => int
+		"cap": &z.UserFunction{
+			Name: "cap",
+			Value: FuncARI(func() int {
+				return cap(ch)
+			}),
+		},
+	}
+	return c
+}
+
+func channelSend(
+	vm *z.VM,
+	ch chan z.Object,
+	value z.Object,
+	timeout <-chan time.Time,
+) (ret z.Object, err error) {
+	defer func() {
+		// the channel was closed while blocking on send
+		if r := recover(); r != nil {
+			ret, err = wrapError(errSyncClosedChan), nil
+		}
+	}()
+	select {
+	case ch <- value:
+		return z.TrueValue, nil
+	case <-timeout:
+		return z.FalseValue, nil
+	case <-vm.Done():
+		return nil, z.ErrAborted
+	}
+}
+
+func syncSelect(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	var chans []z.Object
+	switch arg := args[0].(type) {
+	case *z.Array:
+		chans = arg.Value
+	case *z.ImmutableArray:
+		chans = arg.Value
+	default:
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "array",
+			Found:    args[0].TypeName(),
+		}
+	}
+	timeout, err := timeoutArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	cases := make([]reflect.SelectCase, 0, len(chans)+2)
+	for _, c := range chans {
+		ch, ok := c.(*Channel)
+		if !ok {
+			return nil, z.ErrInvalidArgumentType{
+				Name:     "first",
+				Expected: "array(channel)",
+				Found:    c.TypeName(),
+			}
+		}
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(ch.ch),
+		})
+	}
+	cases = append(cases, reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(timeout),
+	}, reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(vm.Done()),
+	})
+
+	chosen, recv, ok := reflect.Select(cases)
+	switch chosen {
+	case len(chans):
+		return wrapError(errSyncTimeout), nil
+	case len(chans) + 1:
+		return nil, z.ErrAborted
+	}
+	var value z.Object = z.UndefinedValue
+	if ok {
+		value = recv.Interface().(z.Object)
+	}
+	return &z.Array{Value: []z.Object{
+		&z.Int{Value: int64(chosen)},
+		value,
+	}}, nil
+}
+
+func syncMutex(args ...z.Object) (z.Object, error) {
+	if len(args) != 0 {
+		return nil, z.ErrWrongNumArguments
+	}
+	// the mutex is locked while the channel holds a value, so that unlocking
+	// an unlocked mutex is an error instead of a fatal error of the host
+	locked := make(chan struct{}, 1)
+	return &z.ImmutableMap{
+		Value: map[string]z.Object{
+			// lock()
+			"lock": &z.VMFunction{
+				Name: "lock",
+				Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+					if len(args) != 0 {
+						return nil, z.ErrWrongNumArguments
+					}
+					select {
+					case locked <- struct{}{}:
+						return z.UndefinedValue, nil
+					case <-vm.Done():
+						return nil, z.ErrAborted
+					}
+				},
+			},
+			// unlock()
+			"unlock": &z.UserFunction{
+				Name: "unlock",
+				Value: func(args ...z.Object) (z.Object, error) {
+					if len(args) != 0 {
+						return nil, z.ErrWrongNumArguments
+					}
+					select {
+					case <-locked:
+						return z.UndefinedValue, nil
+					default:
+						return nil, errSyncUnlocked
+					}
+				},
+			},
+			// try_lock() => bool
+			"try_lock": &z.UserFunction{
+				Name: "try_lock",
+				Value: FuncARB(func() bool {
+					select {
+					case locked <- struct{}{}:
+						return true
+					default:
+						return false
+					}
+				}),
+			},
+		},
+	}, nil
+}
+
+// waitGroup is a wait group whose counter can't go negative.
+type waitGroup struct {
+	mu   sync.Mutex
+	n    int
+	zero chan struct{} // closed when n goes back to zero
+}
+
+func (wg *waitGroup) add(delta int) error {
+	wg.mu.Lock()
+	defer wg.mu.Unlock()
+	if wg.n+delta < 0 {
+		return errSyncNegativeCounter
+	}
+	wg.n += delta
+	if wg.n == 0 && wg.zero != nil {
+		close(wg.zero)
+		wg.zero = nil
+	}
+	return nil
+}
+
+// done returns a channel closed when the counter is zero.
+func (wg *waitGroup) done() <-chan struct{} {
+	wg.mu.Lock()
+	defer wg.mu.Unlock()
+	if wg.n == 0 {
+		return closedChan
+	}
+	if wg.zero == nil {
+		wg.zero = make(chan struct{})
+	}
+	return wg.zero
+}
+
+// closedChan is a closed channel.
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+func syncWaitGroup(_ *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 0 {
+		return nil, z.ErrWrongNumArguments
+	}
+	wg := &waitGroup{}
+	return &z.ImmutableMap{
+		Value: map[string]z.Object{
+			// add(delta int)
+			"add": &z.UserFunction{
+				Name: "add",
+				Value: func(args ...z.Object) (z.Object, error) {
+					if len(args) > 1 {
+						return nil, z.ErrWrongNumArguments
+					}
+					delta := 1
+					if len(args) == 1 {
+						var ok bool
+						delta, ok = z.ToInt(args[0])
+						if !ok {
+							return nil, z.ErrInvalidArgumentType{
+								Name:     "first",
+								Expected: "int(compatible)",
+								Found:    args[0].TypeName(),
+							}
+						}
+					}
+					if err := wg.add(delta); err != nil {
+						return nil, err
+					}
+					return z.UndefinedValue, nil
+				},
+			},
+			// done()
+			"done": &z.UserFunction{
+				Name: "done",
+				Value: func(args ...z.Object) (z.Object, error) {
+					if len(args) != 0 {
+						return nil, z.ErrWrongNumArguments
+					}
+					if err := wg.add(-1); err != nil {
+						return nil, err
+					}
+					return z.UndefinedValue, nil
+				},
+			},
+			// wait(timeout int) => true/false
+			"wait": &z.VMFunction{
+				Name: "wait",
+				Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+					if len(args) > 1 {
+						return nil, z.ErrWrongNumArguments
+					}
+					timeout, err := timeoutArg(args, 0)
+					if err != nil {
+						return nil, err
+					}
+					select {
+					case <-wg.done():
+						return z.TrueValue, nil
+					case <-timeout:
+						return z.FalseValue, nil
+					case <-vm.Done():
+						return nil, z.ErrAborted
+					}
+				},
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,220 @@
+package stdlib_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+)
+
+func TestSyncGo(t *testing.T) {
+	expect(t, `
+sync := import("sync")
+sum := func(a, b) { return a + b }
+out := sync.go(sum, 1, 2).wait()
+`, int64(3))
+
+	// closures get a copy of their free variables
+	expect(t, `
+sync := import("sync")
+out := 0
+f := func() {
+	x := [1, 2]
+	h := sync.go(func() { x[0] = 5; return x[0] })
+	return [h.wait(), x[0]]
+}
+out = string(f())
+`, "[5, 1]")
+
+	// arguments are copied
+	expect(t, `
+sync := import("sync")
+m := {a: 1}
+sync.go(func(m) { m.a = 2 }, m).wait()
+out := m.a
+`, int64(1))
+
+	// runtime errors are returned by wait
+	expect(t, `
+sync := import("sync")
+out := is_error(sync.go(func() { return 1 + "a" + [] }).wait())
+`, true)
+}
+
+func TestSyncChan(t *testing.T) {
+	expect(t, `
+sync := import("sync")
+ch := sync.chan()
+sync.go(func() {
+	for i := 0; i < 5; i++ { ch.send(i) }
+	ch.close()
+})
+out := 0
+for {
+	v := ch.recv()
+	if v == undefined { break }
+	out += v
+}
+`, int64(10))
+
+	expect(t, `
+sync := import("sync")
+times := import("times")
+ch := sync.chan(1)
+out := string([ch.send(1, 10 * times.millisecond), ch.send(2, 10 * times.millisecond), ch.len(), ch.cap()])
+`, "[true, false, 1, 1]")
+
+	expect(t, `
+sync := import("sync")
+times := import("times")
+ch := sync.chan()
+out := string(ch.recv(10 * times.millisecond))
+`, "error: \"timeout\"")
+
+	expect(t, `
+sync := import("sync")
+ch := sync.chan(1)
+ch.close()
+out := is_error(ch.send(1))
+`, true)
+
+	expect(t, `
+sync := import("sync")
+times := import("times")
+a := sync.chan(1)
+b := sync.chan(1)
+b.send("b")
+out := string(sync.select([a, b], times.second))
+`, `[1, "b"]`)
+
+	expect(t, `
+sync := import("sync")
+times := import("times")
+out := is_error(sync.select([sync.chan()], 10 * times.millisecond))
+`, true)
+}
+
+func TestSyncMutexWaitGroup(t *testing.T) {
+	expect(t, `
+sync := import("sync")
+mu := sync.mutex()
+wg := sync.wait_group()
+ch := sync.chan(10)
+for i := 0; i < 10; i++ {
+	wg.add()
+	sync.go(func(i) {
+		mu.lock()
+		ch.send(i)
+		mu.unlock()
+		wg.done()
+	}, i)
+}
+wg.wait()
+out := 0
+for i := 0; i < 10; i++ { out += ch.recv() }
+`, int64(45))
+
+	expect(t, `
+sync := import("sync")
+mu := sync.mutex()
+out := string([mu.try_lock(), mu.try_lock()])
+`, "[true, false]")
+
+	expect(t, `
+sync := import("sync")
+times := import("times")
+wg := sync.wait_group()
+wg.add(1)
+out := wg.wait(10 * times.millisecond)
+`, false)
+
+	// misuses are runtime errors instead of crashing the host
+	expectRuntimeError(t, `import("sync").mutex().unlock()`)
+	expectRuntimeError(t, `import("sync").wait_group().done()`)
+	expectRuntimeError(t, `
+wg := import("sync").wait_group()
+wg.add(1)
+wg.add(-2)`)
+	expect(t, `
+sync := import("sync")
+times := import("times")
+mu := sync.mutex()
+mu.lock()
+mu.unlock()
+wg := sync.wait_group()
+wg.add(2)
+wg.done()
+wg.done()
+out := string([mu.try_lock(), wg.wait(10 * times.millisecond)])
+`, "[true, true]")
+}
+
+func TestSyncAbort(t *testing.T) {
+	s := z.NewScript([]byte(`
+sync := import("sync")
+ch := sync.chan()
+sync.go(func() { for { } })
+h := sync.go(func() { return ch.recv() })
+h.wait()
+`))
+	s.SetImports(stdlib.GetModuleMap("sync"))
+	c, err := s.Compile()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, c.RunContext(ctx))
+}
+
+func TestSyncSpawnedLimits(t *testing.T) {
+	run := func(src string, maxAllocs int64) (*z.Compiled, error) {
+		s := z.NewScript([]byte(src))
+		s.SetImports(stdlib.GetModuleMap("sync"))
+		s.SetMaxAllocs(maxAllocs)
+		_ = s.Add("boom", &z.UserFunction{
+			Value: func(args ...z.Object) (z.Object, error) {
+				panic("boom")
+			},
+		})
+		return s.Run()
+	}
+
+	// the spawned functions share the allocation limit of the script
+	loop := `for i := 0; i < 100; i++ { x := [i] }`
+	_, err := run(`sync := import("sync")
+`+loop, 500)
+	require.NoError(t, err)
+	_, err = run(`sync := import("sync")
+h := sync.go(func() { `+loop+` })
+out := is_error(h.wait())`, 500)
+	require.NoError(t, err)
+	_, err = run(`sync := import("sync")
+`+loop+`
+h := sync.go(func() { `+loop+` })
+if is_error(h.wait()) { x := [] + [] }`, 500)
+	require.Error(t, err)
+
+	// a panic in a spawned function is its error
+	c, err := run(`sync := import("sync")
+out := sync.go(func() { return boom() }).wait()`, -1)
+	require.NoError(t, err)
+	require.Equal(t, `error: "boom"`, c.Get("out").String())
+
+	// the spawned functions are aborted when the script returns
+	c, err = run(`h := import("sync").go(func() { for { } })`, -1)
+	require.NoError(t, err)
+	done := c.Get("h").Object().(*z.ImmutableMap).Value["done"]
+	for i := 0; ; i++ {
+		res, err := done.Call()
+		require.NoError(t, err)
+		if res == z.TrueValue {
+			break
+		}
+		require.True(t, i < 100, "spawned function still running")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
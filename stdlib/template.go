@@ -0,0 +1,185 @@
+package stdlib
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/stdlib/template"
+)
+
+// templateModuleNames are the modules the templates can import.
+var templateModuleNames = []string{
+	"base64", "enum", "fmt", "hex", "json", "math", "text", "times",
+}
+
+// templateModules is set in init, as the module map refers to the builtin
+// modules, which include the template module.
+var templateModules *z.ModuleMap
+
+func init() {
+	templateModules = GetModuleMap(templateModuleNames...)
+}
+
+var templateModule = map[string]z.Object{
+	"compile": &z.UserFunction{
+		Name:  "compile",
+		Value: templateCompile,
+	},
+	"render": &z.VMFunction{
+		Name:  "render",
+		Value: templateRender,
+	},
+}
+
+func templateCompile(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, z.ErrWrongNumArguments
+	}
+	t, err := newTemplate(args[0], args[1:])
+	if err != nil {
+		if _, ok := err.(*template.SyntaxError); ok {
+			return wrapError(err), nil
+		}
+		return nil, err
+	}
+	return &z.ImmutableMap{
+		Value: map[string]z.Object{
+			"name": &z.String{Value: t.Name()},
+			"render": &z.VMFunction{
+				Name: "render",
+				Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+					if len(args) > 1 {
+						return nil, z.ErrWrongNumArguments
+					}
+					return renderTemplate(vm, t, args)
+				},
+			},
+		},
+	}, nil
+}
+
+func templateRender(vm *z.VM, args ...z.Object) (z.Object, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, z.ErrWrongNumArguments
+	}
+	t, err := newTemplate(args[0], args[2:])
+	if err != nil {
+		if _, ok := err.(*template.SyntaxError); ok {
+			return wrapError(err), nil
+		}
+		return nil, err
+	}
+	return renderTemplate(vm, t, args[1:2])
+}
+
+func newTemplate(srcArg z.Object, optArgs []z.Object) (*template.Template, error) {
+	src, err := dataArg(srcArg, "src")
+	if err != nil {
+		return nil, err
+	}
+	name := "main"
+	opts := template.Options{Modules: templateModules}
+	if len(optArgs) > 0 {
+		var m map[string]z.Object
+		switch arg := optArgs[0].(type) {
+		case *z.Map:
+			m = arg.Value
+		case *z.ImmutableMap:
+			m = arg.Value
+		default:
+			return nil, z.ErrInvalidArgumentType{
+				Name:     "options",
+				Expected: "map",
+				Found:    optArgs[0].TypeName(),
+			}
+		}
+		for key, value := range m {
+			switch key {
+			case "name":
+				s, ok := value.(*z.String)
+				if !ok {
+					return nil, z.ErrInvalidArgumentType{
+						Name:     "name",
+						Expected: "string",
+						Found:    value.TypeName(),
+					}
+				}
+				name = s.Value
+			case "html":
+				opts.HTML = !value.IsFalsy()
+			case "partials":
+				partials, ok := value.(*z.Map)
+				if !ok {
+					return nil, z.ErrInvalidArgumentType{
+						Name:     "partials",
+						Expected: "map",
+						Found:    value.TypeName(),
+					}
+				}
+				opts.Partials = make(map[string]string, len(partials.Value))
+				for pname, psrc := range partials.Value {
+					s, ok := psrc.(*z.String)
+					if !ok {
+						return nil, z.ErrInvalidArgumentType{
+							Name:     "partials." + pname,
+							Expected: "string",
+							Found:    psrc.TypeName(),
+						}
+					}
+					opts.Partials[pname] = s.Value
+				}
+			default:
+				return nil, fmt.Errorf("unknown template option: %s", key)
+			}
+		}
+	}
+	return template.New(name, string(src), opts)
+}
+
+func renderTemplate(
+	vm *z.VM,
+	t *template.Template,
+	args []z.Object,
+) (z.Object, error) {
+	var data map[string]z.Object
+	if len(args) > 0 {
+		switch arg := args[0].(type) {
+		case *z.Map:
+			data = arg.Value
+		case *z.ImmutableMap:
+			data = arg.Value
+		case *z.Undefined:
+		default:
+			return nil, z.ErrInvalidArgumentType{
+				Name:     "data",
+				Expected: "map",
+				Found:    args[0].TypeName(),
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if vm != nil {
+		// abort the template with the VM
+		go func() {
+			select {
+			case <-vm.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	s, err := t.Render(ctx, data)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, z.ErrAborted
+		}
+		return wrapError(err), nil
+	}
+	if len(s) > z.MaxStringLen {
+		return nil, z.ErrStringLimit
+	}
+	return &z.String{Value: s}, nil
+}
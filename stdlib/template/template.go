@@ -0,0 +1,560 @@
+// Package template implements text templates with embedded Z expressions.
+//
+// A template is translated into a Z script that writes its output, and is
+// compiled by the Z compiler, so the expressions of a template have the same
+// semantics as scripts.
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/parser"
+	"github.com/diiyw/z/token"
+)
+
+// MaxIncludeDepth is the maximum nesting of the included templates.
+const MaxIncludeDepth = 64
+
+// names of the output functions of the generated scripts
+const (
+	textFunc    = "__t"
+	exprFunc    = "__e"
+	rawFunc     = "__r"
+	includeFunc = "__i"
+)
+
+// A SyntaxError is a description of a template syntax error.
+type SyntaxError struct {
+	msg  string
+	Name string // name of the template
+	Line int    // line of the error, starting at 1
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("template: %s:%d: %s", e.Name, e.Line, e.msg)
+}
+
+// Options are the options of the templates.
+type Options struct {
+	// HTML escapes the output of the expressions for HTML, except the
+	// output of the raw actions.
+	HTML bool
+
+	// Partials are the sources of the templates that can be included by
+	// their names.
+	Partials map[string]string
+
+	// Modules are the modules the templates can import.
+	Modules z.ModuleGetter
+}
+
+// Template is a compiled template. It's safe for concurrent use.
+type Template struct {
+	name     string
+	code     string
+	texts    []string
+	html     bool
+	modules  z.ModuleGetter
+	partials map[string]*Template
+
+	mu       sync.Mutex
+	compiled map[string]*z.Compiled
+}
+
+// New parses the template src and its partials.
+func New(name, src string, opts Options) (*Template, error) {
+	partials := make(map[string]*Template, len(opts.Partials))
+	t, err := parse(name, src, opts, partials)
+	if err != nil {
+		return nil, err
+	}
+	for pname, psrc := range opts.Partials {
+		p, err := parse(pname, psrc, opts, partials)
+		if err != nil {
+			return nil, err
+		}
+		partials[pname] = p
+	}
+	return t, nil
+}
+
+func parse(
+	name, src string,
+	opts Options,
+	partials map[string]*Template,
+) (*Template, error) {
+	t := &Template{
+		name:     name,
+		html:     opts.HTML,
+		modules:  opts.Modules,
+		partials: partials,
+		compiled: make(map[string]*z.Compiled),
+	}
+	tr := &translator{name: name, src: src, line: 1}
+	if err := tr.translate(); err != nil {
+		return nil, err
+	}
+	t.code = tr.code.String()
+	t.texts = tr.texts
+	return t, nil
+}
+
+// Name returns the name of the template.
+func (t *Template) Name() string {
+	return t.name
+}
+
+// Render executes the template with the values of data defined as
+// variables, and returns the output. The keys of data that are not valid
+// identifiers are ignored.
+func (t *Template) Render(
+	ctx context.Context,
+	data map[string]z.Object,
+) (string, error) {
+	return t.render(ctx, data, 0)
+}
+
+func (t *Template) render(
+	ctx context.Context,
+	data map[string]z.Object,
+	depth int,
+) (string, error) {
+	if depth > MaxIncludeDepth {
+		return "", errors.New("template: maximum include depth exceeded")
+	}
+	names := make([]string, 0, len(data))
+	for name := range data {
+		if isVarName(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	c, err := t.compile(names)
+	if err != nil {
+		return "", err
+	}
+
+	c = c.Clone()
+	for _, name := range names {
+		if err := c.Set(name, data[name]); err != nil {
+			return "", err
+		}
+	}
+	var out strings.Builder
+	write := func(s string) error {
+		if out.Len()+len(s) > z.MaxStringLen {
+			return z.ErrStringLimit
+		}
+		out.WriteString(s)
+		return nil
+	}
+	_ = c.Set(textFunc, &z.UserFunction{
+		Value: func(args ...z.Object) (z.Object, error) {
+			return nil, write(t.texts[args[0].(*z.Int).Value])
+		},
+	})
+	_ = c.Set(exprFunc, &z.UserFunction{
+		Value: func(args ...z.Object) (z.Object, error) {
+			s := toText(args[0])
+			if t.html {
+				s = html.EscapeString(s)
+			}
+			return nil, write(s)
+		},
+	})
+	_ = c.Set(rawFunc, &z.UserFunction{
+		Value: func(args ...z.Object) (z.Object, error) {
+			return nil, write(toText(args[0]))
+		},
+	})
+	_ = c.Set(includeFunc, &z.UserFunction{
+		Value: func(args ...z.Object) (z.Object, error) {
+			return nil, t.include(ctx, data, depth, write, args...)
+		},
+	})
+	if err := c.RunContext(ctx); err != nil {
+		return "", t.fixError(err)
+	}
+	return out.String(), nil
+}
+
+func (t *Template) include(
+	ctx context.Context,
+	data map[string]z.Object,
+	depth int,
+	write func(string) error,
+	args ...z.Object,
+) error {
+	if len(args) != 1 && len(args) != 2 {
+		return z.ErrWrongNumArguments
+	}
+	name, ok := args[0].(*z.String)
+	if !ok {
+		return z.ErrInvalidArgumentType{
+			Name:     "name",
+			Expected: "string",
+			Found:    args[0].TypeName(),
+		}
+	}
+	p := t.partials[name.Value]
+	if p == nil {
+		return fmt.Errorf("template: no partial named %q", name.Value)
+	}
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *z.Map:
+			data = arg.Value
+		case *z.ImmutableMap:
+			data = arg.Value
+		default:
+			return z.ErrInvalidArgumentType{
+				Name:     "data",
+				Expected: "map",
+				Found:    args[1].TypeName(),
+			}
+		}
+	}
+	s, err := p.render(ctx, data, depth+1)
+	if err != nil {
+		return err
+	}
+	return write(s)
+}
+
+// compile returns the compiled script of the template for the variable
+// names.
+func (t *Template) compile(names []string) (*z.Compiled, error) {
+	key := strings.Join(names, ",")
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c := t.compiled[key]; c != nil {
+		return c, nil
+	}
+
+	s := z.NewScript([]byte(t.code))
+	for _, name := range names {
+		_ = s.Add(name, nil)
+	}
+	for _, name := range []string{textFunc, exprFunc, rawFunc, includeFunc} {
+		_ = s.Add(name, nil)
+	}
+	if t.modules != nil {
+		s.SetImports(t.modules)
+	}
+	c, err := s.Compile()
+	if err != nil {
+		return nil, t.fixError(err)
+	}
+	t.compiled[key] = c
+	return c, nil
+}
+
+// scriptPos matches the positions in the errors of the generated scripts.
+var scriptPos = regexp.MustCompile(`\(main\):(\d+):\d+`)
+
+// fixError replaces the positions of the generated script in err with the
+// positions in the template. The lines of the script are the lines of the
+// template, but the columns are not kept.
+func (t *Template) fixError(err error) error {
+	msg := err.Error()
+	if !scriptPos.MatchString(msg) {
+		return err
+	}
+	return errors.New(scriptPos.ReplaceAllString(msg, t.name+":$1"))
+}
+
+func toText(o z.Object) string {
+	if o == z.UndefinedValue {
+		return ""
+	}
+	s, _ := z.ToString(o)
+	return s
+}
+
+func isVarName(s string) bool {
+	if s == "" || strings.HasPrefix(s, "__") {
+		return false
+	}
+	for i, c := range s {
+		if c != '_' && !isLetter(c) && (i == 0 || !isDigit(c)) {
+			return false
+		}
+	}
+	return token.Lookup(s) == token.Ident
+}
+
+func isLetter(c rune) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c rune) bool {
+	return '0' <= c && c <= '9'
+}
+
+// translator translates a template into the source of a Z script. The
+// code of an action is written at the line of the action in the template,
+// so the positions in the errors of the script point to the template.
+type translator struct {
+	name  string
+	src   string
+	pos   int
+	line  int // line of pos
+	trim  bool
+	code  strings.Builder
+	cline int // line of the end of code
+	sep   bool
+	texts []string
+	open  []block
+}
+
+type block struct {
+	kind string // "if", "else" or "for"
+	line int
+}
+
+func (tr *translator) errorf(line int, format string, args ...any) error {
+	return &SyntaxError{
+		msg:  fmt.Sprintf(format, args...),
+		Name: tr.name,
+		Line: line,
+	}
+}
+
+func (tr *translator) translate() error {
+	tr.cline = 1
+	for {
+		start := strings.Index(tr.src[tr.pos:], "{{")
+		if start < 0 {
+			tr.text(tr.src[tr.pos:], false)
+			break
+		}
+		start += tr.pos
+		trimLeft := strings.HasPrefix(tr.src[start:], "{{- ")
+		tr.text(tr.src[tr.pos:start], trimLeft)
+		tr.advance(start)
+
+		line := tr.line
+		inner, err := tr.action()
+		if err != nil {
+			return err
+		}
+		if err := tr.emitAction(line, inner); err != nil {
+			return err
+		}
+	}
+	if n := len(tr.open); n > 0 {
+		b := tr.open[n-1]
+		return tr.errorf(b.line, "missing end of %s", b.kind)
+	}
+	return nil
+}
+
+// advance moves the position to pos.
+func (tr *translator) advance(pos int) {
+	tr.line += strings.Count(tr.src[tr.pos:pos], "\n")
+	tr.pos = pos
+}
+
+// text writes the text s. The leading white space is trimmed after a "-}}"
+// and the trailing white space is trimmed before a "{{-".
+func (tr *translator) text(s string, trimRight bool) {
+	if tr.trim {
+		s = strings.TrimLeft(s, " \t\r\n")
+	}
+	if trimRight {
+		s = strings.TrimRight(s, " \t\r\n")
+	}
+	if s == "" {
+		return
+	}
+	tr.texts = append(tr.texts, s)
+	tr.emit(tr.cline, fmt.Sprintf("%s(%d)", textFunc, len(tr.texts)-1))
+}
+
+// action reads the action at the position and returns its contents. The
+// "}}" that are in literals or brackets don't end an action.
+func (tr *translator) action() (string, error) {
+	line := tr.line
+	i := tr.pos + 2
+	var depth int
+	for i < len(tr.src) {
+		switch c := tr.src[i]; c {
+		case '{', '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case '}':
+			if depth == 0 && strings.HasPrefix(tr.src[i:], "}}") {
+				inner := tr.src[tr.pos+2 : i]
+				tr.trim = false
+				if strings.HasPrefix(inner, "- ") {
+					inner = inner[1:]
+				}
+				if strings.HasSuffix(inner, " -") {
+					inner = inner[:len(inner)-1]
+					tr.trim = true
+				}
+				tr.advance(i + 2)
+				return strings.TrimSpace(inner), nil
+			}
+			depth--
+		case '"', '\'', '`':
+			end := literalEnd(tr.src, i)
+			if end < 0 {
+				return "", tr.errorf(line, "unterminated literal in action")
+			}
+			i = end
+		case '/':
+			if strings.HasPrefix(tr.src[i:], "/*") {
+				end := strings.Index(tr.src[i+2:], "*/")
+				if end < 0 {
+					return "", tr.errorf(line, "unterminated comment")
+				}
+				i += end + 3
+			}
+		}
+		i++
+	}
+	return "", tr.errorf(line, "unclosed action")
+}
+
+// literalEnd returns the index of the closing quote of the literal that
+// starts at i, or -1 if it's not closed.
+func literalEnd(s string, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case quote:
+			return j
+		case '\\':
+			if quote != '`' {
+				j++
+			}
+		case '\n':
+			if quote != '`' {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+func (tr *translator) emitAction(line int, inner string) error {
+	keyword, rest := inner, ""
+	if i := strings.IndexAny(inner, " \t\r\n"); i >= 0 {
+		keyword, rest = inner[:i], strings.TrimSpace(inner[i:])
+	}
+	switch keyword {
+	case "if":
+		if rest == "" {
+			return tr.errorf(line, "missing condition of if")
+		}
+		tr.open = append(tr.open, block{kind: "if", line: line})
+		tr.emit(line, "if "+rest+" {")
+	case "else":
+		n := len(tr.open)
+		if n == 0 || tr.open[n-1].kind != "if" {
+			return tr.errorf(line, "unexpected else")
+		}
+		if rest == "" {
+			tr.open[n-1].kind = "else"
+			tr.emit(line, "} else {")
+			break
+		}
+		cond := strings.TrimPrefix(rest, "if")
+		if cond == rest || strings.TrimSpace(cond) == "" {
+			return tr.errorf(line, "unexpected %q after else", rest)
+		}
+		tr.emit(line, "} else if "+strings.TrimSpace(cond)+" {")
+	case "for":
+		if rest == "" {
+			return tr.errorf(line, "missing clause of for")
+		}
+		tr.open = append(tr.open, block{kind: "for", line: line})
+		tr.emit(line, "for "+rest+" {")
+	case "end":
+		if rest != "" {
+			return tr.errorf(line, "unexpected %q after end", rest)
+		}
+		if len(tr.open) == 0 {
+			return tr.errorf(line, "unexpected end")
+		}
+		tr.open = tr.open[:len(tr.open)-1]
+		tr.emit(line, "}")
+	case "include":
+		if rest == "" {
+			return tr.errorf(line, "missing name of include")
+		}
+		tr.emit(line, includeFunc+"("+rest+")")
+	case "raw":
+		if rest == "" {
+			return tr.errorf(line, "missing expression of raw")
+		}
+		tr.emit(line, rawFunc+"("+rest+")")
+	default:
+		if strings.HasPrefix(inner, "/*") && strings.HasSuffix(inner, "*/") {
+			break // comment
+		}
+		stmt, err := tr.parseStmt(line, inner)
+		if err != nil {
+			return err
+		}
+		switch stmt.(type) {
+		case *parser.ExprStmt:
+			tr.emit(line, exprFunc+"("+inner+")")
+		case *parser.AssignStmt, *parser.IncDecStmt:
+			tr.emit(line, inner)
+		default:
+			return tr.errorf(line, "unexpected statement in action")
+		}
+	}
+	return nil
+}
+
+// parseStmt parses the action as a Z statement to tell the expressions
+// from the assignments.
+func (tr *translator) parseStmt(line int, src string) (parser.Stmt, error) {
+	if src == "" {
+		return nil, tr.errorf(line, "missing expression")
+	}
+	fileSet := parser.NewFileSet()
+	srcFile := fileSet.AddFile(tr.name, -1, len(src))
+	file, err := parser.NewParser(srcFile, []byte(src), nil).ParseFile()
+	if err != nil {
+		var list parser.ErrorList
+		if errors.As(err, &list) && len(list) > 0 {
+			return nil, tr.errorf(line+list[0].Pos.Line-1, "%s", list[0].Msg)
+		}
+		return nil, tr.errorf(line, "%s", err)
+	}
+	if len(file.Stmts) != 1 {
+		return nil, tr.errorf(line, "action must be a single statement")
+	}
+	return file.Stmts[0], nil
+}
+
+// emit writes the code at the line of the template, separated from the
+// previous code on the same line.
+func (tr *translator) emit(line int, code string) {
+	for tr.cline < line {
+		tr.code.WriteByte('\n')
+		tr.cline++
+		tr.sep = false
+	}
+	if tr.sep && !strings.HasPrefix(code, "}") {
+		tr.code.WriteString("; ")
+	} else if tr.sep {
+		tr.code.WriteByte(' ')
+	}
+	tr.code.WriteString(code)
+	tr.cline += strings.Count(code, "\n")
+	tr.sep = !strings.HasSuffix(code, "{")
+}
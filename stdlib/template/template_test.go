@@ -0,0 +1,141 @@
+package template_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib/template"
+)
+
+type MAP = map[string]any
+
+func render(t *testing.T, src string, data MAP, opts template.Options) string {
+	tmpl, err := template.New("test", src, opts)
+	require.NoError(t, err)
+	m := make(map[string]z.Object, len(data))
+	for key, value := range data {
+		o, err := z.FromInterface(value)
+		require.NoError(t, err)
+		m[key] = o
+	}
+	s, err := tmpl.Render(context.Background(), m)
+	require.NoError(t, err)
+	return s
+}
+
+func TestRender(t *testing.T) {
+	opts := template.Options{}
+	require.Equal(t, "", render(t, "", nil, opts))
+	require.Equal(t, "plain", render(t, "plain", nil, opts))
+	require.Equal(t, "a=1 b=x c=", render(t, "a={{ a }} b={{ b }} c={{ c }}",
+		MAP{"a": 1, "b": "x", "c": nil}, opts))
+	require.Equal(t, "3 [1, 2]", render(t, "{{ 1 + 2 }} {{ [1, 2] }}", nil, opts))
+	require.Equal(t, "1", render(t, "{{ {a: {b: 1}}.a.b }}", nil, opts))
+	require.Equal(t, "}}", render(t, `{{ "}}" }}`, nil, opts))
+	require.Equal(t, "ab", render(t, "a{{/* {{ x }} */}}b", nil, opts))
+
+	// conditionals and loops
+	src := "{{ for x in xs }}{{ if x > 2 }}big{{ else if x == 2 }}two" +
+		"{{ else }}small{{ end }},{{ end }}"
+	require.Equal(t, "small,two,big,", render(t, src, MAP{"xs": []any{1, 2, 3}},
+		opts))
+	require.Equal(t, "a:1 b:2 ", render(t,
+		"{{ for k in keys }}{{ k }}:{{ m[k] }} {{ end }}",
+		MAP{"keys": []any{"a", "b"}, "m": MAP{"a": 1, "b": 2}}, opts))
+	require.Equal(t, "012", render(t, "{{ for i := 0; i < 3; i++ }}{{ i }}{{ end }}",
+		nil, opts))
+
+	// assignments
+	require.Equal(t, "6", render(t, "{{ s := 0 }}{{ for x in xs }}{{ s += x }}"+
+		"{{ end }}{{ s }}", MAP{"xs": []any{1, 2, 3}}, opts))
+
+	// trim markers
+	require.Equal(t, "[a][b]", render(t, "{{ for x in xs -}}\n  [{{ x }}]\n{{- end }}",
+		MAP{"xs": []any{"a", "b"}}, opts))
+	require.Equal(t, "-1", render(t, "{{-1}}", nil, opts))
+
+	// invalid variable names are ignored
+	require.Equal(t, "1", render(t, "{{ a }}", MAP{"a": 1, "b-c": 2, "if": 3,
+		"__t": 4}, opts))
+}
+
+func TestRenderHTML(t *testing.T) {
+	opts := template.Options{HTML: true}
+	require.Equal(t, "<p>&lt;b&gt; &amp; &#34;q&#34;</p>", render(t,
+		`<p>{{ s }}</p>`, MAP{"s": `<b> & "q"`}, opts))
+	require.Equal(t, "<b>", render(t, `{{ raw s }}`, MAP{"s": "<b>"}, opts))
+}
+
+func TestPartials(t *testing.T) {
+	opts := template.Options{
+		HTML: true,
+		Partials: map[string]string{
+			"item": "<li>{{ name }}</li>",
+			"list": `<ul>{{ for x in items }}{{ include "item", {name: x} }}{{ end }}</ul>`,
+			"loop": `{{ include "loop" }}`,
+		},
+	}
+	require.Equal(t, "<ul><li>a</li><li>&lt;b&gt;</li></ul>", render(t,
+		`{{ include "list" }}`, MAP{"items": []any{"a", "<b>"}}, opts))
+
+	tmpl, err := template.New("test", `{{ include "loop" }}`, opts)
+	require.NoError(t, err)
+	_, err = tmpl.Render(context.Background(), nil)
+	require.Error(t, err)
+
+	tmpl, err = template.New("test", `{{ include "none" }}`, opts)
+	require.NoError(t, err)
+	_, err = tmpl.Render(context.Background(), nil)
+	require.Error(t, err)
+}
+
+func TestImport(t *testing.T) {
+	mods := z.NewModuleMap()
+	mods.AddSourceModule("double", []byte(`export func(x) { return x * 2 }`))
+	require.Equal(t, "42", render(t, `{{ double := import("double") }}{{ double(n) }}`,
+		MAP{"n": 21}, template.Options{Modules: mods}))
+}
+
+func TestErrors(t *testing.T) {
+	for src, msg := range map[string]string{
+		"a {{ x":                         "template: test:1: unclosed action",
+		"{{ if true }}":                  "template: test:1: missing end of if",
+		"\n{{ end }}":                    "template: test:2: unexpected end",
+		"{{ for x in y }}{{ else }}":     "template: test:1: unexpected else",
+		"{{ if a }}{{ else }}{{ else }}": "template: test:1: unexpected else",
+		"a\n\n{{ 1 + }}":                 "template: test:3: expected operand, found 'EOF'",
+		"{{ return 1 }}":                 "template: test:1: unexpected statement in action",
+		"{{ a; b }}":                     "template: test:1: action must be a single statement",
+		`{{ "a }}`:                       "template: test:1: unterminated literal in action",
+	} {
+		_, err := template.New("test", src, template.Options{})
+		require.Error(t, err, src)
+		require.Equal(t, msg, err.Error(), src)
+	}
+
+	// compile and run-time errors point to the lines of the template
+	tmpl, err := template.New("test", "a\n{{ x }}", template.Options{})
+	require.NoError(t, err)
+	_, err = tmpl.Render(context.Background(), nil)
+	require.Equal(t, "Compile Error: unresolved reference 'x'\n\tat test:2", err.Error())
+
+	tmpl, err = template.New("test", "a\n\nb{{ x() }}", template.Options{})
+	require.NoError(t, err)
+	_, err = tmpl.Render(context.Background(), map[string]z.Object{
+		"x": &z.Int{Value: 0},
+	})
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "at test:3"), err.Error())
+
+	// the rendering is aborted with the context
+	tmpl, err = template.New("test", "{{ for true }}{{ end }}", template.Options{})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = tmpl.Render(ctx, nil)
+	require.Equal(t, context.DeadlineExceeded, err)
+}
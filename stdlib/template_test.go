@@ -0,0 +1,54 @@
+package stdlib_test
+
+import (
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+)
+
+func TestTemplate(t *testing.T) {
+	module(t, "template").call("compile", "{{ if true }}").expect(&z.Error{
+		Value: &z.String{Value: "template: main:1: missing end of if"},
+	})
+	module(t, "template").call("compile", 1).expectError()
+	module(t, "template").call("compile", "", MAP{"partials": MAP{"a": 1}}).
+		expectError()
+
+	expect(t, `
+template := import("template")
+out := template.render("a={{ a }}", {a: 1}) + "|" +
+	template.render("{{ s }}", {s: "<b>"}, {html: true}) + "|" +
+	template.render("{{ x }}", {}).value + "|" +
+	template.render("{{ if x }}", {x: 1}).value
+`, "a=1|&lt;b&gt;|Compile Error: unresolved reference 'x'\n\tat main:1|"+
+		"template: main:1: missing end of if")
+	for _, input := range []string{
+		`import("template").render("", 1)`,
+		`import("template").render("", {}, {x: 1})`,
+		`import("template").compile("").render({}, {})`,
+	} {
+		s := z.NewScript([]byte(input))
+		s.SetImports(stdlib.GetModuleMap("template"))
+		_, err := s.Run()
+		require.Error(t, err, input)
+	}
+
+	expect(t, `
+template := import("template")
+page := template.compile(`+"`"+`{{ text := import("text") -}}
+<h1>{{ text.to_upper(title) }}</h1>
+{{ for item in items -}}
+{{ include "item", {item: item} }}
+{{ end }}`+"`"+`, {
+	name: "page",
+	html: true,
+	partials: {item: "<li>{{ item.name }}{{ if item.new }} (new){{ end }}</li>"}
+})
+out := page.name + ":" + page.render({
+	title: "list",
+	items: [{name: "a"}, {name: "<b>", new: true}]
+})
+`, "page:<h1>LIST</h1>\n<li>a</li>\n<li>&lt;b&gt; (new)</li>\n")
+}
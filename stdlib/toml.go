@@ -0,0 +1,52 @@
+package stdlib
+
+import (
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/stdlib/toml"
+)
+
+var tomlModule = map[string]z.Object{
+	"decode": &z.UserFunction{
+		Name:  "decode",
+		Value: tomlDecode,
+	},
+	"encode": &z.UserFunction{
+		Name:  "encode",
+		Value: tomlEncode,
+	},
+}
+
+func tomlDecode(args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	data, err := dataArg(args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	v, err := toml.Decode(data)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	return v, nil
+}
+
+func tomlEncode(args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	switch args[0].(type) {
+	case *z.Map, *z.ImmutableMap:
+	default:
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "map",
+			Found:    args[0].TypeName(),
+		}
+	}
+	b, err := toml.Encode(args[0])
+	if err != nil {
+		return wrapError(err), nil
+	}
+	return &z.Bytes{Value: b}, nil
+}
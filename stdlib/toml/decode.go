@@ -0,0 +1,656 @@
+package toml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/diiyw/z"
+)
+
+// A SyntaxError is a description of a TOML syntax error.
+type SyntaxError struct {
+	msg  string
+	Line int // line of the error, starting at 1
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("toml: line %d: %s", e.Line, e.msg)
+}
+
+// Decode parses the TOML document and returns the result map. Tables are
+// decoded as maps, arrays as arrays, and offset date-times, local
+// date-times and local dates as times. Local times are decoded as strings.
+func Decode(data []byte) (ret z.Object, err error) {
+	src := strings.TrimPrefix(string(data), "\ufeff")
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	p := &parser{
+		src:     src,
+		root:    make(map[string]z.Object),
+		defined: make(map[*z.Map]bool),
+		inline:  make(map[*z.Map]bool),
+		arrays:  make(map[*z.Array]bool),
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			serr, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			ret, err = nil, serr
+		}
+	}()
+	p.document()
+	return &z.Map{Value: p.root}, nil
+}
+
+// parser is a recursive descent parser of TOML documents. Errors are raised
+// as *SyntaxError panics.
+type parser struct {
+	src  string
+	pos  int
+	root map[string]z.Object
+
+	// defined holds the tables defined by a header or by dotted keys, and
+	// inline holds the inline tables, which cannot be extended. arrays
+	// holds the arrays of tables; static arrays cannot be extended.
+	defined map[*z.Map]bool
+	inline  map[*z.Map]bool
+	arrays  map[*z.Array]bool
+}
+
+func (p *parser) fail(format string, args ...any) {
+	panic(&SyntaxError{
+		msg:  fmt.Sprintf(format, args...),
+		Line: strings.Count(p.src[:p.pos], "\n") + 1,
+	})
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) peekAt(off int) byte {
+	if p.pos+off >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos+off]
+}
+
+func (p *parser) skipSpaces() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+// skipComment skips the spaces and a comment of the current line.
+func (p *parser) skipComment() {
+	p.skipSpaces()
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips the white spaces, the line breaks and the comments.
+func (p *parser) skipBlank() {
+	for {
+		p.skipComment()
+		if p.peek() != '\n' {
+			return
+		}
+		p.pos++
+	}
+}
+
+// endLine expects the end of the current line.
+func (p *parser) endLine() {
+	p.skipComment()
+	if !p.eof() && p.peek() != '\n' {
+		p.fail("expected a new line, found %q", p.peek())
+	}
+}
+
+func (p *parser) document() {
+	current := p.root
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return
+		}
+		if p.peek() == '[' {
+			current = p.header()
+		} else {
+			p.keyValue(current)
+		}
+		p.endLine()
+	}
+}
+
+// header parses a [table] or an [[array of tables]] header and returns the
+// table.
+func (p *parser) header() map[string]z.Object {
+	array := p.peekAt(1) == '['
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	p.skipSpaces()
+	keys := p.key()
+	p.skipSpaces()
+	if array {
+		if !strings.HasPrefix(p.src[p.pos:], "]]") {
+			p.fail("expected ']]'")
+		}
+		p.pos += 2
+	} else {
+		if p.peek() != ']' {
+			p.fail("expected ']'")
+		}
+		p.pos++
+	}
+
+	parent := p.root
+	for _, key := range keys[:len(keys)-1] {
+		parent = p.subTable(parent, key, false)
+	}
+	last := keys[len(keys)-1]
+	if array {
+		table := &z.Map{Value: make(map[string]z.Object)}
+		switch v := parent[last].(type) {
+		case nil:
+			arr := &z.Array{Value: []z.Object{table}}
+			p.arrays[arr] = true
+			parent[last] = arr
+		case *z.Array:
+			if !p.arrays[v] {
+				p.fail("cannot extend static array '%s'", strings.Join(keys, "."))
+			}
+			v.Value = append(v.Value, table)
+		default:
+			p.fail("key '%s' is already defined", strings.Join(keys, "."))
+		}
+		p.defined[table] = true
+		return table.Value
+	}
+	table, ok := parent[last].(*z.Map)
+	if parent[last] != nil && (!ok || p.defined[table] || p.inline[table]) {
+		p.fail("table '%s' is already defined", strings.Join(keys, "."))
+	}
+	if !ok {
+		table = &z.Map{Value: make(map[string]z.Object)}
+		parent[last] = table
+	}
+	p.defined[table] = true
+	return table.Value
+}
+
+// subTable returns the table of the key in parent, creating it if needed.
+// For an array of tables, the last table is returned.
+func (p *parser) subTable(
+	parent map[string]z.Object,
+	key string,
+	dotted bool,
+) map[string]z.Object {
+	switch v := parent[key].(type) {
+	case nil:
+		table := &z.Map{Value: make(map[string]z.Object)}
+		parent[key] = table
+		if dotted {
+			p.defined[table] = true
+		}
+		return table.Value
+	case *z.Map:
+		if p.inline[v] || (dotted && !p.defined[v]) {
+			p.fail("cannot extend table '%s'", key)
+		}
+		return v.Value
+	case *z.Array:
+		if dotted || !p.arrays[v] {
+			p.fail("key '%s' is already defined", key)
+		}
+		return v.Value[len(v.Value)-1].(*z.Map).Value
+	default:
+		p.fail("key '%s' is already defined", key)
+		return nil
+	}
+}
+
+func (p *parser) keyValue(table map[string]z.Object) {
+	keys := p.key()
+	p.skipSpaces()
+	if p.peek() != '=' {
+		p.fail("expected '=' after a key")
+	}
+	p.pos++
+	p.skipSpaces()
+	value := p.value()
+	for _, key := range keys[:len(keys)-1] {
+		table = p.subTable(table, key, true)
+	}
+	last := keys[len(keys)-1]
+	if _, exists := table[last]; exists {
+		p.fail("key '%s' is already defined", strings.Join(keys, "."))
+	}
+	table[last] = value
+}
+
+// key parses a simple or dotted key.
+func (p *parser) key() []string {
+	var keys []string
+	for {
+		p.skipSpaces()
+		switch c := p.peek(); {
+		case c == '"':
+			keys = append(keys, p.basicString())
+		case c == '\'':
+			keys = append(keys, p.literalString())
+		default:
+			start := p.pos
+			for isBareKey(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				p.fail("invalid key character %q", c)
+			}
+			keys = append(keys, p.src[start:p.pos])
+		}
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return keys
+		}
+		p.pos++
+	}
+}
+
+func isBareKey(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *parser) value() z.Object {
+	switch c := p.peek(); {
+	case c == '"':
+		if strings.HasPrefix(p.src[p.pos:], `"""`) {
+			return &z.String{Value: p.multilineBasicString()}
+		}
+		return &z.String{Value: p.basicString()}
+	case c == '\'':
+		if strings.HasPrefix(p.src[p.pos:], "'''") {
+			return &z.String{Value: p.multilineLiteralString()}
+		}
+		return &z.String{Value: p.literalString()}
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	case strings.HasPrefix(p.src[p.pos:], "true"):
+		p.pos += 4
+		return z.TrueValue
+	case strings.HasPrefix(p.src[p.pos:], "false"):
+		p.pos += 5
+		return z.FalseValue
+	default:
+		return p.scalar()
+	}
+}
+
+func (p *parser) basicString() string {
+	p.pos++ // '"'
+	var sb strings.Builder
+	for {
+		c := p.peek()
+		switch {
+		case p.eof() || c == '\n':
+			p.fail("unterminated string")
+		case c == '"':
+			p.pos++
+			return sb.String()
+		case c == '\\':
+			p.escape(&sb)
+		case c < 0x20 && c != '\t' || c == 0x7f:
+			p.fail("control characters are not allowed in strings")
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *parser) multilineBasicString() string {
+	p.pos += 3
+	if p.peek() == '\n' {
+		// a new line following the delimiter is trimmed
+		p.pos++
+	}
+	var sb strings.Builder
+	for {
+		c := p.peek()
+		switch {
+		case p.eof():
+			p.fail("unterminated string")
+		case strings.HasPrefix(p.src[p.pos:], `"""`):
+			// up to two quotes can precede the delimiter
+			p.pos += 3
+			for i := 0; i < 2 && p.peek() == '"'; i++ {
+				sb.WriteByte('"')
+				p.pos++
+			}
+			return sb.String()
+		case c == '\\':
+			// a line ending backslash trims the following white spaces
+			save := p.pos
+			p.pos++
+			p.skipSpaces()
+			if p.peek() == '\n' {
+				for p.peek() == '\n' || p.peek() == ' ' || p.peek() == '\t' {
+					p.pos++
+				}
+				continue
+			}
+			p.pos = save
+			p.escape(&sb)
+		case c < 0x20 && c != '\t' && c != '\n' || c == 0x7f:
+			p.fail("control characters are not allowed in strings")
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *parser) literalString() string {
+	p.pos++ // '\''
+	start := p.pos
+	for p.peek() != '\'' {
+		if p.eof() || p.peek() == '\n' {
+			p.fail("unterminated string")
+		}
+		p.pos++
+	}
+	p.pos++
+	return p.src[start : p.pos-1]
+}
+
+func (p *parser) multilineLiteralString() string {
+	p.pos += 3
+	if p.peek() == '\n' {
+		p.pos++
+	}
+	end := strings.Index(p.src[p.pos:], "'''")
+	if end < 0 {
+		p.pos = len(p.src)
+		p.fail("unterminated string")
+	}
+	end += p.pos
+	// up to two quotes can precede the delimiter
+	for i := 0; i < 2 && end+3 < len(p.src) && p.src[end+3] == '\''; i++ {
+		end++
+	}
+	s := p.src[p.pos:end]
+	p.pos = end + 3
+	return s
+}
+
+func (p *parser) escape(sb *strings.Builder) {
+	p.pos++ // '\'
+	c := p.peek()
+	p.pos++
+	var size int
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+		return
+	case 't':
+		sb.WriteByte('\t')
+		return
+	case 'n':
+		sb.WriteByte('\n')
+		return
+	case 'f':
+		sb.WriteByte('\f')
+		return
+	case 'r':
+		sb.WriteByte('\r')
+		return
+	case 'e':
+		sb.WriteByte('\x1b')
+		return
+	case '"':
+		sb.WriteByte('"')
+		return
+	case '\\':
+		sb.WriteByte('\\')
+		return
+	case 'x':
+		size = 2
+	case 'u':
+		size = 4
+	case 'U':
+		size = 8
+	default:
+		p.pos--
+		p.fail("invalid escape sequence %q", c)
+	}
+	if p.pos+size > len(p.src) {
+		p.fail("invalid escape sequence")
+	}
+	n, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(n)) {
+		p.fail("invalid escape sequence")
+	}
+	sb.WriteRune(rune(n))
+	p.pos += size
+}
+
+func (p *parser) array() z.Object {
+	p.pos++ // '['
+	arr := []z.Object{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.pos++
+			return &z.Array{Value: arr}
+		}
+		if p.eof() {
+			p.fail("unterminated array")
+		}
+		arr = append(arr, p.value())
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			p.fail("expected ',' or ']' in an array")
+		}
+	}
+}
+
+func (p *parser) inlineTable() z.Object {
+	p.pos++ // '{'
+	table := &z.Map{Value: make(map[string]z.Object)}
+	p.skipSpaces()
+	if p.peek() == '}' {
+		p.pos++
+	} else {
+		for {
+			p.keyValue(table.Value)
+			p.skipSpaces()
+			if p.peek() == '}' {
+				p.pos++
+				break
+			}
+			if p.peek() != ',' {
+				p.fail("expected ',' or '}' in an inline table")
+			}
+			p.pos++
+		}
+	}
+	p.markInline(table)
+	return table
+}
+
+// markInline marks an inline table and its sub tables as not extendable.
+func (p *parser) markInline(table *z.Map) {
+	p.inline[table] = true
+	for _, v := range table.Value {
+		if m, ok := v.(*z.Map); ok {
+			p.markInline(m)
+		}
+	}
+}
+
+// scalar parses a number or a date-time.
+func (p *parser) scalar() z.Object {
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		if c == ' ' && p.isTimeSeparator() {
+			p.pos++
+			continue
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == ',' || c == ']' ||
+			c == '}' || c == '#' {
+			break
+		}
+		p.pos++
+	}
+	s := p.src[start:p.pos]
+	if s == "" {
+		p.fail("expected a value")
+	}
+	if v := parseDateTime(s); v != nil {
+		return v
+	}
+	if v := parseNumber(s); v != nil {
+		return v
+	}
+	p.pos = start
+	p.fail("invalid value '%s'", s)
+	return nil
+}
+
+// isTimeSeparator reports whether the space at the current position
+// separates the date and the time of a date-time.
+func (p *parser) isTimeSeparator() bool {
+	line := p.src[strings.LastIndexByte(p.src[:p.pos], '\n')+1:]
+	date := p.pos - (len(p.src) - len(line))
+	return date >= 10 && isDate(line[date-10:date]) &&
+		p.peekAt(1) >= '0' && p.peekAt(1) <= '9'
+}
+
+func isDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+var dateTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+func parseDateTime(s string) z.Object {
+	if len(s) >= 11 && (s[10] == ' ' || s[10] == 't') {
+		s = s[:10] + "T" + s[11:]
+	}
+	if strings.HasSuffix(s, "z") {
+		s = s[:len(s)-1] + "Z"
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &z.Time{Value: t}
+		}
+	}
+	for _, layout := range []string{"15:04:05.999999999", "15:04"} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return &z.String{Value: s}
+		}
+	}
+	return nil
+}
+
+func parseNumber(s string) z.Object {
+	switch s {
+	case "inf", "+inf":
+		return &z.Float{Value: math.Inf(1)}
+	case "-inf":
+		return &z.Float{Value: math.Inf(-1)}
+	case "nan", "+nan", "-nan":
+		return &z.Float{Value: math.NaN()}
+	}
+	if !validUnderscores(s) {
+		return nil
+	}
+	digits := strings.ReplaceAll(s, "_", "")
+	if len(digits) > 2 && digits[0] == '0' {
+		base := 0
+		switch digits[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base != 0 {
+			n, err := strconv.ParseInt(digits[2:], base, 64)
+			if err != nil {
+				return nil
+			}
+			return &z.Int{Value: n}
+		}
+	}
+	unsigned := strings.TrimLeft(digits, "+-")
+	if len(unsigned) > 1 && unsigned[0] == '0' && unsigned[1] != '.' &&
+		unsigned[1] != 'e' && unsigned[1] != 'E' {
+		// leading zeros are not allowed
+		return nil
+	}
+	if n, err := strconv.ParseInt(digits, 10, 64); err == nil {
+		return &z.Int{Value: n}
+	}
+	if !strings.ContainsAny(digits, ".eE") ||
+		strings.Contains(digits, ".e") || strings.Contains(digits, ".E") ||
+		strings.HasPrefix(unsigned, ".") || strings.HasSuffix(digits, ".") {
+		return nil
+	}
+	f, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return nil
+	}
+	return &z.Float{Value: f}
+}
+
+// validUnderscores reports whether each underscore of a number is
+// surrounded by digits.
+func validUnderscores(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(s)-1 || !isHexDigit(s[i-1]) || !isHexDigit(s[i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,252 @@
+package toml
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diiyw/z"
+)
+
+// Encode returns the TOML encoding of the map. Keys are written sorted,
+// plain values first, then the tables and the arrays of tables. Undefined
+// values are omitted from tables as TOML has no null value.
+func Encode(o z.Object) ([]byte, error) {
+	m, ok := mapValue(o)
+	if !ok {
+		return nil, errors.New("toml: top-level value must be a map")
+	}
+	return encodeTable(nil, m, nil)
+}
+
+func mapValue(o z.Object) (map[string]z.Object, bool) {
+	switch o := o.(type) {
+	case *z.Map:
+		return o.Value, true
+	case *z.ImmutableMap:
+		return o.Value, true
+	}
+	return nil, false
+}
+
+func arrayValue(o z.Object) ([]z.Object, bool) {
+	switch o := o.(type) {
+	case *z.Array:
+		return o.Value, true
+	case *z.ImmutableArray:
+		return o.Value, true
+	}
+	return nil, false
+}
+
+// isTableArray reports whether the array is written as an array of tables.
+func isTableArray(arr []z.Object) bool {
+	for _, elem := range arr {
+		if _, ok := mapValue(elem); !ok {
+			return false
+		}
+	}
+	return len(arr) > 0
+}
+
+func encodeTable(b []byte, m map[string]z.Object, path []string) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var err error
+	var tables []string
+	for _, key := range keys {
+		value := m[key]
+		if _, ok := mapValue(value); ok {
+			tables = append(tables, key)
+			continue
+		}
+		if arr, ok := arrayValue(value); ok && isTableArray(arr) {
+			tables = append(tables, key)
+			continue
+		}
+		if !isEncodable(value) {
+			continue
+		}
+		b = encodeKey(b, key)
+		b = append(b, " = "...)
+		if b, err = encodeValue(b, value); err != nil {
+			return nil, err
+		}
+		b = append(b, '\n')
+	}
+
+	for _, key := range tables {
+		sub := append(path[:len(path):len(path)], key)
+		if table, ok := mapValue(m[key]); ok {
+			b = encodeHeader(b, sub, "[", "]")
+			if b, err = encodeTable(b, table, sub); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		arr, _ := arrayValue(m[key])
+		for _, elem := range arr {
+			table, _ := mapValue(elem)
+			b = encodeHeader(b, sub, "[[", "]]")
+			if b, err = encodeTable(b, table, sub); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+func encodeHeader(b []byte, path []string, open, close string) []byte {
+	if len(b) > 0 {
+		b = append(b, '\n')
+	}
+	b = append(b, open...)
+	for idx, key := range path {
+		if idx > 0 {
+			b = append(b, '.')
+		}
+		b = encodeKey(b, key)
+	}
+	b = append(b, close...)
+	return append(b, '\n')
+}
+
+// isEncodable reports whether the value has a TOML representation.
+func isEncodable(o z.Object) bool {
+	switch o.(type) {
+	case *z.Array, *z.ImmutableArray, *z.Map, *z.ImmutableMap, *z.Bool,
+		*z.Bytes, *z.Char, *z.Float, *z.Int, *z.String, *z.Time:
+		return true
+	}
+	return false
+}
+
+func encodeKey(b []byte, key string) []byte {
+	for i := 0; i < len(key); i++ {
+		if !isBareKey(key[i]) {
+			return encodeString(b, key)
+		}
+	}
+	if key == "" {
+		return append(b, `""`...)
+	}
+	return append(b, key...)
+}
+
+// encodeValue writes an inline value.
+func encodeValue(b []byte, o z.Object) ([]byte, error) {
+	var err error
+	switch o := o.(type) {
+	case *z.Array, *z.ImmutableArray:
+		arr, _ := arrayValue(o)
+		b = append(b, '[')
+		for idx, elem := range arr {
+			if idx > 0 {
+				b = append(b, ", "...)
+			}
+			if !isEncodable(elem) {
+				return nil, errors.New("toml: unsupported value in an array")
+			}
+			if b, err = encodeValue(b, elem); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ']'), nil
+	case *z.Map, *z.ImmutableMap:
+		m, _ := mapValue(o)
+		keys := make([]string, 0, len(m))
+		for key, value := range m {
+			if isEncodable(value) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		b = append(b, '{')
+		for idx, key := range keys {
+			if idx > 0 {
+				b = append(b, ',')
+			}
+			b = append(b, ' ')
+			b = encodeKey(b, key)
+			b = append(b, " = "...)
+			if b, err = encodeValue(b, m[key]); err != nil {
+				return nil, err
+			}
+		}
+		if len(keys) > 0 {
+			b = append(b, ' ')
+		}
+		return append(b, '}'), nil
+	case *z.Bool:
+		return strconv.AppendBool(b, !o.IsFalsy()), nil
+	case *z.Bytes:
+		return encodeString(b, base64.StdEncoding.EncodeToString(o.Value)), nil
+	case *z.Char:
+		return strconv.AppendInt(b, int64(o.Value), 10), nil
+	case *z.Float:
+		return encodeFloat(b, o.Value), nil
+	case *z.Int:
+		return strconv.AppendInt(b, o.Value, 10), nil
+	case *z.String:
+		return encodeString(b, o.Value), nil
+	case *z.Time:
+		return append(b, o.Value.Format(time.RFC3339Nano)...), nil
+	}
+	return nil, errors.New("toml: unsupported value")
+}
+
+func encodeFloat(b []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(b, "nan"...)
+	case math.IsInf(f, 1):
+		return append(b, "inf"...)
+	case math.IsInf(f, -1):
+		return append(b, "-inf"...)
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		// keep the value a float when it is decoded
+		s += ".0"
+	}
+	return append(b, s...)
+}
+
+// encodeString writes a basic string using the TOML escape sequences.
+func encodeString(b []byte, s string) []byte {
+	b = append(b, '"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b = append(b, `\"`...)
+		case '\\':
+			b = append(b, `\\`...)
+		case '\b':
+			b = append(b, `\b`...)
+		case '\t':
+			b = append(b, `\t`...)
+		case '\n':
+			b = append(b, `\n`...)
+		case '\f':
+			b = append(b, `\f`...)
+		case '\r':
+			b = append(b, `\r`...)
+		default:
+			if r < 0x20 || r == 0x7f {
+				b = append(b, `\u00`...)
+				b = append(b, "0123456789abcdef"[r>>4], "0123456789abcdef"[r&0xf])
+				continue
+			}
+			b = append(b, string(r)...)
+		}
+	}
+	return append(b, '"')
+}
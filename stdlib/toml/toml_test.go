@@ -0,0 +1,198 @@
+package toml_test
+
+import (
+	gojson "encoding/json"
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib/json"
+	"github.com/diiyw/z/stdlib/toml"
+)
+
+type ARR = []any
+type MAP = map[string]any
+
+func TestTOML(t *testing.T) {
+	testTOMLEncodeDecode(t, MAP{})
+	testTOMLEncodeDecode(t, MAP{"a": 0, "b": -1984, "c": 19.84, "d": 3.0})
+	testTOMLEncodeDecode(t, MAP{"s": "foo \"bar\"\n\tbaz \\ \x01", "e": ""})
+	testTOMLEncodeDecode(t, MAP{"çığöşü": "错误测试", "a b": true, "": false})
+	testTOMLEncodeDecode(t, MAP{"arr": ARR{1, "two", ARR{3.5}, MAP{"x": 1}},
+		"empty": ARR{}})
+	testTOMLEncodeDecode(t, MAP{"a": 1, "t": MAP{"b": 2, "u": MAP{"c": 3},
+		"v": MAP{}}})
+	testTOMLEncodeDecode(t, MAP{"a": ARR{MAP{"b": 1}, MAP{"c": ARR{MAP{"d": 2}}}},
+		"z": 1})
+}
+
+func testTOMLEncodeDecode(t *testing.T, v any) {
+	o, err := z.FromInterface(v)
+	require.NoError(t, err)
+
+	b, err := toml.Encode(o)
+	require.NoError(t, err)
+
+	a, err := toml.Decode(b)
+	require.NoError(t, err, string(b))
+
+	vj, err := gojson.Marshal(v)
+	require.NoError(t, err)
+
+	aj, err := gojson.Marshal(z.ToInterface(a))
+	require.NoError(t, err)
+
+	require.Equal(t, string(vj), string(aj), string(b))
+}
+
+func TestDecode(t *testing.T) {
+	testDecode(t, "", `{}`)
+	testDecode(t, `
+# comment
+title = "TOML" # comment
+"quoted key" = 'literal \n'
+int = +1_000
+hex = 0xDEAD_beef
+oct = 0o755
+bin = 0b1101
+floats = [3.14, -0.01, 5e+22, 1E06, -2E-2, 6.626e-34, 224_617.445_991]
+bools = [ true, false, ]
+nested = [[1, 2], ["a"],
+  # comment
+  [],
+]
+`, `{"bin":13,"bools":[true,false],"floats":[3.14,-0.01,5e+22,1000000,-0.02,6.626e-34,224617.445991],`+
+		`"hex":3735928559,"int":1000,"nested":[[1,2],["a"],[]],"oct":493,"quoted key":"literal \\n","title":"TOML"}`)
+	testDecode(t, `a.b.c = 1
+a.d = 2
+site."google.com" = true
+point = { x = 1, y.z = 2 }`,
+		`{"a":{"b":{"c":1},"d":2},"point":{"x":1,"y":{"z":2}},"site":{"google.com":true}}`)
+	testDecode(t, `[server]
+ip = "10.0.0.1"
+
+[server.alpha]
+role = "frontend"
+
+[x.y.z]
+
+[x]
+w = 1
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+
+[[products]]
+name = "Nail"
+[products.size]
+mm = 2
+
+[[products.tags]]
+tag = "x"
+`, `{"products":[{"name":"Hammer"},{},{"name":"Nail","size":{"mm":2},"tags":[{"tag":"x"}]}],`+
+		`"server":{"alpha":{"role":"frontend"},"ip":"10.0.0.1"},"x":{"w":1,"y":{"z":{}}}}`)
+
+	// strings
+	testDecode(t, `a = "tab\there \u00e7 \U0001F600 \"q\""
+b = """
+Roses are red
+Violets are blue"""
+c = """The quick \
+    brown fox \
+
+    jumps."""
+d = '''
+C:\path\'''
+e = """""quotes"""""
+f = ''''one quote''''`,
+		`{"a":"tab\there ç 😀 \"q\"","b":"Roses are red\nViolets are blue",`+
+			`"c":"The quick brown fox jumps.","d":"C:\\path\\","e":"\"\"quotes\"\"","f":"'one quote'"}`)
+}
+
+func testDecode(t *testing.T, input, expected string) {
+	o, err := toml.Decode([]byte(input))
+	require.NoError(t, err, input)
+	b, err := json.EncodeWithOptions(o, json.EncodeOptions{SortKeys: true})
+	require.NoError(t, err)
+	require.Equal(t, expected, string(b), input)
+}
+
+func TestDecodeTypes(t *testing.T) {
+	o, err := toml.Decode([]byte(`
+odt1 = 1979-05-27T07:32:00Z
+odt2 = 1979-05-27 00:32:00.999999-07:00
+ldt = 1979-05-27T07:32:00
+ld = 1979-05-27
+lt = 07:32:00
+inf = -inf
+nan = nan
+`))
+	require.NoError(t, err)
+	m := o.(*z.Map).Value
+	const layout = "2006-01-02T15:04:05.999999Z07:00"
+	require.Equal(t, "1979-05-27T07:32:00Z",
+		m["odt1"].(*z.Time).Value.Format(layout))
+	require.Equal(t, "1979-05-27T07:32:00.999999Z",
+		m["odt2"].(*z.Time).Value.UTC().Format(layout))
+	require.Equal(t, "1979-05-27T07:32:00Z",
+		m["ldt"].(*z.Time).Value.Format(layout))
+	require.Equal(t, "1979-05-27T00:00:00Z",
+		m["ld"].(*z.Time).Value.Format(layout))
+	require.Equal(t, "07:32:00", z.ToInterface(m["lt"]))
+	require.Equal(t, "-Inf", m["inf"].String())
+	require.Equal(t, "NaN", m["nan"].String())
+
+	delete(m, "odt2")
+	delete(m, "ldt")
+	delete(m, "lt")
+	m["bytes"] = &z.Bytes{Value: []byte("hi")}
+	m["none"] = z.UndefinedValue
+	b, err := toml.Encode(o)
+	require.NoError(t, err)
+	require.Equal(t, `bytes = "aGk="
+inf = -inf
+ld = 1979-05-27T00:00:00Z
+nan = nan
+odt1 = 1979-05-27T07:32:00Z
+`, string(b))
+}
+
+func TestDecodeError(t *testing.T) {
+	testDecodeError(t, "a")
+	testDecodeError(t, "a =")
+	testDecodeError(t, "a = 1 b = 2")
+	testDecodeError(t, "a = 1\na = 2")
+	testDecodeError(t, "a.b = 1\na = 2")
+	testDecodeError(t, "[a]\n[a]")
+	testDecodeError(t, "a = 1\n[a]")
+	testDecodeError(t, "a = {}\n[a]")
+	testDecodeError(t, "a = {b = 1}\na.c = 2")
+	testDecodeError(t, "a = []\n[[a]]")
+	testDecodeError(t, "[[a]]\n[a]")
+	testDecodeError(t, "a = \"abc")
+	testDecodeError(t, "a = \"\\q\"")
+	testDecodeError(t, "a = [1 2]")
+	testDecodeError(t, "a = 012")
+	testDecodeError(t, "a = 1__0")
+	testDecodeError(t, "a = .5")
+	testDecodeError(t, "a = 5.")
+	testDecodeError(t, "a = 1979-13-27")
+	testDecodeError(t, "a = {b = 1,}")
+
+	_, err := toml.Decode([]byte("a = 1\n\nb = [1,\n"))
+	require.Equal(t, "toml: line 4: unterminated array", err.Error())
+
+	_, err = toml.Encode(&z.Array{})
+	require.Error(t, err)
+	_, err = toml.Encode(&z.Map{Value: map[string]z.Object{
+		"a": &z.Array{Value: []z.Object{z.UndefinedValue}},
+	}})
+	require.Error(t, err)
+}
+
+func testDecodeError(t *testing.T, input string) {
+	_, err := toml.Decode([]byte(input))
+	require.Error(t, err, input)
+}
@@ -0,0 +1,30 @@
+package stdlib_test
+
+import (
+	"testing"
+
+	"github.com/diiyw/z"
+)
+
+func TestTOML(t *testing.T) {
+	module(t, "toml").call("decode", "a = 1\n[b]\nc = [\"x\", 2.5]\n").
+		expect(MAP{"a": 1, "b": MAP{"c": ARR{"x", 2.5}}})
+	module(t, "toml").call("decode", []byte("[[a]]\nb = true\n")).
+		expect(MAP{"a": ARR{MAP{"b": true}}})
+	module(t, "toml").call("decode", "a = ").expect(&z.Error{
+		Value: &z.String{Value: "toml: line 1: expected a value"},
+	})
+	module(t, "toml").call("decode", 1).expectError()
+
+	module(t, "toml").call("encode", MAP{"b": MAP{"c": 1.0}, "a": ARR{1, 2}}).
+		expect([]byte("a = [1, 2]\n\n[b]\nc = 1.0\n"))
+	module(t, "toml").call("encode", IMAP{"a": ARR{MAP{"b": "x"}}}).
+		expect([]byte("[[a]]\nb = \"x\"\n"))
+	module(t, "toml").call("encode", ARR{}).expectError()
+
+	expect(t, `
+toml := import("toml")
+doc := toml.decode(toml.encode({name: "z", server: {ports: [80, 443]}}))
+out := doc.name + string(doc.server.ports[1])
+`, "z443")
+}
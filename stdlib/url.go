@@ -0,0 +1,266 @@
+package stdlib
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/diiyw/z"
+)
+
+var urlModule = map[string]z.Object{
+	"parse": &z.UserFunction{
+		Name:  "parse",
+		Value: urlParse,
+	}, // parse(s) => map/error
+	"build": &z.UserFunction{
+		Name:  "build",
+		Value: urlBuild,
+	}, // build(parts) => string/error
+	"query_encode": &z.UserFunction{
+		Name:  "query_encode",
+		Value: urlQueryEncode,
+	}, // query_encode(values) => string/error
+	"query_decode": &z.UserFunction{
+		Name:  "query_decode",
+		Value: urlQueryDecode,
+	}, // query_decode(s) => map/error
+	"query_escape": &z.UserFunction{
+		Name:  "query_escape",
+		Value: FuncASRS(url.QueryEscape),
+	}, // query_escape(s) => string
+	"query_unescape": &z.UserFunction{
+		Name:  "query_unescape",
+		Value: FuncASRSE(url.QueryUnescape),
+	}, // query_unescape(s) => string/error
+	"path_escape": &z.UserFunction{
+		Name:  "path_escape",
+		Value: FuncASRS(url.PathEscape),
+	}, // path_escape(s) => string
+	"path_unescape": &z.UserFunction{
+		Name:  "path_unescape",
+		Value: FuncASRSE(url.PathUnescape),
+	}, // path_unescape(s) => string/error
+}
+
+// urlParse returns the parts of a URL.
+func urlParse(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	s, ok := z.ToString(args[0])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "string(compatible)",
+			Found:    args[0].TypeName(),
+		}
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	parts := map[string]z.Object{
+		"scheme":    &z.String{Value: u.Scheme},
+		"opaque":    &z.String{Value: u.Opaque},
+		"username":  &z.String{Value: u.User.Username()},
+		"host":      &z.String{Value: u.Host},
+		"hostname":  &z.String{Value: u.Hostname()},
+		"port":      &z.String{Value: u.Port()},
+		"path":      &z.String{Value: u.Path},
+		"raw_query": &z.String{Value: u.RawQuery},
+		"query":     urlValuesMap(query),
+		"fragment":  &z.String{Value: u.Fragment},
+	}
+	if password, ok := u.User.Password(); ok {
+		parts["password"] = &z.String{Value: password}
+	}
+	return &z.Map{Value: parts}, nil
+}
+
+// urlBuild returns the URL of the parts returned by parse. The "query" part,
+// a map or a string, takes precedence over "raw_query". The "host" part
+// takes precedence over "hostname" and "port".
+func urlBuild(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	parts, ok := mapValue(args[0])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "map",
+			Found:    args[0].TypeName(),
+		}
+	}
+	str := func(key string) (string, error) {
+		v, ok := parts[key]
+		if !ok || v == z.UndefinedValue {
+			return "", nil
+		}
+		s, ok := v.(*z.String)
+		if !ok {
+			return "", fmt.Errorf("invalid url part %s: expected string, found %s",
+				key, v.TypeName())
+		}
+		return s.Value, nil
+	}
+	var u url.URL
+	var hostname, port, username, password string
+	var err error
+	for _, p := range []struct {
+		key string
+		dst *string
+	}{
+		{"scheme", &u.Scheme},
+		{"opaque", &u.Opaque},
+		{"host", &u.Host},
+		{"hostname", &hostname},
+		{"port", &port},
+		{"username", &username},
+		{"password", &password},
+		{"path", &u.Path},
+		{"raw_query", &u.RawQuery},
+		{"fragment", &u.Fragment},
+	} {
+		if *p.dst, err = str(p.key); err != nil {
+			return wrapError(err), nil
+		}
+	}
+	if u.Host == "" && hostname != "" {
+		u.Host = hostname
+		if port != "" {
+			u.Host += ":" + port
+		}
+	}
+	if _, ok := parts["password"]; ok {
+		u.User = url.UserPassword(username, password)
+	} else if username != "" {
+		u.User = url.User(username)
+	}
+	if q, ok := parts["query"]; ok && q != z.UndefinedValue {
+		if s, ok := q.(*z.String); ok {
+			u.RawQuery = s.Value
+		} else {
+			values, err := urlValues(q)
+			if err != nil {
+				return wrapError(err), nil
+			}
+			u.RawQuery = values.Encode()
+		}
+	}
+	s := u.String()
+	if len(s) > z.MaxStringLen {
+		return nil, z.ErrStringLimit
+	}
+	return &z.String{Value: s}, nil
+}
+
+// urlQueryEncode returns the URL encoding of a map of values, sorted by key.
+// A value is a string, or an array of strings for a repeated key; other
+// values are converted to strings.
+func urlQueryEncode(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	if _, ok := mapValue(args[0]); !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "map",
+			Found:    args[0].TypeName(),
+		}
+	}
+	values, err := urlValues(args[0])
+	if err != nil {
+		return wrapError(err), nil
+	}
+	s := values.Encode()
+	if len(s) > z.MaxStringLen {
+		return nil, z.ErrStringLimit
+	}
+	return &z.String{Value: s}, nil
+}
+
+// urlQueryDecode returns the map of the values of a URL encoded query. Each
+// key maps to the array of its values.
+func urlQueryDecode(args ...z.Object) (z.Object, error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	s, ok := z.ToString(args[0])
+	if !ok {
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "string(compatible)",
+			Found:    args[0].TypeName(),
+		}
+	}
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	return urlValuesMap(values), nil
+}
+
+// urlValuesMap returns the map of arrays of the values.
+func urlValuesMap(values url.Values) *z.Map {
+	m := &z.Map{Value: make(map[string]z.Object, len(values))}
+	for k, vs := range values {
+		arr := &z.Array{Value: make([]z.Object, len(vs))}
+		for i, v := range vs {
+			arr.Value[i] = &z.String{Value: v}
+		}
+		m.Value[k] = arr
+	}
+	return m
+}
+
+// urlValues returns the values of a map of strings or arrays of strings.
+func urlValues(o z.Object) (url.Values, error) {
+	m, ok := mapValue(o)
+	if !ok {
+		return nil, fmt.Errorf("invalid query: expected map, found %s",
+			o.TypeName())
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := url.Values{}
+	for _, k := range keys {
+		var elems []z.Object
+		switch v := m[k].(type) {
+		case *z.Array:
+			elems = v.Value
+		case *z.ImmutableArray:
+			elems = v.Value
+		default:
+			elems = []z.Object{v}
+		}
+		for _, e := range elems {
+			s, ok := z.ToString(e)
+			if !ok {
+				return nil, fmt.Errorf("invalid query value %s: %s",
+					k, e.TypeName())
+			}
+			values.Add(k, s)
+		}
+	}
+	return values, nil
+}
+
+// mapValue returns the values of a map or an immutable map.
+func mapValue(o z.Object) (map[string]z.Object, bool) {
+	switch o := o.(type) {
+	case *z.Map:
+		return o.Value, true
+	case *z.ImmutableMap:
+		return o.Value, true
+	}
+	return nil, false
+}
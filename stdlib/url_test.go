@@ -0,0 +1,50 @@
+package stdlib_test
+
+import (
+	"testing"
+
+	"github.com/diiyw/z"
+)
+
+func TestURL(t *testing.T) {
+	module(t, "url").call("query_escape", "a b&c").expect("a+b%26c")
+	module(t, "url").call("query_unescape", "a+b%26c").expect("a b&c")
+	module(t, "url").call("path_escape", "a b/c").expect("a%20b%2Fc")
+	module(t, "url").call("path_unescape", "a%20b%2Fc").expect("a b/c")
+	module(t, "url").call("path_unescape", "%zz").
+		expect(&z.Error{Value: &z.String{Value: `invalid URL escape "%zz"`}})
+	module(t, "url").call("query_encode", MAP{"b": ARR{1, "2"}, "a": "x y"}).
+		expect("a=x+y&b=1&b=2")
+	module(t, "url").call("query_encode", "a=1").expectError()
+	module(t, "url").call("query_decode", "a=1&a=2&b=").
+		expect(MAP{"a": ARR{"1", "2"}, "b": ARR{""}})
+	module(t, "url").call("parse", ":").
+		expect(&z.Error{Value: &z.String{Value: `parse ":": missing protocol scheme`}})
+
+	expect(t, `
+url := import("url")
+u := url.parse("https://bob:pw@example.com:8080/a%20b?x=1&x=2#frag")
+out := format("%s|%s|%s|%s|%s|%s|%s|%s|%v|%s", u.scheme, u.username, u.password,
+	u.hostname, u.port, u.path, u.raw_query, u.fragment, u.query.x, u.host)
+`, `https|bob|pw|example.com|8080|/a b|x=1&x=2|frag|["1", "2"]|example.com:8080`)
+
+	expect(t, `
+url := import("url")
+u := url.parse("http://example.com/p?q=1")
+u.query = {q: ["a b"], r: 2}
+u.path = "/a b"
+out := url.build(u)
+`, "http://example.com/a%20b?q=a+b&r=2")
+
+	expect(t, `
+url := import("url")
+out := url.build({scheme: "https", hostname: "example.com", port: "8443",
+	username: "u", path: "/x", raw_query: "a=1", fragment: "f"})
+`, "https://u@example.com:8443/x?a=1#f")
+
+	expect(t, `
+url := import("url")
+out := [url.build({scheme: 1}), url.build({query: {a: [undefined]}})]
+out = string([is_error(out[0]), is_error(out[1])])
+`, "[true, true]")
+}
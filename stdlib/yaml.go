@@ -0,0 +1,93 @@
+package stdlib
+
+import (
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/stdlib/yaml"
+)
+
+var yamlModule = map[string]z.Object{
+	"decode": &z.UserFunction{
+		Name:  "decode",
+		Value: yamlDecode,
+	},
+	"decode_all": &z.UserFunction{
+		Name:  "decode_all",
+		Value: yamlDecodeAll,
+	},
+	"encode": &z.UserFunction{
+		Name:  "encode",
+		Value: yamlEncode,
+	},
+	"encode_all": &z.UserFunction{
+		Name:  "encode_all",
+		Value: yamlEncodeAll,
+	},
+}
+
+func yamlDecode(args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	data, err := dataArg(args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	v, err := yaml.Decode(data)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	return v, nil
+}
+
+func yamlDecodeAll(args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	data, err := dataArg(args[0], "first")
+	if err != nil {
+		return nil, err
+	}
+	docs, err := yaml.DecodeAll(data)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	if docs == nil {
+		docs = []z.Object{}
+	}
+	return &z.Array{Value: docs}, nil
+}
+
+func yamlEncode(args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	b, err := yaml.Encode(args[0])
+	if err != nil {
+		return wrapError(err), nil
+	}
+	return &z.Bytes{Value: b}, nil
+}
+
+func yamlEncodeAll(args ...z.Object) (ret z.Object, err error) {
+	if len(args) != 1 {
+		return nil, z.ErrWrongNumArguments
+	}
+	var docs []z.Object
+	switch arg := args[0].(type) {
+	case *z.Array:
+		docs = arg.Value
+	case *z.ImmutableArray:
+		docs = arg.Value
+	default:
+		return nil, z.ErrInvalidArgumentType{
+			Name:     "first",
+			Expected: "array",
+			Found:    args[0].TypeName(),
+		}
+	}
+	b, err := yaml.EncodeAll(docs)
+	if err != nil {
+		return wrapError(err), nil
+	}
+	return &z.Bytes{Value: b}, nil
+}
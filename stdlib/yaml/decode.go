@@ -0,0 +1,906 @@
+package yaml
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/diiyw/z"
+)
+
+// A SyntaxError is a description of a YAML syntax error.
+type SyntaxError struct {
+	msg  string
+	Line int // line of the error, starting at 1
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("yaml: line %d: %s", e.Line, e.msg)
+}
+
+// Decode parses the first document of the YAML data and returns the result
+// object. Mappings are decoded as maps, sequences as arrays, and scalars as
+// strings, ints, floats, bools, times or undefined (null).
+func Decode(data []byte) (z.Object, error) {
+	docs, err := DecodeAll(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return z.UndefinedValue, nil
+	}
+	return docs[0], nil
+}
+
+// DecodeAll parses all the documents of the YAML data.
+func DecodeAll(data []byte) (docs []z.Object, err error) {
+	src := strings.TrimPrefix(string(data), "\ufeff")
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	p := &parser{src: src}
+	defer func() {
+		if r := recover(); r != nil {
+			serr, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			docs, err = nil, serr
+		}
+	}()
+	return p.documents(), nil
+}
+
+// parser is a recursive descent parser of YAML documents. Errors are raised
+// as *SyntaxError panics.
+type parser struct {
+	src     string
+	pos     int
+	anchors map[string]z.Object
+}
+
+func (p *parser) fail(format string, args ...any) {
+	panic(&SyntaxError{
+		msg:  fmt.Sprintf(format, args...),
+		Line: strings.Count(p.src[:p.pos], "\n") + 1,
+	})
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) peekAt(off int) byte {
+	if p.pos+off >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos+off]
+}
+
+// col returns the column of the current position.
+func (p *parser) col() int {
+	return p.pos - strings.LastIndexByte(p.src[:p.pos], '\n') - 1
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == 0
+}
+
+func isFlowIndicator(c byte) bool {
+	return c == ',' || c == '[' || c == ']' || c == '{' || c == '}'
+}
+
+// atMarker reports whether a document marker ("---" or "...") is at the
+// current position.
+func (p *parser) atMarker(marker string) bool {
+	return p.col() == 0 && strings.HasPrefix(p.src[p.pos:], marker) &&
+		isBlank(p.peekAt(3))
+}
+
+func (p *parser) atDocumentBoundary() bool {
+	return p.atMarker("---") || p.atMarker("...")
+}
+
+// skipSpaces skips the spaces and the tabs of the current line.
+func (p *parser) skipSpaces() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+// skipToEOL skips a comment or the trailing spaces of the current line.
+func (p *parser) skipToEOL() {
+	p.skipSpaces()
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips the white spaces, the line breaks and the comments. Tabs
+// are not allowed for indentation.
+func (p *parser) skipBlank() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\n':
+			p.pos++
+		case '\t':
+			if p.col() == 0 || strings.TrimLeft(
+				p.src[strings.LastIndexByte(p.src[:p.pos], '\n')+1:p.pos],
+				" ") == "" {
+				p.fail("found a tab character used as indentation")
+			}
+			p.pos++
+		case '#':
+			p.skipToEOL()
+		default:
+			return
+		}
+	}
+}
+
+// atLineEnd reports whether the rest of the line is blank or a comment.
+func (p *parser) atLineEnd() bool {
+	save := p.pos
+	p.skipToEOL()
+	end := p.eof() || p.peek() == '\n'
+	p.pos = save
+	return end
+}
+
+func (p *parser) documents() []z.Object {
+	var docs []z.Object
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return docs
+		}
+		if p.col() == 0 && p.peek() == '%' {
+			// directive
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		if p.atMarker("...") {
+			p.pos += 3
+			continue
+		}
+		if p.atMarker("---") {
+			p.pos += 3
+		}
+		p.anchors = make(map[string]z.Object)
+		docs = append(docs, p.node(-1, true))
+		p.skipBlank()
+		if !p.eof() && !p.atDocumentBoundary() {
+			p.fail("unexpected content after the document")
+		}
+	}
+}
+
+// node parses a node whose content is indented more than indent. If block
+// is false, block collections are not allowed on the current line.
+func (p *parser) node(indent int, block bool) z.Object {
+	p.skipSpaces()
+	sameLine := true
+	if p.atLineEnd() {
+		p.skipBlank()
+		sameLine = false
+	}
+	if p.eof() || p.atDocumentBoundary() || (!sameLine && p.col() <= indent) {
+		return z.UndefinedValue
+	}
+
+	var anchor, tag string
+	for p.peek() == '&' || p.peek() == '!' {
+		if p.peek() == '&' {
+			p.pos++
+			anchor = p.word()
+		} else {
+			tag = p.word()
+		}
+		p.skipSpaces()
+	}
+	if (anchor != "" || tag != "") && p.atLineEnd() {
+		p.skipBlank()
+		sameLine = false
+		if p.eof() || p.atDocumentBoundary() || p.col() <= indent {
+			return p.register(anchor, resolve("", false, tag))
+		}
+	}
+
+	col := p.col()
+	var node z.Object
+	switch c := p.peek(); {
+	case c == '-' && isBlank(p.peekAt(1)):
+		if sameLine && !block {
+			p.fail("block sequence entries are not allowed here")
+		}
+		node = p.blockSequence(col)
+	case c == '[' || c == '{':
+		node = p.flowCollection()
+	case c == '|' || c == '>':
+		node = resolve(p.blockScalar(indent), true, tag)
+	case c == '*':
+		node = p.alias()
+	case c == '?' && isBlank(p.peekAt(1)):
+		p.fail("complex mapping keys are not supported")
+	case p.isMappingKey():
+		if sameLine && !block {
+			p.fail("mapping values are not allowed here")
+		}
+		node = p.blockMapping(col)
+	case c == '"' || c == '\'':
+		node = resolve(p.quotedScalar(), true, tag)
+	default:
+		if c == '@' || c == '`' {
+			p.fail("found character %q that cannot start any token", c)
+		}
+		node = resolve(p.plainScalar(indent, false), false, tag)
+	}
+	return p.register(anchor, node)
+}
+
+func (p *parser) register(anchor string, node z.Object) z.Object {
+	if anchor != "" {
+		p.anchors[anchor] = node
+	}
+	return node
+}
+
+// word reads an anchor, alias or tag name.
+func (p *parser) word() string {
+	start := p.pos
+	for !p.eof() && !isBlank(p.peek()) && !isFlowIndicator(p.peek()) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.fail("missing name")
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *parser) alias() z.Object {
+	p.pos++
+	name := p.word()
+	node, ok := p.anchors[name]
+	if !ok {
+		p.fail("unknown anchor '%s' referenced", name)
+	}
+	return node
+}
+
+// isMappingKey reports whether a block mapping key followed by ':' is at
+// the current position.
+func (p *parser) isMappingKey() bool {
+	save := p.pos
+	defer func() { p.pos = save }()
+	switch p.peek() {
+	case '"', '\'':
+		line := strings.IndexByte(p.src[p.pos:], '\n')
+		if line < 0 {
+			line = len(p.src) - p.pos
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					p.pos = len(p.src)
+				}
+			}()
+			p.quotedScalar()
+		}()
+		if p.pos > save+line {
+			return false
+		}
+		p.skipSpaces()
+		return p.peek() == ':' && isBlank(p.peekAt(1))
+	case '[', '{', '*', '&', '!', '|', '>', '#':
+		return false
+	}
+	for !p.eof() && p.peek() != '\n' {
+		c := p.peek()
+		if c == ':' && isBlank(p.peekAt(1)) {
+			return true
+		}
+		if (c == ' ' || c == '\t') && p.peekAt(1) == '#' {
+			return false
+		}
+		p.pos++
+	}
+	return false
+}
+
+// mappingKey parses a single line mapping key and the ':' following it.
+func (p *parser) mappingKey() string {
+	var key z.Object
+	switch p.peek() {
+	case '"', '\'':
+		key = &z.String{Value: p.quotedScalar()}
+	default:
+		start := p.pos
+		for !(p.peek() == ':' && isBlank(p.peekAt(1))) {
+			p.pos++
+		}
+		key = resolve(strings.TrimRight(p.src[start:p.pos], " \t"), false, "")
+	}
+	p.skipSpaces()
+	if p.peek() != ':' {
+		p.fail("could not find expected ':'")
+	}
+	p.pos++
+	return keyString(key)
+}
+
+func keyString(key z.Object) string {
+	switch key := key.(type) {
+	case *z.String:
+		return key.Value
+	case *z.Undefined:
+		return "null"
+	}
+	return key.String()
+}
+
+func (p *parser) blockMapping(col int) z.Object {
+	m := make(map[string]z.Object)
+	var merges []z.Object
+	for {
+		key := p.mappingKey()
+
+		// a sequence can be at the same indentation as its key
+		var value z.Object
+		save := p.pos
+		p.skipSpaces()
+		if p.atLineEnd() {
+			p.skipBlank()
+			if !p.eof() && p.col() == col && p.peek() == '-' &&
+				isBlank(p.peekAt(1)) && !p.atDocumentBoundary() {
+				value = p.blockSequence(col)
+			}
+		}
+		if value == nil {
+			p.pos = save
+			value = p.node(col, false)
+		}
+
+		if key == "<<" {
+			merges = append(merges, value)
+		} else if _, exists := m[key]; exists {
+			p.fail("duplicate mapping key '%s'", key)
+		} else {
+			m[key] = value
+		}
+
+		p.skipBlank()
+		if p.eof() || p.atDocumentBoundary() || p.col() < col {
+			break
+		}
+		if p.col() > col || !p.isMappingKey() {
+			if p.col() == col && p.peek() == '-' && isBlank(p.peekAt(1)) {
+				break
+			}
+			p.fail("bad indentation of a mapping entry")
+		}
+	}
+	for _, merge := range merges {
+		p.merge(m, merge)
+	}
+	return &z.Map{Value: m}
+}
+
+// merge adds the entries of the merged mappings that are not in m.
+func (p *parser) merge(m map[string]z.Object, merge z.Object) {
+	switch merge := merge.(type) {
+	case *z.Map:
+		for key, value := range merge.Value {
+			if _, exists := m[key]; !exists {
+				m[key] = value
+			}
+		}
+	case *z.Array:
+		for _, elem := range merge.Value {
+			if _, ok := elem.(*z.Map); !ok {
+				p.fail("merge key requires mappings")
+			}
+			p.merge(m, elem)
+		}
+	default:
+		p.fail("merge key requires mappings")
+	}
+}
+
+func (p *parser) blockSequence(col int) z.Object {
+	arr := []z.Object{}
+	for {
+		p.pos++ // '-'
+		arr = append(arr, p.node(col, true))
+		p.skipBlank()
+		if p.eof() || p.atDocumentBoundary() || p.col() < col {
+			break
+		}
+		if p.col() > col {
+			p.fail("bad indentation of a sequence entry")
+		}
+		if p.peek() != '-' || !isBlank(p.peekAt(1)) {
+			// the end of a sequence at the same indentation as its key
+			break
+		}
+	}
+	return &z.Array{Value: arr}
+}
+
+// plainScalar parses a plain scalar. Lines indented more than indent
+// continue the scalar in block context.
+func (p *parser) plainScalar(indent int, flow bool) string {
+	var sb strings.Builder
+	for {
+		start := p.pos
+		for !p.eof() {
+			c := p.peek()
+			if c == '\n' ||
+				(c == ':' && (isBlank(p.peekAt(1)) ||
+					flow && isFlowIndicator(p.peekAt(1)))) ||
+				((c == ' ' || c == '\t') && p.peekAt(1) == '#') ||
+				(flow && isFlowIndicator(c)) {
+				break
+			}
+			p.pos++
+		}
+		sb.WriteString(strings.TrimRight(p.src[start:p.pos], " \t"))
+
+		// continuation lines
+		end := p.pos
+		if p.peek() != '\n' {
+			p.skipSpaces()
+			if p.peek() != '\n' {
+				p.pos = end
+				break
+			}
+		}
+		breaks := 0
+		for p.peek() == '\n' {
+			breaks++
+			p.pos++
+			p.skipSpaces()
+		}
+		if p.eof() || p.peek() == '#' || p.atDocumentBoundary() ||
+			(!flow && p.col() <= indent) ||
+			(flow && isFlowIndicator(p.peek())) ||
+			(p.peek() == ':' && isBlank(p.peekAt(1))) {
+			p.pos = end
+			break
+		}
+		if breaks == 1 {
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteString(strings.Repeat("\n", breaks-1))
+		}
+	}
+	return sb.String()
+}
+
+// quotedScalar parses a single or double quoted scalar.
+func (p *parser) quotedScalar() string {
+	quote := p.peek()
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			p.fail("found unexpected end of stream in quoted scalar")
+		}
+		c := p.peek()
+		switch {
+		case c == quote && quote == '\'' && p.peekAt(1) == '\'':
+			sb.WriteByte('\'')
+			p.pos += 2
+		case c == quote:
+			p.pos++
+			return sb.String()
+		case c == '\\' && quote == '"':
+			p.escape(&sb)
+		case c == '\n' || c == ' ' || c == '\t':
+			p.fold(&sb)
+		default:
+			_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			sb.WriteString(p.src[p.pos : p.pos+size])
+			p.pos += size
+		}
+	}
+}
+
+// fold folds the white spaces and the line breaks of a quoted scalar.
+func (p *parser) fold(sb *strings.Builder) {
+	start := p.pos
+	p.skipSpaces()
+	if p.peek() != '\n' {
+		sb.WriteString(p.src[start:p.pos])
+		return
+	}
+	breaks := 0
+	for p.peek() == '\n' {
+		breaks++
+		p.pos++
+		p.skipSpaces()
+	}
+	if breaks == 1 {
+		sb.WriteByte(' ')
+	} else {
+		sb.WriteString(strings.Repeat("\n", breaks-1))
+	}
+}
+
+var escapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': " ", 'L': " ",
+	'P': " ",
+}
+
+func (p *parser) escape(sb *strings.Builder) {
+	p.pos++ // '\'
+	c := p.peek()
+	if s, ok := escapes[c]; ok {
+		sb.WriteString(s)
+		p.pos++
+		return
+	}
+	var size int
+	switch c {
+	case '\n':
+		// escaped line break
+		p.pos++
+		p.skipSpaces()
+		return
+	case 'x':
+		size = 2
+	case 'u':
+		size = 4
+	case 'U':
+		size = 8
+	default:
+		p.fail("found unknown escape character %q", c)
+	}
+	p.pos++
+	if p.pos+size > len(p.src) {
+		p.fail("invalid escape sequence")
+	}
+	n, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+	if err != nil {
+		p.fail("invalid escape sequence")
+	}
+	sb.WriteRune(rune(n))
+	p.pos += size
+}
+
+// blockScalar parses a literal (|) or folded (>) block scalar.
+func (p *parser) blockScalar(indent int) string {
+	folded := p.peek() == '>'
+	p.pos++
+	chomp := byte(0)
+	contentIndent := -1
+	for {
+		c := p.peek()
+		if c == '+' || c == '-' {
+			chomp = c
+		} else if c >= '1' && c <= '9' {
+			contentIndent = max(indent, 0) + int(c-'0')
+		} else {
+			break
+		}
+		p.pos++
+	}
+	if !p.atLineEnd() {
+		p.fail("unexpected content after a block scalar indicator")
+	}
+	p.skipToEOL()
+	if p.eof() {
+		return ""
+	}
+	p.pos++ // '\n'
+
+	var lines []string
+	for !p.eof() {
+		start := p.pos
+		p.skipSpaces()
+		spaces := p.pos - start
+		if p.eof() || p.peek() == '\n' {
+			if contentIndent >= 0 && spaces > contentIndent {
+				lines = append(lines, p.src[start+contentIndent:p.pos])
+			} else {
+				lines = append(lines, "")
+			}
+			if !p.eof() {
+				p.pos++
+			}
+			continue
+		}
+		if contentIndent < 0 {
+			contentIndent = spaces
+		}
+		p.pos = start
+		if spaces < contentIndent || spaces <= indent ||
+			(spaces == 0 && p.atDocumentBoundary()) {
+			break
+		}
+		end := strings.IndexByte(p.src[p.pos:], '\n')
+		if end < 0 {
+			end = len(p.src) - p.pos
+		}
+		lines = append(lines, p.src[p.pos+contentIndent:p.pos+end])
+		p.pos += end
+		if !p.eof() {
+			p.pos++
+		}
+	}
+	if !p.eof() || strings.HasSuffix(p.src, "\n") {
+		// p.pos is at the start of the next line
+		for p.pos > 0 && p.src[p.pos-1] != '\n' {
+			p.pos--
+		}
+	}
+
+	// trailing empty lines are handled by the chomping
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var sb strings.Builder
+	if folded {
+		breaks := 0
+		prev := ""
+		for idx, line := range lines {
+			switch {
+			case line == "":
+				breaks++
+				continue
+			case idx == 0 || idx == breaks:
+				sb.WriteString(strings.Repeat("\n", breaks))
+			case moreIndented(prev) || moreIndented(line):
+				sb.WriteString(strings.Repeat("\n", breaks+1))
+			case breaks > 0:
+				sb.WriteString(strings.Repeat("\n", breaks))
+			default:
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(line)
+			breaks = 0
+			prev = line
+		}
+	} else {
+		sb.WriteString(strings.Join(lines, "\n"))
+	}
+	if len(lines) > 0 {
+		switch chomp {
+		case 0:
+			sb.WriteByte('\n')
+		case '+':
+			sb.WriteString(strings.Repeat("\n", trailing+1))
+		}
+	} else if chomp == '+' {
+		sb.WriteString(strings.Repeat("\n", trailing))
+	}
+	return sb.String()
+}
+
+func moreIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+// skipFlowBlank skips the white spaces, line breaks and comments inside a
+// flow collection.
+func (p *parser) skipFlowBlank() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\n':
+			p.pos++
+		case '#':
+			p.skipToEOL()
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) flowCollection() z.Object {
+	if p.peek() == '[' {
+		return p.flowSequence()
+	}
+	return p.flowMapping()
+}
+
+func (p *parser) flowSequence() z.Object {
+	p.pos++ // '['
+	arr := []z.Object{}
+	for {
+		p.skipFlowBlank()
+		if p.eof() {
+			p.fail("did not find expected ',' or ']'")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return &z.Array{Value: arr}
+		}
+		item := p.flowNode()
+		p.skipFlowBlank()
+		if p.peek() == ':' {
+			// single pair mapping
+			p.pos++
+			p.skipFlowBlank()
+			var value z.Object = z.UndefinedValue
+			if p.peek() != ',' && p.peek() != ']' {
+				value = p.flowNode()
+			}
+			item = &z.Map{Value: map[string]z.Object{keyString(item): value}}
+			p.skipFlowBlank()
+		}
+		arr = append(arr, item)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			p.fail("did not find expected ',' or ']'")
+		}
+	}
+}
+
+func (p *parser) flowMapping() z.Object {
+	p.pos++ // '{'
+	m := make(map[string]z.Object)
+	for {
+		p.skipFlowBlank()
+		if p.eof() {
+			p.fail("did not find expected ',' or '}'")
+		}
+		if p.peek() == '}' {
+			p.pos++
+			return &z.Map{Value: m}
+		}
+		key := keyString(p.flowNode())
+		p.skipFlowBlank()
+		var value z.Object = z.UndefinedValue
+		if p.peek() == ':' {
+			p.pos++
+			p.skipFlowBlank()
+			if p.peek() != ',' && p.peek() != '}' {
+				value = p.flowNode()
+			}
+			p.skipFlowBlank()
+		}
+		if key == "<<" {
+			p.merge(m, value)
+		} else {
+			m[key] = value
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+		default:
+			p.fail("did not find expected ',' or '}'")
+		}
+	}
+}
+
+func (p *parser) flowNode() z.Object {
+	var anchor, tag string
+	for p.peek() == '&' || p.peek() == '!' {
+		if p.peek() == '&' {
+			p.pos++
+			anchor = p.word()
+		} else {
+			tag = p.word()
+		}
+		p.skipFlowBlank()
+	}
+	var node z.Object
+	switch p.peek() {
+	case '[', '{':
+		node = p.flowCollection()
+	case '"', '\'':
+		node = resolve(p.quotedScalar(), true, tag)
+	case '*':
+		node = p.alias()
+	default:
+		node = resolve(p.plainScalar(-1, true), false, tag)
+	}
+	return p.register(anchor, node)
+}
+
+var (
+	intRE       = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	floatRE     = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	timestampRE = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}`)
+)
+
+var timeLayouts = []string{
+	"2006-1-2T15:4:5.999999999Z07:00",
+	"2006-1-2t15:4:5.999999999Z07:00",
+	"2006-1-2 15:4:5.999999999Z07:00",
+	"2006-1-2 15:4:5.999999999 Z07:00",
+	"2006-1-2 15:4:5.999999999 -7",
+	"2006-1-2T15:4:5.999999999",
+	"2006-1-2t15:4:5.999999999",
+	"2006-1-2 15:4:5.999999999",
+	"2006-1-2",
+}
+
+// resolve returns the object of a scalar. Plain scalars are resolved using
+// the YAML 1.2 core schema and timestamps, quoted scalars are strings. The
+// standard tags override the resolution.
+func resolve(s string, quoted bool, tag string) z.Object {
+	switch tag {
+	case "", "!":
+	case "!!str":
+		return &z.String{Value: s}
+	case "!!binary":
+		b, err := base64.StdEncoding.DecodeString(
+			strings.Join(strings.Fields(s), ""))
+		if err == nil {
+			return &z.Bytes{Value: b}
+		}
+	case "!!int", "!!float", "!!bool", "!!null", "!!timestamp":
+		quoted = false
+	}
+	if quoted || tag == "!" {
+		return &z.String{Value: s}
+	}
+
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return z.UndefinedValue
+	case "true", "True", "TRUE":
+		return z.TrueValue
+	case "false", "False", "FALSE":
+		return z.FalseValue
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return &z.Float{Value: math.Inf(1)}
+	case "-.inf", "-.Inf", "-.INF":
+		return &z.Float{Value: math.Inf(-1)}
+	case ".nan", ".NaN", ".NAN":
+		return &z.Float{Value: math.NaN()}
+	}
+	switch {
+	case intRE.MatchString(s):
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			if tag == "!!float" {
+				return &z.Float{Value: float64(n)}
+			}
+			return &z.Int{Value: n}
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return &z.Float{Value: f}
+		}
+	case strings.HasPrefix(s, "0x"):
+		if n, err := strconv.ParseInt(s[2:], 16, 64); err == nil {
+			return &z.Int{Value: n}
+		}
+	case strings.HasPrefix(s, "0o"):
+		if n, err := strconv.ParseInt(s[2:], 8, 64); err == nil {
+			return &z.Int{Value: n}
+		}
+	case floatRE.MatchString(s):
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return &z.Float{Value: f}
+		}
+	case timestampRE.MatchString(s):
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return &z.Time{Value: t}
+			}
+		}
+	}
+	return &z.String{Value: s}
+}
@@ -0,0 +1,192 @@
+package yaml
+
+import (
+	"encoding/base64"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/diiyw/z"
+)
+
+// Encode returns the YAML encoding of the object. Maps are written in block
+// style with their keys sorted, so the output is deterministic.
+func Encode(o z.Object) ([]byte, error) {
+	return encodeNode(nil, o, 0), nil
+}
+
+// EncodeAll returns the YAML encoding of the documents, separated by
+// document start markers.
+func EncodeAll(docs []z.Object) ([]byte, error) {
+	var b []byte
+	for idx, doc := range docs {
+		if idx > 0 {
+			b = append(b, "---\n"...)
+		}
+		b = encodeNode(b, doc, 0)
+	}
+	return b, nil
+}
+
+// encodeNode encodes a document or a block collection starting at the
+// beginning of a line.
+func encodeNode(b []byte, o z.Object, indent int) []byte {
+	if m, ok := mapValue(o); ok && len(m) > 0 {
+		return encodeMap(b, m, indent, false)
+	}
+	if arr, ok := arrayValue(o); ok && len(arr) > 0 {
+		return encodeArray(b, arr, indent, false)
+	}
+	b = encodeScalar(b, o)
+	return append(b, '\n')
+}
+
+func mapValue(o z.Object) (map[string]z.Object, bool) {
+	switch o := o.(type) {
+	case *z.Map:
+		return o.Value, true
+	case *z.ImmutableMap:
+		return o.Value, true
+	}
+	return nil, false
+}
+
+func arrayValue(o z.Object) ([]z.Object, bool) {
+	switch o := o.(type) {
+	case *z.Array:
+		return o.Value, true
+	case *z.ImmutableArray:
+		return o.Value, true
+	}
+	return nil, false
+}
+
+// encodeMap writes the entries of a mapping at the indentation. If inline
+// is true, the first entry continues the current line.
+func encodeMap(b []byte, m map[string]z.Object, indent int, inline bool) []byte {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for idx, key := range keys {
+		if idx > 0 || !inline {
+			b = appendIndent(b, indent)
+		}
+		b = encodeString(b, key)
+		b = append(b, ':')
+		b = encodeValue(b, m[key], indent, false)
+	}
+	return b
+}
+
+// encodeArray writes the entries of a sequence at the indentation. If
+// inline is true, the first entry continues the current line.
+func encodeArray(b []byte, arr []z.Object, indent int, inline bool) []byte {
+	for idx, elem := range arr {
+		if idx > 0 || !inline {
+			b = appendIndent(b, indent)
+		}
+		b = append(b, '-')
+		b = encodeValue(b, elem, indent, true)
+	}
+	return b
+}
+
+// encodeValue writes a value following a mapping key or a sequence entry
+// indicator. Nested collections in sequences start on the entry line.
+func encodeValue(b []byte, o z.Object, indent int, entry bool) []byte {
+	if m, ok := mapValue(o); ok && len(m) > 0 {
+		if entry {
+			return encodeMap(append(b, ' '), m, indent+2, true)
+		}
+		return encodeMap(append(b, '\n'), m, indent+2, false)
+	}
+	if arr, ok := arrayValue(o); ok && len(arr) > 0 {
+		if entry {
+			return encodeArray(append(b, ' '), arr, indent+2, true)
+		}
+		return encodeArray(append(b, '\n'), arr, indent+2, false)
+	}
+	b = encodeScalar(append(b, ' '), o)
+	return append(b, '\n')
+}
+
+func appendIndent(b []byte, indent int) []byte {
+	for i := 0; i < indent; i++ {
+		b = append(b, ' ')
+	}
+	return b
+}
+
+func encodeScalar(b []byte, o z.Object) []byte {
+	switch o := o.(type) {
+	case *z.Map, *z.ImmutableMap:
+		return append(b, "{}"...)
+	case *z.Array, *z.ImmutableArray:
+		return append(b, "[]"...)
+	case *z.Bool:
+		return strconv.AppendBool(b, !o.IsFalsy())
+	case *z.Bytes:
+		b = append(b, "!!binary "...)
+		return append(b, base64.StdEncoding.EncodeToString(o.Value)...)
+	case *z.Char:
+		return strconv.AppendInt(b, int64(o.Value), 10)
+	case *z.Float:
+		return encodeFloat(b, o.Value)
+	case *z.Int:
+		return strconv.AppendInt(b, o.Value, 10)
+	case *z.String:
+		return encodeString(b, o.Value)
+	case *z.Time:
+		return append(b, o.Value.Format(time.RFC3339Nano)...)
+	default:
+		// undefined and unknown types
+		return append(b, "null"...)
+	}
+}
+
+func encodeFloat(b []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(b, ".nan"...)
+	case math.IsInf(f, 1):
+		return append(b, ".inf"...)
+	case math.IsInf(f, -1):
+		return append(b, "-.inf"...)
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		// keep the value a float when it is decoded
+		s += ".0"
+	}
+	return append(b, s...)
+}
+
+// encodeString writes a plain scalar if the string would be decoded back
+// as the same string, or a double quoted scalar otherwise.
+func encodeString(b []byte, s string) []byte {
+	if isPlainSafe(s) {
+		return append(b, s...)
+	}
+	return strconv.AppendQuote(b, s)
+}
+
+func isPlainSafe(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s ||
+		strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") ||
+		strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	_, ok := resolve(s, false, "").(*z.String)
+	return ok
+}
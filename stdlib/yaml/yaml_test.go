@@ -0,0 +1,185 @@
+package yaml_test
+
+import (
+	gojson "encoding/json"
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib/json"
+	"github.com/diiyw/z/stdlib/yaml"
+)
+
+type ARR = []any
+type MAP = map[string]any
+
+func TestYAML(t *testing.T) {
+	testYAMLEncodeDecode(t, nil)
+	testYAMLEncodeDecode(t, 0)
+	testYAMLEncodeDecode(t, -1984)
+	testYAMLEncodeDecode(t, 19.84)
+	testYAMLEncodeDecode(t, 3.0)
+	testYAMLEncodeDecode(t, "")
+	testYAMLEncodeDecode(t, "foo bar")
+	testYAMLEncodeDecode(t, "foo: \"bar\" # baz")
+	testYAMLEncodeDecode(t, "123")
+	testYAMLEncodeDecode(t, "true")
+	testYAMLEncodeDecode(t, "- a")
+	testYAMLEncodeDecode(t, " line\nline \n")
+	testYAMLEncodeDecode(t, "çığöşü")
+	testYAMLEncodeDecode(t, true)
+	testYAMLEncodeDecode(t, ARR{})
+	testYAMLEncodeDecode(t, ARR{1, 2, 3, "four", false, nil})
+	testYAMLEncodeDecode(t, ARR{ARR{1, ARR{2}}, MAP{"a": 0, "b": ARR{}}})
+	testYAMLEncodeDecode(t, MAP{})
+	testYAMLEncodeDecode(t, MAP{"a": 0, "b": "bee", "c d": MAP{}})
+	testYAMLEncodeDecode(t, MAP{"a": ARR{1, MAP{"x": ARR{"y"}, "z": 1.5}},
+		"b": MAP{"c": MAP{"d": nil}}, "null": "~", "": "empty"})
+}
+
+func testYAMLEncodeDecode(t *testing.T, v any) {
+	o, err := z.FromInterface(v)
+	require.NoError(t, err)
+
+	b, err := yaml.Encode(o)
+	require.NoError(t, err)
+
+	a, err := yaml.Decode(b)
+	require.NoError(t, err, string(b))
+
+	vj, err := gojson.Marshal(v)
+	require.NoError(t, err)
+
+	aj, err := gojson.Marshal(z.ToInterface(a))
+	require.NoError(t, err)
+
+	require.Equal(t, string(vj), string(aj), string(b))
+}
+
+func TestDecode(t *testing.T) {
+	testDecode(t, "", `null`)
+	testDecode(t, "# comment\n", `null`)
+	testDecode(t, "a: 1\nb: -2.5\nc: true\nd: ~\ne: text # comment\n",
+		`{"a":1,"b":-2.5,"c":true,"d":null,"e":"text"}`)
+	testDecode(t, "0x1f", `31`)
+	testDecode(t, "0o17", `15`)
+	testDecode(t, "1e3", `1000`)
+	testDecode(t, "'it''s'", `"it's"`)
+	testDecode(t, `"a\tb\u00e7\n"`, `"a\tbç\n"`)
+	testDecode(t, "\"folded\n  quoted\n\n  text\"", `"folded quoted\ntext"`)
+	testDecode(t, "plain\n  multi line\n", `"plain multi line"`)
+	testDecode(t, "url: http://example.com:80/a", `{"url":"http://example.com:80/a"}`)
+	testDecode(t, `
+a:
+  b:
+    - 1
+    - c: 2
+      d: [3, 4]
+  e:
+  - x
+  - y
+f: {g: 5, h: [i, "j"]}
+`, `{"a":{"b":[1,{"c":2,"d":[3,4]}],"e":["x","y"]},"f":{"g":5,"h":["i","j"]}}`)
+	testDecode(t, "- - 1\n  - 2\n- 3", `[[1,2],3]`)
+	testDecode(t, "- \n- a:\n  b: 1", `[null,{"a":null,"b":1}]`)
+	testDecode(t, "[a, [b, c], {d: e}, f: g]", `["a",["b","c"],{"d":"e"},{"f":"g"}]`)
+	testDecode(t, "{a: 1, b, c: }", `{"a":1,"b":null,"c":null}`)
+
+	// block scalars
+	testDecode(t, "a: |\n  line 1\n  line 2\n\nb: 1",
+		`{"a":"line 1\nline 2\n","b":1}`)
+	testDecode(t, "a: |-\n  line 1\n   line 2\n", `{"a":"line 1\n line 2"}`)
+	testDecode(t, "a: |+\n  text\n\n", `{"a":"text\n\n"}`)
+	testDecode(t, "a: >\n  folded\n  text\n\n  next\n", `{"a":"folded text\nnext\n"}`)
+	testDecode(t, "- >-\n  a\n    b\n  c\n- d", `["a\n  b\nc","d"]`)
+
+	// anchors, aliases and merge keys
+	testDecode(t, `
+base: &base
+  a: 1
+  b: 2
+derived:
+  <<: *base
+  b: 3
+list: [&x 1, *x]
+`, `{"base":{"a":1,"b":2},"derived":{"a":1,"b":3},"list":[1,1]}`)
+
+	// tags
+	testDecode(t, "[!!str 1, !!float 2, !!int '3', !!binary aGk=]",
+		`["1",2,3,"aGk="]`)
+}
+
+func testDecode(t *testing.T, input, expected string) {
+	o, err := yaml.Decode([]byte(input))
+	require.NoError(t, err, input)
+	b, err := json.EncodeWithOptions(o, json.EncodeOptions{SortKeys: true})
+	require.NoError(t, err)
+	require.Equal(t, expected, string(b), input)
+}
+
+func TestDecodeTypes(t *testing.T) {
+	o, err := yaml.Decode([]byte(`
+date: 2002-12-14
+time: 2001-12-14T21:59:43.10-05:00
+inf: -.inf
+bin: !!binary aGk=
+`))
+	require.NoError(t, err)
+	m := o.(*z.Map).Value
+	require.Equal(t, "2002-12-14T00:00:00Z",
+		m["date"].(*z.Time).Value.Format("2006-01-02T15:04:05Z07:00"))
+	require.Equal(t, "2001-12-15T02:59:43.1Z",
+		m["time"].(*z.Time).Value.UTC().Format("2006-01-02T15:04:05.999Z07:00"))
+	require.Equal(t, "-Inf", m["inf"].String())
+	require.Equal(t, []byte("hi"), m["bin"].(*z.Bytes).Value)
+
+	b, err := yaml.Encode(o)
+	require.NoError(t, err)
+	require.Equal(t, `bin: !!binary aGk=
+date: 2002-12-14T00:00:00Z
+inf: -.inf
+time: 2001-12-14T21:59:43.1-05:00
+`, string(b))
+}
+
+func TestDecodeAll(t *testing.T) {
+	docs, err := yaml.DecodeAll([]byte(`%YAML 1.2
+---
+a: 1
+...
+--- text
+---
+- b
+---
+`))
+	require.NoError(t, err)
+	require.Equal(t, 4, len(docs))
+	require.Equal(t, "text", z.ToInterface(docs[1]))
+	require.Equal(t, z.UndefinedValue, docs[3])
+
+	b, err := yaml.EncodeAll(docs)
+	require.NoError(t, err)
+	require.Equal(t, "a: 1\n---\ntext\n---\n- b\n---\nnull\n", string(b))
+}
+
+func TestDecodeError(t *testing.T) {
+	testDecodeError(t, "a: b: c")
+	testDecodeError(t, "a: 1\na: 2")
+	testDecodeError(t, "a:\n  b: 1\n c: 2")
+	testDecodeError(t, "- a\n- b\nc: 1")
+	testDecodeError(t, "[a, b")
+	testDecodeError(t, "{a: 1")
+	testDecodeError(t, "\"abc")
+	testDecodeError(t, "a: *unknown")
+	testDecodeError(t, "a:\n\tb: 1")
+	testDecodeError(t, "key: - a")
+
+	_, err := yaml.Decode([]byte("a: 1\nb: [\n"))
+	require.Equal(t, "yaml: line 3: did not find expected ',' or ']'",
+		err.Error())
+}
+
+func testDecodeError(t *testing.T, input string) {
+	_, err := yaml.Decode([]byte(input))
+	require.Error(t, err, input)
+}
@@ -0,0 +1,36 @@
+package stdlib_test
+
+import (
+	"testing"
+
+	"github.com/diiyw/z"
+)
+
+func TestYAML(t *testing.T) {
+	module(t, "yaml").call("decode", "a: 1\nb: [x, 2.5]\n").
+		expect(MAP{"a": 1, "b": ARR{"x", 2.5}})
+	module(t, "yaml").call("decode", []byte("- true\n- ~\n")).
+		expect(ARR{true, z.UndefinedValue})
+	module(t, "yaml").call("decode", "").expect(z.UndefinedValue)
+	module(t, "yaml").call("decode", "a: [1").expect(&z.Error{
+		Value: &z.String{Value: "yaml: line 1: did not find expected ',' or ']'"},
+	})
+	module(t, "yaml").call("decode", 1).expectError()
+	module(t, "yaml").call("decode_all", "a\n---\nb\n").expect(ARR{"a", "b"})
+	module(t, "yaml").call("decode_all", "").expect(ARR{})
+
+	module(t, "yaml").call("encode", MAP{"b": ARR{1, "x: y"}, "a": MAP{"c": 1.0}}).
+		expect([]byte("a:\n  c: 1.0\nb:\n  - 1\n  - \"x: y\"\n"))
+	module(t, "yaml").call("encode", ARR{MAP{"a": 1, "b": 2}}).
+		expect([]byte("- a: 1\n  b: 2\n"))
+	module(t, "yaml").call("encode_all", ARR{"a", ARR{}}).
+		expect([]byte("a\n---\n[]\n"))
+	module(t, "yaml").call("encode_all", "a").expectError()
+
+	expect(t, `
+yaml := import("yaml")
+times := import("times")
+doc := yaml.decode("name: z\nlist:\n- 1\n- 2\nwhen: 2020-01-02\n")
+out := doc.name + string(len(doc.list)) + string(times.time_year(doc.when))
+`, "z22020")
+}
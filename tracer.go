@@ -0,0 +1,297 @@
+package z
+
+import (
+	"context"
+	"time"
+
+	"github.com/diiyw/z/parser"
+)
+
+// Tracer receives the execution events of the VMs it's set to, see
+// VM.SetTracer and Script.SetTracer. The VMs running the script functions
+// called by host functions, or spawned, report to the same Tracer, possibly
+// on other goroutines.
+//
+// The context of the events is the one passed to VM.SetTracer for the top
+// level code, and the one returned by Enter for the code of a function call.
+// The script functions called by a host function get the context of its
+// caller.
+type Tracer interface {
+	// Enter is called when a compiled function is called. Recursive tail
+	// calls reuse the call of their caller and are not reported.
+	Enter(ctx context.Context, call *TraceCall) context.Context
+	// Exit is called when a compiled function returns ret, or fails with
+	// err.
+	Exit(ctx context.Context, call *TraceCall, ret Object, err error)
+	// HostCall is called when a host function, e.g. a UserFunction or a
+	// builtin function, returns ret or err after the duration d.
+	HostCall(
+		ctx context.Context,
+		call *TraceCall,
+		ret Object,
+		err error,
+		d time.Duration,
+	)
+	// Import is called when a module is imported, if the bytecode is
+	// compiled with Compiler.EnableImportTrace, as Script does when a
+	// Tracer is set.
+	Import(ctx context.Context, name string, pos parser.SourceFilePos)
+	// Error is called when a runtime error stops a VM, before the
+	// compiled functions on its call stack exit.
+	Error(ctx context.Context, err error, pos parser.SourceFilePos)
+}
+
+// TraceCall describes a function call.
+type TraceCall struct {
+	// Name is the name of the function: the name of a host function, the
+	// name a compiled function is bound to, e.g. "add" for
+	// `add := func(a, b) {...}`, or the position of an anonymous compiled
+	// function, e.g. "func@lib.z:12".
+	Name string
+	Args []Object
+	// Pos is the source position of the call.
+	Pos parser.SourceFilePos
+}
+
+// NopTracer is a Tracer ignoring all the events. Embed it to implement only
+// some of the methods of Tracer.
+type NopTracer struct{}
+
+// Enter implements Tracer.
+func (NopTracer) Enter(ctx context.Context, _ *TraceCall) context.Context {
+	return ctx
+}
+
+// Exit implements Tracer.
+func (NopTracer) Exit(context.Context, *TraceCall, Object, error) {}
+
+// HostCall implements Tracer.
+func (NopTracer) HostCall(
+	context.Context,
+	*TraceCall,
+	Object,
+	error,
+	time.Duration,
+) {
+}
+
+// Import implements Tracer.
+func (NopTracer) Import(context.Context, string, parser.SourceFilePos) {}
+
+// Error implements Tracer.
+func (NopTracer) Error(context.Context, error, parser.SourceFilePos) {}
+
+// Span is a traced operation, e.g. an OpenTelemetry span.
+type Span interface {
+	// SetError records that the operation failed with err.
+	SetError(err error)
+	// End ends the operation at t.
+	End(t time.Time)
+}
+
+// SpanStartFunc starts a span named name at t, with the attributes attrs, as
+// a child of the span of ctx. It returns the span and a context holding it.
+type SpanStartFunc func(
+	ctx context.Context,
+	name string,
+	t time.Time,
+	attrs map[string]any,
+) (context.Context, Span)
+
+// Span attributes set by SpanTracer, named after the OpenTelemetry semantic
+// conventions.
+const (
+	SpanAttrFunction = "code.function"
+	SpanAttrFile     = "code.filepath"
+	SpanAttrLine     = "code.lineno"
+	SpanAttrColumn   = "code.column"
+	SpanAttrModule   = "z.module"
+)
+
+// SpanTracer is a Tracer creating spans, in the way of OpenTelemetry, for the
+// function calls and the imports. An OpenTelemetry tracer is adapted with a
+// SpanStartFunc calling its Start method with the start time and the
+// attributes as options, and returning a Span wrapping the OpenTelemetry
+// span.
+type SpanTracer struct {
+	start SpanStartFunc
+}
+
+// NewSpanTracer creates a SpanTracer starting spans with start.
+func NewSpanTracer(start SpanStartFunc) *SpanTracer {
+	return &SpanTracer{start: start}
+}
+
+type spanKey struct{}
+
+// Enter starts the span of a compiled function call.
+func (t *SpanTracer) Enter(
+	ctx context.Context,
+	call *TraceCall,
+) context.Context {
+	ctx, span := t.start(ctx, call.Name, time.Now(), callAttrs(call))
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// Exit ends the span started by Enter.
+func (t *SpanTracer) Exit(
+	ctx context.Context,
+	_ *TraceCall,
+	_ Object,
+	err error,
+) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return
+	}
+	if err != nil {
+		span.SetError(err)
+	}
+	span.End(time.Now())
+}
+
+// HostCall creates the span of a host function call.
+func (t *SpanTracer) HostCall(
+	ctx context.Context,
+	call *TraceCall,
+	_ Object,
+	err error,
+	d time.Duration,
+) {
+	end := time.Now()
+	_, span := t.start(ctx, call.Name, end.Add(-d), callAttrs(call))
+	if err != nil {
+		span.SetError(err)
+	}
+	span.End(end)
+}
+
+// Import creates an empty span for an import.
+func (t *SpanTracer) Import(
+	ctx context.Context,
+	name string,
+	pos parser.SourceFilePos,
+) {
+	now := time.Now()
+	attrs := posAttrs(pos)
+	attrs[SpanAttrModule] = name
+	_, span := t.start(ctx, "import "+name, now, attrs)
+	span.End(now)
+}
+
+// Error does nothing: the errors are set to the spans of the calls they
+// stop.
+func (t *SpanTracer) Error(context.Context, error, parser.SourceFilePos) {}
+
+func callAttrs(call *TraceCall) map[string]any {
+	attrs := posAttrs(call.Pos)
+	attrs[SpanAttrFunction] = call.Name
+	return attrs
+}
+
+func posAttrs(pos parser.SourceFilePos) map[string]any {
+	attrs := make(map[string]any)
+	if pos.IsValid() {
+		attrs[SpanAttrFile] = pos.Filename
+		attrs[SpanAttrLine] = pos.Line
+		attrs[SpanAttrColumn] = pos.Column
+	}
+	return attrs
+}
+
+// traceFrame is a compiled function call traced by a VM.
+type traceFrame struct {
+	ctx  context.Context
+	call *TraceCall
+}
+
+// SetTracer makes the VM, and the child VMs created by Call or Spawn, report
+// their execution to t. ctx is the context of the events of the top level
+// code, e.g. holding a parent span. It must be called before Run.
+func (v *VM) SetTracer(ctx context.Context, t Tracer) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	v.tracer = t
+	v.traceCtx = ctx
+}
+
+// traceContext returns the context of the current call.
+func (v *VM) traceContext() context.Context {
+	if n := len(v.traces); n > 0 {
+		return v.traces[n-1].ctx
+	}
+	return v.traceCtx
+}
+
+// tracePos returns the source position of the current instruction, or the
+// one of the caller of the VM.
+func (v *VM) tracePos() parser.SourceFilePos {
+	if len(v.curFrame.fn.SourceMap) == 0 {
+		return v.traceCaller
+	}
+	return v.fileSet.Position(v.curFrame.fn.SourcePos(v.ip))
+}
+
+// traceEnter reports the call of a compiled function with the arguments on
+// top of the stack.
+func (v *VM) traceEnter(fn *CompiledFunction, numArgs int) {
+	call := &TraceCall{
+		Name: fn.traceName(v.fileSet),
+		Args: append([]Object(nil), v.stack[v.sp-numArgs:v.sp]...),
+		Pos:  v.tracePos(),
+	}
+	ctx := v.tracer.Enter(v.traceContext(), call)
+	v.traces = append(v.traces, traceFrame{ctx: ctx, call: call})
+}
+
+// traceExit reports the return of the current compiled function.
+func (v *VM) traceExit(ret Object) {
+	n := len(v.traces) - 1
+	t := v.traces[n]
+	v.traces = v.traces[:n]
+	v.tracer.Exit(t.ctx, t.call, ret, nil)
+}
+
+// traceHostCall reports the call of a host function.
+func (v *VM) traceHostCall(
+	fn Object,
+	args []Object,
+	ret Object,
+	err error,
+	d time.Duration,
+) {
+	var name string
+	switch fn := fn.(type) {
+	case *UserFunction:
+		name = fn.Name
+	case *VMFunction:
+		name = fn.Name
+	case *BuiltinFunction:
+		name = fn.Name
+	}
+	if name == "" {
+		name = fn.TypeName()
+	}
+	call := &TraceCall{Name: name, Args: args, Pos: v.tracePos()}
+	v.tracer.HostCall(v.traceContext(), call, ret, err, d)
+}
+
+// traceEnd reports the runtime error or the abort stopping the VM, and
+// exits the calls left.
+func (v *VM) traceEnd() {
+	err := v.err
+	if err != nil {
+		pos := v.traceCaller
+		if len(v.curFrame.fn.SourceMap) > 0 {
+			pos = v.fileSet.Position(v.curFrame.fn.SourcePos(v.ip - 1))
+		}
+		v.tracer.Error(v.traceContext(), err, pos)
+	} else if len(v.traces) > 0 {
+		err = ErrAborted
+	}
+	for i := len(v.traces) - 1; i >= 0; i-- {
+		v.tracer.Exit(v.traces[i].ctx, v.traces[i].call, nil, err)
+	}
+	v.traces = v.traces[:0]
+}
@@ -0,0 +1,240 @@
+package z_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/parser"
+	"github.com/diiyw/z/require"
+)
+
+// recordTracer records the events as lines, indented by the depth of the
+// calls.
+type recordTracer struct {
+	lock   sync.Mutex
+	events []string
+}
+
+type depthKey struct{}
+
+func depth(ctx context.Context) int {
+	d, _ := ctx.Value(depthKey{}).(int)
+	return d
+}
+
+func (r *recordTracer) record(ctx context.Context, format string,
+	args ...any) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = append(r.events, strings.Repeat("  ", depth(ctx))+
+		fmt.Sprintf(format, args...))
+}
+
+func (r *recordTracer) Enter(
+	ctx context.Context,
+	call *z.TraceCall,
+) context.Context {
+	r.record(ctx, "enter %s %v %s", call.Name, call.Args, call.Pos)
+	return context.WithValue(ctx, depthKey{}, depth(ctx)+1)
+}
+
+func (r *recordTracer) Exit(
+	ctx context.Context,
+	call *z.TraceCall,
+	ret z.Object,
+	err error,
+) {
+	r.record(ctx, "exit %s %v %v", call.Name, ret, err)
+}
+
+func (r *recordTracer) HostCall(
+	ctx context.Context,
+	call *z.TraceCall,
+	ret z.Object,
+	err error,
+	d time.Duration,
+) {
+	r.record(ctx, "host %s %v %s %v %v", call.Name, call.Args, call.Pos,
+		ret, err)
+}
+
+func (r *recordTracer) Import(
+	ctx context.Context,
+	name string,
+	pos parser.SourceFilePos,
+) {
+	r.record(ctx, "import %s %s", name, pos)
+}
+
+func (r *recordTracer) Error(
+	ctx context.Context,
+	err error,
+	pos parser.SourceFilePos,
+) {
+	r.record(ctx, "error %v %s", err, pos)
+}
+
+func traceModules() *z.ModuleMap {
+	modules := z.NewModuleMap()
+	modules.AddSourceModule("lib", []byte(`export func(x) {
+	return x * 2
+}`))
+	modules.AddBuiltinModule("calls", map[string]z.Object{
+		"call": &z.VMFunction{
+			Name: "call",
+			Value: func(vm *z.VM, args ...z.Object) (z.Object, error) {
+				return vm.Call(args[0], args[1:]...)
+			},
+		},
+	})
+	return modules
+}
+
+func TestTracer(t *testing.T) {
+	s := z.NewScript([]byte(`double := import("lib")
+calls := import("calls")
+add := func(a, b) { return double(a) + b }
+x := add(1, 2)
+y := calls.call(func(v) { return len(v) }, [1, 2])`))
+	s.SetImports(traceModules())
+	tracer := &recordTracer{}
+	s.SetTracer(tracer)
+	_, err := s.Run()
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"import lib (main):1:11",
+		"enter func@lib:1 [] (main):1:11",
+		"  exit func@lib:1 <compiled-function lib> <nil>",
+		"import calls (main):2:10",
+		"enter add [1 2] (main):4:6",
+		"  enter lib [1] (main):3:28",
+		"    exit lib 2 <nil>",
+		"  exit add 4 <nil>",
+		// the functions called by host functions get the context of
+		// their caller
+		"enter func@(main):5 [[1, 2]] (main):5:6",
+		"  host len [[1, 2]] (main):5:34 2 <nil>",
+		"  exit func@(main):5 2 <nil>",
+		"host call [<compiled-function> [1, 2]] (main):5:6 2 <nil>",
+	}, tracer.events)
+}
+
+func TestTracerError(t *testing.T) {
+	s := z.NewScript([]byte(`calls := import("calls")
+f := func(x) { return x + {} }
+calls.call(func() { return f(1) })`))
+	s.SetImports(traceModules())
+	tracer := &recordTracer{}
+	s.SetTracer(tracer)
+	_, err := s.Run()
+	require.Error(t, err)
+	require.Equal(t, []string{
+		"import calls (main):1:10",
+		"    error invalid operation: int + map (main):2:23",
+		"    exit f <nil> invalid operation: int + map",
+		"  exit func@(main):3 <nil> invalid operation: int + map",
+		"host call [<compiled-function>] (main):3:1 <nil> " +
+			"invalid operation: int + map\n\tat (main):2:23\n\tat (main):3:28",
+		"error invalid operation: int + map\n\tat (main):2:23\n\t" +
+			"at (main):3:28 (main):3:1",
+	}, filterEnter(tracer.events))
+
+	// aborted calls exit with ErrAborted
+	s = z.NewScript([]byte(`f := func() { for {} }; f()`))
+	tracer = &recordTracer{}
+	s.SetTracer(tracer)
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	_, err = s.RunContext(ctx)
+	require.Error(t, err)
+	require.Equal(t, []string{
+		"enter f [] (main):1:25",
+		"  exit f <nil> " + z.ErrAborted.Error(),
+	}, tracer.events)
+}
+
+// filterEnter removes the enter events.
+func filterEnter(events []string) (res []string) {
+	for _, e := range events {
+		if !strings.HasPrefix(strings.TrimSpace(e), "enter") {
+			res = append(res, e)
+		}
+	}
+	return
+}
+
+// testSpan records the spans in their parent.
+type testSpan struct {
+	name     string
+	attrs    map[string]any
+	err      error
+	start    time.Time
+	end      time.Time
+	children []*testSpan
+}
+
+type testSpanKey struct{}
+
+func (s *testSpan) SetError(err error) { s.err = err }
+func (s *testSpan) End(t time.Time)    { s.end = t }
+
+func (s *testSpan) String() string {
+	var b strings.Builder
+	b.WriteString(s.name)
+	if s.err != nil {
+		b.WriteString(" error")
+	}
+	if len(s.children) > 0 {
+		var children []string
+		for _, c := range s.children {
+			children = append(children, c.String())
+		}
+		b.WriteString("(" + strings.Join(children, ", ") + ")")
+	}
+	return b.String()
+}
+
+func TestSpanTracer(t *testing.T) {
+	root := &testSpan{name: "root"}
+	tracer := z.NewSpanTracer(func(
+		ctx context.Context,
+		name string,
+		start time.Time,
+		attrs map[string]any,
+	) (context.Context, z.Span) {
+		parent := ctx.Value(testSpanKey{}).(*testSpan)
+		span := &testSpan{name: name, attrs: attrs, start: start}
+		parent.children = append(parent.children, span)
+		return context.WithValue(ctx, testSpanKey{}, span), span
+	})
+
+	s := z.NewScript([]byte(`double := import("lib")
+calls := import("calls")
+add := func(a, b) { return double(a) + b }
+x := add(1, 2)
+y := calls.call(func() { return add(1, error("e")) })`))
+	s.SetImports(traceModules())
+	s.SetTracer(tracer)
+	ctx := context.WithValue(context.Background(), testSpanKey{}, root)
+	_, err := s.RunContext(ctx)
+	require.Error(t, err)
+	require.Equal(t, "root(import lib, func@lib:1, import calls, "+
+		"add(lib), func@(main):5 error(add error(lib)), "+
+		"call error)", root.String())
+
+	call := root.children[3]
+	require.Equal(t, "map[code.column:6 code.filepath:(main) "+
+		"code.function:add code.lineno:4]",
+		fmt.Sprint(call.attrs))
+	require.True(t, !call.end.Before(call.start))
+	for _, span := range root.children[4:] {
+		require.True(t, strings.HasPrefix(span.err.Error(),
+			"invalid operation: int + error"), span.err.Error())
+	}
+}
@@ -1,8 +1,13 @@
 package z
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/big"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/diiyw/z/parser"
 	"github.com/diiyw/z/token"
@@ -30,8 +35,28 @@ type VM struct {
 	ip          int
 	aborting    int64
 	maxAllocs   int64
-	allocs      int64
+	allocs      *int64 // remaining allocations, shared with the child VMs
+	allocated   int64  // allocations made by this VM
 	err         error
+	parent      *VM
+	childLock   sync.Mutex
+	children    map[*VM]struct{}
+	abortCh     chan struct{}
+
+	instrumented  bool // coverage or profiler set
+	coverage      *Coverage
+	coveredFn     *CompiledFunction
+	coveredCounts []uint32
+
+	profiler   *Profiler
+	profTicks  uint64
+	profAllocs int64
+	profCaller []profileFrame
+
+	tracer      Tracer
+	traceCtx    context.Context
+	traceCaller parser.SourceFilePos
+	traces      []traceFrame
 }
 
 // NewVM creates a VM.
@@ -51,6 +76,8 @@ func NewVM(
 		framesIndex: 1,
 		ip:          -1,
 		maxAllocs:   maxAllocs,
+		allocs:      new(int64),
+		abortCh:     make(chan struct{}),
 	}
 	v.frames[0].fn = bytecode.MainFunction
 	v.frames[0].ip = -1
@@ -59,9 +86,50 @@ func NewVM(
 	return v
 }
 
-// Abort aborts the execution.
+// SetCoverage makes the VM, and the child VMs created by Call or Spawn,
+// record the executed instructions to c. It must be called before Run.
+func (v *VM) SetCoverage(c *Coverage) {
+	v.coverage = c
+	v.instrumented = v.coverage != nil || v.profiler != nil
+}
+
+// SetProfiler makes the VM, and the child VMs created by Call or Spawn,
+// record their time and allocations to p. It must be called before Run.
+func (v *VM) SetProfiler(p *Profiler) {
+	v.profiler = p
+	v.instrumented = v.coverage != nil || v.profiler != nil
+	if p != nil && v.parent == nil {
+		p.nameFunc(v.fileSet, v.frames[0].fn, "main")
+	}
+}
+
+// Abort aborts the execution. Child VMs created by Call or Spawn are aborted
+// as well.
 func (v *VM) Abort() {
 	atomic.StoreInt64(&v.aborting, 1)
+
+	v.childLock.Lock()
+	defer v.childLock.Unlock()
+	select {
+	case <-v.abortCh:
+	default:
+		close(v.abortCh)
+	}
+	for child := range v.children {
+		child.Abort()
+	}
+}
+
+// Done returns a channel that is closed when the VM is aborted. Functions
+// blocking on behalf of a script should select on it so they can be
+// interrupted.
+func (v *VM) Done() <-chan struct{} {
+	if v == nil {
+		return nil
+	}
+	v.childLock.Lock()
+	defer v.childLock.Unlock()
+	return v.abortCh
 }
 
 // Run starts the execution.
@@ -72,31 +140,238 @@ func (v *VM) Run() (err error) {
 	v.curInsts = v.curFrame.fn.Instructions
 	v.framesIndex = 1
 	v.ip = -1
-	v.allocs = v.maxAllocs + 1
+	atomic.StoreInt64(v.allocs, v.maxAllocs)
 
+	v.profileStart()
 	v.run()
+	v.profileEnd()
+	if v.tracer != nil {
+		v.traceEnd()
+	}
 	atomic.StoreInt64(&v.aborting, 0)
-	err = v.err
+	v.childLock.Lock()
+	select {
+	case <-v.abortCh:
+		v.abortCh = make(chan struct{})
+	default:
+	}
+	v.childLock.Unlock()
+	if v.err != nil {
+		return v.runtimeError(1)
+	}
+	return nil
+}
+
+// runtimeError returns the VM error as a RuntimeError with the call frames,
+// stopping at the frame index minFrame. The errors of child VMs are merged
+// into the RuntimeError of the parent VM when the host functions return them
+// as they are.
+func (v *VM) runtimeError(minFrame int) error {
+	e := &RuntimeError{Err: v.err, nested: v.parent != nil}
+	if child, ok := v.err.(*RuntimeError); ok && child.nested {
+		e.Err = child.Err
+		e.Frames = append(e.Frames, child.Frames...)
+	}
+	e.Frames = append(e.Frames, v.stackFrame(v.curFrame.fn, v.ip-1))
+	for v.framesIndex > minFrame {
+		v.framesIndex--
+		v.curFrame = &v.frames[v.framesIndex-1]
+		e.Frames = append(e.Frames,
+			v.stackFrame(v.curFrame.fn, v.curFrame.ip-1))
+	}
+	return e
+}
+
+// stackFrame describes the frame of fn executing the instruction at ip.
+func (v *VM) stackFrame(fn *CompiledFunction, ip int) StackFrame {
+	p := fn.SourcePos(ip)
+	name := fn.traceName(v.fileSet)
+	if v.parent == nil && fn == v.frames[0].fn {
+		name = "main"
+	}
+	return StackFrame{
+		Func:   name,
+		Pos:    v.fileSet.Position(p),
+		Source: v.fileSet.LineSource(p),
+	}
+}
+
+// Call calls a callable object fn with the given arguments and returns its
+// result. Compiled functions (script closures) are executed on a child VM
+// sharing the constants and the globals of v, so Call must only be used from
+// the goroutine running v, typically within a VMFunction. Other callable
+// objects are called directly.
+func (v *VM) Call(fn Object, args ...Object) (Object, error) {
+	if !fn.CanCall() {
+		return nil, fmt.Errorf("not callable: %s", fn.TypeName())
+	}
+	switch fn := fn.(type) {
+	case *CompiledFunction:
+		if v == nil {
+			return nil, fmt.Errorf("compiled function called outside VM")
+		}
+		if v.profiler == nil {
+			return v.newChild(v.globals).invoke(fn, args)
+		}
+		// the child VM records its own time
+		v.profile()
+		ret, err := v.newChild(v.globals).invoke(fn, args)
+		v.profTicks = v.profiler.ticks()
+		return ret, err
+	case *VMFunction:
+		return fn.Value(v, args...)
+	}
+	ret, err := fn.Call(args...)
 	if err != nil {
-		filePos := v.fileSet.Position(
-			v.curFrame.fn.SourcePos(v.ip - 1))
-		err = fmt.Errorf("Runtime Error: %w\n\tat %s",
-			err, filePos)
-		for v.framesIndex > 1 {
-			v.framesIndex--
-			v.curFrame = &v.frames[v.framesIndex-1]
-			filePos = v.fileSet.Position(
-				v.curFrame.fn.SourcePos(v.curFrame.ip - 1))
-			err = fmt.Errorf("%w\n\tat %s", err, filePos)
+		return nil, err
+	}
+	if ret == nil {
+		ret = UndefinedValue
+	}
+	return ret, nil
+}
+
+// Spawn runs the compiled function fn on a new goroutine using a child VM
+// with a copy of the globals of v. The free variables of fn are copied as
+// well so the spawned function cannot mutate the local state of v. Callers
+// are responsible for copying args if they are shared. The result and the
+// error of the function, or of its panic, are sent to the returned channel
+// once it finishes.
+func (v *VM) Spawn(fn *CompiledFunction, args ...Object) <-chan SpawnResult {
+	globals := make([]Object, len(v.globals))
+	for idx, g := range v.globals {
+		if g != nil {
+			globals[idx] = g.Copy()
 		}
-		return err
 	}
-	return nil
+	free := make([]*ObjectPtr, len(fn.Free))
+	for idx, p := range fn.Free {
+		var val Object = UndefinedValue
+		if p.Value != nil && *p.Value != nil {
+			val = (*p.Value).Copy()
+		}
+		free[idx] = &ObjectPtr{Value: &val}
+	}
+	cl := *fn
+	cl.Free = free
+
+	child := v.newChild(globals)
+	ch := make(chan SpawnResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				ch <- SpawnResult{Err: panicError(r)}
+			}
+		}()
+		ret, err := child.invoke(&cl, args)
+		ch <- SpawnResult{Value: ret, Err: err}
+	}()
+	return ch
+}
+
+// panicError returns the error of a recovered panic.
+func panicError(r any) error {
+	switch e := r.(type) {
+	case string:
+		return errors.New(e)
+	case error:
+		return e
+	default:
+		return fmt.Errorf("unknown panic: %v", e)
+	}
+}
+
+// SpawnResult is the outcome of a function started with VM.Spawn.
+type SpawnResult struct {
+	Value Object
+	Err   error
+}
+
+// newChild creates a VM sharing the constants of v and registers it so it is
+// aborted together with v.
+func (v *VM) newChild(globals []Object) *VM {
+	child := &VM{
+		constants:    v.constants,
+		globals:      globals,
+		fileSet:      v.fileSet,
+		framesIndex:  1,
+		ip:           -1,
+		maxAllocs:    v.maxAllocs,
+		allocs:       v.allocs,
+		parent:       v,
+		instrumented: v.instrumented,
+		coverage:     v.coverage,
+		profiler:     v.profiler,
+		tracer:       v.tracer,
+		abortCh:      make(chan struct{}),
+	}
+	if v.tracer != nil {
+		child.traceCtx = v.traceContext()
+		child.traceCaller = v.tracePos()
+	}
+	if v.profiler != nil {
+		child.profCaller = v.callStack()
+	}
+	v.childLock.Lock()
+	defer v.childLock.Unlock()
+	if v.children == nil {
+		v.children = make(map[*VM]struct{})
+	}
+	v.children[child] = struct{}{}
+	if atomic.LoadInt64(&v.aborting) == 1 {
+		child.aborting = 1
+		close(child.abortCh)
+	}
+	return child
+}
+
+// invoke executes fn with args using a trampoline main function and returns
+// the value left on the stack.
+func (v *VM) invoke(fn Object, args []Object) (ret Object, err error) {
+	defer func() {
+		v.parent.childLock.Lock()
+		delete(v.parent.children, v)
+		v.parent.childLock.Unlock()
+	}()
+	if len(args) >= StackSize-1 {
+		return nil, ErrStackOverflow
+	}
+
+	v.frames[0].fn = &CompiledFunction{
+		Instructions: append(
+			MakeInstruction(parser.OpCall, len(args), 0),
+			parser.OpSuspend),
+	}
+	v.frames[0].ip = -1
+	v.curFrame = &v.frames[0]
+	v.curInsts = v.curFrame.fn.Instructions
+	v.stack[0] = fn
+	copy(v.stack[1:], args)
+	v.sp = len(args) + 1
+	v.framesIndex = 1
+	v.ip = -1
+
+	v.profileStart()
+	v.run()
+	v.profileEnd()
+	if v.tracer != nil {
+		v.traceEnd()
+	}
+	if v.err != nil {
+		return nil, v.runtimeError(2)
+	}
+	if atomic.LoadInt64(&v.aborting) == 1 {
+		return nil, ErrAborted
+	}
+	return v.stack[0], nil
 }
 
 func (v *VM) run() {
 	for atomic.LoadInt64(&v.aborting) == 0 {
 		v.ip++
+		if v.instrumented {
+			v.instrument()
+		}
 
 		switch v.curInsts[v.ip] {
 		case parser.OpConstant:
@@ -125,8 +400,7 @@ func (v *VM) run() {
 				return
 			}
 
-			v.allocs--
-			if v.allocs == 0 {
+			if v.alloc() {
 				v.err = ErrObjectAllocLimit
 				return
 			}
@@ -177,8 +451,7 @@ func (v *VM) run() {
 			switch x := operand.(type) {
 			case *Int:
 				var res Object = &Int{Value: ^x.Value}
-				v.allocs--
-				if v.allocs == 0 {
+				if v.alloc() {
 					v.err = ErrObjectAllocLimit
 					return
 				}
@@ -196,8 +469,7 @@ func (v *VM) run() {
 			switch x := operand.(type) {
 			case *Int:
 				var res Object = &Int{Value: -x.Value}
-				v.allocs--
-				if v.allocs == 0 {
+				if v.alloc() {
 					v.err = ErrObjectAllocLimit
 					return
 				}
@@ -205,8 +477,26 @@ func (v *VM) run() {
 				v.sp++
 			case *Float:
 				var res Object = &Float{Value: -x.Value}
-				v.allocs--
-				if v.allocs == 0 {
+				if v.alloc() {
+					v.err = ErrObjectAllocLimit
+					return
+				}
+				v.stack[v.sp] = res
+				v.sp++
+			case *BigInt:
+				var res Object = &BigInt{Value: new(big.Int).Neg(x.Value)}
+				if v.alloc() {
+					v.err = ErrObjectAllocLimit
+					return
+				}
+				v.stack[v.sp] = res
+				v.sp++
+			case *Decimal:
+				var res Object = &Decimal{
+					Unscaled: new(big.Int).Neg(x.Unscaled),
+					Scale:    x.Scale,
+				}
+				if v.alloc() {
 					v.err = ErrObjectAllocLimit
 					return
 				}
@@ -282,8 +572,7 @@ func (v *VM) run() {
 			v.sp -= numElements
 
 			var arr Object = &Array{Value: elements}
-			v.allocs--
-			if v.allocs == 0 {
+			if v.alloc() {
 				v.err = ErrObjectAllocLimit
 				return
 			}
@@ -302,8 +591,7 @@ func (v *VM) run() {
 			v.sp -= numElements
 
 			var m Object = &Map{Value: kv}
-			v.allocs--
-			if v.allocs == 0 {
+			if v.alloc() {
 				v.err = ErrObjectAllocLimit
 				return
 			}
@@ -314,8 +602,7 @@ func (v *VM) run() {
 			var e Object = &Error{
 				Value: value,
 			}
-			v.allocs--
-			if v.allocs == 0 {
+			if v.alloc() {
 				v.err = ErrObjectAllocLimit
 				return
 			}
@@ -327,8 +614,7 @@ func (v *VM) run() {
 				var immutableArray Object = &ImmutableArray{
 					Value: value.Value,
 				}
-				v.allocs--
-				if v.allocs == 0 {
+				if v.alloc() {
 					v.err = ErrObjectAllocLimit
 					return
 				}
@@ -337,8 +623,7 @@ func (v *VM) run() {
 				var immutableMap Object = &ImmutableMap{
 					Value: value.Value,
 				}
-				v.allocs--
-				if v.allocs == 0 {
+				if v.alloc() {
 					v.err = ErrObjectAllocLimit
 					return
 				}
@@ -416,8 +701,7 @@ func (v *VM) run() {
 				var val Object = &Array{
 					Value: left.Value[lowIdx:highIdx],
 				}
-				v.allocs--
-				if v.allocs == 0 {
+				if v.alloc() {
 					v.err = ErrObjectAllocLimit
 					return
 				}
@@ -453,8 +737,7 @@ func (v *VM) run() {
 				var val Object = &Array{
 					Value: left.Value[lowIdx:highIdx],
 				}
-				v.allocs--
-				if v.allocs == 0 {
+				if v.alloc() {
 					v.err = ErrObjectAllocLimit
 					return
 				}
@@ -490,8 +773,7 @@ func (v *VM) run() {
 				var val Object = &String{
 					Value: left.Value[lowIdx:highIdx],
 				}
-				v.allocs--
-				if v.allocs == 0 {
+				if v.alloc() {
 					v.err = ErrObjectAllocLimit
 					return
 				}
@@ -527,8 +809,7 @@ func (v *VM) run() {
 				var val Object = &Bytes{
 					Value: left.Value[lowIdx:highIdx],
 				}
-				v.allocs--
-				if v.allocs == 0 {
+				if v.alloc() {
 					v.err = ErrObjectAllocLimit
 					return
 				}
@@ -619,6 +900,9 @@ func (v *VM) run() {
 					v.err = ErrStackOverflow
 					return
 				}
+				if v.tracer != nil {
+					v.traceEnter(callee, numArgs)
+				}
 
 				// update call frame
 				v.curFrame.ip = v.ip // store current ip before call
@@ -633,7 +917,20 @@ func (v *VM) run() {
 			} else {
 				var args []Object
 				args = append(args, v.stack[v.sp-numArgs:v.sp]...)
-				ret, e := value.Call(args...)
+				var ret Object
+				var e error
+				var start time.Time
+				if v.tracer != nil {
+					start = time.Now()
+				}
+				if fn, ok := value.(*VMFunction); ok {
+					ret, e = fn.Value(v, args...)
+				} else {
+					ret, e = value.Call(args...)
+				}
+				if v.tracer != nil {
+					v.traceHostCall(value, args, ret, e, time.Since(start))
+				}
 				v.sp -= numArgs + 1
 
 				// runtime error
@@ -659,8 +956,7 @@ func (v *VM) run() {
 				if ret == nil {
 					ret = UndefinedValue
 				}
-				v.allocs--
-				if v.allocs == 0 {
+				if v.alloc() {
 					v.err = ErrObjectAllocLimit
 					return
 				}
@@ -675,6 +971,9 @@ func (v *VM) run() {
 			} else {
 				retVal = UndefinedValue
 			}
+			if v.tracer != nil {
+				v.traceExit(retVal)
+			}
 			//v.sp--
 			v.framesIndex--
 			v.curFrame = &v.frames[v.framesIndex-1]
@@ -766,6 +1065,7 @@ func (v *VM) run() {
 			}
 			v.sp -= numFree
 			cl := &CompiledFunction{
+				Name:          fn.Name,
 				Instructions:  fn.Instructions,
 				NumLocals:     fn.NumLocals,
 				NumParameters: fn.NumParameters,
@@ -773,8 +1073,7 @@ func (v *VM) run() {
 				SourceMap:     fn.SourceMap,
 				Free:          free,
 			}
-			v.allocs--
-			if v.allocs == 0 {
+			if v.alloc() {
 				v.err = ErrObjectAllocLimit
 				return
 			}
@@ -838,8 +1137,7 @@ func (v *VM) run() {
 				return
 			}
 			iterator = dst.Iterate()
-			v.allocs--
-			if v.allocs == 0 {
+			if v.alloc() {
 				v.err = ErrObjectAllocLimit
 				return
 			}
@@ -867,6 +1165,13 @@ func (v *VM) run() {
 			val := iterator.(Iterator).Value()
 			v.stack[v.sp] = val
 			v.sp++
+		case parser.OpImport:
+			v.ip += 2
+			if v.tracer != nil {
+				cidx := int(v.curInsts[v.ip]) | int(v.curInsts[v.ip-1])<<8
+				v.tracer.Import(v.traceContext(),
+					v.constants[cidx].(*String).Value, v.tracePos())
+			}
 		case parser.OpSuspend:
 			return
 		default:
@@ -876,6 +1181,84 @@ func (v *VM) run() {
 	}
 }
 
+// instrument records the current instruction to the coverage and the
+// profiler.
+func (v *VM) instrument() {
+	if v.coverage != nil {
+		v.cover()
+	}
+	if v.profiler != nil {
+		v.profile()
+	}
+}
+
+// cover increments the execution count of the current instruction.
+func (v *VM) cover() {
+	if fn := v.curFrame.fn; fn != v.coveredFn {
+		v.coveredFn = fn
+		v.coveredCounts = v.coverage.counters(fn, v.fileSet)
+	}
+	if v.coveredCounts != nil {
+		atomic.AddUint32(&v.coveredCounts[v.ip], 1)
+	}
+}
+
+// profileStart starts recording the time and the allocations to the
+// profiler.
+func (v *VM) profileStart() {
+	if v.profiler != nil {
+		v.profTicks = v.profiler.ticks()
+		v.profAllocs = v.allocated
+	}
+}
+
+// profileEnd charges the time and the allocations not sampled yet to the
+// last instruction.
+func (v *VM) profileEnd() {
+	if v.profiler == nil {
+		return
+	}
+	if v.profiler.ticks() != v.profTicks || v.allocated != v.profAllocs {
+		v.profileSample()
+	}
+}
+
+// profile samples the current call stack when an interval has elapsed since
+// the last sample.
+func (v *VM) profile() {
+	if v.profiler.ticks() != v.profTicks {
+		v.profileSample()
+	}
+}
+
+// profileSample charges the time and the allocations since the last sample
+// to the current call stack.
+func (v *VM) profileSample() {
+	ticks := v.profiler.ticks()
+	v.profiler.add(v.fileSet, v.callStack(),
+		int64(ticks-v.profTicks), v.allocated-v.profAllocs)
+	v.profTicks, v.profAllocs = ticks, v.allocated
+}
+
+// callStack returns the call frames, innermost first, including the ones
+// of the parent VMs.
+func (v *VM) callStack() []profileFrame {
+	stack := make([]profileFrame, 0, v.framesIndex+len(v.profCaller))
+	stack = append(stack, profileFrame{fn: v.curFrame.fn, ip: v.ip})
+	for i := v.framesIndex - 2; i >= 0; i-- {
+		stack = append(stack,
+			profileFrame{fn: v.frames[i].fn, ip: v.frames[i].ip})
+	}
+	return append(stack, v.profCaller...)
+}
+
+// alloc counts an allocation against the limit shared by the VM and its
+// child VMs, and reports whether the limit is exceeded.
+func (v *VM) alloc() bool {
+	v.allocated++
+	return v.maxAllocs >= 0 && atomic.AddInt64(v.allocs, -1) < 0
+}
+
 // IsStackEmpty tests if the stack is empty or not.
 func (v *VM) IsStackEmpty() bool {
 	return v.sp == 0
@@ -680,6 +680,18 @@ func TestBuiltinFunction(t *testing.T) {
 	expectRun(t, `a := func(x) { return func() { return x } }; out = type_name(a(5))`,
 		nil, "compiled-function") // closure
 
+	// function names
+	expectRun(t, `f := func() {}; out = f.name`, nil, "f")
+	expectRun(t, `m := {a: {b: func() {}}}; out = m.a.b.name`, nil,
+		"m.a.b")
+	expectRun(t, `x := 1; f := func() { return x }; out = f.name`,
+		nil, "f") // closure
+	expectRun(t, `out = func() {}.name`, nil, "")
+	expectRun(t, `out = len.name`, nil, "len")
+	expectRun(t, `f := len; out = copy(f).name`, nil, "len")
+	expectError(t, `f := func() {}; f.value`, nil,
+		"invalid index on function")
+
 	// is_function
 	expectRun(t, `out = is_function(1)`, nil, false)
 	expectRun(t, `out = is_function(func() {})`, nil, true)
@@ -940,6 +952,29 @@ b(a, c)
 `, nil, "Runtime Error: not callable: int\n\tat test:7:4\n\tat test:3:4\n\tat test:9:1")
 }
 
+func TestVMCall(t *testing.T) {
+	apply := &z.VMFunction{
+		Name: "apply",
+		Value: func(v *z.VM, args ...z.Object) (z.Object, error) {
+			return v.Call(args[0], args[1:]...)
+		},
+	}
+	expectRun(t, `out = apply(func(a, b) { return a + b }, 1, 2)`,
+		Opts().Symbol("apply", apply).Skip2ndPass(), 3)
+	expectRun(t, `out = apply(len, [1, 2])`,
+		Opts().Symbol("apply", apply).Skip2ndPass(), 2)
+	expectRun(t, `x := 10; out = apply(func(a) { x += a; return x }, 5) + x`,
+		Opts().Symbol("apply", apply).Skip2ndPass(), 30)
+	expectRun(t, `out = apply(func(...a) { return apply(len, a) }, 1, 2, 3)`,
+		Opts().Symbol("apply", apply).Skip2ndPass(), 3)
+	expectError(t, `apply(func() {
+	return 1 + "a" + []
+})`, Opts().Symbol("apply", apply).Skip2ndPass(),
+		"Runtime Error: invalid operation: int + string\n\tat test:2:9\n\tat test:1:1")
+	expectError(t, `apply(1)`, Opts().Symbol("apply", apply).Skip2ndPass(),
+		"not callable: int")
+}
+
 func TestChar(t *testing.T) {
 	expectRun(t, `out = 'a'`, nil, 'a')
 	expectRun(t, `out = '九'`, nil, rune(20061))
@@ -1177,6 +1212,28 @@ func TestVMErrorUnwrap(t *testing.T) {
 		"expected error as:%v, got:%v", wrapUserErr, asErr2)
 }
 
+func TestVMRuntimeError(t *testing.T) {
+	s := z.NewScript([]byte(`f := func(a) {
+	return a + "x"
+}
+f(1)`))
+	_, err := s.Run()
+	require.Error(t, err)
+	var rte *z.RuntimeError
+	require.True(t, errors.As(err, &rte))
+	require.Equal(t, "invalid operation: int + string", rte.Err.Error())
+	require.Equal(t, 2, len(rte.Frames))
+	require.Equal(t, "f", rte.Frames[0].Func)
+	require.Equal(t, 2, rte.Frames[0].Pos.Line)
+	require.Equal(t, 9, rte.Frames[0].Pos.Column)
+	require.Equal(t, "main", rte.Frames[1].Func)
+	require.Equal(t, 4, rte.Frames[1].Pos.Line)
+	require.Equal(t, " 2 | \treturn a + \"x\"\n   | \t       ^\n",
+		rte.Snippet())
+	require.Equal(t, "Runtime Error: invalid operation: int + string"+
+		"\n\tat (main):2:9\n\tat (main):4:1", err.Error())
+}
+
 func TestError(t *testing.T) {
 	expectRun(t, `out = error(1)`, nil, errorObject(1))
 	expectRun(t, `out = error(1).value`, nil, 1)
@@ -1203,6 +1260,64 @@ func TestFloat(t *testing.T) {
 	expectRun(t, `out = -5.0 + +5.0`, nil, 0.0)
 }
 
+func TestBigInt(t *testing.T) {
+	expectDecimal(t, `out = string(decimal.bigint("123456789012345678901234567890") * 10)`,
+		"1234567890123456789012345678900")
+	expectDecimal(t, `out = string(decimal.bigint(1) << 100)`,
+		"1267650600228229401496703205376")
+	expectDecimal(t, `out = string(-decimal.bigint(5) + 2)`, "-3")
+	expectDecimal(t, `out = string(7 / decimal.bigint(2))`, "3")
+	expectDecimal(t, `out = decimal.bigint(7) % 4 == decimal.bigint(3)`, true)
+	expectDecimal(t, `out = decimal.bigint(3) * 1.5`, 4.5)
+	expectDecimal(t, `out = 2 < decimal.bigint(3)`, true)
+	expectDecimal(t, `out = decimal.bigint(1) == 1`, true)
+	expectDecimal(t, `out = int(decimal.bigint("42"))`, 42)
+	expectDecimal(t, `out = int(decimal.bigint(1) << 64)`, z.UndefinedValue)
+	expectDecimal(t, `out = decimal.bigint("x", 0)`, 0)
+	expectDecimal(t, `out = decimal.is_bigint(decimal.bigint(1))`, true)
+	expectDecimal(t, `out = format("%x|%+d|%5d",
+		decimal.bigint(255), decimal.bigint(1), decimal.bigint(7))`,
+		"ff|+1|    7")
+	expectDecimalError(t, `decimal.bigint(1) / 0`, "division by zero")
+	expectDecimalError(t, `decimal.bigint(1) << -1`, "invalid shift count")
+}
+
+func TestDecimal(t *testing.T) {
+	expectDecimal(t, `out = string(decimal.new(0.1) + decimal.new(0.2))`, "0.3")
+	expectDecimal(t, `out = string(decimal.new("19.99") * 3)`, "59.97")
+	expectDecimal(t, `out = string(decimal.new(1) / 3)`, "0.3333333333333333")
+	expectDecimal(t, `out = string(decimal.new("10.50") / 2)`, "5.25")
+	expectDecimal(t, `out = string(decimal.new(10) % decimal.new("3.5"))`, "3.0")
+	expectDecimal(t, `out = string(1.5 - decimal.new("0.25"))`, "1.25")
+	expectDecimal(t, `out = string(-decimal.new("1.5"))`, "-1.5")
+	expectDecimal(t, `out = decimal.new("2.50") == decimal.new("2.5")`, true)
+	expectDecimal(t, `out = decimal.new("2.5") > 2`, true)
+	expectDecimal(t, `out = decimal.new(1) == 1`, true)
+	expectDecimal(t, `out = int(decimal.new("-7.9"))`, -7)
+	expectDecimal(t, `out = float(decimal.new("0.5"))`, 0.5)
+	expectDecimal(t, `out = decimal.new("1e2000000", false)`, false)
+	expectDecimal(t, `out = decimal.is_decimal(decimal.new(1))`, true)
+	expectDecimal(t, `out = !decimal.new("0.00")`, true)
+	expectDecimal(t, `out = format("%f|%.1f|%.1f|%8.2f|%e|%s",
+		decimal.new("2.50"), decimal.new("2.25"), decimal.new("2.35"),
+		decimal.new("-1.005"), decimal.new("1234.5"), decimal.new("0.10"))`,
+		"2.50|2.2|2.4|   -1.00|1.234500e+03|0.10")
+	expectDecimalError(t, `decimal.new(1) / decimal.new("0.0")`, "division by zero")
+	expectDecimalError(t, `decimal.new(1) & 1`, "invalid operation: decimal & int")
+}
+
+func expectDecimal(t *testing.T, input string, expected any) {
+	expectRun(t, `decimal := import("decimal"); `+input,
+		Opts().Module("decimal", stdlib.GetModuleMap("decimal").Get("decimal")),
+		expected)
+}
+
+func expectDecimalError(t *testing.T, input string, expected string) {
+	expectError(t, `decimal := import("decimal"); `+input,
+		Opts().Module("decimal", stdlib.GetModuleMap("decimal").Get("decimal")),
+		expected)
+}
+
 func TestForIn(t *testing.T) {
 	// array
 	expectRun(t, `out = 0; for x in [1, 2, 3] { out += x }`,
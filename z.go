@@ -3,6 +3,8 @@ package z
 import (
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"strconv"
 	"time"
 )
@@ -34,6 +36,10 @@ const (
 // CallableFunc is a function signature for the callable functions.
 type CallableFunc = func(args ...Object) (ret Object, err error)
 
+// CallableFuncVM is a function signature for the callable functions that
+// need the calling VM.
+type CallableFuncVM = func(vm *VM, args ...Object) (ret Object, err error)
+
 // CountObjects returns the number of objects that a given object o contains.
 // For scalar value types, it will always be 1. For compound value types,
 // this will include its elements and all of their elements recursively.
@@ -85,6 +91,16 @@ func ToInt(o Object) (v int, ok bool) {
 	case *Float:
 		v = int(o.Value)
 		ok = true
+	case *BigInt:
+		if o.Value.IsInt64() {
+			v = int(o.Value.Int64())
+			ok = true
+		}
+	case *Decimal:
+		if i := o.BigInt(); i.IsInt64() {
+			v = int(i.Int64())
+			ok = true
+		}
 	case *Char:
 		v = int(o.Value)
 		ok = true
@@ -112,6 +128,16 @@ func ToInt64(o Object) (v int64, ok bool) {
 	case *Float:
 		v = int64(o.Value)
 		ok = true
+	case *BigInt:
+		if o.Value.IsInt64() {
+			v = o.Value.Int64()
+			ok = true
+		}
+	case *Decimal:
+		if i := o.BigInt(); i.IsInt64() {
+			v = i.Int64()
+			ok = true
+		}
 	case *Char:
 		v = int64(o.Value)
 		ok = true
@@ -139,6 +165,12 @@ func ToFloat64(o Object) (v float64, ok bool) {
 	case *Float:
 		v = o.Value
 		ok = true
+	case *BigInt:
+		v, _ = new(big.Float).SetInt(o.Value).Float64()
+		ok = true
+	case *Decimal:
+		v = o.Float64()
+		ok = true
 	case *String:
 		c, err := strconv.ParseFloat(o.Value, 64)
 		if err == nil {
@@ -149,6 +181,67 @@ func ToFloat64(o Object) (v float64, ok bool) {
 	return
 }
 
+// ToBigInt will try to convert object o to *big.Int value. Floats and
+// decimals are truncated.
+func ToBigInt(o Object) (v *big.Int, ok bool) {
+	switch o := o.(type) {
+	case *BigInt:
+		v = o.Value
+		ok = true
+	case *Int:
+		v = big.NewInt(o.Value)
+		ok = true
+	case *Float:
+		if !math.IsInf(o.Value, 0) && !math.IsNaN(o.Value) {
+			v, _ = big.NewFloat(o.Value).Int(nil)
+			ok = true
+		}
+	case *Decimal:
+		v = o.BigInt()
+		ok = true
+	case *Char:
+		v = big.NewInt(int64(o.Value))
+		ok = true
+	case *Bool:
+		v = new(big.Int)
+		if o == TrueValue {
+			v.SetInt64(1)
+		}
+		ok = true
+	case *String:
+		v, ok = new(big.Int).SetString(o.Value, 10)
+	}
+	return
+}
+
+// ToDecimal will try to convert object o to *Decimal value.
+func ToDecimal(o Object) (v *Decimal, ok bool) {
+	switch o := o.(type) {
+	case *Decimal:
+		v = o
+		ok = true
+	case *Int:
+		v = &Decimal{Unscaled: big.NewInt(o.Value)}
+		ok = true
+	case *BigInt:
+		v = &Decimal{Unscaled: o.Value}
+		ok = true
+	case *Float:
+		d, err := decimalFromFloat(o.Value)
+		if err == nil {
+			v = d
+			ok = true
+		}
+	case *String:
+		d, err := ParseDecimal(o.Value)
+		if err == nil {
+			v = d
+			ok = true
+		}
+	}
+	return
+}
+
 // ToBool will try to convert object o to bool value.
 func ToBool(o Object) (v bool, ok bool) {
 	ok = true
@@ -200,6 +293,8 @@ func ToInterface(o Object) (res any) {
 	switch o := o.(type) {
 	case *Int:
 		res = o.Value
+	case *BigInt:
+		res = o.Value
 	case *String:
 		res = o.Value
 	case *Float:
@@ -267,6 +362,8 @@ func FromInterface(v any) (Object, error) {
 		return &Char{Value: rune(v)}, nil
 	case float64:
 		return &Float{Value: v}, nil
+	case *big.Int:
+		return &BigInt{Value: v}, nil
 	case []byte:
 		if len(v) > MaxBytesLen {
 			return nil, ErrBytesLimit
@@ -304,6 +401,8 @@ func FromInterface(v any) (Object, error) {
 		return v, nil
 	case CallableFunc:
 		return &UserFunction{Value: v}, nil
+	case CallableFuncVM:
+		return &VMFunction{Value: v}, nil
 	}
 	return nil, fmt.Errorf("cannot convert to object: %T", v)
 }
@@ -0,0 +1,222 @@
+// Package zhttp serves HTTP requests with Z scripts.
+//
+// A script handling requests reads the request from the "request" variable
+// and sets the "response" variable:
+//
+//	json := import("json")
+//	if request.method != "POST" {
+//	    response = {status: 405}
+//	} else {
+//	    event := json.decode(request.body)
+//	    response = {status: 200, body: {received: event.id}}
+//	}
+package zhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/stdlib/json"
+)
+
+const (
+	// RequestVar is the name of the variable holding the request.
+	RequestVar = "request"
+
+	// ResponseVar is the name of the variable set by the script to the
+	// response.
+	ResponseVar = "response"
+)
+
+// NewScript returns a script with the request and response variables
+// defined. Compile it to create a Handler.
+func NewScript(src []byte) *z.Script {
+	s := z.NewScript(src)
+	_ = s.Add(RequestVar, nil)
+	_ = s.Add(ResponseVar, nil)
+	return s
+}
+
+// Handler is an http.Handler running a compiled script for each request.
+// Each request runs on a clone of the compiled script, with a context that
+// is canceled when the request is canceled.
+type Handler struct {
+	compiled *z.Compiled
+
+	// MaxBodyBytes limits the size of the request bodies. Zero means
+	// z.MaxBytesLen.
+	MaxBodyBytes int64
+
+	// ErrorHandler is called when the script fails or sets an invalid
+	// response. A nil ErrorHandler replies with an internal server error.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewHandler returns a handler running the compiled script. The script
+// must have been compiled with the request and response variables defined,
+// see NewScript.
+func NewHandler(compiled *z.Compiled) *Handler {
+	return &Handler{compiled: compiled}
+}
+
+// ServeHTTP runs the script for the request and writes its response.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.serve(w, r); err != nil {
+		if h.ErrorHandler != nil {
+			h.ErrorHandler(w, r, err)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) error {
+	limit := h.MaxBodyBytes
+	if limit <= 0 {
+		limit = int64(z.MaxBytesLen)
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge),
+				http.StatusRequestEntityTooLarge)
+			return nil
+		}
+		return err
+	}
+
+	c := h.compiled.Clone()
+	if err := c.Set(RequestVar, Request(r, body)); err != nil {
+		return err
+	}
+	if err := c.RunContext(r.Context()); err != nil {
+		return err
+	}
+	return WriteResponse(w, c.Get(ResponseVar).Object())
+}
+
+// Request returns the immutable map exposing the request to scripts.
+func Request(r *http.Request, body []byte) z.Object {
+	headers := make(map[string]z.Object, len(r.Header))
+	for key, values := range r.Header {
+		headers[key] = &z.String{Value: strings.Join(values, ", ")}
+	}
+	query := make(map[string]z.Object)
+	for key, values := range r.URL.Query() {
+		query[key] = &z.String{Value: values[0]}
+	}
+	return &z.ImmutableMap{
+		Value: map[string]z.Object{
+			"method":      &z.String{Value: r.Method},
+			"path":        &z.String{Value: r.URL.Path},
+			"url":         &z.String{Value: r.URL.String()},
+			"host":        &z.String{Value: r.Host},
+			"remote_addr": &z.String{Value: r.RemoteAddr},
+			"headers":     &z.ImmutableMap{Value: headers},
+			"query":       &z.ImmutableMap{Value: query},
+			"raw_query":   &z.String{Value: r.URL.RawQuery},
+			"body":        &z.Bytes{Value: body},
+		},
+	}
+}
+
+// WriteResponse writes the response set by a script:
+//   - undefined: an empty response with the 204 status.
+//   - a string or bytes: the body of a response with the 200 status.
+//   - a map with the optional "status" (int), "headers" (map of strings or
+//     arrays of strings) and "body" keys. A body other than a string or
+//     bytes is encoded as JSON.
+func WriteResponse(w http.ResponseWriter, o z.Object) error {
+	switch o := o.(type) {
+	case *z.Undefined:
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	case *z.String:
+		_, err := io.WriteString(w, o.Value)
+		return err
+	case *z.Bytes:
+		_, err := w.Write(o.Value)
+		return err
+	case *z.Map:
+		return writeResponseMap(w, o.Value)
+	case *z.ImmutableMap:
+		return writeResponseMap(w, o.Value)
+	}
+	return fmt.Errorf("invalid response type: %s", o.TypeName())
+}
+
+func writeResponseMap(w http.ResponseWriter, m map[string]z.Object) error {
+	status := http.StatusOK
+	var body []byte
+	for key, value := range m {
+		switch key {
+		case "status":
+			n, ok := z.ToInt(value)
+			if !ok || n < 100 || n > 999 {
+				return fmt.Errorf("invalid response status: %s", value)
+			}
+			status = n
+		case "headers":
+			if err := setHeaders(w.Header(), value); err != nil {
+				return err
+			}
+		case "body":
+		default:
+			return fmt.Errorf("unknown response key: %s", key)
+		}
+	}
+	switch v := m["body"].(type) {
+	case nil, *z.Undefined:
+	case *z.String:
+		body = []byte(v.Value)
+	case *z.Bytes:
+		body = v.Value
+	default:
+		b, err := json.Encode(v)
+		if err != nil {
+			return err
+		}
+		body = b
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+	}
+	w.WriteHeader(status)
+	_, err := w.Write(body)
+	return err
+}
+
+func setHeaders(h http.Header, o z.Object) error {
+	var m map[string]z.Object
+	switch o := o.(type) {
+	case *z.Map:
+		m = o.Value
+	case *z.ImmutableMap:
+		m = o.Value
+	default:
+		return fmt.Errorf("invalid response headers type: %s", o.TypeName())
+	}
+	for key, value := range m {
+		values := []z.Object{value}
+		switch value := value.(type) {
+		case *z.Array:
+			values = value.Value
+		case *z.ImmutableArray:
+			values = value.Value
+		}
+		for _, v := range values {
+			s, ok := z.ToString(v)
+			if !ok {
+				return fmt.Errorf("invalid response header: %s", key)
+			}
+			h.Add(key, s)
+		}
+	}
+	return nil
+}
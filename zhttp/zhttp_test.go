@@ -0,0 +1,112 @@
+package zhttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+	"github.com/diiyw/z/zhttp"
+)
+
+func newHandler(t *testing.T, src string) *zhttp.Handler {
+	s := zhttp.NewScript([]byte(src))
+	s.SetImports(stdlib.GetModuleMap("json", "times"))
+	c, err := s.Compile()
+	require.NoError(t, err)
+	return zhttp.NewHandler(c)
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	h := newHandler(t, `
+json := import("json")
+if request.path == "/text" {
+	response = "hello " + request.query.name
+} else if request.path == "/empty" {
+	// no response
+} else if request.method == "POST" {
+	event := json.decode(request.body)
+	response = {
+		status: 201,
+		headers: {"X-Id": string(event.id), "X-Multi": ["a", "b"]},
+		body: {id: event.id, token: request.headers["X-Token"]}
+	}
+} else {
+	response = {status: 405, body: bytes("not allowed")}
+}
+`)
+
+	w := serve(h, httptest.NewRequest("GET", "/text?name=z&name=x", nil))
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, "hello z", w.Body.String())
+
+	w = serve(h, httptest.NewRequest("GET", "/empty", nil))
+	require.Equal(t, 204, w.Code)
+	require.Equal(t, "", w.Body.String())
+
+	r := httptest.NewRequest("POST", "/events", strings.NewReader(`{"id": 7}`))
+	r.Header.Set("X-Token", "secret")
+	w = serve(h, r)
+	require.Equal(t, 201, w.Code)
+	require.Equal(t, "7", w.Header().Get("X-Id"))
+	require.Equal(t, []string{"a", "b"}, w.Header().Values("X-Multi"))
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var body struct {
+		ID    int    `json:"id"`
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, 7, body.ID)
+	require.Equal(t, "secret", body.Token)
+
+	w = serve(h, httptest.NewRequest("PUT", "/events", nil))
+	require.Equal(t, 405, w.Code)
+	require.Equal(t, "not allowed", w.Body.String())
+
+	// each request runs on a clone: the response of a request is not seen
+	// by the next one
+	w = serve(h, httptest.NewRequest("GET", "/empty", nil))
+	require.Equal(t, 204, w.Code)
+}
+
+func TestHandlerErrors(t *testing.T) {
+	h := newHandler(t, `response = {status: "x"}`)
+	w := serve(h, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, 500, w.Code)
+
+	h = newHandler(t, `response = [1, 2]`)
+	var handled error
+	h.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+		w.WriteHeader(502)
+	}
+	w = serve(h, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, 502, w.Code)
+	require.Equal(t, "invalid response type: array", handled.Error())
+
+	h = newHandler(t, `response = len(request.body)`)
+	h.MaxBodyBytes = 4
+	w = serve(h, httptest.NewRequest("POST", "/", strings.NewReader("12345")))
+	require.Equal(t, 413, w.Code)
+
+	// the script is aborted when the request is canceled
+	h = newHandler(t, `for true {}`)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	h.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+	}
+	serve(h, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	require.Equal(t, context.DeadlineExceeded, handled)
+}
@@ -0,0 +1,175 @@
+package zmod
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Lock is the content of a z.sum file: the resolved dependencies of a
+// module, direct and indirect, one per line:
+//
+//	strs ../strs - - h1:<hash>
+//	text https://github.com/acme/text.git v1.2.0 <commit> h1:<hash>
+type Lock struct {
+	Modules []Locked
+}
+
+// Locked is a resolved dependency.
+type Locked struct {
+	Name string
+	// Source is the git URL, or the local path relative to the directory
+	// of the main module.
+	Source string
+	// Version is the required version, or "" for the local directories.
+	Version string
+	// Rev is the git commit the version resolved to, or "" for the local
+	// directories.
+	Rev string
+	// Hash is the hash of the files of the dependency, see HashDir.
+	Hash string
+}
+
+// Find returns the locked dependency name, or nil.
+func (l *Lock) Find(name string) *Locked {
+	for i := range l.Modules {
+		if l.Modules[i].Name == name {
+			return &l.Modules[i]
+		}
+	}
+	return nil
+}
+
+// ParseLock parses the content of a z.sum file.
+func ParseLock(data []byte) (*Lock, error) {
+	l := &Lock{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("%s:%d: malformed line", LockFile, line)
+		}
+		m := Locked{
+			Name:    fields[0],
+			Source:  fields[1],
+			Version: fields[2],
+			Rev:     fields[3],
+			Hash:    fields[4],
+		}
+		if strings.HasPrefix(m.Source, "-") ||
+			(m.Rev != "-" && strings.HasPrefix(m.Rev, "-")) {
+			return nil, fmt.Errorf("%s:%d: source or commit starting with -",
+				LockFile, line)
+		}
+		if m.Version == "-" {
+			m.Version = ""
+		}
+		if m.Rev == "-" {
+			m.Rev = ""
+		}
+		l.Modules = append(l.Modules, m)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Format returns the content of the z.sum file of the lock, sorted by
+// module name.
+func (l *Lock) Format() []byte {
+	modules := append([]Locked(nil), l.Modules...)
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Name < modules[j].Name
+	})
+	dash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	var b bytes.Buffer
+	for _, m := range modules {
+		fmt.Fprintf(&b, "%s %s %s %s %s\n", m.Name, m.Source,
+			dash(m.Version), dash(m.Rev), m.Hash)
+	}
+	return b.Bytes()
+}
+
+// HashDir returns the hash of the files of a dependency, the ones listed by
+// moduleFiles: "h1:" followed by the base64 SHA-256 of the list of the
+// SHA-256 and the slash-separated path of each file, sorted by path.
+func HashDir(dir string) (string, error) {
+	files, err := moduleFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, file := range files {
+		fh, err := hashFile(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%x  %s\n", fh, file)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// moduleFiles returns the sorted slash-separated paths of the regular files
+// of the module in dir, skipping the hidden files and directories and the
+// vendor directory of the module.
+func moduleFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry,
+		err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") || rel == VendorDir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
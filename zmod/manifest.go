@@ -0,0 +1,140 @@
+package zmod
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// validName matches the module names: they are the first element of the
+// import paths of the modules, so they can't contain slashes.
+var validName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// Manifest is the content of a z.mod file:
+//
+//	// comments start with two slashes
+//	module myapp
+//
+//	require strs ../strs
+//	require text https://github.com/acme/text.git v1.2.0
+//	require util ../util v0.3.0
+type Manifest struct {
+	// Module is the name of the module.
+	Module string
+	// Requires are the dependencies of the module, in their order in the
+	// file.
+	Requires []Require
+}
+
+// Require is a dependency of a module.
+type Require struct {
+	// Name is the name the dependency is imported with.
+	Name string
+	// Source is a git URL, or a local path relative to the directory of the
+	// z.mod file.
+	Source string
+	// Version is the git tag, branch or commit of the dependency. It's
+	// required for the git URLs. A local path with a version must be a git
+	// repository; without one, the directory is used as is.
+	Version string
+}
+
+// IsGit reports whether the dependency is fetched with git.
+func (r Require) IsGit() bool {
+	return r.Version != "" || isGitURL(r.Source)
+}
+
+// isGitURL reports whether source is a remote git URL rather than a local
+// path.
+func isGitURL(source string) bool {
+	return strings.Contains(source, "://") ||
+		strings.HasPrefix(source, "git@")
+}
+
+// ParseManifest parses the content of a z.mod file.
+func ParseManifest(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	names := make(map[string]bool)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if i := strings.Index(text, "//"); i >= 0 &&
+			(i == 0 || text[i-1] == ' ' || text[i-1] == '\t') {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "module":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%s:%d: usage: module name",
+					ManifestFile, line)
+			}
+			if m.Module != "" {
+				return nil, fmt.Errorf("%s:%d: repeated module statement",
+					ManifestFile, line)
+			}
+			m.Module = fields[1]
+		case "require":
+			if len(fields) != 3 && len(fields) != 4 {
+				return nil, fmt.Errorf(
+					"%s:%d: usage: require name source [version]",
+					ManifestFile, line)
+			}
+			r := Require{Name: fields[1], Source: fields[2]}
+			if len(fields) == 4 {
+				r.Version = fields[3]
+			}
+			if !validName.MatchString(r.Name) {
+				return nil, fmt.Errorf("%s:%d: invalid module name: %s",
+					ManifestFile, line, r.Name)
+			}
+			if names[r.Name] {
+				return nil, fmt.Errorf("%s:%d: %s required twice",
+					ManifestFile, line, r.Name)
+			}
+			if strings.HasPrefix(r.Source, "-") ||
+				strings.HasPrefix(r.Version, "-") {
+				return nil, fmt.Errorf("%s:%d: %s: source or version "+
+					"starting with -", ManifestFile, line, r.Name)
+			}
+			if isGitURL(r.Source) && r.Version == "" {
+				return nil, fmt.Errorf("%s:%d: %s: version required",
+					ManifestFile, line, r.Name)
+			}
+			names[r.Name] = true
+			m.Requires = append(m.Requires, r)
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown statement: %s",
+				ManifestFile, line, fields[0])
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if m.Module == "" {
+		return nil, fmt.Errorf("%s: missing module statement", ManifestFile)
+	}
+	return m, nil
+}
+
+// Format returns the content of the z.mod file of the manifest.
+func (m *Manifest) Format() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "module %s\n", m.Module)
+	if len(m.Requires) > 0 {
+		b.WriteString("\n")
+	}
+	for _, r := range m.Requires {
+		fmt.Fprintf(&b, "require %s %s", r.Name, r.Source)
+		if r.Version != "" {
+			fmt.Fprintf(&b, " %s", r.Version)
+		}
+		b.WriteString("\n")
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,473 @@
+// Package zmod manages the dependencies of Z modules.
+//
+// A module is a directory with a z.mod manifest, see Manifest, declaring its
+// name and its dependencies: git repositories at a version, or local
+// directories. Tidy resolves the dependencies, including the ones of the
+// dependencies, and records their git commits and content hashes in the
+// z.sum lock file. Vendor copies them into the vendor directory, checking
+// their hashes, and Verify checks the vendored copies have not been
+// modified since.
+//
+// Scripts import the vendored modules by name, with Loader: import("strs")
+// loads vendor/strs/index.z and import("strs/unicode") loads
+// vendor/strs/unicode.z.
+//
+// Git dependencies are fetched with the git command.
+package zmod
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/diiyw/z"
+)
+
+const (
+	// ManifestFile is the name of the manifest of a module.
+	ManifestFile = "z.mod"
+
+	// LockFile is the name of the lock file of a module.
+	LockFile = "z.sum"
+
+	// VendorDir is the name of the directory of the vendored dependencies.
+	VendorDir = "vendor"
+
+	// IndexFile is the name, without extension, of the file imported with
+	// the name of a dependency.
+	IndexFile = "index"
+)
+
+var (
+	// ErrNoManifest is returned by FindRoot when no z.mod file is found.
+	ErrNoManifest = errors.New("z.mod file not found")
+
+	// ErrChecksum is an error where the files of a dependency don't match
+	// the hash recorded in the lock file.
+	ErrChecksum = errors.New("checksum mismatch")
+
+	// ErrLockOutdated is an error where the lock file doesn't match the
+	// manifest, see Tidy.
+	ErrLockOutdated = errors.New("z.sum is out of date, run z mod tidy")
+)
+
+// Project is a module being developed: its manifest and its lock file.
+type Project struct {
+	// Dir is the directory of the z.mod file.
+	Dir      string
+	Manifest *Manifest
+	// Lock is the content of the z.sum file, empty if there is none.
+	Lock *Lock
+}
+
+// FindRoot returns the directory of the module containing dir: the nearest
+// directory with a z.mod file, starting with dir.
+func FindRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		fi, err := os.Stat(filepath.Join(dir, ManifestFile))
+		if err == nil && !fi.IsDir() {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrNoManifest
+		}
+		dir = parent
+	}
+}
+
+// Init creates the z.mod file of a new module named name in dir.
+func Init(dir, name string) (*Project, error) {
+	path := filepath.Join(dir, ManifestFile)
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists", path)
+	}
+	p := &Project{Dir: dir, Manifest: &Manifest{Module: name}, Lock: &Lock{}}
+	if err := os.WriteFile(path, p.Manifest.Format(), 0644); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Load reads the manifest and the lock file of the module in dir.
+func Load(dir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	p := &Project{Dir: dir, Manifest: m, Lock: &Lock{}}
+	data, err = os.ReadFile(filepath.Join(dir, LockFile))
+	if err == nil {
+		p.Lock, err = ParseLock(data)
+	} else if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Tidy resolves the dependencies and writes the z.sum file. The git
+// dependencies whose source and version are unchanged keep their locked
+// commit; the other versions are resolved again. The dependencies no longer
+// required are removed.
+func (p *Project) Tidy() error {
+	r := &resolver{root: p.Dir, old: p.Lock, lock: &Lock{}}
+	if err := r.resolve(p.Dir, false, p.Manifest.Requires); err != nil {
+		return err
+	}
+	err := os.WriteFile(filepath.Join(p.Dir, LockFile), r.lock.Format(),
+		0644)
+	if err != nil {
+		return err
+	}
+	p.Lock = r.lock
+	return nil
+}
+
+// Vendor replaces the vendor directory with the locked dependencies. It
+// fails with ErrChecksum if the files of a dependency don't match their
+// locked hash.
+func (p *Project) Vendor() error {
+	if err := p.checkLock(); err != nil {
+		return err
+	}
+	vendor := filepath.Join(p.Dir, VendorDir)
+	if err := os.RemoveAll(vendor); err != nil {
+		return err
+	}
+	for _, m := range p.Lock.Modules {
+		dir, cleanup, err := fetchLocked(p.Dir, m)
+		if err != nil {
+			return err
+		}
+		err = vendorModule(dir, filepath.Join(vendor, m.Name), m)
+		cleanup()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify checks that the vendored dependencies match the lock file.
+func (p *Project) Verify() error {
+	if err := p.checkLock(); err != nil {
+		return err
+	}
+	var errs []error
+	for _, m := range p.Lock.Modules {
+		dir := filepath.Join(p.Dir, VendorDir, m.Name)
+		if _, err := os.Stat(dir); err != nil {
+			errs = append(errs, fmt.Errorf("%s: not vendored, run z mod "+
+				"vendor", m.Name))
+			continue
+		}
+		hash, err := HashDir(dir)
+		if err != nil {
+			return err
+		}
+		if hash != m.Hash {
+			errs = append(errs, fmt.Errorf("%s: %w: vendored %s, locked %s",
+				m.Name, ErrChecksum, hash, m.Hash))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Loader returns a loader of the vendored dependencies of the module.
+func (p *Project) Loader() *Loader {
+	return &Loader{Dir: filepath.Join(p.Dir, VendorDir)}
+}
+
+// checkLock returns ErrLockOutdated if a direct dependency isn't locked
+// with its current source and version.
+func (p *Project) checkLock() error {
+	for _, req := range p.Manifest.Requires {
+		m := p.Lock.Find(req.Name)
+		if m == nil || m.Source != lockSource(p.Dir, p.Dir, req.Source) ||
+			m.Version != req.Version {
+			return fmt.Errorf("%s: %w", req.Name, ErrLockOutdated)
+		}
+	}
+	return nil
+}
+
+// resolver resolves the dependencies of a module and of its dependencies.
+type resolver struct {
+	root string
+	old  *Lock
+	lock *Lock
+}
+
+// resolve locks the dependencies reqs of the module in dir. The local paths
+// are relative to dir; they are not allowed in the modules fetched with
+// git, whose directories are temporary.
+func (r *resolver) resolve(dir string, fetched bool, reqs []Require) error {
+	for _, req := range reqs {
+		if fetched && !isGitURL(req.Source) {
+			return fmt.Errorf("%s: local path %s not allowed in a git "+
+				"dependency", req.Name, req.Source)
+		}
+		m := Locked{
+			Name:    req.Name,
+			Source:  lockSource(r.root, dir, req.Source),
+			Version: req.Version,
+		}
+		if prev := r.lock.Find(m.Name); prev != nil {
+			if prev.Source != m.Source || prev.Version != m.Version {
+				return fmt.Errorf("%s: conflicting requirements: %s %s "+
+					"and %s %s", m.Name, prev.Source, prev.Version,
+					m.Source, m.Version)
+			}
+			continue
+		}
+
+		rev := m.Version
+		if old := r.old.Find(m.Name); old != nil && old.Rev != "" &&
+			old.Source == m.Source && old.Version == m.Version {
+			rev = old.Rev
+		}
+		depDir, cleanup, err := fetch(r.root, m.Source, rev, req.IsGit())
+		if err != nil {
+			return err
+		}
+		err = r.lockModule(depDir, m, req.IsGit() || fetched)
+		cleanup()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockModule hashes the fetched dependency m in dir, then resolves its
+// dependencies.
+func (r *resolver) lockModule(dir string, m Locked, fetched bool) error {
+	if m.Version != "" {
+		rev, err := git(dir, "rev-parse", "HEAD")
+		if err != nil {
+			return err
+		}
+		m.Rev = rev
+	}
+	hash, err := HashDir(dir)
+	if err != nil {
+		return err
+	}
+	m.Hash = hash
+	r.lock.Modules = append(r.lock.Modules, m)
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	manifest, err := ParseManifest(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.Name, err)
+	}
+	return r.resolve(dir, fetched, manifest.Requires)
+}
+
+// lockSource returns the source of a dependency in the lock file: local
+// paths, relative to dir, are made relative to root.
+func lockSource(root, dir, source string) string {
+	if isGitURL(source) {
+		return source
+	}
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(dir, source)
+	}
+	if rel, err := filepath.Rel(root, source); err == nil {
+		source = rel
+	}
+	return filepath.ToSlash(source)
+}
+
+// fetch returns the directory of the dependency at source, relative to
+// root if it is a local path. The git dependencies are cloned in a
+// temporary directory, removed by cleanup, and checked out at rev.
+func fetch(
+	root, source, rev string,
+	isGit bool,
+) (dir string, cleanup func(), err error) {
+	cleanup = func() {}
+	if !isGitURL(source) && !filepath.IsAbs(source) {
+		source = filepath.Join(root, filepath.FromSlash(source))
+	}
+	if !isGit {
+		fi, err := os.Stat(source)
+		if err != nil {
+			return "", cleanup, err
+		}
+		if !fi.IsDir() {
+			return "", cleanup, fmt.Errorf("%s is not a directory", source)
+		}
+		return source, cleanup, nil
+	}
+
+	dir, err = os.MkdirTemp("", "zmod-")
+	if err != nil {
+		return "", cleanup, err
+	}
+	cleanup = func() { _ = os.RemoveAll(dir) }
+	if _, err := git("", "clone", "--quiet", "--", source, dir); err != nil {
+		cleanup()
+		return "", func() {}, err
+	}
+	// rev is resolved to a commit first as checkout would take it for an
+	// option if it starts with "-"
+	commit, err := git(dir, "rev-parse", "--verify", "--quiet",
+		"--end-of-options", rev+"^{commit}")
+	if err == nil {
+		_, err = git(dir, "-c", "advice.detachedHead=false", "checkout",
+			"--quiet", commit)
+	}
+	if err != nil {
+		cleanup()
+		return "", func() {}, err
+	}
+	return dir, cleanup, nil
+}
+
+// fetchLocked fetches the locked dependency m at its locked commit.
+func fetchLocked(root string, m Locked) (string, func(), error) {
+	if m.Rev == "" {
+		return fetch(root, m.Source, "", false)
+	}
+	return fetch(root, m.Source, m.Rev, true)
+}
+
+// vendorModule copies the files of the dependency m from src to dst after
+// checking their hash.
+func vendorModule(src, dst string, m Locked) error {
+	hash, err := HashDir(src)
+	if err != nil {
+		return err
+	}
+	if hash != m.Hash {
+		return fmt.Errorf("%s: %w: fetched %s, locked %s", m.Name,
+			ErrChecksum, hash, m.Hash)
+	}
+	files, err := moduleFiles(src)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		err := copyFile(filepath.Join(src, filepath.FromSlash(file)),
+			filepath.Join(dst, filepath.FromSlash(file)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// git runs a git command in dir and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Loader loads the vendored dependencies. The first element of the import
+// path is the name of the dependency: the name alone imports its IndexFile,
+// and the rest of the path is the path of a file of the dependency.
+// Canonical paths are absolute file paths, so the relative imports of the
+// dependencies can be loaded with z.FileLoader:
+//
+//	c.SetModuleLoader(z.ChainLoader{project.Loader(), &z.FileLoader{}})
+type Loader struct {
+	// Dir is the vendor directory.
+	Dir string
+	// Exts are the module file extensions, z.SourceFileExtDefault if empty.
+	Exts []string
+}
+
+// Load reads the module file.
+func (l *Loader) Load(name, _ string) ([]byte, string, error) {
+	dep, file, _ := strings.Cut(name, "/")
+	if !validName.MatchString(dep) ||
+		strings.Contains("/"+file+"/", "/../") {
+		return nil, "", fmt.Errorf("%w: %s", z.ErrModuleNotFound, name)
+	}
+	dir := filepath.Join(l.Dir, dep)
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return nil, "", fmt.Errorf("%w: %s", z.ErrModuleNotFound, name)
+	}
+	if file == "" {
+		file = IndexFile
+	}
+	exts := l.Exts
+	if len(exts) == 0 {
+		exts = []string{z.SourceFileExtDefault}
+	}
+	for _, ext := range exts {
+		p := filepath.Join(dir, filepath.FromSlash(file))
+		if !strings.HasSuffix(p, ext) {
+			p += ext
+		}
+		p, err := filepath.Abs(p)
+		if err != nil {
+			return nil, "", err
+		}
+		src, err := os.ReadFile(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+		return src, p, nil
+	}
+	return nil, "", fmt.Errorf("%w: %s", z.ErrModuleNotFound, name)
+}
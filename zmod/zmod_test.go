@@ -0,0 +1,250 @@
+package zmod_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/zmod"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// gitRepo creates a git repository with a commit of files, tagged v1.0.0,
+// and a second commit changing them on the default branch.
+func gitRepo(t *testing.T, dir string, files map[string]string) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	writeFiles(t, dir, files)
+	runGit(t, dir, "init", "--quiet")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "v1")
+	runGit(t, dir, "tag", "v1.0.0")
+	rev := runGit(t, dir, "rev-parse", "HEAD")
+	writeFiles(t, dir, map[string]string{"index.z": `export "v2"`})
+	runGit(t, dir, "commit", "--quiet", "-am", "v2")
+	return rev
+}
+
+func TestParseManifest(t *testing.T) {
+	m, err := zmod.ParseManifest([]byte(`// app
+module app
+
+require strs ../strs // local
+require text https://example.com/text.git v1.2.0
+require util ../util v0.1.0
+`))
+	require.NoError(t, err)
+	require.Equal(t, "app", m.Module)
+	require.Equal(t, 3, len(m.Requires))
+	require.True(t, m.Requires[0] == zmod.Require{
+		Name: "strs", Source: "../strs"})
+	require.True(t, m.Requires[1] == zmod.Require{Name: "text",
+		Source: "https://example.com/text.git", Version: "v1.2.0"})
+	require.True(t, m.Requires[2] == zmod.Require{
+		Name: "util", Source: "../util", Version: "v0.1.0"})
+	require.False(t, m.Requires[0].IsGit())
+	require.True(t, m.Requires[2].IsGit())
+	require.Equal(t, "module app\n\nrequire strs ../strs\n"+
+		"require text https://example.com/text.git v1.2.0\n"+
+		"require util ../util v0.1.0\n", string(m.Format()))
+
+	for src, msg := range map[string]string{
+		"module a\nrequire b":                "usage: require",
+		"module a\nmodule b":                 "repeated module",
+		"module a\nrequire a/b c":            "invalid module name: a/b",
+		"module a\nrequire b c\nrequire b d": "b required twice",
+		"module a\nrequire b git@h:b.git":    "b: version required",
+		"module a\nreplace b c":              "unknown statement: replace",
+		"module a\nrequire b -u":             "starting with -",
+		"module a\nrequire b c -o":           "starting with -",
+		"":                                   "missing module statement",
+	} {
+		_, err := zmod.ParseManifest([]byte(src))
+		require.Error(t, err)
+		require.True(t, strings.Contains(err.Error(), msg), err.Error())
+	}
+}
+
+func TestLock(t *testing.T) {
+	l, err := zmod.ParseLock([]byte("b ../b - - h1:b\n" +
+		"a https://example.com/a.git v1 0123 h1:a\n"))
+	require.NoError(t, err)
+	require.True(t, *l.Find("b") == zmod.Locked{
+		Name: "b", Source: "../b", Hash: "h1:b"})
+	require.Equal(t, "0123", l.Find("a").Rev)
+	require.Nil(t, l.Find("c"))
+	require.Equal(t, "a https://example.com/a.git v1 0123 h1:a\n"+
+		"b ../b - - h1:b\n", string(l.Format()))
+
+	_, err = zmod.ParseLock([]byte("a b c"))
+	require.Error(t, err)
+	_, err = zmod.ParseLock([]byte("a --upload-pack=x v1 0123 h1:a"))
+	require.Error(t, err)
+	_, err = zmod.ParseLock([]byte("a ../a v1 --output=x h1:a"))
+	require.Error(t, err)
+}
+
+func TestHashDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.z":          `export 1`,
+		"sub/a.z":          `export 2`,
+		".git/HEAD":        "ignored",
+		"vendor/x/index.z": "ignored",
+	})
+	h1, err := zmod.HashDir(dir)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(h1, "h1:"))
+
+	writeFiles(t, dir, map[string]string{".hidden": "x", "vendor/y": "y"})
+	h2, err := zmod.HashDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, h1, h2)
+
+	writeFiles(t, dir, map[string]string{"sub/a.z": `export 3`})
+	h3, err := zmod.HashDir(dir)
+	require.NoError(t, err)
+	require.True(t, h1 != h3)
+}
+
+func TestProject(t *testing.T) {
+	root := t.TempDir()
+	textRev := gitRepo(t, filepath.Join(root, "text"), map[string]string{
+		"index.z": `export "v1"`,
+	})
+	writeFiles(t, root, map[string]string{
+		"strs/z.mod": "module strs\n\nrequire text ../text v1.0.0\n",
+		"strs/index.z": `text := import("text")
+export {upper: func(s) { return text + ":" + s }}`,
+		"strs/util/pad.z": `export func(s) { return "[" + s + "]" }`,
+		"app/main.z": `strs := import("strs")
+pad := import("strs/util/pad")
+out := pad(strs.upper("x"))`,
+	})
+	app := filepath.Join(root, "app")
+	_, err := zmod.FindRoot(app)
+	require.True(t, errors.Is(err, zmod.ErrNoManifest))
+
+	p, err := zmod.Init(app, "app")
+	require.NoError(t, err)
+	_, err = zmod.Init(app, "app")
+	require.Error(t, err)
+	found, err := zmod.FindRoot(filepath.Join(app, "sub"))
+	require.NoError(t, err)
+	require.Equal(t, app, found)
+
+	p.Manifest.Requires = []zmod.Require{{Name: "strs", Source: "../strs"}}
+	require.NoError(t, os.WriteFile(filepath.Join(app, zmod.ManifestFile),
+		p.Manifest.Format(), 0644))
+	p, err = zmod.Load(app)
+	require.NoError(t, err)
+	require.True(t, errors.Is(p.Vendor(), zmod.ErrLockOutdated))
+
+	// the dependencies of the local dependencies are relative to them
+	require.NoError(t, p.Tidy())
+	require.Equal(t, 2, len(p.Lock.Modules))
+	strs := p.Lock.Find("strs")
+	require.Equal(t, "../strs", strs.Source)
+	require.Equal(t, "", strs.Rev)
+	text := p.Lock.Find("text")
+	require.Equal(t, "../text", text.Source)
+	require.Equal(t, "v1.0.0", text.Version)
+	require.Equal(t, textRev, text.Rev)
+	data, err := os.ReadFile(filepath.Join(app, zmod.LockFile))
+	require.NoError(t, err)
+	require.Equal(t, string(p.Lock.Format()), string(data))
+
+	require.NoError(t, p.Vendor())
+	require.NoError(t, p.Verify())
+	src, err := os.ReadFile(filepath.Join(app, "vendor", "text", "index.z"))
+	require.NoError(t, err)
+	require.Equal(t, `export "v1"`, string(src))
+
+	// scripts import the vendored modules
+	s := z.NewScript([]byte(`strs := import("strs")
+pad := import("strs/util/pad")
+out := pad(strs.upper("x"))`))
+	s.SetModuleLoader(p.Loader())
+	compiled, err := s.Run()
+	require.NoError(t, err)
+	require.Equal(t, "[v1:x]", compiled.Get("out").String())
+	_, _, err = p.Loader().Load("strs/../text/index", "")
+	require.True(t, errors.Is(err, z.ErrModuleNotFound))
+	_, _, err = p.Loader().Load("none", "")
+	require.True(t, errors.Is(err, z.ErrModuleNotFound))
+
+	// modified vendored files and modified dependencies are detected
+	writeFiles(t, app, map[string]string{"vendor/strs/index.z": `export 1`})
+	err = p.Verify()
+	require.True(t, errors.Is(err, zmod.ErrChecksum))
+	writeFiles(t, root, map[string]string{"strs/index.z": `export 2`})
+	require.True(t, errors.Is(p.Vendor(), zmod.ErrChecksum))
+
+	// tidy keeps the locked commits and updates the local directories
+	runGit(t, filepath.Join(root, "text"), "tag", "-f", "v1.0.0")
+	require.NoError(t, p.Tidy())
+	require.Equal(t, textRev, p.Lock.Find("text").Rev)
+	require.True(t, strs.Hash != p.Lock.Find("strs").Hash)
+	require.NoError(t, p.Vendor())
+	require.NoError(t, p.Verify())
+
+	// the dependencies no longer required are removed
+	branch := runGit(t, filepath.Join(root, "text"), "rev-parse",
+		"--abbrev-ref", "HEAD")
+	p.Manifest.Requires = []zmod.Require{
+		{Name: "text", Source: "../text", Version: branch},
+	}
+	require.NoError(t, p.Tidy())
+	require.Equal(t, 1, len(p.Lock.Modules))
+	require.True(t, textRev != p.Lock.Find("text").Rev)
+}
+
+func TestProjectConflicts(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a/z.mod":    "module a\n\nrequire c ../c1\n",
+		"a/index.z":  `export 1`,
+		"c1/index.z": `export 1`,
+		"c2/index.z": `export 2`,
+	})
+	p := &zmod.Project{
+		Dir: root,
+		Manifest: &zmod.Manifest{Module: "app", Requires: []zmod.Require{
+			{Name: "a", Source: "a"},
+			{Name: "c", Source: "c2"},
+		}},
+		Lock: &zmod.Lock{},
+	}
+	err := p.Tidy()
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(),
+		"c: conflicting requirements"), err.Error())
+
+	p.Manifest.Requires[1].Source = "c1"
+	require.NoError(t, p.Tidy())
+	require.Equal(t, 2, len(p.Lock.Modules))
+}
@@ -0,0 +1,184 @@
+package ztest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteText writes the failed and skipped tests, all the tests if verbose
+// is set, and a summary line.
+func WriteText(w io.Writer, results []Result, verbose bool) error {
+	var total time.Duration
+	for _, res := range results {
+		total += res.Duration
+		if res.Status == Pass && !verbose {
+			continue
+		}
+		name := res.Name
+		if name == "" {
+			name = res.File
+		} else {
+			name += " (" + res.File + ")"
+		}
+		_, err := fmt.Fprintf(w, "--- %s: %s (%s)\n",
+			strings.ToUpper(res.Status.String()), name,
+			formatSeconds(res.Duration))
+		if err != nil {
+			return err
+		}
+		msg := res.Message
+		if res.Pos != "" {
+			msg = res.Pos + ": " + msg
+		}
+		if msg != "" {
+			msg = strings.ReplaceAll(msg, "\n", "\n    ")
+			if _, err := fmt.Fprintf(w, "    %s\n", msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	passed, failed, skipped := Summary(results)
+	status := "ok"
+	if failed > 0 {
+		status = "FAIL"
+	}
+	_, err := fmt.Fprintf(w, "%s\t%d passed, %d failed, %d skipped (%s)\n",
+		status, passed, failed, skipped, formatSeconds(total))
+	return err
+}
+
+// WriteTAP writes the results in the Test Anything Protocol, version 13.
+func WriteTAP(w io.Writer, results []Result) error {
+	var b strings.Builder
+	b.WriteString("TAP version 13\n")
+	fmt.Fprintf(&b, "1..%d\n", len(results))
+	for i, res := range results {
+		desc := res.File
+		if res.Name != "" {
+			desc += ": " + res.Name
+		}
+		switch res.Status {
+		case Pass:
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, desc)
+		case Skip:
+			fmt.Fprintf(&b, "ok %d - %s # SKIP %s\n", i+1, desc,
+				strings.ReplaceAll(res.Message, "\n", " "))
+		case Fail:
+			fmt.Fprintf(&b, "not ok %d - %s\n", i+1, desc)
+			b.WriteString("  ---\n")
+			fmt.Fprintf(&b, "  message: %s\n", yamlQuote(res.Message))
+			if res.Pos != "" {
+				fmt.Fprintf(&b, "  at: %s\n", yamlQuote(res.Pos))
+			}
+			b.WriteString("  ...\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// yamlQuote quotes s as a single-quoted YAML scalar.
+func yamlQuote(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as a JUnit XML report with a test suite
+// for each file.
+func WriteJUnit(w io.Writer, results []Result) error {
+	var report junitSuites
+	var total time.Duration
+	for _, file := range resultFiles(results) {
+		suite := junitSuite{Name: file}
+		var d time.Duration
+		for _, res := range results {
+			if res.File != file {
+				continue
+			}
+			tc := junitCase{
+				Name:      res.Name,
+				ClassName: file,
+				Time:      junitSeconds(res.Duration),
+			}
+			if tc.Name == "" {
+				tc.Name = file
+			}
+			switch res.Status {
+			case Fail:
+				text := res.Message
+				if res.Pos != "" {
+					text = res.Pos + ": " + text
+				}
+				tc.Failure = &junitMessage{Message: res.Message, Text: text}
+				suite.Failures++
+			case Skip:
+				tc.Skipped = &junitMessage{Message: res.Message}
+				suite.Skipped++
+			}
+			suite.Tests++
+			suite.Cases = append(suite.Cases, tc)
+			d += res.Duration
+		}
+		suite.Time = junitSeconds(d)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, suite)
+		total += d
+	}
+	report.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,380 @@
+// Package ztest runs tests written in Z.
+//
+// Tests are the global functions whose names start with "test_" in the
+// files whose names end with "_test.z". Each test runs on a new VM with its
+// own globals: the file is run first, then the test function is called
+// without arguments. A test fails if it stops with a runtime error, usually
+// raised by the assert module:
+//
+//	assert := import("assert")
+//
+//	test_add := func() {
+//	    assert.equal(1 + 2, 3)
+//	}
+package ztest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/parser"
+	"github.com/diiyw/z/stdlib"
+)
+
+const (
+	// FileSuffix is the suffix of the names of test files.
+	FileSuffix = "_test.z"
+
+	// FuncPrefix is the prefix of the names of test functions.
+	FuncPrefix = "test_"
+)
+
+// Status is the outcome of a test.
+type Status int
+
+// Test outcomes.
+const (
+	Pass Status = iota
+	Fail
+	Skip
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "pass"
+	case Fail:
+		return "fail"
+	case Skip:
+		return "skip"
+	}
+	return "unknown"
+}
+
+// Result is the result of a test.
+type Result struct {
+	// File is the path of the test file.
+	File string
+	// Name is the name of the test function. It's empty if the file itself
+	// fails to compile or run, in which case none of its tests are run.
+	Name   string
+	Status Status
+	// Message is the error of a failed test or the reason of a skipped
+	// test.
+	Message string
+	// Pos is the source position where a failed test stopped, e.g.
+	// "tests/math_test.z:12:5". It's the innermost position of the error
+	// trace within File, if any.
+	Pos      string
+	Duration time.Duration
+}
+
+// Runner runs test files.
+type Runner struct {
+	// Modules are the modules the tests can import. The assert module of the
+	// standard library is always available.
+	Modules *z.ModuleMap
+	// Match selects the tests to run by name. Nil runs all the tests.
+	Match *regexp.Regexp
+	// ModuleLoader, if set, loads the modules not found in Modules before
+	// the files relative to the test file, e.g. the vendored dependencies
+	// of a zmod module.
+	ModuleLoader z.ModuleLoader
+	// Coverage, if set, records the instructions executed by the tests.
+	// See CoverageLines.
+	Coverage *z.Coverage
+}
+
+// FindFiles returns the test files in paths. Directories are searched
+// recursively, skipping the hidden ones. Files are returned as is, whatever
+// their name.
+func FindFiles(paths ...string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry,
+			err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if p != path && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(d.Name(), FileSuffix) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// RunFile runs the tests of a file, in their order in the file.
+func (r *Runner) RunFile(path string) []Result {
+	start := time.Now()
+	f, err := r.compile(path)
+	if err != nil {
+		return []Result{fileFailure(path, err, time.Since(start))}
+	}
+
+	var results []Result
+	for _, name := range f.tests {
+		if r.Match != nil && !r.Match.MatchString(name) {
+			continue
+		}
+		res := Result{File: path, Name: name}
+		start := time.Now()
+		initErr, err := f.run(name)
+		res.Duration = time.Since(start)
+		if initErr != nil {
+			return []Result{fileFailure(path, initErr, res.Duration)}
+		}
+		switch {
+		case errors.Is(err, stdlib.ErrSkip):
+			res.Status = Skip
+			res.Message, _ = splitTrace(err, path)
+			res.Message = strings.TrimPrefix(
+				strings.TrimPrefix(res.Message, stdlib.ErrSkip.Error()),
+				": ")
+		case err != nil:
+			res.Status = Fail
+			res.Message, res.Pos = splitTrace(err, path)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// RunFiles runs the tests of the files.
+func (r *Runner) RunFiles(paths ...string) []Result {
+	var results []Result
+	for _, path := range paths {
+		results = append(results, r.RunFile(path)...)
+	}
+	return results
+}
+
+func fileFailure(path string, err error, d time.Duration) Result {
+	msg, pos := splitTrace(err, path)
+	return Result{
+		File:     path,
+		Status:   Fail,
+		Message:  msg,
+		Pos:      pos,
+		Duration: d,
+	}
+}
+
+// splitTrace splits the error of a test into its message and the innermost
+// source position within the test file.
+func splitTrace(err error, path string) (msg, pos string) {
+	// the errors of the functions called by host functions are wrapped in
+	// the errors of their callers, their frames are the innermost
+	var frames []z.StackFrame
+	var rtErr *z.RuntimeError
+	for errors.As(err, &rtErr) {
+		frames = append(append([]z.StackFrame(nil), rtErr.Frames...),
+			frames...)
+		err = rtErr.Err
+	}
+	msg = err.Error()
+	for _, f := range frames {
+		if f.Pos.Filename == path {
+			return msg, f.Pos.String()
+		}
+	}
+	if len(frames) > 0 {
+		pos = frames[0].Pos.String()
+	}
+	return msg, pos
+}
+
+// testFile is a compiled test file.
+type testFile struct {
+	bytecode *z.Bytecode
+	indexes  map[string]int // test name to global index
+	tests    []string
+	coverage *z.Coverage
+}
+
+func (r *Runner) compile(path string) (*testFile, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(src) > 1 && string(src[:2]) == "#!" {
+		copy(src, "//")
+	}
+	importDir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	fileSet := parser.NewFileSet()
+	srcFile := fileSet.AddFile(path, -1, len(src))
+	p := parser.NewParser(srcFile, src, nil)
+	file, err := p.ParseFile()
+	if err != nil {
+		return nil, err
+	}
+
+	symbolTable := z.NewSymbolTable()
+	for idx, fn := range z.GetAllBuiltinFunctions() {
+		symbolTable.DefineBuiltin(idx, fn.Name)
+	}
+	modules := stdlib.GetModuleMap("assert")
+	if r.Modules != nil {
+		modules.AddMap(r.Modules)
+	}
+	c := z.NewCompiler(srcFile, symbolTable, nil, modules, nil)
+	c.EnableFileImport(true)
+	c.SetImportDir(importDir)
+	if r.ModuleLoader != nil {
+		c.SetModuleLoader(z.ChainLoader{r.ModuleLoader,
+			&z.FileLoader{Dir: importDir}})
+	}
+	if err := c.Compile(file); err != nil {
+		return nil, err
+	}
+
+	f := &testFile{
+		bytecode: c.Bytecode(),
+		indexes:  make(map[string]int),
+		coverage: r.Coverage,
+	}
+	if f.coverage != nil {
+		f.coverage.Add(f.bytecode)
+	}
+	for _, name := range testNames(file) {
+		symbol, _, ok := symbolTable.Resolve(name, false)
+		if ok && symbol.Scope == z.ScopeGlobal {
+			f.indexes[name] = symbol.Index
+			f.tests = append(f.tests, name)
+		}
+	}
+	return f, nil
+}
+
+// testNames returns the names of the test functions assigned at the top
+// level of file, in order.
+func testNames(file *parser.File) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, stmt := range file.Stmts {
+		assign, ok := stmt.(*parser.AssignStmt)
+		if !ok {
+			continue
+		}
+		for i, lhs := range assign.LHS {
+			ident, ok := lhs.(*parser.Ident)
+			if !ok || !strings.HasPrefix(ident.Name, FuncPrefix) ||
+				seen[ident.Name] || i >= len(assign.RHS) {
+				continue
+			}
+			if _, ok := assign.RHS[i].(*parser.FuncLit); ok {
+				seen[ident.Name] = true
+				names = append(names, ident.Name)
+			}
+		}
+	}
+	return names
+}
+
+// run runs the file on a new VM, then calls the test function name. The
+// error of running the file is returned separately from the test error.
+func (f *testFile) run(name string) (initErr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	globals := make([]z.Object, z.GlobalsSize)
+	v := z.NewVM(f.bytecode, globals, -1)
+	if f.coverage != nil {
+		v.SetCoverage(f.coverage)
+	}
+	if err := v.Run(); err != nil {
+		return err, nil
+	}
+	fn := globals[f.indexes[name]]
+	if fn == nil || !fn.CanCall() {
+		return nil, fmt.Errorf("%s is not a function", name)
+	}
+	_, err = v.Call(fn)
+	return nil, err
+}
+
+// CoverageLines returns the coverage of the lines of the source files
+// imported by the tests, leaving out the test files and the source modules
+// that aren't files. Paths within the current directory are made relative.
+func CoverageLines(c *z.Coverage) []z.CoverageLine {
+	wd, _ := os.Getwd()
+	var lines []z.CoverageLine
+	for _, l := range c.Lines() {
+		if !strings.HasSuffix(l.File, ".z") ||
+			strings.HasSuffix(l.File, FileSuffix) {
+			continue
+		}
+		if wd != "" {
+			if rel, err := filepath.Rel(wd, l.File); err == nil &&
+				!strings.HasPrefix(rel, "..") {
+				l.File = rel
+			}
+		}
+		lines = append(lines, l)
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].File < lines[j].File
+	})
+	return lines
+}
+
+// Summary counts the results by status.
+func Summary(results []Result) (passed, failed, skipped int) {
+	for _, res := range results {
+		switch res.Status {
+		case Pass:
+			passed++
+		case Fail:
+			failed++
+		case Skip:
+			skipped++
+		}
+	}
+	return
+}
+
+// resultFiles returns the files of the results in order of appearance.
+func resultFiles(results []Result) []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, res := range results {
+		if !seen[res.File] {
+			seen[res.File] = true
+			files = append(files, res.File)
+		}
+	}
+	return files
+}
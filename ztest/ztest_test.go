@@ -0,0 +1,205 @@
+package ztest_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/z"
+	"github.com/diiyw/z/require"
+	"github.com/diiyw/z/stdlib"
+	"github.com/diiyw/z/ztest"
+)
+
+const mathTest = `assert := import("assert")
+lib := import("./lib")
+counter := 0
+
+test_add := func() {
+    counter++
+    assert.equal(lib.add(1, 2), 3)
+    assert.equal(counter, 1)
+}
+
+test_fail := func() {
+    counter++
+    assert.equal(counter, 1)
+    assert.equal(lib.add(1, 1), 3, "sum")
+}
+
+test_skip := func() {
+    assert.skip("not ready")
+}
+
+check := func(v) {
+    assert.ok(v)
+}
+
+test_helper := func() {
+    check(false)
+}
+
+test_not_a_func := 1
+not_a_test := func() { assert.fail() }
+`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(src), 0644))
+	}
+	return dir
+}
+
+func TestRunner(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"math_test.z":        mathTest,
+		"lib.z":              `export {add: func(a, b) { return a + b }}`,
+		"sub/bad_test.z":     `x := 1 +`,
+		"sub/init_test.z":    "x := 1 + {}\ntest_a := func() {}",
+		".hidden/foo_test.z": `test_a := func() {}`,
+	})
+
+	files, err := ztest.FindFiles(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "math_test.z"),
+		filepath.Join(dir, "sub/bad_test.z"),
+		filepath.Join(dir, "sub/init_test.z"),
+	}, files)
+
+	r := &ztest.Runner{Modules: stdlib.GetModuleMap("text")}
+	results := r.RunFile(files[0])
+	require.Equal(t, 4, len(results))
+
+	require.Equal(t, "test_add", results[0].Name)
+	require.True(t, results[0].Status == ztest.Pass)
+
+	require.Equal(t, "test_fail", results[1].Name)
+	require.True(t, results[1].Status == ztest.Fail)
+	require.Equal(t, "assertion failed: sum: expected 3, got 2",
+		results[1].Message)
+	require.Equal(t, filepath.Join(dir, "math_test.z:14:5"), results[1].Pos)
+
+	require.True(t, results[2].Status == ztest.Skip)
+	require.Equal(t, "not ready", results[2].Message)
+
+	require.True(t, results[3].Status == ztest.Fail)
+	require.Equal(t, filepath.Join(dir, "math_test.z:22:5"), results[3].Pos)
+
+	results = r.RunFile(files[1])
+	require.Equal(t, 1, len(results))
+	require.Equal(t, "", results[0].Name)
+	require.True(t, results[0].Status == ztest.Fail)
+	require.True(t, strings.HasPrefix(results[0].Message, "Parse Error"))
+
+	results = r.RunFile(files[2])
+	require.Equal(t, 1, len(results))
+	require.Equal(t, "", results[0].Name)
+	require.Equal(t, filepath.Join(dir, "sub/init_test.z:1:6"),
+		results[0].Pos)
+
+	// files with no matching tests are not run
+	r.Match = regexp.MustCompile("add|skip")
+	results = r.RunFiles(files...)
+	require.Equal(t, 3, len(results))
+	passed, failed, skipped := ztest.Summary(results)
+	require.Equal(t, 1, passed)
+	require.Equal(t, 1, failed)
+	require.Equal(t, 1, skipped)
+}
+
+func TestRunnerModuleLoader(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a_test.z": `assert := import("assert")
+lib := import("./lib")
+dep := import("dep")
+test_a := func() { assert.equal(lib + dep, 3) }`,
+		"lib.z": `export 1`,
+	})
+	r := &ztest.Runner{ModuleLoader: z.MapLoader{"dep": []byte(`export 2`)}}
+	results := r.RunFile(filepath.Join(dir, "a_test.z"))
+	require.Equal(t, 1, len(results))
+	require.True(t, results[0].Status == ztest.Pass, results[0].Message)
+}
+
+func TestRunnerCoverage(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"math_test.z": mathTest,
+		"lib.z": `export {
+	add: func(a, b) {
+		return a + b
+	},
+	sub: func(a, b) {
+		return a - b
+	}
+}`,
+	})
+
+	r := &ztest.Runner{Coverage: z.NewCoverage()}
+	r.RunFile(filepath.Join(dir, "math_test.z"))
+	lines := ztest.CoverageLines(r.Coverage)
+	counts := make(map[int]int)
+	for _, l := range lines {
+		require.Equal(t, filepath.Join(dir, "lib.z"), l.File)
+		counts[l.Line] = l.Count
+	}
+	require.Equal(t, 2, counts[3]) // add, called by test_add and test_fail
+	require.Equal(t, 0, counts[6]) // sub
+	require.Equal(t, float64(80), z.CoveragePercent(lines))
+}
+
+func TestReports(t *testing.T) {
+	results := []ztest.Result{
+		{File: "a_test.z", Name: "test_a", Status: ztest.Pass},
+		{File: "a_test.z", Name: "test_b", Status: ztest.Fail,
+			Message: "it's wrong", Pos: "a_test.z:3:5"},
+		{File: "b_test.z", Name: "test_c", Status: ztest.Skip,
+			Message: "later"},
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, ztest.WriteText(&b, results, false))
+	require.Equal(t, `--- FAIL: test_b (a_test.z) (0.000s)
+    a_test.z:3:5: it's wrong
+--- SKIP: test_c (b_test.z) (0.000s)
+    later
+FAIL	1 passed, 1 failed, 1 skipped (0.000s)
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, ztest.WriteTAP(&b, results))
+	require.Equal(t, `TAP version 13
+1..3
+ok 1 - a_test.z: test_a
+not ok 2 - a_test.z: test_b
+  ---
+  message: 'it''s wrong'
+  at: 'a_test.z:3:5'
+  ...
+ok 3 - b_test.z: test_c # SKIP later
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, ztest.WriteJUnit(&b, results))
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" skipped="1" time="0.000">
+  <testsuite name="a_test.z" tests="2" failures="1" skipped="0" time="0.000">
+    <testcase name="test_a" classname="a_test.z" time="0.000"></testcase>
+    <testcase name="test_b" classname="a_test.z" time="0.000">
+      <failure message="it&#39;s wrong">a_test.z:3:5: it&#39;s wrong</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="b_test.z" tests="1" failures="0" skipped="1" time="0.000">
+    <testcase name="test_c" classname="b_test.z" time="0.000">
+      <skipped message="later"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, b.String())
+}